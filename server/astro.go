@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/astro"
+)
+
+// AstroResponse is returned by GET /api/astro
+type AstroResponse struct {
+	Date      string     `json:"date"`     // YYYY-MM-DD
+	Timezone  string     `json:"timezone"` // the dashboard timezone, which times are in
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	Sun       astro.Sun  `json:"sun"`
+	Moon      astro.Moon `json:"moon"`
+}
+
+// getAstro handles GET /api/astro
+func getAstro(w http.ResponseWriter, r *http.Request) {
+	lat, lon, ok := shared.GetCoordinates()
+	if !ok {
+		shared.WriteError(w, http.StatusServiceUnavailable, "Location not configured")
+		return
+	}
+
+	loc := shared.GetLocation()
+	now := time.Now().In(loc)
+	date := now
+	if value := r.URL.Query().Get("date"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		// Other days get the moon's phase at noon
+		if day.Format("2006-01-02") != now.Format("2006-01-02") {
+			date = day.Add(12 * time.Hour)
+		}
+	}
+
+	shared.WriteJSON(w, http.StatusOK, AstroResponse{
+		Date:      date.Format("2006-01-02"),
+		Timezone:  loc.String(),
+		Latitude:  lat,
+		Longitude: lon,
+		Sun:       astro.SunTimes(date, lat, lon),
+		Moon:      astro.MoonTimes(date, lat, lon),
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// command is a subcommand of the server binary
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{"serve", "Run the dashboard HTTP server (default)", func(args []string) int { serve(); return 0 }},
+	{"validate", "Check config.json against the widget schemas", runValidate},
+	{"doctor", "Check environment, config files and Redis and print a readiness report", runDoctor},
+	{"migrate", "Upgrade an older config.json to the current shape", runMigrate},
+}
+
+// runCommand dispatches a subcommand and returns the process exit code
+func runCommand(name string, args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args)
+		}
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	printUsage()
+	if name == "help" || name == "-h" || name == "--help" {
+		return 0
+	}
+	return 2
+}
+
+func printUsage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", name)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for command flags.\n", name)
+}
+
+// newFlagSet creates a flag set with the shared -config flag
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", externalConfigFile, "path to config.json")
+	return fs, configPath
+}
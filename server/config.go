@@ -16,6 +16,12 @@ type GlobalConfig struct {
 	RefreshIntervalMinutes int    `json:"refresh_interval_minutes"`
 	GridColumns            int    `json:"grid_columns"`
 	GridRows               int    `json:"grid_rows"`
+	TemperatureUnit        string `json:"temperature_unit"` // F or C
+	DistanceUnit           string `json:"distance_unit"`    // mi or km
+	SpeedUnit              string `json:"speed_unit"`       // mph, km/h, m/s or kn
+	PressureUnit           string `json:"pressure_unit"`    // inHg, hPa or mmHg
+	RainfallUnit           string `json:"rainfall_unit"`    // in or mm
+	Locale                 string `json:"locale"`           // e.g. en-US
 }
 
 // WidgetLocation stores widget position and size on the grid
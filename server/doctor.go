@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"themancavedashboard/widgets"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// doctorStatus is the outcome of a single readiness check
+type doctorStatus int
+
+const (
+	statusOK doctorStatus = iota
+	statusWarn
+	statusFail
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case statusOK:
+		return "  OK"
+	case statusWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// doctorCheck is one line of the readiness report
+type doctorCheck struct {
+	Status doctorStatus
+	Name   string
+	Detail string
+}
+
+// runDoctor handles `doctor [-config path]`
+func runDoctor(args []string) int {
+	fs, configPath := newFlagSet("doctor")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	sections := []struct {
+		title  string
+		checks []doctorCheck
+	}{
+		{"Config", checkConfigFile(*configPath)},
+		{"Environment", checkEnvironment(*configPath)},
+		{"Files", checkFiles(*configPath)},
+		{"Redis", checkRedis()},
+	}
+
+	failures, warnings := 0, 0
+	for _, section := range sections {
+		fmt.Printf("%s\n", section.title)
+		for _, check := range section.checks {
+			fmt.Printf("  [%s] %s", check.Status, check.Name)
+			if check.Detail != "" {
+				fmt.Printf(" - %s", check.Detail)
+			}
+			fmt.Println()
+			switch check.Status {
+			case statusFail:
+				failures++
+			case statusWarn:
+				warnings++
+			}
+		}
+		fmt.Println()
+	}
+
+	if failures > 0 {
+		fmt.Printf("Not ready: %d problem(s), %d warning(s)\n", failures, warnings)
+		return 1
+	}
+	fmt.Printf("Ready (%d warning(s))\n", warnings)
+	return 0
+}
+
+// readWidgetSections returns the config of every widget on the dashboard, keyed by config section id
+func readWidgetSections(configPath string) map[string]map[string]interface{} {
+	sections := make(map[string]map[string]interface{})
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return sections
+	}
+	var config DashboardConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return sections
+	}
+	for _, widget := range config.Widgets {
+		if _, exists := sections[widget.ID]; !exists {
+			if widget.Config == nil {
+				widget.Config = map[string]interface{}{}
+			}
+			sections[widget.ID] = widget.Config
+		}
+	}
+	return sections
+}
+
+func checkConfigFile(configPath string) []doctorCheck {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return []doctorCheck{{statusFail, configPath, err.Error()}}
+	}
+
+	errorCount, warningCount := 0, 0
+	for _, issue := range validateConfig(data, widgets.GetSchemas()) {
+		if issue.Warning {
+			warningCount++
+		} else {
+			errorCount++
+		}
+	}
+
+	switch {
+	case errorCount > 0:
+		return []doctorCheck{{statusFail, configPath, fmt.Sprintf("%d error(s), run `validate` for details", errorCount)}}
+	case warningCount > 0:
+		return []doctorCheck{{statusWarn, configPath, fmt.Sprintf("%d warning(s), run `validate` for details", warningCount)}}
+	}
+	return []doctorCheck{{statusOK, configPath, "valid"}}
+}
+
+// checkEnvironment reports each widget's GetRequiredEnvVars. Missing variables are only
+// a failure for widgets that are actually placed on the dashboard.
+func checkEnvironment(configPath string) []doctorCheck {
+	placed := readWidgetSections(configPath)
+
+	var ids []string
+	for id := range widgets.GetAll() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var checks []doctorCheck
+	for _, id := range ids {
+		widget, _ := widgets.Get(id)
+		section := id
+		if provider, ok := widget.(widgets.SchemaProvider); ok && provider.ConfigSchema().Section != "" {
+			section = provider.ConfigSchema().Section
+		}
+		_, onDashboard := placed[section]
+
+		var missing []string
+		envVars := widget.GetRequiredEnvVars()
+		for _, key := range envVars {
+			if os.Getenv(key) == "" {
+				missing = append(missing, key)
+			}
+		}
+
+		name := id
+		if section != id {
+			name = fmt.Sprintf("%s (%s)", id, section)
+		}
+		switch {
+		case len(envVars) == 0:
+			checks = append(checks, doctorCheck{statusOK, name, "no environment variables required"})
+		case len(missing) == 0:
+			checks = append(checks, doctorCheck{statusOK, name, strings.Join(envVars, ", ")})
+		case onDashboard:
+			checks = append(checks, doctorCheck{statusFail, name, "missing " + strings.Join(missing, ", ")})
+		default:
+			checks = append(checks, doctorCheck{statusWarn, name, "missing " + strings.Join(missing, ", ") + " (not on dashboard)"})
+		}
+	}
+	return checks
+}
+
+// checkFiles verifies the files that widgets read from the config directory
+func checkFiles(configPath string) []doctorCheck {
+	configDir := filepath.Dir(configPath)
+	placed := readWidgetSections(configPath)
+
+	stringValue := func(section, key, fallback string) string {
+		if value, ok := placed[section][key].(string); ok && value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	var checks []doctorCheck
+
+	if _, ok := placed["calendar"]; ok && usesGoogleCalendar(placed["calendar"]) {
+		for _, file := range []struct{ key, fallback, purpose string }{
+			{"google_credentials_filename", "credentials.json", "Google OAuth client"},
+			{"google_token_filename", "token.json", "Google Calendar token, created by /api/google/auth/start"},
+		} {
+			path := filepath.Join(configDir, stringValue("calendar", file.key, file.fallback))
+			checks = append(checks, checkJSONFile(path, file.purpose))
+		}
+	}
+
+	if _, ok := placed["photos"]; ok {
+		photosDir := filepath.Join(configDir, stringValue("photos", "photos_folder", "photos"))
+		// Remote sources can only be reached by the running server; a folder source is checked here
+		source, _ := placed["photos"]["source"].(map[string]interface{})
+		sourceType, _ := source["type"].(string)
+		if path, _ := source["path"].(string); sourceType == "folder" {
+			photosDir = path
+		}
+		entries, err := os.ReadDir(photosDir)
+		switch {
+		case sourceType == "webdav" || sourceType == "immich":
+			checks = append(checks, doctorCheck{statusOK, "photos source", sourceType + ", synced by the server (see the [Photos] log)"})
+		case err != nil:
+			checks = append(checks, doctorCheck{statusFail, photosDir, err.Error()})
+		case len(entries) == 0:
+			checks = append(checks, doctorCheck{statusWarn, photosDir, "folder is empty"})
+		default:
+			checks = append(checks, doctorCheck{statusOK, photosDir, fmt.Sprintf("%d entries", len(entries))})
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, doctorCheck{statusOK, configDir, "no widget files to check"})
+	}
+	return checks
+}
+
+// usesGoogleCalendar reports whether the calendar widget reads any Google
+// calendar; CalDAV and ICS calendars need no files
+func usesGoogleCalendar(config map[string]interface{}) bool {
+	calendars, _ := config["calendars"].([]interface{})
+	if len(calendars) == 0 {
+		return true
+	}
+	for _, entry := range calendars {
+		calendar, _ := entry.(map[string]interface{})
+		if kind, _ := calendar["type"].(string); kind == "" || kind == "google" {
+			return true
+		}
+	}
+	return false
+}
+
+func checkJSONFile(path, purpose string) doctorCheck {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doctorCheck{statusFail, path, fmt.Sprintf("%s: %v", purpose, err)}
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return doctorCheck{statusFail, path, fmt.Sprintf("%s: invalid JSON: %v", purpose, err)}
+	}
+	return doctorCheck{statusOK, path, purpose}
+}
+
+func checkRedis() []doctorCheck {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return []doctorCheck{{statusWarn, "REDIS_URL", "not set, Traeger history is disabled"}}
+	}
+
+	// The URL may hold a password, which mustn't end up in the report
+	label := "REDIS_URL"
+	if u, err := url.Parse(redisURL); err == nil && u.Host != "" {
+		label = u.Redacted()
+	}
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		// url.Parse errors quote the whole URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return []doctorCheck{{statusFail, label, fmt.Sprintf("invalid URL: %v", err)}}
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return []doctorCheck{{statusFail, label, fmt.Sprintf("unreachable: %v", err)}}
+	}
+	return []doctorCheck{{statusOK, label, "reachable"}}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// compressibleTypes are gzipped on the fly when no precompressed copy exists
+var compressibleTypes = []string{
+	"text/", "application/javascript", "application/json", "application/xml", "image/svg+xml", "application/manifest+json",
+}
+
+// mountFrontend serves the built Vite app when it is available, either embedded in
+// the binary (built with -tags embedfrontend) or from FRONTEND_DIR. Photos are
+// served by the photos widget at /api/photos/{name}, which keeps the metadata,
+// trash and mirror files in the photos folder private.
+func mountFrontend(r chi.Router) {
+	fsys, source := frontendFS()
+	if fsys == nil {
+		return
+	}
+	if _, err := fs.Stat(fsys, "index.html"); err != nil {
+		log.Printf("[Frontend] %s has no index.html, not serving the frontend", source)
+		return
+	}
+	log.Printf("[Frontend] Serving frontend from %s", source)
+
+	r.Handle("/*", newFrontendHandler(fsys))
+}
+
+// frontendFS picks the frontend source: FRONTEND_DIR overrides the embedded copy
+func frontendFS() (fs.FS, string) {
+	if dir := os.Getenv("FRONTEND_DIR"); dir != "" {
+		return os.DirFS(dir), dir
+	}
+	if embedded, ok := embeddedFrontend(); ok {
+		return embedded, "embedded dist/"
+	}
+	return nil, ""
+}
+
+// frontendHandler serves static files with SPA fallback to index.html
+type frontendHandler struct {
+	fsys fs.FS
+
+	mu    sync.Mutex
+	etags map[string]string // keyed by path, size and modification time
+}
+
+func newFrontendHandler(fsys fs.FS) *frontendHandler {
+	return &frontendHandler{fsys: fsys, etags: make(map[string]string)}
+}
+
+func (h *frontendHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		shared.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		// Client-side routes fall back to index.html; missing assets are a real 404
+		if path.Ext(name) != "" && !strings.HasSuffix(name, ".html") {
+			http.NotFound(w, r)
+			return
+		}
+		name = "index.html"
+		if info, err = fs.Stat(h.fsys, name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", cacheControlFor(name))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	// Prefer precompressed siblings (app.js.br, app.js.gz) produced at build time
+	accept := r.Header.Get("Accept-Encoding")
+	for _, enc := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		if !acceptsEncoding(accept, enc.name) {
+			continue
+		}
+		if compressed, err := fs.Stat(h.fsys, name+enc.ext); err == nil && !compressed.IsDir() {
+			h.serveFile(w, r, name+enc.ext, compressed, enc.name, false)
+			return
+		}
+	}
+
+	gzipOnTheFly := acceptsEncoding(accept, "gzip") && isCompressible(contentType) && info.Size() > 1024
+	h.serveFile(w, r, name, info, "", gzipOnTheFly)
+}
+
+// serveFile writes a file with an ETag, honoring If-None-Match
+func (h *frontendHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo, encoding string, gzipBody bool) {
+	etag, err := h.etag(name, info)
+	if err != nil {
+		shared.WriteError(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	if gzipBody {
+		encoding = "gzip"
+	}
+	if encoding != "" {
+		// Each encoding is a different representation and needs its own validator
+		etag = strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	file, err := h.fsys.Open(name)
+	if err != nil {
+		shared.WriteError(w, http.StatusInternalServerError, "Failed to open file")
+		return
+	}
+	defer file.Close()
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if gzipBody {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		io.Copy(gz, file)
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	io.Copy(w, file)
+}
+
+// etag returns a content hash for the file, cached by size and modification time
+func (h *frontendHandler) etag(name string, info fs.FileInfo) (string, error) {
+	key := fmt.Sprintf("%s|%d|%d", name, info.Size(), info.ModTime().UnixNano())
+
+	h.mu.Lock()
+	etag, ok := h.etags[key]
+	h.mu.Unlock()
+	if ok {
+		return etag, nil
+	}
+
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	h.mu.Lock()
+	h.etags[key] = etag
+	h.mu.Unlock()
+	return etag, nil
+}
+
+// cacheControlFor returns the caching policy: Vite's hashed assets never change,
+// index.html must always be revalidated so new builds are picked up
+func cacheControlFor(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".html"):
+		return "no-cache"
+	case strings.HasPrefix(name, "assets/"):
+		return fmt.Sprintf("public, max-age=%d, immutable", int((365 * 24 * time.Hour).Seconds()))
+	default:
+		return "public, max-age=3600"
+	}
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(fields[0], encoding) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q := strings.ReplaceAll(param, " ", ""); q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
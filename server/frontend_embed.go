@@ -0,0 +1,23 @@
+//go:build embedfrontend
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// The Vite build output is copied to server/dist before building with
+// -tags embedfrontend (see the root Dockerfile)
+//
+//go:embed all:dist
+var embeddedDist embed.FS
+
+// embeddedFrontend returns the frontend bundled into the binary
+func embeddedFrontend() (fs.FS, bool) {
+	dist, err := fs.Sub(embeddedDist, "dist")
+	if err != nil {
+		return nil, false
+	}
+	return dist, true
+}
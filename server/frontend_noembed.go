@@ -0,0 +1,11 @@
+//go:build !embedfrontend
+
+package main
+
+import "io/fs"
+
+// embeddedFrontend reports that this binary was built without the frontend.
+// Build with -tags embedfrontend to include it.
+func embeddedFrontend() (fs.FS, bool) {
+	return nil, false
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"themancavedashboard/shared"
 )
 
 // DashboardLayout is the structure expected by the frontend
@@ -33,6 +35,12 @@ type GridPosition struct {
 	Height int `json:"height"`
 }
 
+// LayoutSaveResponse is returned after the layout is saved
+type LayoutSaveResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
 func getDashboardLayout(w http.ResponseWriter, r *http.Request) {
 	config := getDashboardConfig()
 
@@ -85,19 +93,19 @@ func getDashboardLayout(w http.ResponseWriter, r *http.Request) {
 
 func saveDashboardLayout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		shared.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, `{"error":"Failed to read request body"}`, http.StatusBadRequest)
+		shared.WriteError(w, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
 
 	var layout DashboardLayout
 	if err := json.Unmarshal(body, &layout); err != nil {
-		http.Error(w, `{"error":"Invalid JSON"}`, http.StatusBadRequest)
+		shared.WriteError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
@@ -144,15 +152,15 @@ func saveDashboardLayout(w http.ResponseWriter, r *http.Request) {
 
 	// Save to file
 	if err := saveConfig(); err != nil {
-		http.Error(w, `{"error":"Failed to save config"}`, http.StatusInternalServerError)
+		shared.WriteError(w, http.StatusInternalServerError, "Failed to save config")
 		return
 	}
 
 	fmt.Printf("[Layout] Dashboard layout saved\n")
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Layout saved successfully",
+	json.NewEncoder(w).Encode(LayoutSaveResponse{
+		Success: true,
+		Message: "Layout saved successfully",
 	})
 }
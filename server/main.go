@@ -6,6 +6,8 @@ import (
 	"os"
 	"time"
 
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
 	"themancavedashboard/widgets"
 
 	"github.com/go-chi/chi/v5"
@@ -14,6 +16,16 @@ import (
 )
 
 func main() {
+	// Subcommands (validate, doctor, migrate) run and exit without starting the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	serve()
+}
+
+// serve starts the HTTP server
+func serve() {
 	// Load configuration on startup
 	loadConfig()
 
@@ -33,7 +45,7 @@ func main() {
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:*", "http://192.168.*", "http://127.0.0.1:*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Content-Type"},
 		AllowCredentials: false,
 		MaxAge:           300,
@@ -41,11 +53,12 @@ func main() {
 
 	// Health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+		shared.WriteJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
 	})
 
+	// The API description is built once every route is registered
+	var apiDocument *openapi.Document
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		// Register all widget routes
@@ -54,8 +67,15 @@ func main() {
 		// Shared infrastructure endpoints
 		r.Get("/layout", getDashboardLayout)
 		r.Post("/layout", saveDashboardLayout)
+		r.Get("/astro", getAstro)
+		r.Get("/openapi.json", serveOpenAPIDocument(&apiDocument))
 	})
 
+	// Single-binary installs serve the frontend too (no-op unless it is available)
+	mountFrontend(r)
+
+	apiDocument = buildOpenAPIDocument(r)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
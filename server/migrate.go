@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"themancavedashboard/widgets"
+)
+
+// configMigration upgrades one older config.json shape in place and
+// returns a description of each change it made
+type configMigration struct {
+	name string
+	run  func(root map[string]interface{}) []string
+}
+
+// Migrations run in order; each one must be a no-op on an already current config
+var configMigrations = []configMigration{
+	{"root-level global settings", migrateRootGlobals},
+	{"per-type widget sections", migrateWidgetSections},
+	{"ecowitt sensor section", migrateEcowittSection},
+	{"photo rotation setting", migratePhotoRotation},
+	{"setting types", migrateSettingTypes},
+}
+
+// runMigrate handles `migrate [-config path] [-dry-run]`
+func runMigrate(args []string) int {
+	fs, configPath := newFlagSet("migrate")
+	dryRun := fs.Bool("dry-run", false, "print the migrated config instead of writing it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+
+	migrated, changes, err := migrateConfig(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("%s is already up to date\n", *configPath)
+		return 0
+	}
+	for _, change := range changes {
+		fmt.Printf("  - %s\n", change)
+	}
+
+	if *dryRun {
+		fmt.Printf("\n%s\n", migrated)
+		return 0
+	}
+
+	backupPath := *configPath + ".bak"
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write backup %s: %v\n", backupPath, err)
+		return 1
+	}
+
+	// Truncate and write directly, like saveConfig, so bind-mounted files keep working
+	file, err := os.OpenFile(*configPath, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open config file: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+	if _, err := file.Write(migrated); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config file: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Migrated %s (backup saved to %s)\n", *configPath, backupPath)
+	return 0
+}
+
+// migrateConfig applies every migration and re-encodes the result in the
+// current DashboardConfig shape
+func migrateConfig(data []byte) ([]byte, []string, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	var changes []string
+	for _, migration := range configMigrations {
+		for _, change := range migration.run(root) {
+			changes = append(changes, fmt.Sprintf("%s: %s", migration.name, change))
+		}
+	}
+
+	for key := range root {
+		if key != "global" && key != "widgets" {
+			changes = append(changes, fmt.Sprintf("dropped unknown top-level key %q", key))
+			delete(root, key)
+		}
+	}
+
+	if len(changes) == 0 {
+		return data, nil, nil
+	}
+
+	// Round-trip through DashboardConfig so the output matches what saveConfig writes
+	intermediate, err := json.Marshal(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	var config DashboardConfig
+	dec := json.NewDecoder(bytes.NewReader(intermediate))
+	if err := dec.Decode(&config); err != nil {
+		return nil, nil, fmt.Errorf("migrated config does not match the current shape: %w", err)
+	}
+	if config.Widgets == nil {
+		config.Widgets = []WidgetConfig{}
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, changes, nil
+}
+
+// migrateRootGlobals moves settings like "timezone" from the top level into "global"
+func migrateRootGlobals(root map[string]interface{}) []string {
+	global, ok := root["global"].(map[string]interface{})
+	if !ok {
+		global = map[string]interface{}{}
+	}
+
+	var changes []string
+	t := reflect.TypeOf(GlobalConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		value, present := root[key]
+		if !present {
+			continue
+		}
+		if _, exists := global[key]; !exists {
+			global[key] = value
+			changes = append(changes, fmt.Sprintf("moved %q into global", key))
+		} else {
+			changes = append(changes, fmt.Sprintf("dropped root %q (global already sets it)", key))
+		}
+		delete(root, key)
+	}
+
+	if len(changes) > 0 || root["global"] != nil {
+		root["global"] = global
+	}
+	return changes
+}
+
+// migrateWidgetSections converts per-type widget config into widget entries. It handles
+// both a "widgets" object keyed by widget id and widget sections at the top level.
+func migrateWidgetSections(root map[string]interface{}) []string {
+	var changes []string
+	var list []interface{}
+
+	switch existing := root["widgets"].(type) {
+	case []interface{}:
+		list = existing
+	case map[string]interface{}:
+		for _, id := range sortedKeys(existing) {
+			list = append(list, legacyWidgetEntry(id, existing[id]))
+			changes = append(changes, fmt.Sprintf("converted widgets.%s into a widget entry", id))
+		}
+	}
+
+	known := make(map[string]bool)
+	for section := range widgets.GetSchemas() {
+		known[section] = true
+	}
+	for id := range widgets.GetAll() {
+		known[id] = true
+	}
+
+	for _, key := range sortedKeys(root) {
+		section, isObject := root[key].(map[string]interface{})
+		if key == "global" || key == "widgets" || !isObject || !known[key] {
+			continue
+		}
+		list = append(list, legacyWidgetEntry(key, section))
+		delete(root, key)
+		changes = append(changes, fmt.Sprintf("converted top-level %q into a widget entry", key))
+	}
+
+	if len(changes) > 0 {
+		placeUnpositioned(root, list)
+	}
+	if list != nil || root["widgets"] != nil {
+		root["widgets"] = list
+	}
+	return changes
+}
+
+// legacyWidgetEntry builds a widget entry from an old per-type section. Position
+// keys (location, or x/y/width/height) are lifted out of the section if present.
+func legacyWidgetEntry(id string, value interface{}) map[string]interface{} {
+	config, _ := value.(map[string]interface{})
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	entry := map[string]interface{}{"id": id}
+	if location, ok := config["location"].(map[string]interface{}); ok {
+		entry["location"] = location
+		delete(config, "location")
+	} else {
+		location := map[string]interface{}{}
+		for _, key := range []string{"x", "y", "width", "height"} {
+			if v, ok := config[key]; ok {
+				location[key] = v
+				delete(config, key)
+			}
+		}
+		if len(location) > 0 {
+			entry["location"] = location
+		}
+	}
+
+	if nested, ok := config["config"].(map[string]interface{}); ok && len(config) == 1 {
+		config = nested
+	}
+	entry["config"] = config
+	return entry
+}
+
+// placeUnpositioned gives widgets without a location the first free grid cell
+func placeUnpositioned(root map[string]interface{}, list []interface{}) {
+	columns, rows := 6, 4
+	if global, ok := root["global"].(map[string]interface{}); ok {
+		if n, ok := global["grid_columns"].(float64); ok && n > 0 {
+			columns = int(n)
+		}
+		if n, ok := global["grid_rows"].(float64); ok && n > 0 {
+			rows = int(n)
+		}
+	}
+
+	occupied := make(map[[2]int]bool)
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		location, ok := entry["location"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		x, _ := location["x"].(float64)
+		y, _ := location["y"].(float64)
+		width, _ := location["width"].(float64)
+		height, _ := location["height"].(float64)
+		for dx := 0; dx < max(int(width), 1); dx++ {
+			for dy := 0; dy < max(int(height), 1); dy++ {
+				occupied[[2]int{int(x) + dx, int(y) + dy}] = true
+			}
+		}
+	}
+
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if entry == nil || entry["location"] != nil {
+			continue
+		}
+		cell := [2]int{0, 0}
+	search:
+		for y := 0; y < rows; y++ {
+			for x := 0; x < columns; x++ {
+				if !occupied[[2]int{x, y}] {
+					cell = [2]int{x, y}
+					break search
+				}
+			}
+		}
+		occupied[cell] = true
+		entry["location"] = map[string]interface{}{"x": cell[0], "y": cell[1]}
+	}
+}
+
+// migrateEcowittSection renames "ecowitt" widget entries to the "plants" section the
+// plant sensor widget reads, and upgrades old sensor keys
+func migrateEcowittSection(root map[string]interface{}) []string {
+	list, ok := root["widgets"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var changes []string
+	var plants map[string]interface{}
+	kept := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if entry != nil && entry["id"] == "plants" && plants == nil {
+			plants = entry
+		}
+	}
+
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if entry == nil || entry["id"] != "ecowitt" {
+			kept = append(kept, item)
+			continue
+		}
+		if plants == nil {
+			entry["id"] = "plants"
+			plants = entry
+			kept = append(kept, entry)
+			changes = append(changes, `renamed widget "ecowitt" to "plants"`)
+			continue
+		}
+		// Merge into the existing plants entry, keeping its position
+		plantsConfig, _ := plants["config"].(map[string]interface{})
+		if plantsConfig == nil {
+			plantsConfig = map[string]interface{}{}
+			plants["config"] = plantsConfig
+		}
+		if ecowittConfig, ok := entry["config"].(map[string]interface{}); ok {
+			for key, value := range ecowittConfig {
+				if _, exists := plantsConfig[key]; !exists {
+					plantsConfig[key] = value
+				}
+			}
+		}
+		changes = append(changes, `merged widget "ecowitt" into "plants"`)
+	}
+	root["widgets"] = kept
+
+	if plants == nil {
+		return changes
+	}
+	config, _ := plants["config"].(map[string]interface{})
+	sensors, _ := config["sensors"].([]interface{})
+	renames := map[string]string{"min_moisture": "ideal_min", "max_moisture": "ideal_max"}
+	for i, item := range sensors {
+		sensor, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for oldKey, newKey := range renames {
+			if value, ok := sensor[oldKey]; ok {
+				if _, exists := sensor[newKey]; !exists {
+					sensor[newKey] = value
+				}
+				delete(sensor, oldKey)
+				changes = append(changes, fmt.Sprintf("sensors[%d]: renamed %q to %q", i, oldKey, newKey))
+			}
+		}
+		switch channel := sensor["channel"].(type) {
+		case float64:
+			sensor["channel"] = fmt.Sprintf("soil_ch%d", int(channel))
+			changes = append(changes, fmt.Sprintf("sensors[%d]: channel %v is now %q", i, channel, sensor["channel"]))
+		case string:
+			if !strings.HasPrefix(channel, "soil_ch") && channel != "" {
+				sensor["channel"] = "soil_ch" + strings.TrimPrefix(strings.ToLower(channel), "ch")
+				changes = append(changes, fmt.Sprintf("sensors[%d]: channel %q is now %q", i, channel, sensor["channel"]))
+			}
+		}
+	}
+	return changes
+}
+
+// migratePhotoRotation copies global "photo_rotation_seconds" into the photos widget,
+// which is where the carousel reads it from
+func migratePhotoRotation(root map[string]interface{}) []string {
+	global, _ := root["global"].(map[string]interface{})
+	seconds, ok := global["photo_rotation_seconds"]
+	if !ok {
+		return nil
+	}
+	list, _ := root["widgets"].([]interface{})
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if entry == nil || entry["id"] != "photos" {
+			continue
+		}
+		config, _ := entry["config"].(map[string]interface{})
+		if config == nil {
+			config = map[string]interface{}{}
+			entry["config"] = config
+		}
+		if _, exists := config["photo_rotation_seconds"]; exists {
+			return nil
+		}
+		config["photo_rotation_seconds"] = seconds
+		return []string{"copied global photo_rotation_seconds into the photos widget"}
+	}
+	return nil
+}
+
+// migrateSettingTypes converts numbers and booleans to strings for settings the
+// widgets read as strings (e.g. weather latitude/longitude)
+func migrateSettingTypes(root map[string]interface{}) []string {
+	schemas := widgets.GetSchemas()
+	list, _ := root["widgets"].([]interface{})
+
+	var changes []string
+	for i, item := range list {
+		entry, _ := item.(map[string]interface{})
+		id, _ := entry["id"].(string)
+		config, _ := entry["config"].(map[string]interface{})
+		if config == nil {
+			continue
+		}
+		for _, field := range schemas[id].Fields {
+			if field.Type != "string" {
+				continue
+			}
+			switch value := config[field.Key].(type) {
+			case float64:
+				config[field.Key] = strconv.FormatFloat(value, 'f', -1, 64)
+			case bool:
+				config[field.Key] = strconv.FormatBool(value)
+			default:
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("widgets[%d].config.%s is now the string %q", i, field.Key, config[field.Key]))
+		}
+	}
+	return changes
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+	"themancavedashboard/widgets"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HealthResponse is returned by GET /health
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// coreRoutes documents the endpoints registered in main rather than by a widget
+var coreRoutes = []openapi.Route{
+	{
+		Method:   http.MethodGet,
+		Path:     "/health",
+		Summary:  "Liveness check",
+		Tags:     []string{"core"},
+		Response: HealthResponse{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/api/layout",
+		Summary:  "Dashboard layout and global settings",
+		Tags:     []string{"core"},
+		Response: DashboardLayout{},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/api/layout",
+		Summary:  "Save widget positions and grid size",
+		Tags:     []string{"core"},
+		Request:  DashboardLayout{},
+		Response: LayoutSaveResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/api/astro",
+		Summary: "Sunrise, sunset, twilight and the moon",
+		Description: "Computed offline for the dashboard location (the weather widget's latitude and longitude) in the dashboard timezone. " +
+			"Sun times are nil when the sun doesn't reach that altitude that day, and polar says whether it stays up or down. " +
+			"The moon's phase is that of now, or of noon on another date.",
+		Tags: []string{"core"},
+		Query: []openapi.Param{
+			{Name: "date", Type: "string", Description: "Day to compute, YYYY-MM-DD (default today)"},
+		},
+		Response: AstroResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/api/openapi.json",
+		Summary:     "This OpenAPI document",
+		Tags:        []string{"core"},
+		ContentType: "application/json",
+	},
+}
+
+// buildOpenAPIDocument documents every route on the router. Widget routes come from
+// APIRoutes(); anything registered but not described is still listed so the
+// document never silently misses an endpoint.
+func buildOpenAPIDocument(router chi.Routes) *openapi.Document {
+	routes := append([]openapi.Route{}, coreRoutes...)
+	for _, route := range widgets.GetAPIRoutes() {
+		route.Path = "/api" + route.Path
+		routes = append(routes, route)
+	}
+
+	documented := make(map[string]bool)
+	for _, route := range routes {
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		// Frontend and photo file routes are not part of the API
+		if !strings.HasPrefix(route, "/api/") || method == http.MethodOptions || method == http.MethodHead || documented[method+" "+route] {
+			return nil
+		}
+		log.Printf("[OpenAPI] %s %s is not documented", method, route)
+		routes = append(routes, openapi.Route{
+			Method:  method,
+			Path:    route,
+			Summary: "Undocumented endpoint",
+			Tags:    []string{"undocumented"},
+		})
+		documented[method+" "+route] = true
+		return nil
+	})
+
+	return openapi.Generate("The Man Cave Dashboard API", "2.0", routes)
+}
+
+// serveOpenAPIDocument handles GET /api/openapi.json
+func serveOpenAPIDocument(doc **openapi.Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *doc == nil {
+			shared.WriteError(w, http.StatusServiceUnavailable, "API document not ready")
+			return
+		}
+		shared.WriteJSON(w, http.StatusOK, *doc)
+	}
+}
@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorResponse is the body of every API error
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// WriteJSON encodes data as the JSON response body with the given status
+func WriteJSON(rw http.ResponseWriter, status int, data interface{}) error {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	return json.NewEncoder(rw).Encode(data)
+}
+
+// WriteError writes an ErrorResponse with the given status
+func WriteError(rw http.ResponseWriter, status int, message string) {
+	WriteJSON(rw, status, ErrorResponse{Error: message})
+}
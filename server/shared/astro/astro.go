@@ -0,0 +1,165 @@
+// Package astro computes the times of the sun and moon for a place, offline. It
+// uses the low-precision formulas of the Astronomical Almanac and Meeus'
+// Astronomical Algorithms, good to about a minute for the sun and a few
+// minutes for the moon, which is plenty for a dashboard.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// Altitudes of the sun's centre at its events, in degrees. Sunrise and sunset
+// allow for refraction and the sun's radius.
+const (
+	sunriseAltitude  = -0.833
+	civilAltitude    = -6
+	nauticalAltitude = -12
+)
+
+// scanStep is how far apart altitudes are sampled when looking for a rise or
+// set. The sun and moon can't cross a horizon twice in that time.
+const scanStep = 10 * time.Minute
+
+// j2000 is the Julian day of 2000-01-01 12:00 TT, which the formulas count from
+const j2000 = 2451545.0
+
+// position is where a body is on the sky
+type position struct {
+	ra, dec float64 // right ascension and declination, in degrees
+}
+
+// julianDay returns the Julian day of a time
+func julianDay(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+}
+
+// centuries returns the Julian centuries since J2000
+func centuries(t time.Time) float64 {
+	return (julianDay(t) - j2000) / 36525
+}
+
+// obliquity is the tilt of the Earth's axis, in degrees
+func obliquity(T float64) float64 {
+	return 23.439291 - 0.0130042*T
+}
+
+// equatorial converts ecliptic longitude and latitude into a position
+func equatorial(lambda, beta, epsilon float64) position {
+	l, b, e := rad(lambda), rad(beta), rad(epsilon)
+	ra := math.Atan2(math.Sin(l)*math.Cos(e)-math.Tan(b)*math.Sin(e), math.Cos(l))
+	dec := math.Asin(math.Sin(b)*math.Cos(e) + math.Cos(b)*math.Sin(e)*math.Sin(l))
+	return position{ra: normalize(deg(ra)), dec: deg(dec)}
+}
+
+// siderealTime is the local mean sidereal time, in degrees
+func siderealTime(t time.Time, lon float64) float64 {
+	d := julianDay(t) - j2000
+	return normalize(280.46061837 + 360.98564736629*d + lon)
+}
+
+// hourAngle is how far west of the meridian a position is, from -180 to 180
+// degrees
+func hourAngle(t time.Time, lon float64, p position) float64 {
+	return signed(siderealTime(t, lon) - p.ra)
+}
+
+// altitude is the height of a position above the horizon, in degrees
+func altitude(t time.Time, lat, lon float64, p position) float64 {
+	phi, dec, h := rad(lat), rad(p.dec), rad(hourAngle(t, lon, p))
+	return deg(math.Asin(math.Sin(phi)*math.Sin(dec) + math.Cos(phi)*math.Cos(dec)*math.Cos(h)))
+}
+
+// crossings are the times a body rises above and sets below an altitude
+// during a day. Either is nil when it doesn't happen that day.
+type crossings struct {
+	rise, set *time.Time
+}
+
+// crossing finds when f(t), a body's altitude less the altitude sought, changes
+// sign between start and end. samples are f at start and every scanStep after.
+// The first rise and the last set are kept, so a body that sets just after
+// midnight and again before the next is given the set of that day's evening.
+func crossing(start, end time.Time, samples []float64, f func(time.Time) float64) crossings {
+	var result crossings
+	for i := 1; i < len(samples); i++ {
+		before, after := samples[i-1], samples[i]
+		if (before < 0) == (after < 0) {
+			continue
+		}
+		t := refine(start.Add(time.Duration(i-1)*scanStep), start.Add(time.Duration(i)*scanStep), before, f)
+		if !t.Before(end) {
+			continue
+		}
+		if before < 0 && result.rise == nil {
+			result.rise = &t
+		} else if before >= 0 {
+			result.set = &t
+		}
+	}
+	return result
+}
+
+// refine narrows down the time f changes sign between a and b by bisection,
+// to the second
+func refine(a, b time.Time, fa float64, f func(time.Time) float64) time.Time {
+	for b.Sub(a) > time.Second {
+		mid := a.Add(b.Sub(a) / 2)
+		fm := f(mid)
+		if (fm < 0) == (fa < 0) {
+			a, fa = mid, fm
+		} else {
+			b = mid
+		}
+	}
+	return a.Add(b.Sub(a) / 2).Truncate(time.Second)
+}
+
+// sample returns f at start and every scanStep until end, inclusive
+func sample(start, end time.Time, f func(time.Time) float64) []float64 {
+	var samples []float64
+	for t := start; !t.After(end.Add(scanStep)); t = t.Add(scanStep) {
+		samples = append(samples, f(t))
+	}
+	return samples
+}
+
+// dayBounds returns the start of the day of date in its location, and the
+// start of the next one, which is not always 24 hours later
+func dayBounds(date time.Time) (time.Time, time.Time) {
+	y, m, d := date.Date()
+	loc := date.Location()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
+
+func rad(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func deg(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// normalize brings an angle into [0, 360)
+func normalize(degrees float64) float64 {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	return degrees
+}
+
+// signed brings an angle into [-180, 180)
+func signed(degrees float64) float64 {
+	return normalize(degrees+180) - 180
+}
+
+// sinDeg is the sine of an angle in degrees
+func sinDeg(degrees float64) float64 {
+	return math.Sin(rad(degrees))
+}
+
+// cosDeg is the cosine of an angle in degrees
+func cosDeg(degrees float64) float64 {
+	return math.Cos(rad(degrees))
+}
@@ -0,0 +1,164 @@
+package astro
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// mustLoad returns a time zone, skipping the test without the time zone database
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+// checkTime compares an event with a published local time (HH:MM, "" when the
+// event doesn't happen that day), allowing for rounding and the model's error
+func checkTime(t *testing.T, name string, got *time.Time, want string, date time.Time) {
+	t.Helper()
+	if want == "" {
+		if got != nil {
+			t.Errorf("%s = %s, want none", name, got.Format("15:04:05"))
+		}
+		return
+	}
+	if got == nil {
+		t.Errorf("%s = none, want %s", name, want)
+		return
+	}
+	clock, err := time.Parse("15:04", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location())
+	if diff := got.Sub(expected); diff < -2*time.Minute || diff > 2*time.Minute {
+		t.Errorf("%s = %s, want %s", name, got.Format("15:04:05"), want)
+	}
+	if got.Location() != date.Location() {
+		t.Errorf("%s is in %s, want %s", name, got.Location(), date.Location())
+	}
+}
+
+func TestSunTimes(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	oslo := mustLoad(t, "Europe/Oslo")
+
+	// Published by the USNO and NOAA, to the minute
+	tests := []struct {
+		name                    string
+		date                    time.Time
+		lat, lon                float64
+		nauticalDawn, civilDawn string
+		sunrise, noon, sunset   string
+		civilDusk, nauticalDusk string
+		dayLength               int
+		polar                   string
+	}{
+		{"New York solstice", time.Date(2024, 6, 21, 0, 0, 0, 0, newYork), 40.7128, -74.0060,
+			"04:10", "04:52", "05:25", "12:58", "20:31", "21:04", "21:46", 906, ""},
+		{"New York winter", time.Date(2024, 12, 21, 15, 0, 0, 0, newYork), 40.7128, -74.0060,
+			"06:11", "06:46", "07:17", "11:54", "16:32", "17:03", "17:38", 555, ""},
+		// The sun sets just after midnight and rises an hour later
+		{"Tromsø midnight sunset", time.Date(2024, 7, 26, 0, 0, 0, 0, oslo), 69.6492, 18.9553,
+			"", "", "01:18", "12:51", "00:23", "", "", 1385, ""},
+		{"Tromsø midnight sun", time.Date(2024, 6, 21, 0, 0, 0, 0, oslo), 69.6492, 18.9553,
+			"", "", "", "12:46", "", "", "", 1440, "day"},
+		{"Tromsø polar night", time.Date(2024, 12, 21, 0, 0, 0, 0, oslo), 69.6492, 18.9553,
+			"07:47", "09:31", "", "11:42", "", "13:53", "15:38", 0, "night"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sun := SunTimes(tt.date, tt.lat, tt.lon)
+			checkTime(t, "nautical dawn", sun.NauticalDawn, tt.nauticalDawn, tt.date)
+			checkTime(t, "civil dawn", sun.CivilDawn, tt.civilDawn, tt.date)
+			checkTime(t, "sunrise", sun.Sunrise, tt.sunrise, tt.date)
+			checkTime(t, "solar noon", &sun.SolarNoon, tt.noon, tt.date)
+			checkTime(t, "sunset", sun.Sunset, tt.sunset, tt.date)
+			checkTime(t, "civil dusk", sun.CivilDusk, tt.civilDusk, tt.date)
+			checkTime(t, "nautical dusk", sun.NauticalDusk, tt.nauticalDusk, tt.date)
+			if math.Abs(float64(sun.DayLength-tt.dayLength)) > 2 {
+				t.Errorf("day length = %d minutes, want %d", sun.DayLength, tt.dayLength)
+			}
+			if sun.Polar != tt.polar {
+				t.Errorf("polar = %q, want %q", sun.Polar, tt.polar)
+			}
+		})
+	}
+}
+
+func TestMoonPhases(t *testing.T) {
+	// The USNO's times of the 2024 new and full moons, in UTC
+	tests := []struct {
+		from      time.Time
+		new, full string
+	}{
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "2024-01-11T11:57:00Z", "2024-01-25T17:54:00Z"},
+		{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), "2024-04-08T18:21:00Z", "2024-04-23T23:49:00Z"},
+		{time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), "2024-07-05T22:57:00Z", "2024-06-22T01:08:00Z"},
+		{time.Date(2024, 9, 5, 0, 0, 0, 0, time.UTC), "2024-10-02T18:49:00Z", "2024-09-18T02:34:00Z"},
+		{time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC), "2024-10-02T18:49:00Z", "2024-10-17T11:26:00Z"},
+	}
+	// The moon's series is good to a few arcminutes, which the moon crosses in
+	// well under half an hour
+	near := func(got time.Time, want string) bool {
+		expected, err := time.Parse(time.RFC3339, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff := got.Sub(expected)
+		return diff > -30*time.Minute && diff < 30*time.Minute
+	}
+	for _, tt := range tests {
+		moon := MoonTimes(tt.from, 0, 0)
+		if !near(moon.NextNewMoon, tt.new) {
+			t.Errorf("new moon after %s = %s, want %s", tt.from.Format("2006-01-02"), moon.NextNewMoon.Format(time.RFC3339), tt.new)
+		}
+		if !near(moon.NextFullMoon, tt.full) {
+			t.Errorf("full moon after %s = %s, want %s", tt.from.Format("2006-01-02"), moon.NextFullMoon.Format(time.RFC3339), tt.full)
+		}
+	}
+
+	// On the day of the full moon, the disc is fully lit
+	full := MoonTimes(time.Date(2024, 6, 22, 1, 8, 0, 0, time.UTC), 40.7128, -74.0060)
+	if full.Phase != FullMoon || full.Illumination < 0.99 {
+		t.Errorf("at the full moon, phase = %s lit %.2f, want full moon lit 1", full.Phase, full.Illumination)
+	}
+	if full.Age < 14 || full.Age > 15.5 {
+		t.Errorf("at the full moon, age = %.1f days, want about 14.8", full.Age)
+	}
+}
+
+func TestMoonTimes(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	sydney := mustLoad(t, "Australia/Sydney")
+
+	// Worked out with Meeus' fuller lunar series (Astronomical Algorithms,
+	// chapter 47), rounded to the minute. The moon rises about 50 minutes later
+	// each day, so once a month a rise slips past midnight and the day has none,
+	// and likewise for the set.
+	tests := []struct {
+		name              string
+		date              time.Time
+		lat, lon          float64
+		moonrise, moonset string
+	}{
+		{"New York eclipse", time.Date(2024, 4, 8, 0, 0, 0, 0, newYork), 40.7128, -74.0060, "06:22", "19:40"},
+		{"New York before no moonrise", time.Date(2024, 10, 23, 0, 0, 0, 0, newYork), 40.7128, -74.0060, "23:18", "14:13"},
+		{"New York no moonrise", time.Date(2024, 10, 24, 12, 0, 0, 0, newYork), 40.7128, -74.0060, "", "14:49"},
+		{"New York after no moonrise", time.Date(2024, 10, 25, 0, 0, 0, 0, newYork), 40.7128, -74.0060, "00:25", "15:17"},
+		{"New York no moonset", time.Date(2024, 7, 13, 0, 0, 0, 0, newYork), 40.7128, -74.0060, "13:07", ""},
+		{"Sydney no moonrise", time.Date(2024, 6, 29, 0, 0, 0, 0, sydney), -33.8688, 151.2093, "", "11:50"},
+		{"Sydney after no moonrise", time.Date(2024, 6, 30, 0, 0, 0, 0, sydney), -33.8688, 151.2093, "00:47", "12:20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moon := MoonTimes(tt.date, tt.lat, tt.lon)
+			checkTime(t, "moonrise", moon.Moonrise, tt.moonrise, tt.date)
+			checkTime(t, "moonset", moon.Moonset, tt.moonset, tt.date)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// synodicMonth is the average time from one new moon to the next, in days
+const synodicMonth = 29.530588853
+
+// Moon phases, by the moon's elongation from the sun
+const (
+	NewMoon        = "new moon"
+	WaxingCrescent = "waxing crescent"
+	FirstQuarter   = "first quarter"
+	WaxingGibbous  = "waxing gibbous"
+	FullMoon       = "full moon"
+	WaningGibbous  = "waning gibbous"
+	LastQuarter    = "last quarter"
+	WaningCrescent = "waning crescent"
+)
+
+var phases = []string{NewMoon, WaxingCrescent, FirstQuarter, WaxingGibbous, FullMoon, WaningGibbous, LastQuarter, WaningCrescent}
+
+// Moon is the moon's day at a place. Times are in the location of the date
+// asked for; moonrise and moonset are nil on the days, about one a month, the
+// moon doesn't rise or set.
+type Moon struct {
+	Phase        string     `json:"phase"`        // e.g. waxing crescent
+	Illumination float64    `json:"illumination"` // lit fraction of the disc, 0 to 1
+	Age          float64    `json:"age"`          // days since the new moon
+	Moonrise     *time.Time `json:"moonrise"`
+	Moonset      *time.Time `json:"moonset"`
+	NextNewMoon  time.Time  `json:"nextNewMoon"`
+	NextFullMoon time.Time  `json:"nextFullMoon"`
+}
+
+// moonEcliptic returns the moon's ecliptic longitude and latitude and its
+// horizontal parallax, in degrees, from the Astronomical Almanac's
+// low-precision series
+func moonEcliptic(t time.Time) (lambda, beta, parallax float64) {
+	T := centuries(t)
+	lambda = 218.32 + 481267.881*T +
+		6.29*sinDeg(135.0+477198.87*T) -
+		1.27*sinDeg(259.3-413335.36*T) +
+		0.66*sinDeg(235.7+890534.22*T) +
+		0.21*sinDeg(269.9+954397.74*T) -
+		0.19*sinDeg(357.5+35999.05*T) -
+		0.11*sinDeg(186.5+966404.03*T)
+	beta = 5.13*sinDeg(93.3+483202.02*T) +
+		0.28*sinDeg(228.2+960400.89*T) -
+		0.28*sinDeg(318.3+6003.15*T) -
+		0.17*sinDeg(217.6-407332.21*T)
+	parallax = 0.9508 +
+		0.0518*cosDeg(135.0+477198.87*T) +
+		0.0095*cosDeg(259.3-413335.36*T) +
+		0.0078*cosDeg(235.7+890534.22*T) +
+		0.0028*cosDeg(269.9+954397.74*T)
+	return normalize(lambda), beta, parallax
+}
+
+// elongation is how far the moon is east of the sun along the ecliptic, from 0
+// at new moon through 180 at full moon
+func elongation(t time.Time) float64 {
+	lambda, _, _ := moonEcliptic(t)
+	_, sunLambda := sunPosition(t)
+	return normalize(lambda - sunLambda)
+}
+
+// MoonTimes computes the moon's phase and its rise and set on date, at a
+// latitude and longitude in degrees. The phase is that of date's time.
+func MoonTimes(date time.Time, lat, lon float64) Moon {
+	start, end := dayBounds(date)
+	loc := date.Location()
+
+	// The moon rises when its top, raised by refraction but lowered by parallax,
+	// clears the horizon
+	moonAltitude := func(t time.Time) float64 {
+		lambda, beta, parallax := moonEcliptic(t)
+		p := equatorial(lambda, beta, obliquity(centuries(t)))
+		return altitude(t, lat, lon, p) - (0.7275*parallax - 0.5667)
+	}
+	events := crossing(start, end, sample(start, end, moonAltitude), moonAltitude)
+
+	lambda, beta, _ := moonEcliptic(date)
+	_, sunLambda := sunPosition(date)
+	e := normalize(lambda - sunLambda)
+	// The angle between the sun and moon seen from the Earth, which leaves the
+	// phase angle, and so the lit fraction, as 180° less it
+	psi := math.Acos(cosDeg(beta) * cosDeg(lambda-sunLambda))
+
+	return Moon{
+		Phase:        phases[int(math.Round(e/45))%8],
+		Illumination: math.Round((1-math.Cos(psi))/2*100) / 100,
+		Age:          math.Round(e/360*synodicMonth*10) / 10,
+		Moonrise:     local(events.rise, loc),
+		Moonset:      local(events.set, loc),
+		NextNewMoon:  nextElongation(date, 0).In(loc),
+		NextFullMoon: nextElongation(date, 180).In(loc),
+	}
+}
+
+// nextElongation finds the first time after t the moon reaches an elongation:
+// 0 for the new moon, 180 for the full moon
+func nextElongation(t time.Time, target float64) time.Time {
+	// The moon gains about 12.2° a day on the sun, at an uneven pace, so the
+	// first estimate is within hours and later steps correct it either way
+	ahead := normalize(target - elongation(t))
+	for range 5 {
+		t = t.Add(time.Duration(ahead / 360 * synodicMonth * float64(24*time.Hour)))
+		ahead = signed(target - elongation(t))
+	}
+	return t.Truncate(time.Minute)
+}
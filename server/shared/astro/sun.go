@@ -0,0 +1,107 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// Sun is the sun's day at a place. Times are in the location of the date asked
+// for, and are nil when the sun doesn't reach that altitude that day.
+type Sun struct {
+	NauticalDawn *time.Time `json:"nauticalDawn"` // sun 12° below the horizon, rising
+	CivilDawn    *time.Time `json:"civilDawn"`    // 6° below
+	Sunrise      *time.Time `json:"sunrise"`
+	SolarNoon    time.Time  `json:"solarNoon"` // highest in the sky
+	Sunset       *time.Time `json:"sunset"`
+	CivilDusk    *time.Time `json:"civilDusk"`
+	NauticalDusk *time.Time `json:"nauticalDusk"`
+	DayLength    int        `json:"dayLength"`       // minutes from sunrise to sunset, 0 or 1440 when the sun doesn't rise or set
+	Polar        string     `json:"polar,omitempty"` // "day" when the sun doesn't set, "night" when it doesn't rise
+}
+
+// sunPosition returns the sun's apparent position and its ecliptic longitude
+func sunPosition(t time.Time) (position, float64) {
+	T := centuries(t)
+	L0 := 280.46646 + 36000.76983*T + 0.0003032*T*T
+	M := 357.52911 + 35999.05029*T - 0.0001537*T*T
+	C := (1.914602-0.004817*T-0.000014*T*T)*sinDeg(M) +
+		(0.019993-0.000101*T)*sinDeg(2*M) +
+		0.000289*sinDeg(3*M)
+	omega := 125.04 - 1934.136*T
+	lambda := normalize(L0 + C - 0.00569 - 0.00478*sinDeg(omega))
+	epsilon := obliquity(T) + 0.00256*cosDeg(omega)
+	return equatorial(lambda, 0, epsilon), lambda
+}
+
+// SunTimes computes the sun's day on date, at a latitude and longitude in
+// degrees (north and east positive). The day is that of date in its location.
+func SunTimes(date time.Time, lat, lon float64) Sun {
+	start, end := dayBounds(date)
+	loc := date.Location()
+	sunAltitude := func(t time.Time) float64 {
+		p, _ := sunPosition(t)
+		return altitude(t, lat, lon, p)
+	}
+
+	sun := Sun{SolarNoon: solarNoon(start, end, lon).In(loc)}
+	samples := sample(start, end, sunAltitude)
+	events := func(target float64) crossings {
+		shifted := make([]float64, len(samples))
+		for i, value := range samples {
+			shifted[i] = value - target
+		}
+		c := crossing(start, end, shifted, func(t time.Time) float64 { return sunAltitude(t) - target })
+		return crossings{rise: local(c.rise, loc), set: local(c.set, loc)}
+	}
+
+	rise := events(sunriseAltitude)
+	civil := events(civilAltitude)
+	nautical := events(nauticalAltitude)
+	sun.Sunrise, sun.Sunset = rise.rise, rise.set
+	sun.CivilDawn, sun.CivilDusk = civil.rise, civil.set
+	sun.NauticalDawn, sun.NauticalDusk = nautical.rise, nautical.set
+
+	switch {
+	case sun.Sunrise != nil && sun.Sunset != nil && sun.Sunset.After(*sun.Sunrise):
+		sun.DayLength = int(math.Round(sun.Sunset.Sub(*sun.Sunrise).Minutes()))
+	case sun.Sunrise == nil && sun.Sunset == nil:
+		if sunAltitude(sun.SolarNoon) > sunriseAltitude {
+			sun.Polar = "day"
+			sun.DayLength = 24 * 60
+		} else {
+			sun.Polar = "night"
+		}
+	default:
+		// Near the poles, the sun can set just after midnight, or rise and stay up
+		// past the next one, or both
+		var up time.Duration
+		if sun.Sunset != nil {
+			up += sun.Sunset.Sub(start)
+		}
+		if sun.Sunrise != nil {
+			up += end.Sub(*sun.Sunrise)
+		}
+		sun.DayLength = int(math.Round(up.Minutes()))
+	}
+	return sun
+}
+
+// solarNoon finds when the sun crosses the meridian during a day
+func solarNoon(start, end time.Time, lon float64) time.Time {
+	// Start from noon at the longitude and correct by the sun's hour angle, which
+	// changes by about 360° a day
+	t := start.Add(end.Sub(start) / 2)
+	for range 3 {
+		p, _ := sunPosition(t)
+		t = t.Add(-time.Duration(hourAngle(t, lon, p) / 360 * float64(24*time.Hour)))
+	}
+	return t.Truncate(time.Second)
+}
+
+func local(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	in := t.In(loc)
+	return &in
+}
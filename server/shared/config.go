@@ -2,8 +2,11 @@ package shared
 
 import (
 	"encoding/json"
+	"math"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 )
 
 var (
@@ -20,6 +23,12 @@ type GlobalConfig struct {
 	RefreshIntervalMinutes int    `json:"refresh_interval_minutes"`
 	GridColumns            int    `json:"grid_columns"`
 	GridRows               int    `json:"grid_rows"`
+	TemperatureUnit        string `json:"temperature_unit"` // F or C
+	DistanceUnit           string `json:"distance_unit"`    // mi or km
+	SpeedUnit              string `json:"speed_unit"`       // mph, km/h, m/s or kn
+	PressureUnit           string `json:"pressure_unit"`    // inHg, hPa or mmHg
+	RainfallUnit           string `json:"rainfall_unit"`    // in or mm
+	Locale                 string `json:"locale"`           // e.g. en-US
 }
 
 // DashboardConfig is the unified config structure
@@ -30,30 +39,109 @@ type DashboardConfig struct {
 
 // GetWidgetConfigValue gets a value from a specific widget's config
 func GetWidgetConfigValue(widgetID string, key string, defaultValue string) string {
+	if value, ok := widgetConfigValue(widgetID, key).(string); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetWidgetConfigNumber gets a numeric value from a specific widget's config.
+// Numbers written as strings ("50") are accepted too.
+func GetWidgetConfigNumber(widgetID string, key string, defaultValue float64) float64 {
+	switch value := widgetConfigValue(widgetID, key).(type) {
+	case float64:
+		return value
+	case string:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// GetWidgetConfigObject decodes an object (or array) from a specific widget's
+// config into dest. It returns false if the key is missing or doesn't fit dest.
+func GetWidgetConfigObject(widgetID string, key string, dest interface{}) bool {
+	value := widgetConfigValue(widgetID, key)
+	if value == nil {
+		return false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, dest) == nil
+}
+
+// widgetConfigValue returns the raw JSON value of a widget config key, or nil
+func widgetConfigValue(widgetID string, key string) interface{} {
 	configLock.RLock()
 	defer configLock.RUnlock()
 
 	// Read config from file
 	data, err := os.ReadFile(externalConfigFile)
 	if err != nil {
-		return defaultValue
+		return nil
 	}
 
 	var config DashboardConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return defaultValue
+		return nil
 	}
 
 	// Find the widget config
 	for _, widget := range config.Widgets {
 		if id, ok := widget["id"].(string); ok && id == widgetID {
 			if widgetConfig, ok := widget["config"].(map[string]interface{}); ok {
-				if value, ok := widgetConfig[key].(string); ok && value != "" {
-					return value
-				}
+				return widgetConfig[key]
 			}
 		}
 	}
 
-	return defaultValue
+	return nil
+}
+
+// GetGlobalConfig reads the "global" section of config.json. Unlike the server's
+// copy, defaults are not applied; use the specific helpers for that.
+func GetGlobalConfig() GlobalConfig {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	var config DashboardConfig
+	if data, err := os.ReadFile(externalConfigFile); err == nil {
+		json.Unmarshal(data, &config)
+	}
+	return config.Global
+}
+
+// GetLocation returns the dashboard timezone from global config, falling back to
+// TZ and then America/Chicago like the server does
+func GetLocation() *time.Location {
+	name := GetGlobalConfig().Timezone
+	if name == "" {
+		name = os.Getenv("TZ")
+	}
+	if name == "" {
+		name = "America/Chicago"
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// GetCoordinates returns the dashboard location: the weather widget's latitude
+// and longitude, written as strings or numbers, falling back to the WEATHER_LAT
+// and WEATHER_LON env vars
+func GetCoordinates() (lat, lon float64, ok bool) {
+	coordinate := func(key, env string) (float64, bool) {
+		if value := GetWidgetConfigNumber("weather", key, math.NaN()); !math.IsNaN(value) {
+			return value, true
+		}
+		value, err := strconv.ParseFloat(os.Getenv(env), 64)
+		return value, err == nil
+	}
+	lat, latOK := coordinate("latitude", "WEATHER_LAT")
+	lon, lonOK := coordinate("longitude", "WEATHER_LON")
+	return lat, lon, latOK && lonOK && math.Abs(lat) <= 90 && math.Abs(lon) <= 180
 }
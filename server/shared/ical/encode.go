@@ -0,0 +1,75 @@
+package ical
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line before it is folded
+const maxLineOctets = 75
+
+// textEscaper escapes TEXT values; the reverse of textEscapes
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// EscapeText escapes a value for a TEXT property such as SUMMARY
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// Encode writes the component and those nested in it as iCalendar text, with
+// CRLF line endings and long lines folded. Property values are written as they
+// are, so TEXT values must already be escaped.
+func (c *Component) Encode() string {
+	var b strings.Builder
+	c.encode(&b)
+	return b.String()
+}
+
+func (c *Component) encode(b *strings.Builder) {
+	writeLine(b, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		writeLine(b, p.line())
+	}
+	for _, child := range c.Components {
+		child.encode(b)
+	}
+	writeLine(b, "END:"+c.Name)
+}
+
+// line writes a property as a content line, with parameters in name order
+func (p Property) line() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.ReplaceAll(p.Params[name], `"`, "")
+		if strings.ContainsAny(value, ":;,") {
+			value = `"` + value + `"`
+		}
+		b.WriteString(";" + name + "=" + value)
+	}
+	b.WriteString(":" + p.Value)
+	return b.String()
+}
+
+// writeLine folds a content line into lines of at most maxLineOctets, without
+// splitting a UTF-8 character. Continuation lines start with a space.
+func writeLine(b *strings.Builder, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the next line's length
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line + "\r\n")
+}
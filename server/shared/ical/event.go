@@ -0,0 +1,231 @@
+package ical
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is one occurrence of a VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Status      string // TENTATIVE, CONFIRMED or CANCELLED; often empty
+	Start       time.Time
+	End         time.Time // exclusive; equal to Start for events with no length
+	AllDay      bool
+	// Recurring is set for occurrences of a repeating event, which share a UID;
+	// RecurrenceID, the occurrence's original start, tells them apart
+	Recurring    bool
+	RecurrenceID time.Time
+	// Component is the VEVENT, for properties not decoded here such as ATTACH
+	Component *Component
+}
+
+// series is a recurring event's VEVENT together with the VEVENTs overriding
+// single occurrences of it
+type series struct {
+	master    *Component
+	overrides []*Component
+}
+
+// Events returns the occurrences of the calendar's events that overlap from to
+// to, in start order. Recurring events are expanded, leaving out EXDATEs and
+// adding RDATEs; occurrences with their own VEVENT (RECURRENCE-ID) replace the
+// generated ones. Cancelled events and events without a readable DTSTART are
+// left out. Dates and floating times are in loc.
+func (c *Calendar) Events(from, to time.Time, loc *time.Location) []Event {
+	byUID := make(map[string]*series)
+	var order []*series
+	for _, vevent := range c.Children("VEVENT") {
+		uid := vevent.Text("UID")
+		s := byUID[uid]
+		if s == nil || uid == "" {
+			s = &series{}
+			order = append(order, s)
+			if uid != "" {
+				byUID[uid] = s
+			}
+		}
+		if _, ok := vevent.Get("RECURRENCE-ID"); ok {
+			s.overrides = append(s.overrides, vevent)
+		} else if s.master == nil {
+			s.master = vevent
+		}
+	}
+
+	var events []Event
+	for _, s := range order {
+		events = append(events, c.expand(s, from, to, loc)...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].Summary < events[j].Summary
+	})
+	return events
+}
+
+// expand returns a series' occurrences overlapping from to to
+func (c *Calendar) expand(s *series, from, to time.Time, loc *time.Location) []Event {
+	var events []Event
+
+	// Overridden occurrences are replaced whether or not the override is in range,
+	// since it may have been moved out of it
+	overridden := make(map[int64]bool)
+	for _, override := range s.overrides {
+		p, _ := override.Get("RECURRENCE-ID")
+		recurrenceID, _, err := c.Time(p, loc)
+		if err != nil {
+			continue
+		}
+		overridden[recurrenceID.Unix()] = true
+		event, ok := c.event(override, loc)
+		if !ok {
+			continue
+		}
+		event.Recurring, event.RecurrenceID = true, recurrenceID
+		if event.Status != "CANCELLED" && overlaps(event, from, to) {
+			events = append(events, event)
+		}
+	}
+
+	if s.master == nil {
+		return events
+	}
+	first, ok := c.event(s.master, loc)
+	if !ok || first.Status == "CANCELLED" {
+		return events
+	}
+	span := duration{days: daysBetween(first.Start, first.End), exact: first.End.Sub(first.Start)}
+
+	rrule, hasRule := s.master.Get("RRULE")
+	rdates := s.master.All("RDATE")
+	if !hasRule && len(rdates) == 0 {
+		if overlaps(first, from, to) {
+			events = append(events, first)
+		}
+		return events
+	}
+
+	// Occurrences that started before from may still be running
+	earliest := from.Add(-first.End.Sub(first.Start) - 24*time.Hour)
+	starts := []time.Time{first.Start}
+	if hasRule {
+		if rule, err := ParseRecurrence(rrule.Value, first.Start.Location()); err == nil {
+			starts = rule.Between(first.Start, earliest, to)
+		}
+	}
+	for _, p := range rdates {
+		if times, _, err := c.Times(p, loc); err == nil {
+			starts = append(starts, times...)
+		}
+	}
+
+	excluded := make(map[int64]bool)
+	excludedDates := make(map[string]bool)
+	for _, p := range s.master.All("EXDATE") {
+		times, allDay, err := c.Times(p, loc)
+		if err != nil {
+			continue
+		}
+		for _, t := range times {
+			if allDay {
+				excludedDates[t.Format(dateLayout)] = true
+			} else {
+				excluded[t.Unix()] = true
+			}
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, start := range starts {
+		key := start.Unix()
+		if seen[key] || overridden[key] || excluded[key] || excludedDates[start.Format(dateLayout)] {
+			continue
+		}
+		seen[key] = true
+
+		event := first
+		event.Start, event.End = start, span.after(start, first.AllDay)
+		event.Recurring, event.RecurrenceID = true, start
+		if overlaps(event, from, to) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// event reads a VEVENT's own fields, with its first start
+func (c *Calendar) event(vevent *Component, loc *time.Location) (Event, bool) {
+	dtstart, ok := vevent.Get("DTSTART")
+	if !ok {
+		return Event{}, false
+	}
+	start, allDay, err := c.Time(dtstart, loc)
+	if err != nil {
+		return Event{}, false
+	}
+
+	// Without DTEND or DURATION an event lasts a day, or no time at all
+	end := start
+	if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if p, ok := vevent.Get("DTEND"); ok {
+		if t, _, err := c.Time(p, loc); err == nil && !t.Before(start) {
+			end = t
+		}
+	} else if p, ok := vevent.Get("DURATION"); ok {
+		if d, err := ParseDuration(p.Value); err == nil {
+			if t := d.Add(start); !t.Before(start) {
+				end = t
+			}
+		}
+	}
+
+	return Event{
+		UID:         vevent.Text("UID"),
+		Summary:     vevent.Text("SUMMARY"),
+		Description: vevent.Text("DESCRIPTION"),
+		Location:    vevent.Text("LOCATION"),
+		URL:         strings.TrimSpace(vevent.Text("URL")),
+		Status:      strings.ToUpper(vevent.Text("STATUS")),
+		Start:       start,
+		End:         end,
+		AllDay:      allDay,
+		Component:   vevent,
+	}, true
+}
+
+// duration is the length of a recurring event: whole days for all-day events,
+// so they stay aligned to midnight across DST changes
+type duration struct {
+	days  int
+	exact time.Duration
+}
+
+func (d duration) after(start time.Time, allDay bool) time.Time {
+	if allDay {
+		return start.AddDate(0, 0, d.days)
+	}
+	return start.Add(d.exact)
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	return int(civil(b).Sub(civil(a)).Hours() / 24)
+}
+
+// overlaps reports whether an event overlaps from to to. Events with no length
+// count when they start within it.
+func overlaps(event Event, from, to time.Time) bool {
+	if event.End.Equal(event.Start) {
+		return !event.Start.Before(from) && event.Start.Before(to)
+	}
+	return event.Start.Before(to) && event.End.After(from)
+}
@@ -0,0 +1,197 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultMaxFeedBytes bounds a feed when a FeedCache doesn't set MaxBytes
+const DefaultMaxFeedBytes = 20 << 20
+
+// FeedCache fetches iCalendar feeds over HTTP and keeps the last good copy of
+// each. A feed is fetched at most once per refresh interval, with a conditional
+// request so an unchanged feed isn't downloaded again. When a fetch fails, the
+// last good copy is served and the error is kept for Status.
+type FeedCache struct {
+	Client   *http.Client
+	MaxBytes int64 // largest feed read; DefaultMaxFeedBytes if zero
+
+	mu    sync.Mutex
+	feeds map[string]*feed
+}
+
+// feed is one feed's cached copy. Its mutex is held while fetching, so requests
+// arriving meanwhile share the fetch.
+type feed struct {
+	mu           sync.Mutex
+	calendar     *Calendar
+	etag         string
+	lastModified string
+	checked      time.Time // last fetch, successful or not
+	err          error     // of the last fetch
+	status       FeedStatus
+}
+
+// FeedStatus reports on the fetches of a feed
+type FeedStatus struct {
+	Host        string     `json:"host"` // feed addresses often embed a secret, so only the host is shown
+	LastFetch   *time.Time `json:"lastFetch,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"` // last fetch that returned the feed or found it unchanged
+	LastChange  *time.Time `json:"lastChange,omitempty"`  // last fetch that downloaded the feed
+	Bytes       int        `json:"bytes"`                 // size of the copy being served
+	Error       string     `json:"error,omitempty"`       // of the last fetch, if it failed
+	Stale       bool       `json:"stale"`                 // the last good copy is served because the last fetch failed
+}
+
+// Get returns a feed, fetching it if it was last fetched more than minInterval
+// ago. If the fetch fails, the last good copy is returned if there is one.
+// Otherwise the error is returned, and again for the rest of the interval rather
+// than retrying each time; a fetch cut short by ctx isn't remembered. A response
+// that isn't iCalendar is ErrNotCalendar.
+func (c *FeedCache) Get(ctx context.Context, rawURL string, minInterval time.Duration) (*Calendar, error) {
+	f := c.feed(rawURL)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.checked.IsZero() && time.Since(f.checked) < minInterval {
+		if f.calendar != nil {
+			return f.calendar, nil
+		}
+		return nil, f.err
+	}
+
+	now := time.Now()
+	err := c.fetch(ctx, f, rawURL)
+	if err != nil && ctx.Err() != nil {
+		// A cancelled request says nothing about the feed, so the next one tries again
+		if f.calendar != nil {
+			return f.calendar, nil
+		}
+		return nil, err
+	}
+	f.checked = now
+	f.status.LastFetch = &now
+	f.err = err
+	if f.err != nil {
+		f.status.Error = f.err.Error()
+		f.status.Stale = f.calendar != nil
+		if f.calendar != nil {
+			return f.calendar, nil
+		}
+		return nil, f.err
+	}
+	f.status.LastSuccess = &now
+	f.status.Error, f.status.Stale = "", false
+	return f.calendar, nil
+}
+
+// Status reports on a feed, or returns false if it hasn't been fetched
+func (c *FeedCache) Status(rawURL string) (FeedStatus, bool) {
+	c.mu.Lock()
+	f, ok := c.feeds[rawURL]
+	c.mu.Unlock()
+	if !ok {
+		return FeedStatus{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status, true
+}
+
+func (c *FeedCache) feed(rawURL string) *feed {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.feeds == nil {
+		c.feeds = make(map[string]*feed)
+	}
+	f, ok := c.feeds[rawURL]
+	if !ok {
+		f = &feed{status: FeedStatus{Host: rawURL}}
+		if u, err := url.Parse(rawURL); err == nil {
+			f.status.Host = u.Host
+		}
+		c.feeds[rawURL] = f
+	}
+	return f
+}
+
+// fetch downloads a feed unless it is unchanged, replacing the cached copy only
+// with one that parses
+func (c *FeedCache) fetch(ctx context.Context, f *feed, rawURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return withoutURL(err)
+	}
+	req.Header.Set("Accept", "text/calendar")
+	if f.calendar != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && f.calendar != nil {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	maxBytes := c.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxFeedBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxBytes {
+		return fmt.Errorf("feed is larger than %s", formatBytes(maxBytes))
+	}
+	calendar, err := Parse(string(body))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	f.calendar = calendar
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	f.status.LastChange = &now
+	f.status.Bytes = len(body)
+	return nil
+}
+
+// withoutURL drops the feed address from an error, since it often embeds a
+// secret and errors end up in FeedStatus and the logs
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func formatBytes(n int64) string {
+	if n >= 1<<20 && n%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", n>>20)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
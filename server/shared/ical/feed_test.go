@@ -0,0 +1,64 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const feedBody = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"
+
+func TestFeedErrorHidesURL(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	feedURL := server.URL + "/private/s3cr3t/basic.ics"
+	server.Close() // nothing listens any more, so the fetch fails in the transport
+
+	var cache FeedCache
+	_, err := cache.Get(context.Background(), feedURL, time.Minute)
+	if err == nil {
+		t.Fatal("got no error from a closed server")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("error %q includes the feed URL", err)
+	}
+	status, ok := cache.Status(feedURL)
+	if !ok {
+		t.Fatal("got no status after a fetch")
+	}
+	if status.Error == "" || strings.Contains(status.Error, "s3cr3t") {
+		t.Errorf("status error %q, want the cause without the feed URL", status.Error)
+	}
+}
+
+func TestFeedCancelledFetchNotCached(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		rw.Header().Set("Content-Type", "text/calendar")
+		rw.Write([]byte(feedBody))
+	}))
+	defer server.Close()
+
+	var cache FeedCache
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.Get(ctx, server.URL, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v from a cancelled request, want context.Canceled", err)
+	}
+	if status, _ := cache.Status(server.URL); status.LastFetch != nil || status.Error != "" {
+		t.Errorf("got status %+v after a cancelled request, want none recorded", status)
+	}
+
+	calendar, err := cache.Get(context.Background(), server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("got %v after a cancelled request, want the feed", err)
+	}
+	if calendar == nil || requests.Load() != 1 {
+		t.Errorf("got calendar %v after %d requests, want the feed after 1", calendar, requests.Load())
+	}
+}
@@ -0,0 +1,208 @@
+// Package ical parses iCalendar (RFC 5545) files and expands their events,
+// including recurrence rules, exceptions and time zone definitions. It can also
+// write components back out, for serving feeds, and fetch feeds over HTTP with
+// caching.
+package ical
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDepth bounds component nesting; real files use three levels at most
+const maxDepth = 16
+
+// ErrNotCalendar is returned by Parse when the data has no VCALENDAR
+var ErrNotCalendar = errors.New("not an iCalendar file")
+
+// Property is one content line, e.g. DTSTART;TZID=Europe/London:20261018T090000
+type Property struct {
+	Name   string            // upper case
+	Params map[string]string // upper-case names; quotes removed from values
+	Value  string            // raw value, still escaped for TEXT properties
+}
+
+// Param returns a parameter's value, or "" if the property doesn't have it
+func (p Property) Param(name string) string {
+	return p.Params[strings.ToUpper(name)]
+}
+
+// textEscapes undoes the escaping of TEXT values
+var textEscapes = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// Text returns the value of a TEXT property with escapes undone
+func (p Property) Text() string {
+	return textEscapes.Replace(p.Value)
+}
+
+// Component is a BEGIN/END block such as VEVENT or VTIMEZONE
+type Component struct {
+	Name       string // upper case
+	Properties []Property
+	Components []*Component
+}
+
+// Get returns the first property with the name
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// All returns every property with the name, e.g. each EXDATE line
+func (c *Component) All(name string) []Property {
+	var props []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Text returns the unescaped value of the first property with the name, or ""
+func (c *Component) Text(name string) string {
+	p, _ := c.Get(name)
+	return p.Text()
+}
+
+// Children returns the nested components with the name
+func (c *Component) Children(name string) []*Component {
+	var children []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Calendar is a parsed VCALENDAR
+type Calendar struct {
+	*Component
+
+	mu    sync.Mutex
+	zones map[string]*time.Location // resolved TZIDs; nil for unknown ones
+}
+
+// Parse reads an iCalendar file. It is lenient, as published feeds often aren't
+// quite valid: lines that can't be parsed are skipped and unterminated components
+// are closed at the end. Only data with no VCALENDAR at all is an error. If there
+// are several, their components are merged.
+func Parse(data string) (*Calendar, error) {
+	var root *Component
+	var stack []*Component
+	for _, line := range unfold(data) {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch prop.Name {
+		case "BEGIN":
+			name := strings.ToUpper(strings.TrimSpace(prop.Value))
+			if len(stack) == 0 {
+				if name != "VCALENDAR" {
+					continue
+				}
+				if root == nil {
+					root = &Component{Name: name}
+				}
+				stack = append(stack, root)
+				continue
+			}
+			if len(stack) >= maxDepth {
+				continue
+			}
+			child := &Component{Name: name}
+			parent := stack[len(stack)-1]
+			parent.Components = append(parent.Components, child)
+			stack = append(stack, child)
+		case "END":
+			name := strings.ToUpper(strings.TrimSpace(prop.Value))
+			// Close up to the matching BEGIN, so a missing END doesn't swallow the rest
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].Name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		default:
+			if len(stack) > 0 {
+				current := stack[len(stack)-1]
+				current.Properties = append(current.Properties, prop)
+			}
+		}
+	}
+	if root == nil {
+		return nil, ErrNotCalendar
+	}
+	return &Calendar{Component: root, zones: make(map[string]*time.Location)}, nil
+}
+
+// unfold splits data into content lines, joining continuation lines (which start
+// with a space or tab) to the line before
+func unfold(data string) []string {
+	var lines []string
+	var current strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			current.WriteString(line[1:])
+			continue
+		}
+		if current.Len() > 0 {
+			lines = append(lines, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// parseLine splits a content line into name, parameters and value. Quoted
+// parameter values may contain ':', ';' and ','.
+func parseLine(line string) (Property, bool) {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';' || c == ':':
+			parts = append(parts, line[start:i])
+			start = i + 1
+			if c != ':' {
+				continue
+			}
+			name := strings.ToUpper(strings.TrimSpace(parts[0]))
+			if name == "" {
+				return Property{}, false
+			}
+			prop := Property{Name: name, Value: line[i+1:]}
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(param, "=")
+				if key = strings.ToUpper(strings.TrimSpace(key)); key != "" {
+					if prop.Params == nil {
+						prop.Params = make(map[string]string)
+					}
+					prop.Params[key] = strings.ReplaceAll(value, `"`, "")
+				}
+			}
+			return prop, true
+		}
+	}
+	return Property{}, false
+}
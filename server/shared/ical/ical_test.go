@@ -0,0 +1,554 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// crlf turns a readable test file into one with CRLF line endings, as served
+func crlf(lines ...string) string {
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	return loc
+}
+
+func TestParseFolding(t *testing.T) {
+	data := crlf(
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:folded@example.com",
+		"SUMMARY:Taco Tues",
+		" day",
+		"DESCRIPTION:Shells\\, beef\\, salsa",
+		"\t and cheese",
+		"",
+		"LOCATION:Kitchen",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+	calendar, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	events := calendar.Children("VEVENT")
+	if len(events) != 1 {
+		t.Fatalf("got %d VEVENTs, want 1", len(events))
+	}
+	for _, tc := range []struct{ name, want string }{
+		{"SUMMARY", "Taco Tuesday"},
+		{"DESCRIPTION", "Shells, beef, salsa and cheese"},
+		{"LOCATION", "Kitchen"},
+	} {
+		if got := events[0].Text(tc.name); got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   string
+		events int
+		err    error
+	}{
+		{"not a calendar", "<html>Not found</html>", 0, ErrNotCalendar},
+		{"empty", "", 0, ErrNotCalendar},
+		{"LF line endings", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nEND:VEVENT\nEND:VCALENDAR\n", 1, nil},
+		{"missing END:VEVENT", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nBEGIN:VEVENT\nUID:b\nEND:VEVENT\nEND:VCALENDAR\n", 1, nil},
+		{"unterminated", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\n", 1, nil},
+		{"garbage lines", "BEGIN:VCALENDAR\nnonsense\n;:\nBEGIN:VEVENT\nUID:a\nEND:VEVENT\nEND:VCALENDAR\n", 1, nil},
+		{"two calendars", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nEND:VEVENT\nEND:VCALENDAR\nBEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:b\nEND:VEVENT\nEND:VCALENDAR\n", 2, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calendar, err := Parse(tc.data)
+			if err != tc.err {
+				t.Fatalf("Parse error = %v, want %v", err, tc.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := len(calendar.Children("VEVENT")); got != tc.events {
+				t.Errorf("got %d top-level VEVENTs, want %d", got, tc.events)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		name   string
+		params map[string]string
+		value  string
+		ok     bool
+	}{
+		{"SUMMARY:Tacos", "SUMMARY", nil, "Tacos", true},
+		{"DESCRIPTION:Bake at 350: 20 min", "DESCRIPTION", nil, "Bake at 350: 20 min", true},
+		{"DTSTART;TZID=America/New_York:20240703T100000", "DTSTART", map[string]string{"TZID": "America/New_York"}, "20240703T100000", true},
+		{`DTSTART;TZID="America/New_York":20240703T100000`, "DTSTART", map[string]string{"TZID": "America/New_York"}, "20240703T100000", true},
+		{
+			`ATTENDEE;CN="Doe, Jane: Chef";ROLE=REQ-PARTICIPANT:mailto:jane@example.com`, "ATTENDEE",
+			map[string]string{"CN": "Doe, Jane: Chef", "ROLE": "REQ-PARTICIPANT"}, "mailto:jane@example.com", true,
+		},
+		{`X-NOTE;X-LABEL="a;b":c`, "X-NOTE", map[string]string{"X-LABEL": "a;b"}, "c", true},
+		{"summary;language=en:Tacos", "SUMMARY", map[string]string{"LANGUAGE": "en"}, "Tacos", true},
+		{"EMPTY:", "EMPTY", nil, "", true},
+		{"NO VALUE", "", nil, "", false},
+		{":value", "", nil, "", false},
+		{`X-OPEN;X-Q="unterminated:value`, "", nil, "", false},
+	} {
+		prop, ok := parseLine(tc.line)
+		if ok != tc.ok {
+			t.Errorf("parseLine(%q) ok = %v, want %v", tc.line, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if prop.Name != tc.name || prop.Value != tc.value || len(prop.Params) != len(tc.params) {
+			t.Errorf("parseLine(%q) = %+v, want %s %v %q", tc.line, prop, tc.name, tc.params, tc.value)
+			continue
+		}
+		for key, want := range tc.params {
+			if got := prop.Param(key); got != want {
+				t.Errorf("parseLine(%q) param %s = %q, want %q", tc.line, key, got, want)
+			}
+		}
+	}
+}
+
+func TestPropertyText(t *testing.T) {
+	for _, tc := range []struct{ value, want string }{
+		{`Tacos\, salsa\; chips`, "Tacos, salsa; chips"},
+		{`Line one\nLine two\NLine three`, "Line one\nLine two\nLine three"},
+		{`C:\\Recipes`, `C:\Recipes`},
+		{`Not a newline: \\n`, `Not a newline: \n`},
+		{`Plain`, "Plain"},
+	} {
+		if got := (Property{Value: tc.value}).Text(); got != tc.want {
+			t.Errorf("Text(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	for _, tc := range []struct{ value, want string }{
+		{"Tacos, salsa; chips", `Tacos\, salsa\; chips`},
+		{"Line one\r\nLine two\nLine three", `Line one\nLine two\nLine three`},
+		{"Old Mac\rline\r", `Old Mac\nline\n`},
+		{`C:\Recipes`, `C:\\Recipes`},
+	} {
+		if got := EscapeText(tc.value); got != tc.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+// windowsZone is a VTIMEZONE as Outlook writes it, with a Windows zone name the
+// system's zone database doesn't know
+const windowsZone = `BEGIN:VCALENDAR
+BEGIN:VTIMEZONE
+TZID:Eastern Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11
+TZNAME:EST
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3
+TZNAME:EDT
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTART;TZID=Eastern Standard Time:20240703T093000
+SUMMARY:Standup
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestLocationFromVTIMEZONE(t *testing.T) {
+	calendar, err := Parse(windowsZone)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	loc := calendar.Location("Eastern Standard Time", time.UTC)
+	if loc == time.UTC {
+		t.Fatal("VTIMEZONE was not turned into a location")
+	}
+
+	for _, tc := range []struct {
+		at     time.Time
+		name   string
+		offset int
+	}{
+		{time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), "EST", -5 * 3600},
+		{time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC), "EST", -5 * 3600},
+		{time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), "EDT", -4 * 3600},
+		{time.Date(2024, 7, 3, 12, 0, 0, 0, time.UTC), "EDT", -4 * 3600},
+		{time.Date(2024, 11, 3, 5, 59, 0, 0, time.UTC), "EDT", -4 * 3600},
+		{time.Date(2024, 11, 3, 6, 0, 0, 0, time.UTC), "EST", -5 * 3600},
+		{time.Date(2030, 7, 1, 12, 0, 0, 0, time.UTC), "EDT", -4 * 3600},
+	} {
+		name, offset := tc.at.In(loc).Zone()
+		if name != tc.name || offset != tc.offset {
+			t.Errorf("at %s: zone %s %d, want %s %d", tc.at, name, offset, tc.name, tc.offset)
+		}
+	}
+
+	events := calendar.Events(time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC), time.UTC)
+	if len(events) != 1 || !events[0].Start.Equal(time.Date(2024, 7, 3, 13, 30, 0, 0, time.UTC)) {
+		t.Errorf("Standup = %+v, want 13:30 UTC", events)
+	}
+}
+
+func TestLocationNames(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+	calendar, err := Parse(windowsZone)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	fallback := time.FixedZone("fallback", 3600)
+	for _, tc := range []struct {
+		tzid string
+		want string
+	}{
+		{"Europe/London", london.String()},
+		{"/mozilla.org/20070129_1/Europe/London", london.String()},
+		{"Mars/Olympus_Mons", "fallback"},
+		{"", "fallback"},
+	} {
+		if got := calendar.Location(tc.tzid, fallback).String(); got != tc.want {
+			t.Errorf("Location(%q) = %s, want %s", tc.tzid, got, tc.want)
+		}
+	}
+}
+
+func TestRecurrence(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	day := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+	for _, tc := range []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from, to time.Time
+		want     []string // local start times, 2006-01-02 15:04
+	}{
+		{
+			name:    "second Tuesday",
+			rule:    "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			dtstart: day(time.UTC, 2024, 1, 9, 18, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-09 18:00", "2024-02-13 18:00", "2024-03-12 18:00"},
+		},
+		{
+			name:    "last Friday",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: day(time.UTC, 2024, 1, 26, 17, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-26 17:00", "2024-02-23 17:00", "2024-03-29 17:00"},
+		},
+		{
+			name:    "first Sunday in November, yearly",
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+			dtstart: day(time.UTC, 2007, 11, 4, 2, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2026, 1, 1, 0, 0),
+			want: []string{"2024-11-03 02:00", "2025-11-02 02:00"},
+		},
+		{
+			name:    "last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3",
+			dtstart: day(time.UTC, 2024, 1, 31, 9, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-31 09:00", "2024-02-29 09:00", "2024-03-29 09:00"},
+		},
+		{
+			name:    "first and third weekday",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1,3;UNTIL=20240301T000000Z",
+			dtstart: day(time.UTC, 2024, 1, 1, 8, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-01 08:00", "2024-01-03 08:00", "2024-02-01 08:00", "2024-02-05 08:00"},
+		},
+		{
+			// A date UNTIL includes that day, even for an evening event in a zone
+			// behind UTC
+			name:    "UNTIL as a date",
+			rule:    "FREQ=WEEKLY;BYDAY=TU;UNTIL=20240123",
+			dtstart: day(chicago, 2024, 1, 2, 18, 0),
+			from:    day(chicago, 2024, 1, 1, 0, 0), to: day(chicago, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-02 18:00", "2024-01-09 18:00", "2024-01-16 18:00", "2024-01-23 18:00"},
+		},
+		{
+			name:    "COUNT counts from DTSTART",
+			rule:    "FREQ=DAILY;COUNT=5",
+			dtstart: day(time.UTC, 2024, 1, 1, 9, 0),
+			from:    day(time.UTC, 2024, 1, 4, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-04 09:00", "2024-01-05 09:00"},
+		},
+		{
+			name:    "every other week on two days",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=5",
+			dtstart: day(time.UTC, 2024, 1, 1, 7, 30),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-01 07:30", "2024-01-03 07:30", "2024-01-15 07:30", "2024-01-17 07:30", "2024-01-29 07:30"},
+		},
+		{
+			// The wall-clock time stays put across the DST change
+			name:    "across DST",
+			rule:    "FREQ=DAILY",
+			dtstart: day(chicago, 2024, 3, 9, 8, 0),
+			from:    day(chicago, 2024, 3, 9, 0, 0), to: day(chicago, 2024, 3, 12, 0, 0),
+			want: []string{"2024-03-09 08:00", "2024-03-10 08:00", "2024-03-11 08:00"},
+		},
+		{
+			name:    "the 31st skips short months",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: day(time.UTC, 2024, 1, 31, 12, 0),
+			from:    day(time.UTC, 2024, 1, 1, 0, 0), to: day(time.UTC, 2025, 1, 1, 0, 0),
+			want: []string{"2024-01-31 12:00", "2024-03-31 12:00", "2024-05-31 12:00"},
+		},
+		{
+			// Far more periods since DTSTART than are ever walked
+			name:    "every minute since January",
+			rule:    "FREQ=MINUTELY",
+			dtstart: day(time.UTC, 2024, 1, 1, 0, 0),
+			from:    day(time.UTC, 2024, 7, 1, 10, 0), to: day(time.UTC, 2024, 7, 1, 10, 3),
+			want: []string{"2024-07-01 10:00", "2024-07-01 10:01", "2024-07-01 10:02"},
+		},
+		{
+			name:    "hourly with filters since 2000",
+			rule:    "FREQ=HOURLY;BYDAY=MO;BYHOUR=8,9",
+			dtstart: day(chicago, 2000, 1, 3, 8, 30),
+			from:    day(chicago, 2024, 7, 1, 0, 0), to: day(chicago, 2024, 7, 9, 0, 0),
+			want: []string{"2024-07-01 08:30", "2024-07-01 09:30", "2024-07-08 08:30", "2024-07-08 09:30"},
+		},
+		{
+			// Hours are counted in elapsed time, so in daylight saving time the
+			// two-hour steps from 08:30 standard time fall on odd hours
+			name:    "every other hour across DST",
+			rule:    "FREQ=HOURLY;INTERVAL=2;BYDAY=MO;BYHOUR=8,9,10",
+			dtstart: day(chicago, 2000, 1, 3, 8, 30),
+			from:    day(chicago, 2024, 7, 1, 0, 0), to: day(chicago, 2024, 7, 9, 0, 0),
+			want: []string{"2024-07-01 09:30", "2024-07-08 09:30"},
+		},
+		{
+			name:    "every third day, decades on",
+			rule:    "FREQ=DAILY;INTERVAL=3;UNTIL=20240710T000000Z",
+			dtstart: day(chicago, 1990, 1, 1, 7, 0),
+			from:    day(chicago, 2024, 7, 1, 0, 0), to: day(chicago, 2024, 8, 1, 0, 0),
+			want: []string{"2024-07-01 07:00", "2024-07-04 07:00", "2024-07-07 07:00"},
+		},
+		{
+			name:    "every other week, decades on",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			dtstart: day(time.UTC, 1995, 1, 3, 19, 0),
+			from:    day(time.UTC, 2024, 7, 1, 0, 0), to: day(time.UTC, 2024, 7, 15, 0, 0),
+			want: []string{"2024-07-09 19:00", "2024-07-11 19:00"},
+		},
+		{
+			name:    "never matches",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			dtstart: day(time.UTC, 2024, 1, 1, 0, 0),
+			from:    day(time.UTC, 2024, 1, 2, 0, 0), to: day(time.UTC, 2100, 1, 1, 0, 0),
+			want: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParseRecurrence(tc.rule, tc.dtstart.Location())
+			if err != nil {
+				t.Fatalf("ParseRecurrence(%q): %v", tc.rule, err)
+			}
+			var got []string
+			for _, occurrence := range rule.Between(tc.dtstart, tc.from, tc.to) {
+				got = append(got, occurrence.In(tc.dtstart.Location()).Format("2006-01-02 15:04"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("occurrences\n got %v\nwant %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"COUNT=3",
+		"FREQ=SECONDLY",
+		"FREQ=YEARLY;BYWEEKNO=20",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;UNTIL=tomorrow",
+		"FREQ",
+	} {
+		if _, err := ParseRecurrence(rule, time.UTC); err == nil {
+			t.Errorf("ParseRecurrence(%q) succeeded", rule)
+		}
+	}
+}
+
+// tacoNight repeats weekly with one week skipped (EXDATE), an extra Thursday
+// (RDATE), one week moved to Wednesday and one cancelled (RECURRENCE-ID), next
+// to a daily all-day event with a date EXDATE
+const tacoNight = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:tacos@example.com
+DTSTART;TZID=America/Chicago:20240702T180000
+DTEND;TZID=America/Chicago:20240702T190000
+RRULE:FREQ=WEEKLY;BYDAY=TU;COUNT=6
+EXDATE;TZID=America/Chicago:20240709T180000
+RDATE;TZID=America/Chicago:20240711T180000
+SUMMARY:Taco Tuesday
+END:VEVENT
+BEGIN:VEVENT
+UID:tacos@example.com
+RECURRENCE-ID;TZID=America/Chicago:20240716T180000
+DTSTART;TZID=America/Chicago:20240717T190000
+DTEND;TZID=America/Chicago:20240717T200000
+SUMMARY:Taco Wednesday
+END:VEVENT
+BEGIN:VEVENT
+UID:tacos@example.com
+RECURRENCE-ID;TZID=America/Chicago:20240723T180000
+DTSTART;TZID=America/Chicago:20240723T180000
+STATUS:CANCELLED
+SUMMARY:Taco Tuesday
+END:VEVENT
+BEGIN:VEVENT
+UID:camp@example.com
+DTSTART;VALUE=DATE:20240701
+RRULE:FREQ=DAILY;COUNT=3
+EXDATE;VALUE=DATE:20240702
+SUMMARY:Day camp
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestEventsExpansion(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	calendar, err := Parse(tacoNight)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, chicago)
+	events := calendar.Events(from, from.AddDate(0, 1, 0), chicago)
+
+	want := []struct {
+		summary      string
+		start, end   string
+		allDay       bool
+		recurrenceID string
+	}{
+		{"Day camp", "2024-07-01 00:00", "2024-07-02 00:00", true, "2024-07-01 00:00"},
+		{"Taco Tuesday", "2024-07-02 18:00", "2024-07-02 19:00", false, "2024-07-02 18:00"},
+		{"Day camp", "2024-07-03 00:00", "2024-07-04 00:00", true, "2024-07-03 00:00"},
+		{"Taco Tuesday", "2024-07-11 18:00", "2024-07-11 19:00", false, "2024-07-11 18:00"},
+		{"Taco Wednesday", "2024-07-17 19:00", "2024-07-17 20:00", false, "2024-07-16 18:00"},
+		{"Taco Tuesday", "2024-07-30 18:00", "2024-07-30 19:00", false, "2024-07-30 18:00"},
+	}
+	if len(events) != len(want) {
+		for _, event := range events {
+			t.Logf("%s %s", event.Summary, event.Start.In(chicago))
+		}
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	const layout = "2006-01-02 15:04"
+	for i, w := range want {
+		event := events[i]
+		got := struct {
+			summary      string
+			start, end   string
+			allDay       bool
+			recurrenceID string
+		}{event.Summary, event.Start.In(chicago).Format(layout), event.End.In(chicago).Format(layout), event.AllDay, event.RecurrenceID.In(chicago).Format(layout)}
+		if got != w {
+			t.Errorf("event %d = %+v, want %+v", i, got, w)
+		}
+		if !event.Recurring {
+			t.Errorf("event %d (%s) is not marked recurring", i, event.Summary)
+		}
+	}
+}
+
+func TestEventsOverlap(t *testing.T) {
+	calendar, err := Parse(crlf(
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:overnight",
+		"DTSTART:20240701T220000Z",
+		"DURATION:PT4H",
+		"SUMMARY:Overnight",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:no-start",
+		"SUMMARY:No start",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:bad-start",
+		"DTSTART:tomorrow",
+		"SUMMARY:Bad start",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// Events that began before the range but are still running are included;
+	// events without a readable start are left out rather than made up
+	from := time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)
+	events := calendar.Events(from, from.AddDate(0, 0, 1), time.UTC)
+	if len(events) != 1 || events[0].Summary != "Overnight" || !events[0].End.Equal(time.Date(2024, 7, 2, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("events = %+v, want only Overnight until 02:00", events)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		windowsZone,
+		tacoNight,
+		crlf("BEGIN:VCALENDAR", "BEGIN:VEVENT", "SUMMARY:Folded", " line", "END:VEVENT", "END:VCALENDAR"),
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20240101T090000Z\nRRULE:FREQ=MINUTELY;INTERVAL=1\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20240229\nRRULE:FREQ=YEARLY;BYSETPOS=-1,1;BYDAY=-1FR\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTIMEZONE\nTZID:X\nBEGIN:STANDARD\nDTSTART:19700101T000000\nTZOFFSETFROM:+1400\nTZOFFSETTO:-1200\nRDATE:19700101T000000,20500101T000000\nEND:STANDARD\nEND:VTIMEZONE\nEND:VCALENDAR\n",
+		`BEGIN:VCALENDAR` + "\n" + `ATTENDEE;CN="a:b;c":mailto:x` + "\n" + `X;Y="` + "\n" + `END:VCALENDAR`,
+		"BEGIN:VCALENDAR\n" + strings.Repeat("BEGIN:X\n", 40) + "END:VCALENDAR\n",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.Fuzz(func(t *testing.T, data string) {
+		calendar, err := Parse(data)
+		if err != nil {
+			return
+		}
+		for _, event := range calendar.Events(from, from.AddDate(1, 0, 0), time.UTC) {
+			if event.End.Before(event.Start) {
+				t.Errorf("event %q ends before it starts", event.UID)
+			}
+		}
+		// What is written back must parse again
+		if _, err := Parse(calendar.Encode()); err != nil {
+			t.Errorf("encoded calendar doesn't parse: %v", err)
+		}
+	})
+}
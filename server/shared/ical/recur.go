@@ -0,0 +1,422 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on expanding a recurrence, so a rule that never matches (the 30th of
+// February) or an event repeating every minute since 1970 can't run away
+const (
+	maxPeriods     = 200000
+	maxOccurrences = 5000
+)
+
+// Recurrence frequencies
+const (
+	freqMinutely = "MINUTELY"
+	freqHourly   = "HOURLY"
+	freqDaily    = "DAILY"
+	freqWeekly   = "WEEKLY"
+	freqMonthly  = "MONTHLY"
+	freqYearly   = "YEARLY"
+)
+
+// Recurrence is a parsed RRULE. BYWEEKNO and SECONDLY aren't supported.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int       // 0 for no limit
+	Until      time.Time // zero for no limit; inclusive
+	ByMonth    []int
+	ByWeekDay  []WeekDay
+	ByMonthDay []int
+	ByYearDay  []int
+	ByHour     []int
+	ByMinute   []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// WeekDay is one BYDAY entry, e.g. -1SU for the last Sunday. N is 0 for every
+// such weekday in the period.
+type WeekDay struct {
+	N   int
+	Day time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRecurrence reads an RRULE value. UNTIL given as a date or floating time is
+// taken to be in loc, which should be the zone of the event's DTSTART.
+func ParseRecurrence(value string, loc *time.Location) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(strings.TrimSpace(value), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && (r.Interval < 1 || r.Interval > 10000) {
+				err = fmt.Errorf("must be between 1 and 10000")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			r.Until, _, err = parseTime(val, false, loc, loc)
+			if err == nil && len(val) == len(dateLayout) {
+				// A date includes the whole of that day
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(val, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(val, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseInts(val, 1, 366, true)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(val, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(val, 0, 59, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(val, 1, 366, true)
+		case "BYDAY":
+			r.ByWeekDay, err = parseWeekDays(val)
+		case "WKST":
+			day, ok := weekdayCodes[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday")
+			}
+			r.WeekStart = day
+		case "BYWEEKNO", "BYSECOND":
+			err = fmt.Errorf("not supported")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE %s=%s: %w", key, val, err)
+		}
+	}
+	// Candidates within a day are built in order
+	sort.Ints(r.ByHour)
+	sort.Ints(r.ByMinute)
+
+	switch r.Freq {
+	case freqMinutely, freqHourly, freqDaily, freqWeekly, freqMonthly, freqYearly:
+	case "":
+		return nil, fmt.Errorf("RRULE has no FREQ")
+	default:
+		return nil, fmt.Errorf("RRULE FREQ=%s is not supported", r.Freq)
+	}
+	return r, nil
+}
+
+// parseInts reads a comma-separated list of numbers between lo and hi, or their
+// negatives when negative is set
+func parseInts(value string, lo, hi int, negative bool) ([]int, error) {
+	var values []int
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		abs := n
+		if negative && n < 0 {
+			abs = -n
+		}
+		if abs < lo || abs > hi {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// parseWeekDays reads a BYDAY list such as MO,WE,FR or 2TU,-1FR
+func parseWeekDays(value string) ([]WeekDay, error) {
+	var days []WeekDay
+	for _, s := range strings.Split(strings.ToUpper(value), ",") {
+		s = strings.TrimSpace(s)
+		if len(s) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", s)
+		}
+		day, ok := weekdayCodes[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", s)
+		}
+		n := 0
+		if prefix := s[:len(s)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", s)
+			}
+		}
+		days = append(days, WeekDay{N: n, Day: day})
+	}
+	return days, nil
+}
+
+// Between returns the occurrences of a series starting at dtstart that start
+// at or after from and before to. DTSTART is always the first occurrence, and
+// COUNT counts from it even when from is later.
+func (r *Recurrence) Between(dtstart, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	count := 0
+	// emit records one occurrence, reporting whether to carry on
+	emit := func(t time.Time) bool {
+		if !r.Until.IsZero() && t.After(r.Until) || !t.Before(to) {
+			return false
+		}
+		count++
+		if r.Count > 0 && count > r.Count {
+			return false
+		}
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return len(occurrences) < maxOccurrences
+	}
+	if !emit(dtstart) {
+		return occurrences
+	}
+
+	loc := dtstart.Location()
+	// Without COUNT, the periods before from can't change what comes after, so an
+	// old series starts near from rather than walking every period since DTSTART
+	first := 0
+	if r.Count == 0 && from.After(dtstart) {
+		first = r.periodsBefore(dtstart, from)
+	}
+	for period := first; period < first+maxPeriods; period++ {
+		start, candidates := r.period(dtstart, period)
+		if start.After(to) {
+			break
+		}
+		for _, t := range candidates {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !emit(t.In(loc)) {
+				return occurrences
+			}
+		}
+	}
+	return occurrences
+}
+
+// periodsBefore returns a number of periods after dtstart's that all end before
+// from, erring one period early
+func (r *Recurrence) periodsBefore(dtstart, from time.Time) int {
+	from = from.In(dtstart.Location())
+	days := int(civil(from).Sub(civil(dtstart)).Hours() / 24)
+	var elapsed int
+	switch r.Freq {
+	case freqMinutely:
+		elapsed = int(from.Sub(dtstart) / time.Minute)
+	case freqHourly:
+		elapsed = int(from.Sub(dtstart) / time.Hour)
+	case freqDaily:
+		elapsed = days
+	case freqWeekly:
+		elapsed = days / 7
+	case freqMonthly:
+		elapsed = (from.Year()-dtstart.Year())*12 + int(from.Month()-dtstart.Month())
+	case freqYearly:
+		elapsed = from.Year() - dtstart.Year()
+	}
+	return max(0, elapsed/r.Interval-1)
+}
+
+// period returns the start of the nth period after dtstart's and the candidate
+// occurrences in it, in order
+func (r *Recurrence) period(dtstart time.Time, n int) (time.Time, []time.Time) {
+	loc := dtstart.Location()
+	step := n * r.Interval
+	y, m, d := dtstart.Date()
+
+	var days []time.Time // noon UTC on each day of the period, so DST never shifts them
+	var start time.Time
+	switch r.Freq {
+	case freqMinutely, freqHourly:
+		unit := time.Minute
+		if r.Freq == freqHourly {
+			unit = time.Hour
+		}
+		t := dtstart.Add(time.Duration(step) * unit)
+		if !r.matchDay(civil(t), dtstart) || !contains(r.ByHour, t.Hour(), true) || !contains(r.ByMinute, t.Minute(), true) {
+			return t, nil
+		}
+		return t, []time.Time{t}
+	case freqDaily:
+		day := time.Date(y, m, d+step, 12, 0, 0, 0, time.UTC)
+		start, days = day, []time.Time{day}
+	case freqWeekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		first := time.Date(y, m, d-offset+7*step, 12, 0, 0, 0, time.UTC)
+		start = first
+		for i := 0; i < 7; i++ {
+			days = append(days, first.AddDate(0, 0, i))
+		}
+	case freqMonthly:
+		first := time.Date(y, m+time.Month(step), 1, 12, 0, 0, 0, time.UTC)
+		start = first
+		for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+	case freqYearly:
+		first := time.Date(y+step, 1, 1, 12, 0, 0, 0, time.UTC)
+		start = first
+		for day := first; day.Year() == first.Year(); day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+
+	hours := r.ByHour
+	if len(hours) == 0 {
+		hours = []int{dtstart.Hour()}
+	}
+	minutes := r.ByMinute
+	if len(minutes) == 0 {
+		minutes = []int{dtstart.Minute()}
+	}
+
+	var candidates []time.Time
+	for _, day := range days {
+		if !r.matchDay(day, dtstart) {
+			continue
+		}
+		for _, h := range hours {
+			for _, min := range minutes {
+				candidates = append(candidates, time.Date(day.Year(), day.Month(), day.Day(), h, min, dtstart.Second(), 0, loc))
+			}
+		}
+	}
+	return start, r.setPositions(candidates)
+}
+
+// civil is noon UTC on t's date, for day arithmetic that ignores DST
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, time.UTC)
+}
+
+// matchDay reports whether a day (noon UTC) is part of the rule. When the rule
+// names no days it repeats DTSTART's weekday, day of the month or date.
+func (r *Recurrence) matchDay(day, dtstart time.Time) bool {
+	if !contains(r.ByMonth, int(day.Month()), true) {
+		return false
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 12, 0, 0, 0, time.UTC).Day()
+	daysInYear := time.Date(day.Year(), 12, 31, 12, 0, 0, 0, time.UTC).YearDay()
+
+	if len(r.ByYearDay) > 0 && !matchNumber(r.ByYearDay, day.YearDay(), daysInYear) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !matchNumber(r.ByMonthDay, day.Day(), daysInMonth) {
+		return false
+	}
+	if len(r.ByWeekDay) > 0 && !r.matchWeekDay(day, daysInMonth, daysInYear) {
+		return false
+	}
+
+	if len(r.ByYearDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByWeekDay) == 0 {
+		switch r.Freq {
+		case freqWeekly:
+			return day.Weekday() == dtstart.Weekday()
+		case freqMonthly:
+			return day.Day() == dtstart.Day()
+		case freqYearly:
+			return day.Day() == dtstart.Day() && (len(r.ByMonth) > 0 || day.Month() == dtstart.Month())
+		}
+	}
+	return true
+}
+
+// matchWeekDay checks BYDAY. Numbered weekdays (2TU, -1FR) count within the month
+// for monthly rules and yearly rules with BYMONTH, otherwise within the year.
+func (r *Recurrence) matchWeekDay(day time.Time, daysInMonth, daysInYear int) bool {
+	for _, wd := range r.ByWeekDay {
+		if wd.Day != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0 || r.Freq != freqMonthly && r.Freq != freqYearly:
+			return true
+		case r.Freq == freqMonthly || len(r.ByMonth) > 0:
+			if wd.N == (day.Day()-1)/7+1 || wd.N == -((daysInMonth-day.Day())/7+1) {
+				return true
+			}
+		default:
+			if wd.N == (day.YearDay()-1)/7+1 || wd.N == -((daysInYear-day.YearDay())/7+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchNumber reports whether n is in values, where negative values count back
+// from last (-1 being last itself)
+func matchNumber(values []int, n, last int) bool {
+	for _, v := range values {
+		if v == n || v < 0 && last+v+1 == n {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether n is in values; an empty list matches when empty is set
+func contains(values []int, n int, empty bool) bool {
+	if len(values) == 0 {
+		return empty
+	}
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// setPositions applies BYSETPOS to a period's candidates
+func (r *Recurrence) setPositions(candidates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(candidates) == 0 {
+		return candidates
+	}
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(candidates) + pos
+		}
+		if i >= 0 && i < len(candidates) {
+			selected = append(selected, candidates[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	// Two positions can pick the same candidate
+	unique := selected[:0]
+	for i, t := range selected {
+		if i == 0 || !t.Equal(selected[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
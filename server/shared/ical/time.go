@@ -0,0 +1,112 @@
+package ical
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of DATE and DATE-TIME values
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// Time reads a DATE or DATE-TIME property such as DTSTART. Dates are midnight in
+// loc. Times in UTC keep UTC; times with a TZID are in that zone; floating times,
+// which have neither, are in loc.
+func (c *Calendar) Time(p Property, loc *time.Location) (t time.Time, allDay bool, err error) {
+	times, allDay, err := c.Times(p, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return times[0], allDay, nil
+}
+
+// Times reads every value of a property that may hold a list, such as EXDATE or
+// RDATE. Periods (start/end) are read as their start.
+func (c *Calendar) Times(p Property, loc *time.Location) (times []time.Time, allDay bool, err error) {
+	allDay = strings.EqualFold(p.Param("VALUE"), "DATE")
+	zone := loc
+	if tzid := p.Param("TZID"); tzid != "" && !allDay {
+		zone = c.Location(tzid, loc)
+	}
+
+	for _, value := range strings.Split(p.Value, ",") {
+		value, _, _ = strings.Cut(strings.TrimSpace(value), "/")
+		if value == "" {
+			continue
+		}
+		t, isDate, err := parseTime(value, allDay, zone, loc)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		// A bare date with no VALUE=DATE is still a date
+		allDay = allDay || isDate
+		times = append(times, t)
+	}
+	if len(times) == 0 {
+		return nil, false, fmt.Errorf("%s has no value", p.Name)
+	}
+	return times, allDay, nil
+}
+
+// parseTime reads one DATE or DATE-TIME value
+func parseTime(value string, isDate bool, zone, loc *time.Location) (time.Time, bool, error) {
+	if isDate || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, loc)
+		if err != nil {
+			return t, true, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+	if rest, ok := strings.CutSuffix(strings.ToUpper(value), "Z"); ok {
+		t, err := time.ParseInLocation(dateTimeLayout, rest, time.UTC)
+		if err != nil {
+			return t, false, fmt.Errorf("invalid time %q", value)
+		}
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, value, zone)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid time %q", value)
+	}
+	return t, false, nil
+}
+
+// Duration is a DURATION value. Days and weeks are kept apart from the exact
+// part because a day is not always 24 hours across a DST change.
+type Duration struct {
+	Days int
+	Time time.Duration
+}
+
+// Add returns t moved on by the duration
+func (d Duration) Add(t time.Time) time.Time {
+	return t.AddDate(0, 0, d.Days).Add(d.Time)
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration reads a DURATION value such as PT1H30M, P2D or -PT15M
+func ParseDuration(value string) (Duration, error) {
+	m := durationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return Duration{}, fmt.Errorf("invalid duration %q", value)
+	}
+	n := func(s string) int {
+		// Capped so huge values can't overflow into nonsense
+		v, _ := strconv.Atoi(s)
+		return min(v, 1_000_000)
+	}
+	d := Duration{
+		Days: n(m[2])*7 + n(m[3]),
+		Time: time.Duration(n(m[4]))*time.Hour + time.Duration(n(m[5]))*time.Minute + time.Duration(n(m[6]))*time.Second,
+	}
+	if m[1] == "-" {
+		d.Days, d.Time = -d.Days, -d.Time
+	}
+	return d, nil
+}
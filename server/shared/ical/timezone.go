@@ -0,0 +1,226 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zoneRulesEnd is where transitions built from VTIMEZONE rules stop; later times
+// keep the last offset. The 32-bit zone data format can't go past 2038.
+var zoneRulesEnd = time.Date(2037, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// maxZoneTransitions bounds the transitions built for one VTIMEZONE
+const maxZoneTransitions = 1000
+
+// Location resolves a TZID. IANA names are used directly, including ones with a
+// prefix such as /mozilla.org/20070129_1/Europe/London; other names, such as the
+// Windows ones Outlook writes, are built from the file's VTIMEZONE. Unknown
+// zones resolve to fallback.
+func (c *Calendar) Location(tzid string, fallback *time.Location) *time.Location {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	loc, ok := c.zones[tzid]
+	if !ok {
+		loc = loadIANAZone(tzid)
+		if loc == nil {
+			for _, vtimezone := range c.Children("VTIMEZONE") {
+				if p, _ := vtimezone.Get("TZID"); p.Value == tzid {
+					loc = buildZone(tzid, vtimezone)
+					break
+				}
+			}
+		}
+		c.zones[tzid] = loc
+	}
+	if loc == nil {
+		return fallback
+	}
+	return loc
+}
+
+// loadIANAZone loads tzid, or its longest IANA-looking suffix, from the system's
+// zone database
+func loadIANAZone(tzid string) *time.Location {
+	name := strings.Trim(strings.TrimSpace(tzid), "/")
+	if name == "" || strings.EqualFold(name, "Local") {
+		return nil
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		candidate := strings.Join(parts[i:], "/")
+		if candidate == "" || strings.EqualFold(candidate, "Local") {
+			continue
+		}
+		if loc, err := time.LoadLocation(candidate); err == nil {
+			return loc
+		}
+	}
+	return nil
+}
+
+// zoneTransition is the start of one STANDARD or DAYLIGHT observance
+type zoneTransition struct {
+	at     int64 // Unix time
+	offset int   // seconds east of UTC
+	dst    bool
+	name   string
+}
+
+// buildZone turns a VTIMEZONE's observances into a Location, or nil if it has
+// none that can be read
+func buildZone(tzid string, vtimezone *Component) *time.Location {
+	var transitions []zoneTransition
+	for _, observance := range vtimezone.Components {
+		if observance.Name != "STANDARD" && observance.Name != "DAYLIGHT" {
+			continue
+		}
+		from, errFrom := parseOffset(observance.Text("TZOFFSETFROM"))
+		to, errTo := parseOffset(observance.Text("TZOFFSETTO"))
+		dtstart, ok := observance.Get("DTSTART")
+		if errFrom != nil || errTo != nil || !ok {
+			continue
+		}
+		// Onsets are local times in the offset being left
+		start, _, err := parseTime(dtstart.Value, false, time.UTC, time.UTC)
+		if err != nil {
+			continue
+		}
+		onsets := []time.Time{start}
+		if p, ok := observance.Get("RRULE"); ok {
+			if rule, err := ParseRecurrence(p.Value, time.UTC); err == nil {
+				onsets = rule.Between(start, start, zoneRulesEnd)
+			}
+		}
+		for _, p := range observance.All("RDATE") {
+			for _, value := range strings.Split(p.Value, ",") {
+				if t, _, err := parseTime(strings.TrimSpace(value), false, time.UTC, time.UTC); err == nil {
+					onsets = append(onsets, t)
+				}
+			}
+		}
+
+		name := observance.Text("TZNAME")
+		for _, onset := range onsets {
+			if len(transitions) >= maxZoneTransitions {
+				break
+			}
+			transitions = append(transitions, zoneTransition{
+				at:     onset.Unix() - int64(from),
+				offset: to,
+				dst:    observance.Name == "DAYLIGHT",
+				name:   name,
+			})
+		}
+	}
+	if len(transitions) == 0 {
+		return nil
+	}
+	sort.Slice(transitions, func(i, j int) bool { return transitions[i].at < transitions[j].at })
+
+	loc, err := time.LoadLocationFromTZData(tzid, tzif(transitions))
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// parseOffset reads a UTC offset such as -0500 or +053000 as seconds
+func parseOffset(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if len(value) != 5 && len(value) != 7 || value[0] != '+' && value[0] != '-' {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	digits := value[1:] + "00"
+	h, errH := strconv.Atoi(digits[0:2])
+	m, errM := strconv.Atoi(digits[2:4])
+	s, errS := strconv.Atoi(digits[4:6])
+	if errH != nil || errM != nil || errS != nil || h > 23 || m > 59 || s > 59 {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	offset := h*3600 + m*60 + s
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// tzif encodes transitions as version 1 TZif data, the format of the system's
+// zone files, which is the only way to give a time.Location DST rules. Times
+// before the first transition use its offset.
+func tzif(transitions []zoneTransition) []byte {
+	type zoneType struct {
+		offset int
+		dst    bool
+		name   string
+	}
+	var types []zoneType
+	var abbrevs bytes.Buffer
+	abbrevIndex := make(map[string]int)
+	typeIndex := func(t zoneType) int {
+		for i, existing := range types {
+			if existing == t {
+				return i
+			}
+		}
+		if _, ok := abbrevIndex[t.name]; !ok {
+			abbrevIndex[t.name] = abbrevs.Len()
+			abbrevs.WriteString(t.name)
+			abbrevs.WriteByte(0)
+		}
+		types = append(types, t)
+		return len(types) - 1
+	}
+	zoneName := func(tr zoneTransition) string {
+		if tr.name != "" {
+			return tr.name
+		}
+		return formatOffset(tr.offset)
+	}
+
+	// The first type applies before the first transition
+	typeIndex(zoneType{transitions[0].offset, transitions[0].dst, zoneName(transitions[0])})
+	var times []int32
+	var indexes []byte
+	for _, tr := range transitions {
+		// The format holds 32-bit times and at most 256 types
+		if tr.at < -1<<31 || tr.at >= 1<<31 || len(types) >= 255 {
+			continue
+		}
+		times = append(times, int32(tr.at))
+		indexes = append(indexes, byte(typeIndex(zoneType{tr.offset, tr.dst, zoneName(tr)})))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("TZif")
+	buf.Write(make([]byte, 16)) // version 1 and reserved
+	for _, n := range []int{0, 0, 0, len(times), len(types), abbrevs.Len()} {
+		binary.Write(&buf, binary.BigEndian, uint32(n))
+	}
+	binary.Write(&buf, binary.BigEndian, times)
+	buf.Write(indexes)
+	for _, t := range types {
+		binary.Write(&buf, binary.BigEndian, int32(t.offset))
+		dst := byte(0)
+		if t.dst {
+			dst = 1
+		}
+		buf.Write([]byte{dst, byte(abbrevIndex[t.name])})
+	}
+	buf.Write(abbrevs.Bytes())
+	return buf.Bytes()
+}
+
+// formatOffset names a zone with no TZNAME by its offset, e.g. +0530
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
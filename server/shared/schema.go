@@ -0,0 +1,16 @@
+package shared
+
+// ConfigField describes a single key in a widget's config.json section
+type ConfigField struct {
+	Key         string
+	Type        string // "string", "number", "boolean", "array" or "object"
+	Required    bool
+	Description string
+}
+
+// ConfigSchema describes the config.json section a widget reads
+type ConfigSchema struct {
+	// Section is the widget "id" used in config.json (usually the widget's ID())
+	Section string
+	Fields  []ConfigField
+}
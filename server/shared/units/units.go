@@ -0,0 +1,288 @@
+// Package units converts and labels measurements in the units the dashboard is
+// set to show. Global config chooses the units and locale for every widget, and
+// a widget's config can override any of them with the same keys.
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"themancavedashboard/shared"
+)
+
+// Temperature units
+const (
+	Fahrenheit = "F"
+	Celsius    = "C"
+)
+
+// Distance units
+const (
+	Miles      = "mi"
+	Kilometers = "km"
+)
+
+// Speed units
+const (
+	MilesPerHour      = "mph"
+	KilometersPerHour = "km/h"
+	MetersPerSecond   = "m/s"
+	Knots             = "kn"
+)
+
+// Pressure units
+const (
+	InchesOfMercury      = "inHg"
+	Hectopascals         = "hPa"
+	MillimetersOfMercury = "mmHg"
+)
+
+// Rainfall units
+const (
+	Inches      = "in"
+	Millimeters = "mm"
+)
+
+// Setting keys, in the "global" section and in widget configs
+const (
+	TemperatureKey = "temperature_unit"
+	DistanceKey    = "distance_unit"
+	SpeedKey       = "speed_unit"
+	PressureKey    = "pressure_unit"
+	RainfallKey    = "rainfall_unit"
+	LocaleKey      = "locale"
+)
+
+// DefaultLocale is used when no locale is set
+const DefaultLocale = "en-US"
+
+// Units are the units a widget shows its values in, and the locale it formats
+// them for
+type Units struct {
+	Temperature string `json:"temperature"` // F or C
+	Distance    string `json:"distance"`    // mi or km
+	Speed       string `json:"speed"`       // mph, km/h, m/s or kn
+	Pressure    string `json:"pressure"`    // inHg, hPa or mmHg
+	Rainfall    string `json:"rainfall"`    // in or mm
+	Locale      string `json:"locale"`      // BCP 47 language tag, e.g. en-GB
+}
+
+// Defaults are the US customary units the dashboard has always shown
+var Defaults = Units{
+	Temperature: Fahrenheit,
+	Distance:    Miles,
+	Speed:       MilesPerHour,
+	Pressure:    InchesOfMercury,
+	Rainfall:    Inches,
+	Locale:      DefaultLocale,
+}
+
+// choices are the units each setting accepts
+var choices = map[string][]string{
+	TemperatureKey: {Fahrenheit, Celsius},
+	DistanceKey:    {Miles, Kilometers},
+	SpeedKey:       {MilesPerHour, KilometersPerHour, MetersPerSecond, Knots},
+	PressureKey:    {InchesOfMercury, Hectopascals, MillimetersOfMercury},
+	RainfallKey:    {Inches, Millimeters},
+}
+
+// localePattern matches language tags like en, en-GB or zh-Hant-TW
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// toBase converts each unit to the base unit of its kind: °C, km, m/s, hPa and
+// mm. Fahrenheit is offset by 32 before scaling.
+var toBase = map[string]struct {
+	kind  string
+	scale float64
+}{
+	Fahrenheit:           {TemperatureKey, 5.0 / 9},
+	Celsius:              {TemperatureKey, 1},
+	Miles:                {DistanceKey, 1.609344},
+	Kilometers:           {DistanceKey, 1},
+	MilesPerHour:         {SpeedKey, 0.44704},
+	KilometersPerHour:    {SpeedKey, 1 / 3.6},
+	MetersPerSecond:      {SpeedKey, 1},
+	Knots:                {SpeedKey, 1852.0 / 3600},
+	InchesOfMercury:      {PressureKey, 33.8638866667},
+	Hectopascals:         {PressureKey, 1},
+	MillimetersOfMercury: {PressureKey, 1.33322387415},
+	Inches:               {RainfallKey, 25.4},
+	Millimeters:          {RainfallKey, 1},
+}
+
+// aliases are the ways devices and APIs write each unit, in lower case
+var aliases = map[string][]string{
+	Fahrenheit:           {"f", "°f", "℉", "fahrenheit", "degf"},
+	Celsius:              {"c", "°c", "℃", "celsius", "degc"},
+	Miles:                {"mi", "miles"},
+	Kilometers:           {"km", "kilometers", "kilometres"},
+	MilesPerHour:         {"mph"},
+	KilometersPerHour:    {"km/h", "kmh", "kph"},
+	MetersPerSecond:      {"m/s", "ms"},
+	Knots:                {"kn", "kt", "knot", "knots"},
+	InchesOfMercury:      {"inhg"},
+	Hectopascals:         {"hpa", "mbar", "mb"},
+	MillimetersOfMercury: {"mmhg"},
+	Inches:               {"in", "inches"},
+	Millimeters:          {"mm"},
+}
+
+// Parse returns the unit written as s, accepting symbols like ℉ and names like
+// "celsius" in any case
+func Parse(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for unit, names := range aliases {
+		if slices.Contains(names, s) {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+// Validate checks the value of a unit or locale setting. Other keys are accepted.
+func Validate(key, value string) error {
+	if key == LocaleKey {
+		if !localePattern.MatchString(value) {
+			return fmt.Errorf("expected a language tag like en-US, got %q", value)
+		}
+		return nil
+	}
+	allowed, ok := choices[key]
+	if !ok {
+		return nil
+	}
+	if slices.Contains(allowed, value) {
+		return nil
+	}
+	return fmt.Errorf("expected one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// ConfigFields describe the per-widget overrides, for a widget's ConfigSchema.
+// Only the keys of the given settings are included, along with the locale.
+func ConfigFields(keys ...string) []shared.ConfigField {
+	fields := make([]shared.ConfigField, 0, len(keys)+1)
+	for _, key := range keys {
+		fields = append(fields, shared.ConfigField{
+			Key:         key,
+			Type:        "string",
+			Description: fmt.Sprintf("Overrides the global %s: %s", key, strings.Join(choices[key], ", ")),
+		})
+	}
+	return append(fields, shared.ConfigField{
+		Key:         LocaleKey,
+		Type:        "string",
+		Description: "Overrides the global locale, e.g. en-GB",
+	})
+}
+
+// For returns the units a widget shows: its own config's settings, then the
+// global ones, then Defaults. Invalid settings are skipped.
+func For(widgetID string) Units {
+	return resolve(shared.GetGlobalConfig(), func(key string) string {
+		return shared.GetWidgetConfigValue(widgetID, key, "")
+	})
+}
+
+// resolve picks each setting from a widget's config, read through widget, then
+// from global, then from Defaults
+func resolve(global shared.GlobalConfig, widget func(key string) string) Units {
+	pick := func(key, globalValue, fallback string) string {
+		for _, value := range []string{widget(key), globalValue} {
+			if value != "" && Validate(key, value) == nil {
+				return value
+			}
+		}
+		return fallback
+	}
+	return Units{
+		Temperature: pick(TemperatureKey, global.TemperatureUnit, Defaults.Temperature),
+		Distance:    pick(DistanceKey, global.DistanceUnit, Defaults.Distance),
+		Speed:       pick(SpeedKey, global.SpeedUnit, Defaults.Speed),
+		Pressure:    pick(PressureKey, global.PressureUnit, Defaults.Pressure),
+		Rainfall:    pick(RainfallKey, global.RainfallUnit, Defaults.Rainfall),
+		Locale:      pick(LocaleKey, global.Locale, Defaults.Locale),
+	}
+}
+
+// Convert converts a value between two units of the same kind. Values are
+// returned unchanged when either unit is unknown or the kinds differ.
+func Convert(value float64, from, to string) float64 {
+	source, ok := toBase[from]
+	dest, ok2 := toBase[to]
+	if from == to || !ok || !ok2 || source.kind != dest.kind {
+		return value
+	}
+	if from == Fahrenheit {
+		value -= 32
+	}
+	value = value * source.scale / dest.scale
+	if to == Fahrenheit {
+		value += 32
+	}
+	return value
+}
+
+// ConvertTemperature converts a temperature into u's unit
+func (u Units) ConvertTemperature(value float64, from string) float64 {
+	return Convert(value, from, u.Temperature)
+}
+
+// ConvertDistance converts a distance into u's unit
+func (u Units) ConvertDistance(value float64, from string) float64 {
+	return Convert(value, from, u.Distance)
+}
+
+// ConvertSpeed converts a speed into u's unit
+func (u Units) ConvertSpeed(value float64, from string) float64 {
+	return Convert(value, from, u.Speed)
+}
+
+// ConvertPressure converts a pressure into u's unit
+func (u Units) ConvertPressure(value float64, from string) float64 {
+	return Convert(value, from, u.Pressure)
+}
+
+// ConvertRainfall converts an amount of rain into u's unit
+func (u Units) ConvertRainfall(value float64, from string) float64 {
+	return Convert(value, from, u.Rainfall)
+}
+
+// Decimals is how many decimals a value in the unit is usually shown with
+func Decimals(unit string) int {
+	switch unit {
+	case Fahrenheit, Celsius, Miles, Kilometers, MilesPerHour, KilometersPerHour, Knots, MillimetersOfMercury:
+		return 0
+	case InchesOfMercury, Inches:
+		return 2
+	}
+	return 1
+}
+
+// Round rounds a value to the decimals usual for its unit
+func Round(value float64, unit string) float64 {
+	scale := math.Pow(10, float64(Decimals(unit)))
+	return math.Round(value*scale) / scale
+}
+
+// Symbol is how a unit is written after a value, e.g. °F
+func Symbol(unit string) string {
+	switch unit {
+	case Fahrenheit, Celsius:
+		return "°" + unit
+	}
+	return unit
+}
+
+// Label writes a value rounded for its unit, followed by the unit, e.g. "72°F"
+// or "12 mph"
+func Label(value float64, unit string) string {
+	number := strconv.FormatFloat(Round(value, unit), 'f', Decimals(unit), 64)
+	if unit == Fahrenheit || unit == Celsius {
+		return number + Symbol(unit)
+	}
+	return number + " " + unit
+}
@@ -0,0 +1,112 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"themancavedashboard/shared"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{32, Fahrenheit, Celsius, 0},
+		{212, Fahrenheit, Celsius, 100},
+		{-40, Celsius, Fahrenheit, -40},
+		{20, Celsius, Fahrenheit, 68},
+		{29.92, InchesOfMercury, Hectopascals, 1013.21},
+		{1013.25, Hectopascals, InchesOfMercury, 29.921},
+		{760, MillimetersOfMercury, Hectopascals, 1013.25},
+		{29.92, InchesOfMercury, MillimetersOfMercury, 759.97},
+		{60, MilesPerHour, KilometersPerHour, 96.561},
+		{100, KilometersPerHour, Knots, 53.996},
+		{10, Knots, MilesPerHour, 11.508},
+		{10, MetersPerSecond, KilometersPerHour, 36},
+		{1, Inches, Millimeters, 25.4},
+		{1, Miles, Kilometers, 1.609},
+		// Left alone: same unit, other kinds and unknown units
+		{72, Fahrenheit, Fahrenheit, 72},
+		{72, Fahrenheit, Hectopascals, 72},
+		{72, "K", Celsius, 72},
+	}
+	for _, tt := range tests {
+		if got := Convert(tt.value, tt.from, tt.to); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"°F", Fahrenheit, true},
+		{"℃", Celsius, true},
+		{" Celsius ", Celsius, true},
+		{"degF", Fahrenheit, true},
+		{"KPH", KilometersPerHour, true},
+		{"kt", Knots, true},
+		{"m/s", MetersPerSecond, true},
+		{"mbar", Hectopascals, true},
+		{"inHg", InchesOfMercury, true},
+		{"mmHg", MillimetersOfMercury, true},
+		{"kilometres", Kilometers, true},
+		{"inches", Inches, true},
+		{"kelvin", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := Parse(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := shared.GlobalConfig{TemperatureUnit: Celsius, SpeedUnit: KilometersPerHour, PressureUnit: "bars", Locale: "en-GB"}
+	widget := map[string]string{
+		TemperatureKey: Fahrenheit,   // overrides the global unit
+		SpeedKey:       "furlongs",   // invalid, so the global unit is used
+		PressureKey:    Hectopascals, // overrides an invalid global unit
+		LocaleKey:      "not a tag!", // invalid, so the global locale is used
+	}
+	got := resolve(global, func(key string) string { return widget[key] })
+	want := Units{
+		Temperature: Fahrenheit,
+		Distance:    Defaults.Distance, // set nowhere
+		Speed:       KilometersPerHour,
+		Pressure:    Hectopascals,
+		Rainfall:    Defaults.Rainfall,
+		Locale:      "en-GB",
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got := resolve(shared.GlobalConfig{}, func(string) string { return "" }); got != Defaults {
+		t.Errorf("with nothing set got %+v, want Defaults %+v", got, Defaults)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		value float64
+		unit  string
+		want  string
+	}{
+		{72.4, Fahrenheit, "72°F"},
+		{29.921, InchesOfMercury, "29.92 inHg"},
+		{1013.25, Hectopascals, "1013.3 hPa"},
+		{12.6, MilesPerHour, "13 mph"},
+	}
+	for _, tt := range tests {
+		if got := Label(tt.value, tt.unit); got != tt.want {
+			t.Errorf("Label(%v, %s) = %q, want %q", tt.value, tt.unit, got, tt.want)
+		}
+	}
+}
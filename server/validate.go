@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/units"
+	"themancavedashboard/widgets"
+)
+
+// configIssue is a single problem found in config.json
+type configIssue struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+	Warning bool
+}
+
+func (i configIssue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	if i.Path == "" {
+		return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, level, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s: %s", i.Line, i.Column, level, i.Path, i.Message)
+}
+
+var clockPattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+// runValidate handles `validate [-config path]`
+func runValidate(args []string) int {
+	fs, configPath := newFlagSet("validate")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+
+	issues := validateConfig(data, widgets.GetSchemas())
+	errorCount := 0
+	for _, issue := range issues {
+		fmt.Printf("%s:%s\n", *configPath, issue)
+		if !issue.Warning {
+			errorCount++
+		}
+	}
+
+	if errorCount > 0 {
+		fmt.Printf("%d error(s), %d warning(s)\n", errorCount, len(issues)-errorCount)
+		return 1
+	}
+	fmt.Printf("%s is valid (%d warning(s))\n", *configPath, len(issues))
+	return 0
+}
+
+// validateConfig checks raw config.json data against the dashboard structure
+// and the widget schemas. Issues are returned in file order.
+func validateConfig(data []byte, schemas map[string]shared.ConfigSchema) []configIssue {
+	positions, err := jsonPositions(data)
+	if err != nil {
+		offset := int64(len(data))
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		line, col := lineColumn(data, offset)
+		return []configIssue{{Line: line, Column: col, Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	v := &configValidator{data: data, positions: positions}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		v.errorf("", "top level must be an object")
+		return v.issues
+	}
+
+	for key := range root {
+		if key != "global" && key != "widgets" {
+			v.warnf(key, "unknown top-level key (run `migrate` to upgrade older config files)")
+		}
+	}
+
+	if global, ok := root["global"]; ok {
+		v.checkGlobal(global)
+	} else {
+		v.warnf("", `missing "global" section, defaults will be used`)
+	}
+
+	widgetList, ok := root["widgets"].([]interface{})
+	if !ok {
+		if _, present := root["widgets"]; present {
+			v.errorf("widgets", "expected array, got %s", jsonType(root["widgets"]))
+		} else {
+			v.warnf("", `missing "widgets" array, the dashboard will be empty`)
+		}
+	}
+
+	seen := make(map[string]string)
+	for i, entry := range widgetList {
+		v.checkWidget(fmt.Sprintf("widgets[%d]", i), entry, schemas, seen)
+	}
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		if v.issues[i].Line != v.issues[j].Line {
+			return v.issues[i].Line < v.issues[j].Line
+		}
+		return v.issues[i].Column < v.issues[j].Column
+	})
+	return v.issues
+}
+
+// configValidator collects issues with their source positions
+type configValidator struct {
+	data      []byte
+	positions map[string]int64
+	issues    []configIssue
+}
+
+func (v *configValidator) add(path string, warning bool, format string, args ...interface{}) {
+	// Fall back to the closest parent that has a recorded position
+	offset, ok := v.positions[path]
+	for parent := path; !ok && parent != ""; {
+		parent = parentPath(parent)
+		offset, ok = v.positions[parent]
+	}
+	line, col := lineColumn(v.data, offset)
+	v.issues = append(v.issues, configIssue{
+		Path:    path,
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+func (v *configValidator) errorf(path, format string, args ...interface{}) {
+	v.add(path, false, format, args...)
+}
+
+func (v *configValidator) warnf(path, format string, args ...interface{}) {
+	v.add(path, true, format, args...)
+}
+
+// checkGlobal validates the "global" section against GlobalConfig's fields
+func (v *configValidator) checkGlobal(value interface{}) {
+	global, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf("global", "expected object, got %s", jsonType(value))
+		return
+	}
+
+	fields := make(map[string]string)
+	t := reflect.TypeOf(GlobalConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = kindToJSONType(t.Field(i).Type.Kind())
+	}
+
+	for key, val := range global {
+		path := "global." + key
+		expected, known := fields[key]
+		if !known {
+			v.warnf(path, "unknown global setting")
+			continue
+		}
+		if !matchesType(val, expected) {
+			v.errorf(path, "expected %s, got %s", expected, jsonType(val))
+			continue
+		}
+
+		// Empty values fall back to the defaults applied in loadConfig
+		if val == "" {
+			continue
+		}
+
+		switch key {
+		case "timezone":
+			if _, err := time.LoadLocation(val.(string)); err != nil {
+				v.errorf(path, "unknown timezone %q", val)
+			}
+		case "night_mode_start", "night_mode_end":
+			if !clockPattern.MatchString(val.(string)) {
+				v.errorf(path, "expected HH:MM, got %q", val)
+			}
+		case units.TemperatureKey, units.DistanceKey, units.SpeedKey, units.PressureKey, units.RainfallKey, units.LocaleKey:
+			if err := units.Validate(key, val.(string)); err != nil {
+				v.errorf(path, "%v", err)
+			}
+		default:
+			if n, ok := val.(float64); ok && n < 0 {
+				v.errorf(path, "must not be negative")
+			}
+		}
+	}
+}
+
+// checkWidget validates one entry of the "widgets" array
+func (v *configValidator) checkWidget(path string, value interface{}, schemas map[string]shared.ConfigSchema, seen map[string]string) {
+	widget, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "expected object, got %s", jsonType(value))
+		return
+	}
+
+	id, ok := widget["id"].(string)
+	if !ok || id == "" {
+		v.errorf(path+".id", "widget id is required")
+		return
+	}
+	if previous, dup := seen[id]; dup {
+		v.warnf(path+".id", "duplicate widget %q (also at %s), only the first config is used", id, previous)
+	}
+	seen[id] = path
+
+	for key := range widget {
+		if key != "id" && key != "location" && key != "config" {
+			v.warnf(path+"."+key, "unknown widget key")
+		}
+	}
+
+	if location, ok := widget["location"].(map[string]interface{}); ok {
+		for _, key := range []string{"x", "y", "width", "height"} {
+			val, present := location[key]
+			if !present {
+				if key == "x" || key == "y" {
+					v.errorf(path+".location", "missing %q", key)
+				}
+				continue
+			}
+			n, isNumber := val.(float64)
+			if !isNumber || n != float64(int(n)) || n < 0 {
+				v.errorf(path+".location."+key, "expected non-negative integer, got %v", val)
+			}
+		}
+	} else {
+		v.errorf(path+".location", "location object with x and y is required")
+	}
+
+	schema, known := schemas[id]
+	if !known {
+		v.warnf(path+".id", "no backend widget reads config section %q", id)
+	}
+
+	config, present := widget["config"]
+	if !present {
+		config = map[string]interface{}{}
+	}
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		v.errorf(path+".config", "expected object, got %s", jsonType(config))
+		return
+	}
+	if !known {
+		return
+	}
+
+	fields := make(map[string]shared.ConfigField, len(schema.Fields))
+	for _, field := range schema.Fields {
+		fields[field.Key] = field
+		val, present := configMap[field.Key]
+		if !present {
+			if field.Required {
+				v.errorf(path+".config", "missing required %q (%s)", field.Key, field.Description)
+			}
+			continue
+		}
+		if !matchesType(val, field.Type) {
+			v.errorf(path+".config."+field.Key, "expected %s, got %s", field.Type, jsonType(val))
+		} else if text, ok := val.(string); ok && text != "" {
+			// Unit and locale overrides take the same values as the global settings
+			if err := units.Validate(field.Key, text); err != nil {
+				v.errorf(path+".config."+field.Key, "%v", err)
+			}
+		}
+	}
+	for key := range configMap {
+		if _, ok := fields[key]; !ok {
+			v.warnf(path+".config."+key, "unknown %s setting", id)
+		}
+	}
+}
+
+// jsonPositions walks the document and records the byte offset of every value by path
+// (e.g. "widgets[1].config.latitude")
+func jsonPositions(data []byte) (map[string]int64, error) {
+	positions := make(map[string]int64)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := walkJSON(dec, data, "", positions); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after top-level value")
+		}
+		return nil, err
+	}
+	return positions, nil
+}
+
+func walkJSON(dec *json.Decoder, data []byte, path string, positions map[string]int64) error {
+	positions[path] = skipSeparators(data, dec.InputOffset())
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if err := walkJSON(dec, data, child, positions); err != nil {
+				return err
+			}
+			// Point object members at their key rather than their value
+			positions[child] = keyOffset(data, positions[child])
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := walkJSON(dec, data, fmt.Sprintf("%s[%d]", path, i), positions); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+// skipSeparators advances past whitespace, commas and colons
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// keyOffset walks back from a value's offset to the start of its quoted key
+func keyOffset(data []byte, valueOffset int64) int64 {
+	i := valueOffset - 1
+	for i >= 0 && strings.IndexByte(" \t\r\n:", data[i]) >= 0 {
+		i--
+	}
+	if i < 0 || data[i] != '"' {
+		return valueOffset
+	}
+	for i--; i >= 0; i-- {
+		if data[i] == '"' && (i == 0 || data[i-1] != '\\') {
+			return i
+		}
+	}
+	return valueOffset
+}
+
+// lineColumn converts a byte offset to a 1-based line and column
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+func parentPath(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func matchesType(value interface{}, expected string) bool {
+	if expected == "" {
+		return true
+	}
+	return jsonType(value) == expected
+}
+
+func kindToJSONType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package calendar
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxCalDAVBytes bounds a calendar-query response
+const maxCalDAVBytes = 20 << 20
+
+// caldavSource reads one calendar collection from a CalDAV server such as iCloud,
+// Nextcloud or Fastmail
+// (https://cloud.example.com/remote.php/dav/calendars/<user>/personal/)
+type caldavSource struct {
+	url      string
+	username string
+	password string
+}
+
+func newCalDAVSource(rawURL, username, password string) *caldavSource {
+	return &caldavSource{url: strings.TrimSuffix(rawURL, "/") + "/", username: username, password: password}
+}
+
+func (s *caldavSource) Name() string {
+	if u, err := url.Parse(s.url); err == nil {
+		return "CalDAV " + u.Redacted()
+	}
+	return "CalDAV calendar"
+}
+
+// calendarQuery asks for the events overlapping a time range, with recurring
+// events expanded into their occurrences by the server
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data>
+      <c:expand start="%[1]s" end="%[2]s"/>
+    </c:calendar-data>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%[1]s" end="%[2]s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+// calendarMultistatus is the calendar-query response
+type calendarMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				CalendarData string `xml:"calendar-data"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (s *caldavSource) Events(ctx context.Context, start, end time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	const utc = "20060102T150405Z"
+	body := fmt.Sprintf(calendarQuery, start.UTC().Format(utc), end.UTC().Format(utc))
+
+	req, err := http.NewRequestWithContext(ctx, "REPORT", s.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := calendarHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("REPORT returned %s", resp.Status)
+	}
+
+	var status calendarMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxCalDAVBytes)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid calendar-query response: %w", err)
+	}
+
+	var events []CalendarEvent
+	for _, response := range status.Responses {
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") || propstat.Prop.CalendarData == "" {
+				continue
+			}
+			found, err := icalCalendarEvents(propstat.Prop.CalendarData, start, end, loc)
+			if err != nil {
+				log.Printf("[Calendar] Skipping %s in %s: %v", response.Href, s.Name(), err)
+				continue
+			}
+			events = append(events, found...)
+		}
+	}
+	return events, nil
+}
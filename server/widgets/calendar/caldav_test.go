@@ -0,0 +1,250 @@
+package calendar
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendarQueryRequest is the part of a calendar-query REPORT the stand-in checks
+type calendarQueryRequest struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	Prop    struct {
+		ETag         *struct{} `xml:"DAV: getetag"`
+		CalendarData struct {
+			Expand struct {
+				Start string `xml:"start,attr"`
+				End   string `xml:"end,attr"`
+			} `xml:"urn:ietf:params:xml:ns:caldav expand"`
+		} `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	} `xml:"DAV: prop"`
+	Filter struct {
+		Calendar struct {
+			Name  string `xml:"name,attr"`
+			Event struct {
+				Name      string `xml:"name,attr"`
+				TimeRange struct {
+					Start string `xml:"start,attr"`
+					End   string `xml:"end,attr"`
+				} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+			} `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+		} `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// caldavReport is a calendar-query response as Nextcloud sends it: a one-off event
+// in another time zone, a weekly event expanded into its occurrences, an event the
+// server returned although it is out of range, a calendar object that can't be
+// read and one the server couldn't return
+const caldavReport = `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+ <d:response>
+  <d:href>/remote.php/dav/calendars/me/family/dentist.ics</d:href>
+  <d:propstat>
+   <d:prop>
+    <d:getetag>"a1"</d:getetag>
+    <cal:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Nextcloud//EN
+BEGIN:VTIMEZONE
+TZID:America/New_York
+BEGIN:DAYLIGHT
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+DTSTART:20070311T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+DTSTART:20071104T020000
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:dentist@example.com
+DTSTART;TZID=America/New_York:20240703T100000
+DTEND;TZID=America/New_York:20240703T110000
+SUMMARY:Dentist
+LOCATION:12 Main St\, Suite 2
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+   </d:prop>
+   <d:status>HTTP/1.1 200 OK</d:status>
+  </d:propstat>
+ </d:response>
+ <d:response>
+  <d:href>/remote.php/dav/calendars/me/family/swim.ics</d:href>
+  <d:propstat>
+   <d:prop>
+    <d:getetag>"b2"</d:getetag>
+    <cal:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:swim@example.com
+RECURRENCE-ID:20240702T230000Z
+DTSTART:20240702T230000Z
+DTEND:20240703T000000Z
+SUMMARY:Swim practice
+END:VEVENT
+BEGIN:VEVENT
+UID:swim@example.com
+RECURRENCE-ID:20240709T230000Z
+DTSTART:20240709T230000Z
+DTEND:20240710T000000Z
+SUMMARY:Swim practice
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+   </d:prop>
+   <d:status>HTTP/1.1 200 OK</d:status>
+  </d:propstat>
+ </d:response>
+ <d:response>
+  <d:href>/remote.php/dav/calendars/me/family/camp.ics</d:href>
+  <d:propstat>
+   <d:prop>
+    <cal:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:camp@example.com
+DTSTART;VALUE=DATE:20240705
+DTEND;VALUE=DATE:20240707
+SUMMARY:Camping
+END:VEVENT
+BEGIN:VEVENT
+UID:old@example.com
+DTSTART;VALUE=DATE:20240601
+SUMMARY:Out of range
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+   </d:prop>
+   <d:status>HTTP/1.1 200 OK</d:status>
+  </d:propstat>
+ </d:response>
+ <d:response>
+  <d:href>/remote.php/dav/calendars/me/family/broken.ics</d:href>
+  <d:propstat>
+   <d:prop><cal:calendar-data>not a calendar</cal:calendar-data></d:prop>
+   <d:status>HTTP/1.1 200 OK</d:status>
+  </d:propstat>
+ </d:response>
+ <d:response>
+  <d:href>/remote.php/dav/calendars/me/family/private.ics</d:href>
+  <d:propstat>
+   <d:prop><cal:calendar-data/></d:prop>
+   <d:status>HTTP/1.1 403 Forbidden</d:status>
+  </d:propstat>
+ </d:response>
+</d:multistatus>`
+
+func TestCalDAVEvents(t *testing.T) {
+	var query calendarQueryRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "REPORT" || r.URL.Path != "/remote.php/dav/calendars/me/family/" {
+			t.Errorf("got %s %s, want REPORT of the calendar collection", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if depth := r.Header.Get("Depth"); depth != "1" {
+			t.Errorf("Depth = %q, want 1", depth)
+		}
+		if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/xml") {
+			t.Errorf("Content-Type = %q, want application/xml", contentType)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := xml.Unmarshal(body, &query); err != nil {
+			t.Errorf("request body is not a calendar-query: %v\n%s", err, body)
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, caldavReport)
+	}))
+	defer server.Close()
+
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2024, 7, 8, 0, 0, 0, 0, loc)
+
+	source := newCalDAVSource(server.URL+"/remote.php/dav/calendars/me/family", "me", "app-password")
+	events, err := source.Events(context.Background(), start, end, loc)
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+
+	// The range is sent in UTC, both to filter and to expand recurring events
+	if query.Prop.ETag == nil {
+		t.Error("calendar-query doesn't ask for getetag")
+	}
+	if got := query.Prop.CalendarData.Expand; got.Start != "20240701T050000Z" || got.End != "20240708T050000Z" {
+		t.Errorf("expand = %s to %s, want 20240701T050000Z to 20240708T050000Z", got.Start, got.End)
+	}
+	filter := query.Filter.Calendar
+	if filter.Name != "VCALENDAR" || filter.Event.Name != "VEVENT" {
+		t.Errorf("comp-filter = %s/%s, want VCALENDAR/VEVENT", filter.Name, filter.Event.Name)
+	}
+	if got := filter.Event.TimeRange; got.Start != "20240701T050000Z" || got.End != "20240708T050000Z" {
+		t.Errorf("time-range = %s to %s, want 20240701T050000Z to 20240708T050000Z", got.Start, got.End)
+	}
+
+	want := []CalendarEvent{
+		{ID: "swim@example.com_20240702T230000Z", UID: "swim@example.com", Title: "Swim practice",
+			Start: "2024-07-02T18:00:00-05:00", End: "2024-07-02T19:00:00-05:00"},
+		{ID: "dentist@example.com", UID: "dentist@example.com", Title: "Dentist",
+			Start: "2024-07-03T09:00:00-05:00", End: "2024-07-03T10:00:00-05:00", Location: "12 Main St, Suite 2"},
+		{ID: "camp@example.com", UID: "camp@example.com", Title: "Camping",
+			Start: "2024-07-05", End: "2024-07-07", AllDay: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Events returned %d events, want %d: %+v", len(events), len(want), events)
+	}
+	// Each calendar object is read on its own, so events are matched by start
+	byStart := map[string]CalendarEvent{}
+	for _, event := range events {
+		byStart[event.Start] = event
+	}
+	for _, w := range want {
+		got, ok := byStart[w.Start]
+		if !ok {
+			t.Errorf("no event starting %s (%s)", w.Start, w.Title)
+			continue
+		}
+		if got.ID != w.ID || got.UID != w.UID || got.Title != w.Title || got.End != w.End || got.AllDay != w.AllDay || got.Location != w.Location {
+			t.Errorf("event = %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestCalDAVEventsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Servers without CalDAV answer REPORT with a plain 200
+		io.WriteString(w, "<html>Not a calendar</html>")
+	}))
+	defer server.Close()
+
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	for _, password := range []string{"wrong", "app-password"} {
+		source := newCalDAVSource(server.URL+"/cal/", "me", password)
+		if _, err := source.Events(context.Background(), start, end, time.UTC); err == nil {
+			t.Errorf("Events with password %q succeeded", password)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// eventCacheTTL is how long fetched events are reused for the same range
+const eventCacheTTL = 5 * time.Minute
+
+// maxCachedRanges bounds the event cache; the oldest range is dropped first
+const maxCachedRanges = 16
+
+// maxEventRange is the longest range /api/calendar/events returns
+const maxEventRange = 366 * 24 * time.Hour
+
+// Bounds of the agenda's days parameter
+const (
+	defaultAgendaDays = 7
+	maxAgendaDays     = 62
+)
+
+// AgendaDay is one day of /api/calendar/agenda
+type AgendaDay struct {
+	Date   string        `json:"date"` // YYYY-MM-DD in the dashboard timezone
+	Events []AgendaEvent `json:"events"`
+}
+
+// AgendaEvent is an event on one day of the agenda
+type AgendaEvent struct {
+	CalendarEvent
+	Continued bool `json:"continued"` // started on an earlier day
+	Continues bool `json:"continues"` // goes on to a later day
+}
+
+// eventCache keeps recently fetched events by range, so several widgets and
+// page reloads don't each call Google
+type eventCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedEvents
+}
+
+type cachedEvents struct {
+	events  []CalendarEvent
+	fetched time.Time
+}
+
+func (c *eventCache) get(key string) ([]CalendarEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.fetched) > eventCacheTTL {
+		return nil, false
+	}
+	return entry.events, true
+}
+
+func (c *eventCache) put(key string, events []CalendarEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedEvents)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedRanges {
+		oldest := ""
+		for k, entry := range c.entries {
+			if oldest == "" || entry.fetched.Before(c.entries[oldest].fetched) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = cachedEvents{events: events, fetched: time.Now()}
+}
+
+// clear drops every cached range, e.g. after connecting another account
+func (c *eventCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// eventsBetween returns the events of the configured calendars overlapping start
+// to end, each with the days it covers in loc. Results are cached per range
+// unless a calendar failed to load.
+func (w *CalendarWidget) eventsBetween(ctx context.Context, start, end time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	calendars := configuredCalendars()
+	// The calendars config is part of the key so edits show up straight away
+	key := fmt.Sprintf("%d|%d|%s|%v", start.Unix(), end.Unix(), loc, calendars)
+	if events, ok := w.events.get(key); ok {
+		return events, nil
+	}
+
+	events, complete, err := w.fetchCalendars(ctx, calendars, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []CalendarEvent{}
+	}
+	for i := range events {
+		events[i].Days = eventDays(events[i], loc, start, end)
+	}
+	if complete {
+		w.events.put(key, events)
+	}
+	return events, nil
+}
+
+// eventSpan returns when an event starts and ends in loc. All-day events run
+// from midnight to midnight, their end date being the day after the last.
+func eventSpan(event CalendarEvent, loc *time.Location) (time.Time, time.Time, error) {
+	layout := time.RFC3339
+	if event.AllDay {
+		layout = "2006-01-02"
+	}
+	start, err := time.ParseInLocation(layout, event.Start, loc)
+	if err != nil {
+		return start, start, err
+	}
+	end, err := time.ParseInLocation(layout, event.End, loc)
+	if err != nil || !end.After(start) {
+		// An event with no length still shows on its day
+		end = start
+	}
+	return start.In(loc), end.In(loc), nil
+}
+
+// eventDays lists the dates (YYYY-MM-DD in loc) an event covers between from
+// and to; zero bounds leave that side open. An event ending at midnight doesn't
+// reach the next day.
+func eventDays(event CalendarEvent, loc *time.Location, from, to time.Time) []string {
+	start, end, err := eventSpan(event, loc)
+	if err != nil {
+		return nil
+	}
+	last := end
+	if end.After(start) {
+		last = end.Add(-time.Nanosecond)
+	}
+
+	var days []string
+	lastDay := dayStart(last, loc)
+	for day := dayStart(start, loc); !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		if !to.IsZero() && !day.Before(to) {
+			break
+		}
+		if from.IsZero() || day.AddDate(0, 0, 1).After(from) {
+			days = append(days, day.Format("2006-01-02"))
+		}
+	}
+	return days
+}
+
+// dayStart is midnight at the start of t's day in loc
+func dayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// parseEventRange reads the start and end query parameters: dates (midnight in
+// loc) or RFC 3339 times. Without them it is the current month; with only one,
+// the month from or up to it.
+func parseEventRange(query url.Values, loc *time.Location) (time.Time, time.Time, error) {
+	parse := func(name string) (time.Time, error) {
+		value := query.Get(name)
+		if value == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.In(loc), nil
+		}
+		return time.Time{}, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 time", name)
+	}
+
+	start, err := parse("start")
+	if err != nil {
+		return start, start, err
+	}
+	end, err := parse("end")
+	if err != nil {
+		return start, end, err
+	}
+
+	switch {
+	case start.IsZero() && end.IsZero():
+		now := time.Now().In(loc)
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(0, 1, 0)
+	case end.IsZero():
+		end = start.AddDate(0, 1, 0)
+	case start.IsZero():
+		start = end.AddDate(0, -1, 0)
+	}
+
+	if !end.After(start) {
+		return start, end, fmt.Errorf("end must be after start")
+	}
+	if end.Sub(start) > maxEventRange {
+		return start, end, fmt.Errorf("range can be at most 366 days")
+	}
+	return start, end, nil
+}
+
+// getAgenda handles GET /api/calendar/agenda
+func (w *CalendarWidget) getAgenda(rw http.ResponseWriter, r *http.Request) {
+	days := defaultAgendaDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxAgendaDays {
+			shared.WriteError(rw, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxAgendaDays))
+			return
+		}
+		days = n
+	}
+
+	loc := shared.GetLocation()
+	start := dayStart(time.Now(), loc)
+	end := start.AddDate(0, 0, days)
+
+	events, err := w.eventsBetween(r.Context(), start, end, loc)
+	if err != nil {
+		w.writeGoogleError(rw, fmt.Errorf("Failed to fetch calendar events: %w", err))
+		return
+	}
+
+	agenda := make([]AgendaDay, days)
+	index := make(map[string]int, days)
+	for i := range agenda {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		agenda[i] = AgendaDay{Date: date, Events: []AgendaEvent{}}
+		index[date] = i
+	}
+
+	for _, event := range events {
+		// Days are worked out over the whole event, not just the agenda's range,
+		// so an event begun yesterday still shows as continued
+		all := eventDays(event, loc, time.Time{}, time.Time{})
+		if len(all) == 0 {
+			continue
+		}
+		for _, date := range all {
+			i, ok := index[date]
+			if !ok {
+				continue
+			}
+			agenda[i].Events = append(agenda[i].Events, AgendaEvent{
+				CalendarEvent: event,
+				Continued:     date != all[0],
+				Continues:     date != all[len(all)-1],
+			})
+		}
+	}
+
+	// All-day and continuing events head each day, then timed events by start
+	for _, day := range agenda {
+		sort.SliceStable(day.Events, func(i, j int) bool {
+			a, b := day.Events[i], day.Events[j]
+			aWhole, bWhole := a.AllDay || a.Continued, b.AllDay || b.Continued
+			if aWhole != bWhole {
+				return aWhole
+			}
+			return eventStart(a.CalendarEvent, loc).Before(eventStart(b.CalendarEvent, loc))
+		})
+	}
+
+	shared.WriteJSON(rw, http.StatusOK, agenda)
+}
@@ -0,0 +1,271 @@
+package calendar
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+
+	"golang.org/x/oauth2"
+	gcalendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// googleMetadataTTL is how long calendar names and the color palette are reused.
+// Both rarely change, and refetching them on every request doubles the API calls.
+const googleMetadataTTL = time.Hour
+
+// googleEventsPageSize is how many events are asked for per page; Google allows up to 2500
+const googleEventsPageSize = 250
+
+// GoogleCalendar is a calendar the connected account can read
+type GoogleCalendar struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color"`
+	Primary     bool   `json:"primary"`
+	AccessRole  string `json:"accessRole"` // owner, writer, reader or freeBusyReader
+	Configured  bool   `json:"configured"` // listed in the widget's "calendars" config
+}
+
+// googleMetadata caches the account's calendar list and the color palette
+type googleMetadata struct {
+	mu        sync.Mutex
+	fetched   time.Time
+	calendars map[string]*gcalendar.CalendarListEntry // by ID, plus "primary"
+	colors    *gcalendar.Colors
+}
+
+// load returns the cached calendar list and palette, refreshing them when stale.
+// Failures leave them empty so events still load without names and colors.
+func (m *googleMetadata) load(ctx context.Context, srv *gcalendar.Service) (map[string]*gcalendar.CalendarListEntry, *gcalendar.Colors) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.colors != nil && time.Since(m.fetched) < googleMetadataTTL {
+		return m.calendars, m.colors
+	}
+
+	entries, err := listGoogleCalendars(ctx, srv)
+	if err != nil {
+		log.Printf("[Calendar] Failed to list Google calendars: %v", err)
+		return m.calendars, m.colors
+	}
+	colors, err := srv.Colors.Get().Context(ctx).Do()
+	if err != nil {
+		log.Printf("[Calendar] Failed to fetch Google calendar colors: %v", err)
+		return m.calendars, m.colors
+	}
+
+	calendars := make(map[string]*gcalendar.CalendarListEntry, len(entries)+1)
+	for _, entry := range entries {
+		calendars[entry.Id] = entry
+		if entry.Primary {
+			calendars["primary"] = entry
+		}
+	}
+	m.calendars, m.colors, m.fetched = calendars, colors, time.Now()
+	return calendars, colors
+}
+
+// clear forgets the cached metadata, e.g. after connecting another account
+func (m *googleMetadata) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calendars, m.colors, m.fetched = nil, nil, time.Time{}
+}
+
+// listGoogleCalendars returns every calendar on the account's calendar list
+func listGoogleCalendars(ctx context.Context, srv *gcalendar.Service) ([]*gcalendar.CalendarListEntry, error) {
+	var entries []*gcalendar.CalendarListEntry
+	err := srv.CalendarList.List().Context(ctx).Pages(ctx, func(page *gcalendar.CalendarList) error {
+		entries = append(entries, page.Items...)
+		return nil
+	})
+	return entries, err
+}
+
+// calendarName is the name the account shows for a calendar
+func calendarName(entry *gcalendar.CalendarListEntry) string {
+	if entry.SummaryOverride != "" {
+		return entry.SummaryOverride
+	}
+	return entry.Summary
+}
+
+// calendarColor is a calendar's hex color in Google
+func calendarColor(entry *gcalendar.CalendarListEntry, colors *gcalendar.Colors) string {
+	if entry.BackgroundColor != "" {
+		return entry.BackgroundColor
+	}
+	if colors != nil {
+		if def, ok := colors.Calendar[entry.ColorId]; ok {
+			return def.Background
+		}
+	}
+	return ""
+}
+
+// googleConnection is a Calendar API client with the account's calendar list and palette
+type googleConnection struct {
+	srv       *gcalendar.Service
+	calendars map[string]*gcalendar.CalendarListEntry
+	colors    *gcalendar.Colors
+}
+
+// googleConnection connects with the stored token and loads the cached metadata
+func (w *CalendarWidget) googleConnection(ctx context.Context) (*googleConnection, error) {
+	srv, err := w.calendarService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	calendars, colors := w.google.load(ctx, srv)
+	return &googleConnection{srv: srv, calendars: calendars, colors: colors}, nil
+}
+
+// source returns the source for a Google calendar, filling in its name and color
+// from the account when the config leaves them out
+func (c *googleConnection) source(calendar *CalendarConfig) *googleSource {
+	if entry := c.calendars[calendar.ID]; entry != nil {
+		if calendar.Name == "" {
+			calendar.Name = calendarName(entry)
+		}
+		if calendar.Color == "" {
+			calendar.Color = calendarColor(entry, c.colors)
+		}
+	}
+	return &googleSource{srv: c.srv, calendarID: calendar.ID, colors: c.colors}
+}
+
+// googleSource reads one calendar of the connected Google account
+type googleSource struct {
+	srv        *gcalendar.Service
+	calendarID string
+	colors     *gcalendar.Colors
+}
+
+func (s *googleSource) Name() string {
+	return "Google calendar " + s.calendarID
+}
+
+func (s *googleSource) Events(ctx context.Context, start, end time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	return fetchGoogleEvents(ctx, s.srv, s.calendarID, s.colors, start, end, loc)
+}
+
+// calendarService connects to the Calendar API with the stored token
+func (w *CalendarWidget) calendarService(ctx context.Context) (*gcalendar.Service, error) {
+	source, err := w.tokens.tokenSource()
+	if err != nil {
+		return nil, err
+	}
+	// Refreshed access tokens are written back to the token file
+	return gcalendar.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, source)))
+}
+
+// fetchGoogleEvents returns one calendar's events overlapping timeMin to timeMax,
+// with times given in loc
+func fetchGoogleEvents(ctx context.Context, srv *gcalendar.Service, calendarID string, colors *gcalendar.Colors, timeMin, timeMax time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	call := srv.Events.List(calendarID).
+		Context(ctx).
+		TimeMin(timeMin.Format(time.RFC3339)).
+		TimeMax(timeMax.Format(time.RFC3339)).
+		SingleEvents(true).
+		OrderBy("startTime").
+		MaxResults(googleEventsPageSize)
+	if loc != time.Local {
+		call = call.TimeZone(loc.String())
+	}
+
+	// A busy calendar over a long range takes several pages
+	var items []*gcalendar.Event
+	err := call.Pages(ctx, func(page *gcalendar.Events) error {
+		items = append(items, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	calendarEvents := make([]CalendarEvent, 0, len(items))
+	for _, item := range items {
+		start := item.Start.DateTime
+		end := item.End.DateTime
+		allDay := false
+
+		if start == "" {
+			start = item.Start.Date
+			end = item.End.Date
+			allDay = true
+		}
+
+		// Events can override their calendar's color with one from the palette
+		color := ""
+		if colors != nil && item.ColorId != "" {
+			if def, ok := colors.Event[item.ColorId]; ok {
+				color = def.Background
+			}
+		}
+
+		calendarEvents = append(calendarEvents, CalendarEvent{
+			ID:       item.Id,
+			UID:      item.ICalUID,
+			Title:    item.Summary,
+			Start:    start,
+			End:      end,
+			AllDay:   allDay,
+			Location: item.Location,
+			ColorID:  item.ColorId,
+			Color:    color,
+		})
+	}
+	return calendarEvents, nil
+}
+
+// getGoogleCalendars handles GET /api/google/calendars
+func (w *CalendarWidget) getGoogleCalendars(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	srv, err := w.calendarService(ctx)
+	if err != nil {
+		w.writeGoogleError(rw, err)
+		return
+	}
+	entries, err := listGoogleCalendars(ctx, srv)
+	if err != nil {
+		w.writeGoogleError(rw, err)
+		return
+	}
+	_, colors := w.google.load(ctx, srv)
+
+	configured := make(map[string]bool)
+	for _, calendar := range configuredCalendars() {
+		if calendar.Type == sourceGoogle {
+			configured[calendar.ID] = true
+		}
+	}
+
+	calendars := make([]GoogleCalendar, 0, len(entries))
+	for _, entry := range entries {
+		calendars = append(calendars, GoogleCalendar{
+			ID:          entry.Id,
+			Name:        calendarName(entry),
+			Description: entry.Description,
+			Color:       calendarColor(entry, colors),
+			Primary:     entry.Primary,
+			AccessRole:  entry.AccessRole,
+			Configured:  configured[entry.Id] || entry.Primary && configured["primary"],
+		})
+	}
+	// The account's own calendar first, then by name
+	sort.SliceStable(calendars, func(i, j int) bool {
+		if calendars[i].Primary != calendars[j].Primary {
+			return calendars[i].Primary
+		}
+		return calendars[i].Name < calendars[j].Name
+	})
+	shared.WriteJSON(rw, http.StatusOK, calendars)
+}
@@ -0,0 +1,44 @@
+package calendar
+
+import (
+	"time"
+
+	"themancavedashboard/shared/ical"
+)
+
+// icalCalendarEvents turns an iCalendar file into the events overlapping start to
+// end, with recurring events expanded
+func icalCalendarEvents(data string, start, end time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	calendar, err := ical.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return calendarEvents(calendar, start, end, loc), nil
+}
+
+// calendarEvents returns a parsed calendar's events overlapping start to end
+func calendarEvents(calendar *ical.Calendar, start, end time.Time, loc *time.Location) []CalendarEvent {
+	var events []CalendarEvent
+	for _, occurrence := range calendar.Events(start, end, loc) {
+		// Occurrences of a recurring event share a UID, so the ID includes the
+		// original start
+		id := occurrence.UID
+		if occurrence.Recurring {
+			id += "_" + occurrence.RecurrenceID.UTC().Format("20060102T150405Z")
+		}
+		event := CalendarEvent{
+			ID:       id,
+			UID:      occurrence.UID,
+			Title:    occurrence.Summary,
+			AllDay:   occurrence.AllDay,
+			Location: occurrence.Location,
+		}
+		if occurrence.AllDay {
+			event.Start, event.End = occurrence.Start.Format("2006-01-02"), occurrence.End.Format("2006-01-02")
+		} else {
+			event.Start, event.End = occurrence.Start.In(loc).Format(time.RFC3339), occurrence.End.In(loc).Format(time.RFC3339)
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"themancavedashboard/shared/ical"
+)
+
+// maxICSBytes bounds an ICS feed; years of history in one feed stay well under it
+const maxICSBytes = 20 << 20
+
+// defaultICSRefresh is how often a feed is fetched unless its calendar sets
+// refresh_minutes
+const defaultICSRefresh = 5 * time.Minute
+
+// icsFeeds keeps the last good copy of each feed, served while its host is down
+var icsFeeds = &ical.FeedCache{Client: calendarHTTPClient, MaxBytes: maxICSBytes}
+
+// icsSource reads a published iCalendar feed, such as an iCloud public calendar
+// or Google's secret address
+type icsSource struct {
+	url     string
+	refresh time.Duration
+}
+
+func newICSSource(rawURL string, refreshMinutes float64) *icsSource {
+	// webcal:// is how calendar apps are offered feeds; it is plain HTTPS
+	if rest, ok := strings.CutPrefix(rawURL, "webcal://"); ok {
+		rawURL = "https://" + rest
+	}
+	refresh := defaultICSRefresh
+	if refreshMinutes > 0 {
+		refresh = time.Duration(refreshMinutes * float64(time.Minute))
+	}
+	return &icsSource{url: rawURL, refresh: refresh}
+}
+
+func (s *icsSource) Name() string {
+	// Feed addresses usually embed a secret, so only the host is logged
+	if u, err := url.Parse(s.url); err == nil {
+		return "ICS feed at " + u.Host
+	}
+	return "ICS feed"
+}
+
+func (s *icsSource) Events(ctx context.Context, start, end time.Time, loc *time.Location) ([]CalendarEvent, error) {
+	calendar, err := icsFeeds.Get(ctx, s.url, s.refresh)
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := icsFeeds.Status(s.url); ok && status.Stale {
+		log.Printf("[Calendar] Serving the last good copy of %s: %s", s.Name(), status.Error)
+	}
+	return calendarEvents(calendar, start, end, loc), nil
+}
@@ -0,0 +1,476 @@
+package calendar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	gcalendar "google.golang.org/api/calendar/v3"
+)
+
+// googleScopes are requested when connecting a Google account
+var googleScopes = []string{gcalendar.CalendarReadonlyScope}
+
+// Sign-in attempts expire if the callback doesn't arrive within authStateTTL
+const (
+	authStateTTL     = 10 * time.Minute
+	maxPendingAuths  = 32
+	authStateCookie  = "google_oauth_state"
+	authCallbackPath = "/api/google/auth/callback"
+)
+
+// errNotConnected is returned until a token with a refresh token is stored
+var errNotConnected = errors.New("Google Calendar not configured")
+
+// credentialsPath is the OAuth client file from the Google Cloud console
+func credentialsPath() string {
+	return fmt.Sprintf("/app/config/%s", shared.GetWidgetConfigValue("calendar", "google_credentials_filename", "credentials.json"))
+}
+
+// tokenPath is where the token is stored
+func tokenPath() string {
+	return fmt.Sprintf("/app/config/%s", shared.GetWidgetConfigValue("calendar", "google_token_filename", "token.json"))
+}
+
+// loadCredentials reads the OAuth client, which may be a "web" or "installed" (desktop) client.
+// Only the sign-in flow needs its secret; see codeFlowCredentials.
+func loadCredentials() (GoogleClient, error) {
+	data, err := os.ReadFile(credentialsPath())
+	if err != nil {
+		return GoogleClient{}, err
+	}
+	var creds GoogleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return GoogleClient{}, fmt.Errorf("invalid credentials file: %w", err)
+	}
+	client := creds.Web
+	if client.ClientID == "" {
+		client = creds.Installed
+	}
+	if client.ClientID == "" {
+		return GoogleClient{}, errors.New("credentials file has no client_id")
+	}
+	return client, nil
+}
+
+// codeFlowCredentials reads the OAuth client for exchanging an authorization
+// code, which takes its secret as well
+func codeFlowCredentials() (GoogleClient, error) {
+	client, err := loadCredentials()
+	if err != nil {
+		return GoogleClient{}, err
+	}
+	if client.ClientSecret == "" {
+		return GoogleClient{}, errors.New("credentials file has no client_secret")
+	}
+	return client, nil
+}
+
+func oauthConfig(clientID, clientSecret, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     google.Endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       googleScopes,
+	}
+}
+
+// readToken parses a token file
+func readToken(path string) (*GoogleToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var token GoogleToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid token file: %w", err)
+	}
+	return &token, nil
+}
+
+// writeToken saves a token readable only by the server, through a temp file so a
+// crash can't leave it half-written
+func writeToken(path string, token *GoogleToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// parseTokenTime reads the expiry fields, which other tools write with or without
+// fractional seconds
+func parseTokenTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// tokenStore keeps one token source for the stored token, reloading it when the
+// file changes (e.g. after connecting again) and remembering whether Google
+// rejected it
+type tokenStore struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	source  oauth2.TokenSource
+	token   GoogleToken // as last read or written
+	revoked bool
+	lastErr error
+}
+
+// tokenSource returns a source that refreshes the access token as needed and
+// writes refreshed tokens back to the file
+func (s *tokenStore) tokenSource() (oauth2.TokenSource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := tokenPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		s.source = nil
+		return nil, errNotConnected
+	}
+	if s.source != nil && s.path == path && info.ModTime().Equal(s.modTime) {
+		return s.source, nil
+	}
+
+	stored, err := readToken(path)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RefreshToken == "" {
+		return nil, errNotConnected
+	}
+	// Tokens created elsewhere carry their client; ours use credentials.json
+	clientID, clientSecret := stored.ClientID, stored.ClientSecret
+	if clientID == "" || clientSecret == "" {
+		client, err := loadCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("token has no client and %w", err)
+		}
+		clientID, clientSecret = client.ClientID, client.ClientSecret
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  stored.Token,
+		RefreshToken: stored.RefreshToken,
+		TokenType:    "Bearer",
+		Expiry:       parseTokenTime(stored.Expiry),
+	}
+	config := oauthConfig(clientID, clientSecret, "")
+	s.source = &persistingTokenSource{store: s, base: config.TokenSource(context.Background(), token), accessToken: token.AccessToken}
+	s.path, s.modTime, s.token = path, info.ModTime(), *stored
+	s.revoked, s.lastErr = false, nil
+	return s.source, nil
+}
+
+// refreshed records a new access token and writes it to the file
+func (s *tokenStore) refreshed(token *oauth2.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token.Token = token.AccessToken
+	s.token.Expiry = token.Expiry.Format(time.RFC3339)
+	if token.RefreshToken != "" {
+		s.token.RefreshToken = token.RefreshToken
+	}
+	s.revoked, s.lastErr = false, nil
+
+	if err := writeToken(s.path, &s.token); err != nil {
+		log.Printf("[Calendar] Failed to save refreshed Google token: %v", err)
+		return
+	}
+	// Don't reload what was just written
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+}
+
+// failed records a refresh failure. invalid_grant means the refresh token was
+// revoked, expired or the password changed, so only connecting again helps.
+func (s *tokenStore) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+		if !s.revoked {
+			log.Printf("[Calendar] Google rejected the refresh token; reconnect at /api/google/auth/start")
+		}
+		s.revoked = true
+	}
+}
+
+// isRevoked reports whether Google rejected the stored refresh token
+func (s *tokenStore) isRevoked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked
+}
+
+// status describes the stored token for /api/google/token-status
+func (s *tokenStore) status() GoogleTokenStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := GoogleTokenStatus{Valid: !s.revoked, Revoked: s.revoked}
+	if expiry := parseTokenTime(s.token.Expiry); !expiry.IsZero() {
+		status.Expiry = &expiry
+	}
+	if refreshExpiry := parseTokenTime(s.token.RefreshExpiry); !refreshExpiry.IsZero() {
+		status.RefreshExpiry = &refreshExpiry
+		if time.Now().After(refreshExpiry) {
+			status.Valid = false
+		}
+	}
+	if s.lastErr != nil {
+		status.Error = s.lastErr.Error()
+	}
+	return status
+}
+
+// persistingTokenSource saves every new access token, so a restart doesn't need
+// to refresh again and other tools reading the file see a current token
+type persistingTokenSource struct {
+	store *tokenStore
+	base  oauth2.TokenSource
+
+	mu          sync.Mutex
+	accessToken string
+}
+
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.base.Token()
+	if err != nil {
+		p.store.failed(err)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if token.AccessToken != p.accessToken {
+		p.accessToken = token.AccessToken
+		p.store.refreshed(token)
+	}
+	return token, nil
+}
+
+// pendingAuth is a sign-in that was started and awaits Google's callback
+type pendingAuth struct {
+	verifier    string // PKCE code verifier
+	redirectURL string
+	returnTo    string
+	created     time.Time
+}
+
+// authFlows remembers sign-ins in progress by their state parameter
+type authFlows struct {
+	mu      sync.Mutex
+	pending map[string]pendingAuth
+}
+
+func (f *authFlows) add(state string, auth pendingAuth) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pending == nil {
+		f.pending = make(map[string]pendingAuth)
+	}
+	for key, other := range f.pending {
+		if time.Since(other.created) > authStateTTL || len(f.pending) >= maxPendingAuths {
+			delete(f.pending, key)
+		}
+	}
+	f.pending[state] = auth
+}
+
+// take removes and returns a sign-in; each state can be used once
+func (f *authFlows) take(state string) (pendingAuth, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.pending[state]
+	delete(f.pending, state)
+	if !ok || time.Since(auth.created) > authStateTTL {
+		return pendingAuth{}, false
+	}
+	return auth, true
+}
+
+// randomState returns an unguessable state parameter
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// callbackURL is the redirect URI Google sends the browser back to. It has to be
+// listed under the OAuth client's authorized redirect URIs.
+func callbackURL(r *http.Request) string {
+	if configured := shared.GetWidgetConfigValue("calendar", "google_redirect_url", ""); configured != "" {
+		return configured
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host + authCallbackPath
+}
+
+// safeReturnPath only allows redirects back into the dashboard
+func safeReturnPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.Contains(p, "\\") {
+		return "/"
+	}
+	return p
+}
+
+// startGoogleAuth handles GET /api/google/auth/start
+func (w *CalendarWidget) startGoogleAuth(rw http.ResponseWriter, r *http.Request) {
+	client, err := codeFlowCredentials()
+	if err != nil {
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Google OAuth not configured: "+err.Error())
+		return
+	}
+	state, err := randomState()
+	if err != nil {
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to start sign-in")
+		return
+	}
+
+	auth := pendingAuth{
+		verifier:    oauth2.GenerateVerifier(),
+		redirectURL: callbackURL(r),
+		returnTo:    safeReturnPath(r.URL.Query().Get("return_to")),
+		created:     time.Now(),
+	}
+	w.auth.add(state, auth)
+
+	// Ties the callback to this browser, so a link from someone else can't
+	// connect their account
+	http.SetCookie(rw, &http.Cookie{
+		Name:     authStateCookie,
+		Value:    state,
+		Path:     "/api/google/auth",
+		MaxAge:   int(authStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(auth.redirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// prompt=consent makes Google return a refresh token even if the account
+	// was connected before
+	authURL := oauthConfig(client.ClientID, client.ClientSecret, auth.redirectURL).AuthCodeURL(state,
+		oauth2.AccessTypeOffline, oauth2.ApprovalForce, oauth2.S256ChallengeOption(auth.verifier))
+	if r.URL.Query().Get("format") == "json" {
+		shared.WriteJSON(rw, http.StatusOK, GoogleAuthStart{URL: authURL})
+		return
+	}
+	http.Redirect(rw, r, authURL, http.StatusFound)
+}
+
+// googleAuthCallback handles GET /api/google/auth/callback
+func (w *CalendarWidget) googleAuthCallback(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	auth, ok := w.auth.take(state)
+	cookie, err := r.Cookie(authStateCookie)
+	if !ok || err != nil || cookie.Value != state {
+		shared.WriteError(rw, http.StatusBadRequest, "Sign-in expired or was started in another browser; start again at /api/google/auth/start")
+		return
+	}
+	http.SetCookie(rw, &http.Cookie{Name: authStateCookie, Path: "/api/google/auth", MaxAge: -1})
+
+	if reason := query.Get("error"); reason != "" {
+		shared.WriteError(rw, http.StatusBadRequest, "Google sign-in was not completed: "+reason)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		shared.WriteError(rw, http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	client, err := codeFlowCredentials()
+	if err != nil {
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Google OAuth not configured: "+err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	token, err := oauthConfig(client.ClientID, client.ClientSecret, auth.redirectURL).Exchange(ctx, code, oauth2.VerifierOption(auth.verifier))
+	if err != nil {
+		log.Printf("[Calendar] Google authorization code exchange failed: %v", err)
+		shared.WriteError(rw, http.StatusBadGateway, "Failed to exchange authorization code")
+		return
+	}
+
+	stored := &GoogleToken{
+		Token:        token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		Expiry:       token.Expiry.Format(time.RFC3339),
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		stored.Scopes = strings.Fields(scope)
+	}
+	// Only set for OAuth apps in testing mode, whose refresh tokens last 7 days
+	if seconds, ok := token.Extra("refresh_token_expires_in").(float64); ok && seconds > 0 {
+		stored.RefreshExpiry = time.Now().Add(time.Duration(seconds) * time.Second).Format(time.RFC3339)
+	}
+	if stored.RefreshToken == "" {
+		// Keep the refresh token from an earlier sign-in with the same client
+		if previous, err := readToken(tokenPath()); err == nil && previous.ClientID == client.ClientID {
+			stored.RefreshToken = previous.RefreshToken
+		}
+	}
+	if stored.RefreshToken == "" {
+		shared.WriteError(rw, http.StatusBadGateway, "Google did not return a refresh token; remove the dashboard under https://myaccount.google.com/permissions and connect again")
+		return
+	}
+
+	if err := writeToken(tokenPath(), stored); err != nil {
+		log.Printf("[Calendar] Failed to save Google token: %v", err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to save Google token")
+		return
+	}
+	// Events and calendars cached for the previous account no longer apply
+	w.events.clear()
+	w.google.clear()
+	log.Printf("[Calendar] Connected Google account")
+	http.Redirect(rw, r, auth.returnTo, http.StatusFound)
+}
@@ -0,0 +1,191 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// CalendarSource is somewhere a calendar's events come from
+type CalendarSource interface {
+	// Name describes the source in logs
+	Name() string
+	// Events returns the events overlapping start to end, with times in loc
+	Events(ctx context.Context, start, end time.Time, loc *time.Location) ([]CalendarEvent, error)
+}
+
+// Calendar source types
+const (
+	sourceGoogle = "google"
+	sourceCalDAV = "caldav"
+	sourceICS    = "ics"
+)
+
+// defaultCalDAVPasswordEnv holds the CalDAV password unless a calendar names another variable
+const defaultCalDAVPasswordEnv = "CALDAV_PASSWORD"
+
+// calendarHTTPTimeout bounds one CalDAV or ICS request
+const calendarHTTPTimeout = 30 * time.Second
+
+// calendarHTTPClient is shared by the CalDAV and ICS sources
+var calendarHTTPClient = &http.Client{Timeout: calendarHTTPTimeout}
+
+// CalendarConfig is one entry of the "calendars" widget config
+type CalendarConfig struct {
+	ID          string `json:"id"`           // google: calendar ID, "primary" being the account's own; others: a label for the calendar's events
+	Type        string `json:"type"`         // google (default), caldav or ics
+	URL         string `json:"url"`          // caldav: calendar collection URL; ics: feed URL
+	Username    string `json:"username"`     // caldav
+	PasswordEnv string `json:"password_env"` // caldav: environment variable holding the password, default CALDAV_PASSWORD
+	Name        string `json:"name"`         // shown with its events; defaults to the name in Google
+	Color       string `json:"color"`        // hex color of its events; defaults to the color in Google
+	// RefreshMinutes is how often an ics feed is fetched, default 5; the last
+	// copy is served in between
+	RefreshMinutes float64 `json:"refresh_minutes"`
+}
+
+// configuredCalendars reads the "calendars" widget config, defaulting to the
+// primary Google calendar
+func configuredCalendars() []CalendarConfig {
+	var calendars []CalendarConfig
+	shared.GetWidgetConfigObject("calendar", "calendars", &calendars)
+
+	valid := calendars[:0]
+	for i, calendar := range calendars {
+		if calendar.Type == "" {
+			calendar.Type = sourceGoogle
+		}
+		if calendar.Type == sourceGoogle && calendar.ID == "" {
+			continue
+		}
+		if calendar.Type != sourceGoogle && calendar.URL == "" {
+			log.Printf("[Calendar] Skipping %s calendar without a url", calendar.Type)
+			continue
+		}
+		// Feed URLs often embed a secret, so they are not used as the ID
+		if calendar.ID == "" {
+			calendar.ID = calendar.Name
+		}
+		if calendar.ID == "" {
+			calendar.ID = fmt.Sprintf("%s-%d", calendar.Type, i+1)
+		}
+		valid = append(valid, calendar)
+	}
+	if len(valid) == 0 {
+		return []CalendarConfig{{ID: "primary", Type: sourceGoogle}}
+	}
+	return valid
+}
+
+// calendarSources creates a source for each calendar. Google calendars share
+// one connection and take their names and colors from the account when the
+// config leaves them out. A calendar whose source can't be created gets an error
+// in its place.
+func (w *CalendarWidget) calendarSources(ctx context.Context, calendars []CalendarConfig) ([]CalendarSource, []error) {
+	sources := make([]CalendarSource, len(calendars))
+	errs := make([]error, len(calendars))
+
+	var google *googleConnection
+	var googleErr error
+	for i, calendar := range calendars {
+		switch calendar.Type {
+		case sourceGoogle:
+			if google == nil && googleErr == nil {
+				google, googleErr = w.googleConnection(ctx)
+			}
+			if googleErr != nil {
+				errs[i] = googleErr
+				continue
+			}
+			sources[i] = google.source(&calendars[i])
+		case sourceCalDAV:
+			passwordEnv := calendar.PasswordEnv
+			if passwordEnv == "" {
+				passwordEnv = defaultCalDAVPasswordEnv
+			}
+			sources[i] = newCalDAVSource(calendar.URL, calendar.Username, os.Getenv(passwordEnv))
+		case sourceICS:
+			sources[i] = newICSSource(calendar.URL, calendar.RefreshMinutes)
+		default:
+			errs[i] = fmt.Errorf("unknown calendar type %q", calendar.Type)
+		}
+	}
+	return sources, errs
+}
+
+// fetchCalendars fetches the calendars concurrently and merges their events in
+// start order. A calendar that fails is logged and left out, and complete is
+// false; an error is only returned if every calendar failed.
+func (w *CalendarWidget) fetchCalendars(ctx context.Context, calendars []CalendarConfig, start, end time.Time, loc *time.Location) (events []CalendarEvent, complete bool, err error) {
+	calendars = append([]CalendarConfig(nil), calendars...)
+	sources, errs := w.calendarSources(ctx, calendars)
+
+	results := make([][]CalendarEvent, len(calendars))
+	var wg sync.WaitGroup
+	for i := range calendars {
+		if errs[i] != nil {
+			log.Printf("[Calendar] Failed to fetch %s: %v", calendars[i].ID, errs[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = sources[i].Events(ctx, start, end, loc)
+			if errs[i] != nil {
+				log.Printf("[Calendar] Failed to fetch %s: %v", sources[i].Name(), errs[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var merged []CalendarEvent
+	failed := 0
+	// An invitation shared between two configured calendars is shown once, from
+	// the calendar listed first
+	seen := make(map[string]bool)
+	for i, events := range results {
+		if errs[i] != nil {
+			failed++
+			continue
+		}
+		for _, event := range events {
+			key := event.UID + "|" + event.Start
+			if event.UID != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			event.CalendarID = calendars[i].ID
+			event.Calendar = calendars[i].Name
+			if event.Color == "" {
+				event.Color = calendars[i].Color
+			}
+			merged = append(merged, event)
+		}
+	}
+	if failed == len(calendars) {
+		return nil, false, errs[0]
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return eventStart(merged[i], loc).Before(eventStart(merged[j], loc))
+	})
+	return merged, failed == 0, nil
+}
+
+// eventStart parses an event's start for sorting; all-day events start at
+// midnight in loc, before the day's timed events
+func eventStart(event CalendarEvent, loc *time.Location) time.Time {
+	if event.AllDay {
+		t, _ := time.ParseInLocation("2006-01-02", event.Start, loc)
+		return t
+	}
+	t, _ := time.Parse(time.RFC3339, event.Start)
+	return t
+}
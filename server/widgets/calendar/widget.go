@@ -1,43 +1,54 @@
 package calendar
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
 
 	"github.com/go-chi/chi/v5"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	gcalendar "google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
-// CalendarWidget handles Google Calendar integration
-type CalendarWidget struct{}
+// CalendarWidget shows events from Google, CalDAV and ICS calendars
+type CalendarWidget struct {
+	auth   authFlows
+	tokens tokenStore
+	google googleMetadata
+	events eventCache
+}
 
 // CalendarEvent represents a calendar event
 type CalendarEvent struct {
-	ID       string `json:"id"`
-	Title    string `json:"title"`
-	Start    string `json:"start"`
-	End      string `json:"end"`
-	AllDay   bool   `json:"allDay"`
-	Location string `json:"location"`
-	ColorID  string `json:"colorId"`
+	ID         string   `json:"id"`
+	UID        string   `json:"uid,omitempty"` // iCalendar UID, shared by copies of an event in other calendars
+	Title      string   `json:"title"`
+	Start      string   `json:"start"`
+	End        string   `json:"end"`
+	AllDay     bool     `json:"allDay"`
+	Location   string   `json:"location"`
+	ColorID    string   `json:"colorId"`
+	Color      string   `json:"color,omitempty"` // hex, from the event's color or else its calendar's
+	CalendarID string   `json:"calendarId"`
+	Calendar   string   `json:"calendar,omitempty"` // calendar display name
+	Days       []string `json:"days"`               // dates covered in the dashboard timezone, within the requested range
 }
 
 // GoogleCredentials represents the OAuth credentials file from Google Console
 type GoogleCredentials struct {
-	Web struct {
-		ClientID     string   `json:"client_id"`
-		ClientSecret string   `json:"client_secret"`
-		RedirectURIs []string `json:"redirect_uris"`
-	} `json:"web"`
+	Web       GoogleClient `json:"web"`
+	Installed GoogleClient `json:"installed"` // desktop app clients
+}
+
+// GoogleClient is one OAuth client in the credentials file
+type GoogleClient struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURIs []string `json:"redirect_uris"`
 }
 
 // GoogleClientConfig is what we send to the frontend (without secret)
@@ -47,13 +58,28 @@ type GoogleClientConfig struct {
 
 // GoogleTokenStatus represents the token validation status
 type GoogleTokenStatus struct {
-	Valid bool `json:"valid"`
+	Valid         bool       `json:"valid"`
+	Expiry        *time.Time `json:"expiry,omitempty"`        // when the access token expires; it is refreshed automatically
+	RefreshExpiry *time.Time `json:"refreshExpiry,omitempty"` // when the account has to be connected again (OAuth apps in testing mode)
+	Revoked       bool       `json:"revoked,omitempty"`       // Google rejected the refresh token
+	Error         string     `json:"error,omitempty"`         // the last refresh failure
 }
 
 // GoogleToken represents the token.json structure
 type GoogleToken struct {
-	RefreshToken string `json:"refresh_token"`
-	ClientID     string `json:"client_id"`
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refresh_token"`
+	TokenType     string   `json:"token_type,omitempty"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"`
+	Scopes        []string `json:"scopes,omitempty"`
+	Expiry        string   `json:"expiry"`
+	RefreshExpiry string   `json:"refresh_expiry,omitempty"`
+}
+
+// GoogleAuthStart is returned by /api/google/auth/start?format=json
+type GoogleAuthStart struct {
+	URL string `json:"url"` // Google's consent page
 }
 
 // ID returns the widget identifier
@@ -66,6 +92,21 @@ func (w *CalendarWidget) GetRequiredEnvVars() []string {
 	return []string{} // Uses mounted token.json file
 }
 
+// ConfigSchema describes the "calendar" section of config.json
+func (w *CalendarWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "calendar",
+		Fields: []shared.ConfigField{
+			{Key: "trash_day", Type: "string", Description: "Day of week the trash goes out"},
+			{Key: "reminders", Type: "array", Description: "Recurring reminders shown on the calendar"},
+			{Key: "calendars", Type: "array", Description: "Calendars to show: [{\"type\", \"id\", \"url\", \"username\", \"password_env\", \"name\", \"color\", \"refresh_minutes\"}] where type is google (default), caldav or ics; defaults to the primary Google calendar. Google IDs are listed by /api/google/calendars"},
+			{Key: "google_credentials_filename", Type: "string", Description: "OAuth client credentials file in the config directory"},
+			{Key: "google_token_filename", Type: "string", Description: "OAuth token file in the config directory"},
+			{Key: "google_redirect_url", Type: "string", Description: "OAuth redirect URI, if the dashboard's address as seen by the browser can't be detected"},
+		},
+	}
+}
+
 // Initialize loads configuration
 func (w *CalendarWidget) Initialize() error {
 	return nil
@@ -74,124 +115,135 @@ func (w *CalendarWidget) Initialize() error {
 // RegisterRoutes registers HTTP endpoints
 func (w *CalendarWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/calendar/events", w.getEvents)
+	r.Get("/calendar/agenda", w.getAgenda)
 	// Google OAuth configuration endpoints (used by this widget)
 	r.Get("/google/client-id", w.getGoogleClientID)
+	r.Get("/google/calendars", w.getGoogleCalendars)
 	r.Get("/google/token-status", w.getGoogleTokenStatus)
+	r.Get("/google/auth/start", w.startGoogleAuth)
+	r.Get("/google/auth/callback", w.googleAuthCallback)
 }
 
-// getEvents handles GET /api/calendar/events
-func (w *CalendarWidget) getEvents(rw http.ResponseWriter, r *http.Request) {
-	// Get token filename from widget config, default to token.json
-	tokenFilename := shared.GetWidgetConfigValue("calendar", "google_token_filename", "token.json")
-	tokenPath := fmt.Sprintf("/app/config/%s", tokenFilename)
-	tokenData, err := os.ReadFile(tokenPath)
-	if err != nil {
-		http.Error(rw, `{"error":"Google Calendar not configured"}`, http.StatusServiceUnavailable)
-		return
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *CalendarWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/calendar/events",
+			Summary:     "Events on the configured calendars in a range, by default the current month",
+			Description: "Calendars are fetched concurrently and merged in start order. A calendar that fails is left out. color is the event's own color, or else its calendar's. days lists the dates each event covers in the dashboard timezone. Results are cached for 5 minutes per range.",
+			Query: []openapi.Param{
+				{Name: "start", Description: "Start of the range: a date (midnight in the dashboard timezone) or an RFC 3339 time"},
+				{Name: "end", Description: "End of the range, exclusive; at most 366 days after start"},
+			},
+			Response: []CalendarEvent{},
+			Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/calendar/agenda",
+			Summary:     "Events by day from today",
+			Description: "Every day is listed, with events spanning several days repeated on each. All-day and continuing events come first.",
+			Query: []openapi.Param{
+				{Name: "days", Type: "integer", Description: "Number of days, 1 to 62 (default 7)"},
+			},
+			Response: []AgendaDay{},
+			Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/google/calendars",
+			Summary:     "Calendars the connected Google account can read",
+			Description: "Use the IDs in the calendar widget's calendars config.",
+			Response:    []GoogleCalendar{},
+			Errors:      []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/google/client-id",
+			Summary:  "Google OAuth client ID for the frontend",
+			Response: GoogleClientConfig{},
+			Errors:   []int{http.StatusServiceUnavailable},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/google/token-status",
+			Summary:     "Whether a usable Google token is stored, and when it expires",
+			Description: "valid is false when no token is stored, Google rejected the refresh token (revoked) or the refresh token expired.",
+			Query: []openapi.Param{
+				{Name: "check", Type: "boolean", Description: "Refresh the access token now if it expired, to find out whether it was revoked"},
+			},
+			Response: GoogleTokenStatus{},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/google/auth/start",
+			Summary: "Connect a Google account",
+			Description: "Redirects to Google's consent page (authorization code flow with PKCE). Google redirects back to /google/auth/callback, " +
+				"which must be an authorized redirect URI of the OAuth client in credentials.json.",
+			Query: []openapi.Param{
+				{Name: "return_to", Description: "Dashboard path to return to afterwards (default /)"},
+				{Name: "format", Description: "json to return the consent page URL instead of redirecting"},
+			},
+			Response: GoogleAuthStart{},
+			Errors:   []int{http.StatusServiceUnavailable},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/google/auth/callback",
+			Summary:     "Completes connecting a Google account",
+			Description: "Checks the state, exchanges the code for a token, stores it in the token file and redirects to return_to.",
+			Errors:      []int{http.StatusBadRequest, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
 	}
+}
 
-	var tokenInfo struct {
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-		ClientID     string `json:"client_id"`
-		ClientSecret string `json:"client_secret"`
-		Expiry       string `json:"expiry"`
-	}
-
-	if err := json.Unmarshal(tokenData, &tokenInfo); err != nil {
-		http.Error(rw, `{"error":"Invalid token.json"}`, http.StatusInternalServerError)
-		return
-	}
-
-	// Configure OAuth2
-	config := &oauth2.Config{
-		ClientID:     tokenInfo.ClientID,
-		ClientSecret: tokenInfo.ClientSecret,
-		Endpoint:     google.Endpoint,
-		Scopes:       []string{"https://www.googleapis.com/auth/calendar.readonly"},
-	}
-
-	expiry, _ := time.Parse(time.RFC3339, tokenInfo.Expiry)
-	token := &oauth2.Token{
-		AccessToken:  tokenInfo.Token,
-		RefreshToken: tokenInfo.RefreshToken,
-		Expiry:       expiry,
-		TokenType:    "Bearer",
-	}
-
-	ctx := context.Background()
-	client := config.Client(ctx, token)
-
-	srv, err := gcalendar.NewService(ctx, option.WithHTTPClient(client))
+// getEvents handles GET /api/calendar/events
+func (w *CalendarWidget) getEvents(rw http.ResponseWriter, r *http.Request) {
+	loc := shared.GetLocation()
+	start, end, err := parseEventRange(r.URL.Query(), loc)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to create calendar client"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Get events for the current month
-	now := time.Now()
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	endOfMonth := startOfMonth.AddDate(0, 1, 0).Add(-time.Second)
-
-	events, err := srv.Events.List("primary").
-		TimeMin(startOfMonth.Format(time.RFC3339)).
-		TimeMax(endOfMonth.Format(time.RFC3339)).
-		SingleEvents(true).
-		OrderBy("startTime").
-		Do()
-
+	calendarEvents, err := w.eventsBetween(r.Context(), start, end, loc)
 	if err != nil {
-		http.Error(rw, fmt.Sprintf(`{"error":"Failed to fetch calendar events: %v"}`, err), http.StatusInternalServerError)
+		w.writeGoogleError(rw, fmt.Errorf("Failed to fetch calendar events: %w", err))
 		return
 	}
 
-	var calendarEvents []CalendarEvent
-	for _, item := range events.Items {
-		start := item.Start.DateTime
-		end := item.End.DateTime
-		allDay := false
-
-		if start == "" {
-			start = item.Start.Date
-			end = item.End.Date
-			allDay = true
-		}
-
-		calendarEvents = append(calendarEvents, CalendarEvent{
-			ID:       item.Id,
-			Title:    item.Summary,
-			Start:    start,
-			End:      end,
-			AllDay:   allDay,
-			Location: item.Location,
-			ColorID:  item.ColorId,
-		})
-	}
-
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(calendarEvents)
 }
 
+// writeGoogleError responds to a failed Google request, telling apart a missing
+// or revoked connection from other errors
+func (w *CalendarWidget) writeGoogleError(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNotConnected):
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Google Calendar not configured")
+	case w.tokens.isRevoked():
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Google access was revoked or expired; reconnect at /api/google/auth/start")
+	default:
+		shared.WriteError(rw, http.StatusInternalServerError, err.Error())
+	}
+}
+
 // getGoogleClientID handles GET /api/google/client-id
 func (w *CalendarWidget) getGoogleClientID(rw http.ResponseWriter, r *http.Request) {
-	// Get credentials filename from widget config, default to credentials.json
-	credentialsFilename := shared.GetWidgetConfigValue("calendar", "google_credentials_filename", "credentials.json")
-	credentialsPath := fmt.Sprintf("/app/config/%s", credentialsFilename)
-	if data, err := os.ReadFile(credentialsPath); err == nil {
-		var creds GoogleCredentials
-		if err := json.Unmarshal(data, &creds); err == nil && creds.Web.ClientID != "" {
-			rw.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(rw).Encode(GoogleClientConfig{
-				ClientID: creds.Web.ClientID,
-			})
-			return
-		}
+	if client, err := loadCredentials(); err == nil {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(GoogleClientConfig{
+			ClientID: client.ClientID,
+		})
+		return
 	}
 
 	// Fallback to environment variable
 	clientID := os.Getenv("VITE_GOOGLE_CLIENT_ID")
 	if clientID == "" {
-		http.Error(rw, `{"error":"Google OAuth not configured"}`, http.StatusServiceUnavailable)
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Google OAuth not configured")
 		return
 	}
 
@@ -203,34 +255,20 @@ func (w *CalendarWidget) getGoogleClientID(rw http.ResponseWriter, r *http.Reque
 
 // getGoogleTokenStatus handles GET /api/google/token-status
 func (w *CalendarWidget) getGoogleTokenStatus(rw http.ResponseWriter, r *http.Request) {
-	// Get token filename from widget config, default to token.json
-	tokenFilename := shared.GetWidgetConfigValue("calendar", "google_token_filename", "token.json")
-	tokenPath := fmt.Sprintf("/app/config/%s", tokenFilename)
-	data, err := os.ReadFile(tokenPath)
+	source, err := w.tokens.tokenSource()
 	if err != nil {
-		// Token doesn't exist
-		rw.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(rw).Encode(GoogleTokenStatus{Valid: false})
-		return
-	}
-
-	// Try to parse it
-	var token GoogleToken
-	if err := json.Unmarshal(data, &token); err != nil {
-		// Token is invalid JSON
-		rw.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(rw).Encode(GoogleTokenStatus{Valid: false})
+		// No token, or one without a refresh token
+		status := GoogleTokenStatus{Valid: false}
+		if !errors.Is(err, errNotConnected) {
+			status.Error = err.Error()
+		}
+		shared.WriteJSON(rw, http.StatusOK, status)
 		return
 	}
 
-	// Check if token has required fields
-	if token.RefreshToken == "" || token.ClientID == "" {
-		rw.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(rw).Encode(GoogleTokenStatus{Valid: false})
-		return
+	// Refreshing is the only way to find out whether Google still accepts the token
+	if r.URL.Query().Get("check") == "true" {
+		source.Token()
 	}
-
-	// Token exists and looks valid
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(GoogleTokenStatus{Valid: true})
+	shared.WriteJSON(rw, http.StatusOK, w.tokens.status())
 }
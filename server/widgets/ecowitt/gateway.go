@@ -0,0 +1,140 @@
+package ecowitt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"themancavedashboard/shared/units"
+)
+
+// gatewayTimeout bounds a request to the gateway, which answers within a second
+// when it is reachable
+const gatewayTimeout = 10 * time.Second
+
+var gatewayClient = &http.Client{Timeout: gatewayTimeout}
+
+// liveData is the part of a gateway's get_livedata_info response the widget
+// uses. Values are strings in the units set on the gateway, either with their
+// unit ("29.92 inHg", "48%") or with it alongside ("unit": "F").
+type liveData struct {
+	WH25 []struct {
+		InTemp string `json:"intemp"`
+		Unit   string `json:"unit"`
+		InHumi string `json:"inhumi"`
+		Rel    string `json:"rel"` // relative pressure
+	} `json:"wh25"`
+	ChSoil []struct {
+		Channel  string `json:"channel"` // 1 to 8
+		Humidity string `json:"humidity"`
+	} `json:"ch_soil"`
+}
+
+// fetchLiveData reads a gateway on the local network, such as a GW1100 or
+// GW2000, and describes its readings the way the cloud ones are
+func fetchLiveData(ctx context.Context, gateway string, u units.Units, sensorConfigs map[string]SoilSensorConfig) (EcowittResponse, error) {
+	response := EcowittResponse{Sensors: []SoilMoistureSensor{}, Units: u}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gatewayURL(gateway), nil)
+	if err != nil {
+		return response, err
+	}
+	resp, err := gatewayClient.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return response, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("gateway returned %d", resp.StatusCode)
+	}
+	var data liveData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return response, fmt.Errorf("parsing live data: %w", err)
+	}
+
+	sort.SliceStable(data.ChSoil, func(i, j int) bool {
+		a, _ := strconv.Atoi(data.ChSoil[i].Channel)
+		b, _ := strconv.Atoi(data.ChSoil[j].Channel)
+		return a < b
+	})
+	for _, soil := range data.ChSoil {
+		channel, err := strconv.Atoi(soil.Channel)
+		if err != nil {
+			continue
+		}
+		if moisture, _, ok := parseReading(soil.Humidity); ok {
+			response.Sensors = append(response.Sensors, soilSensor(channel, moisture, sensorConfigs))
+		}
+	}
+
+	if len(data.WH25) > 0 {
+		indoor := &IndoorSensor{}
+		hasIndoorData := false
+		wh25 := data.WH25[0]
+		if temp, unit, ok := parseReading(wh25.InTemp); ok {
+			if unit == "" {
+				unit = wh25.Unit
+			}
+			indoor.Temperature = units.Round(u.ConvertTemperature(temp, parseUnit(unit, units.Fahrenheit)), u.Temperature)
+			hasIndoorData = true
+		}
+		if humidity, _, ok := parseReading(wh25.InHumi); ok {
+			indoor.Humidity = humidity
+			hasIndoorData = true
+		}
+		if pressure, unit, ok := parseReading(wh25.Rel); ok {
+			indoor.Pressure = units.Round(u.ConvertPressure(pressure, parseUnit(unit, units.InchesOfMercury)), u.Pressure)
+			hasIndoorData = true
+		}
+		if hasIndoorData {
+			response.Indoor = indoor
+		}
+	}
+	return response, nil
+}
+
+// gatewayURL completes a gateway address like 192.168.1.20 into the URL of its
+// live data
+func gatewayURL(gateway string) string {
+	gateway = strings.TrimSuffix(gateway, "/")
+	if !strings.Contains(gateway, "://") {
+		gateway = "http://" + gateway
+	}
+	return gateway + "/get_livedata_info"
+}
+
+// parseReading splits a value like "29.92 inHg" or "48%" into its number and
+// unit
+func parseReading(value string) (float64, string, bool) {
+	value = strings.TrimSpace(value)
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-'
+	})
+	if end < 0 {
+		end = len(value)
+	}
+	n, err := strconv.ParseFloat(value[:end], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return n, strings.TrimSpace(value[end:]), true
+}
+
+// parseUnit reads the unit a gateway writes, or returns fallback for one it
+// doesn't know
+func parseUnit(text, fallback string) string {
+	if unit, ok := units.Parse(text); ok {
+		return unit
+	}
+	return fallback
+}
@@ -0,0 +1,140 @@
+package ecowitt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"themancavedashboard/shared/units"
+)
+
+// gatewayStandIn serves a gateway's recorded get_livedata_info response
+func gatewayStandIn(t *testing.T, file string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/get_livedata_info" {
+			http.NotFound(w, r)
+			return
+		}
+		// Gateways answer with text/html whatever the content
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(data)
+	}))
+}
+
+func TestFetchLiveData(t *testing.T) {
+	metric := units.Units{Temperature: units.Celsius, Pressure: units.Hectopascals}
+	imperial := units.Units{Temperature: units.Fahrenheit, Pressure: units.InchesOfMercury}
+	sensorConfigs := map[string]SoilSensorConfig{
+		"soil_ch1": {Channel: "soil_ch1", Name: "Tomatoes", Location: "Greenhouse", MinMoisture: 30, MaxMoisture: 60},
+	}
+	tomatoes := func(moisture int, status string) SoilMoistureSensor {
+		return SoilMoistureSensor{Channel: "soil_ch1", Name: "Tomatoes", Location: "Greenhouse",
+			Moisture: moisture, MoistureStatus: status, MinMoisture: 30, MaxMoisture: 60}
+	}
+	unconfigured := func(channel string, moisture int, status string) SoilMoistureSensor {
+		return SoilMoistureSensor{Channel: "soil_ch" + channel, Name: "Sensor " + channel, Location: "Unknown",
+			Moisture: moisture, MoistureStatus: status, MinMoisture: 30, MaxMoisture: 70}
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		units   units.Units
+		indoor  *IndoorSensor
+		sensors []SoilMoistureSensor
+	}{
+		{
+			name: "GW1100 in metric", file: "gw1100-metric.json", units: metric,
+			indoor:  &IndoorSensor{Temperature: 23, Humidity: 48, Pressure: 1013.4},
+			sensors: []SoilMoistureSensor{tomatoes(37, "good"), unconfigured("2", 64, "good")},
+		},
+		{
+			name: "GW1100 in metric shown in imperial", file: "gw1100-metric.json", units: imperial,
+			indoor:  &IndoorSensor{Temperature: 74, Humidity: 48, Pressure: 29.93},
+			sensors: []SoilMoistureSensor{tomatoes(37, "good"), unconfigured("2", 64, "good")},
+		},
+		{
+			// Soil channels come in any order, and a lost sensor reads "--"
+			name: "GW2000 in imperial", file: "gw2000-imperial.json", units: imperial,
+			indoor:  &IndoorSensor{Temperature: 72, Humidity: 45, Pressure: 29.92},
+			sensors: []SoilMoistureSensor{tomatoes(25, "low"), unconfigured("3", 80, "high")},
+		},
+		{
+			name: "GW2000 in imperial shown in metric", file: "gw2000-imperial.json", units: metric,
+			indoor:  &IndoorSensor{Temperature: 22, Humidity: 45, Pressure: 1013.2},
+			sensors: []SoilMoistureSensor{tomatoes(25, "low"), unconfigured("3", 80, "high")},
+		},
+		{
+			name: "GW2000 in imperial shown in mmHg", file: "gw2000-imperial.json",
+			units:   units.Units{Temperature: units.Celsius, Pressure: units.MillimetersOfMercury},
+			indoor:  &IndoorSensor{Temperature: 22, Humidity: 45, Pressure: 760},
+			sensors: []SoilMoistureSensor{tomatoes(25, "low"), unconfigured("3", 80, "high")},
+		},
+		{
+			name: "GW2000 without an indoor sensor", file: "gw2000-no-wh25.json", units: metric,
+			sensors: []SoilMoistureSensor{unconfigured("4", 51, "good")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := gatewayStandIn(t, tt.file)
+			defer server.Close()
+
+			// Gateways are usually configured by address alone
+			gateway := strings.TrimPrefix(server.URL, "http://")
+			response, err := fetchLiveData(context.Background(), gateway, tt.units, sensorConfigs)
+			if err != nil {
+				t.Fatalf("fetchLiveData: %v", err)
+			}
+			if !reflect.DeepEqual(response.Indoor, tt.indoor) {
+				t.Errorf("indoor = %+v, want %+v", response.Indoor, tt.indoor)
+			}
+			if !reflect.DeepEqual(response.Sensors, tt.sensors) {
+				t.Errorf("sensors = %+v\nwant %+v", response.Sensors, tt.sensors)
+			}
+			if response.Units != tt.units {
+				t.Errorf("units = %+v, want %+v", response.Units, tt.units)
+			}
+		})
+	}
+}
+
+func TestFetchLiveDataErrors(t *testing.T) {
+	for _, body := range []string{"", "<html>Not Found</html>"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body == "" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(body))
+		}))
+		if _, err := fetchLiveData(context.Background(), server.URL+"/", units.Defaults, nil); err == nil {
+			t.Errorf("fetchLiveData succeeded for a gateway answering %q", body)
+		}
+		server.Close()
+	}
+}
+
+func TestGatewayURL(t *testing.T) {
+	tests := map[string]string{
+		"192.168.1.20":            "http://192.168.1.20/get_livedata_info",
+		"192.168.1.20/":           "http://192.168.1.20/get_livedata_info",
+		"http://gw2000.lan:8080":  "http://gw2000.lan:8080/get_livedata_info",
+		"https://weather.lan/gw/": "https://weather.lan/gw/get_livedata_info",
+	}
+	for gateway, want := range tests {
+		if got := gatewayURL(gateway); got != want {
+			t.Errorf("gatewayURL(%q) = %q, want %q", gateway, got, want)
+		}
+	}
+}
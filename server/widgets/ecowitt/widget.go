@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
 
 	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+	"themancavedashboard/shared/units"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -24,6 +27,7 @@ type EcowittWidget struct {
 type EcowittResponse struct {
 	Sensors []SoilMoistureSensor `json:"sensors"`
 	Indoor  *IndoorSensor        `json:"indoor"`
+	Units   units.Units          `json:"units"`
 }
 
 // SoilMoistureSensor represents a soil moisture sensor
@@ -39,9 +43,9 @@ type SoilMoistureSensor struct {
 
 // IndoorSensor represents indoor environmental data
 type IndoorSensor struct {
-	Temperature float64 `json:"temperature"`
+	Temperature float64 `json:"temperature"` // in units.temperature
 	Humidity    float64 `json:"humidity"`
-	Pressure    float64 `json:"pressure"`
+	Pressure    float64 `json:"pressure"` // in units.pressure
 }
 
 // EcowittAPIResponse is the response from Ecowitt API
@@ -63,8 +67,12 @@ func (w *EcowittWidget) ID() string {
 	return "ecowitt"
 }
 
-// GetRequiredEnvVars returns required environment variables
+// GetRequiredEnvVars returns required environment variables. The cloud keys
+// aren't needed when the gateway is read on the local network.
 func (w *EcowittWidget) GetRequiredEnvVars() []string {
+	if shared.GetWidgetConfigValue("plants", "gateway_ip", "") != "" {
+		return []string{}
+	}
 	return []string{
 		"ECOWITT_API_KEY",
 		"ECOWITT_APPLICATION_KEY",
@@ -72,6 +80,17 @@ func (w *EcowittWidget) GetRequiredEnvVars() []string {
 	}
 }
 
+// ConfigSchema describes the "plants" section of config.json
+func (w *EcowittWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "plants",
+		Fields: append([]shared.ConfigField{
+			{Key: "sensors", Type: "array", Description: "Soil sensors with channel, name, ideal_min and ideal_max"},
+			{Key: "gateway_ip", Type: "string", Description: "Address of a GW1100/GW2000 gateway to read on the local network instead of the Ecowitt cloud"},
+		}, units.ConfigFields(units.TemperatureKey, units.PressureKey)...),
+	}
+}
+
 // Initialize loads configuration
 func (w *EcowittWidget) Initialize() error {
 	w.apiKey = os.Getenv("ECOWITT_API_KEY")
@@ -85,10 +104,36 @@ func (w *EcowittWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/ecowitt", w.getData)
 }
 
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *EcowittWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/ecowitt",
+			Summary:     "Soil moisture and indoor readings from the Ecowitt gateway",
+			Description: "Read from the gateway on the local network when gateway_ip is set, and from the Ecowitt cloud otherwise.",
+			Response:    EcowittResponse{},
+			Errors:      []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusBadGateway},
+		},
+	}
+}
+
 // getData handles GET /api/ecowitt
 func (w *EcowittWidget) getData(rw http.ResponseWriter, r *http.Request) {
+	if gateway := shared.GetWidgetConfigValue("plants", "gateway_ip", ""); gateway != "" {
+		response, err := fetchLiveData(r.Context(), gateway, units.For("plants"), w.getSensorConfigs())
+		if err != nil {
+			log.Printf("[Ecowitt] Error reading gateway %s: %v", gateway, err)
+			shared.WriteError(rw, http.StatusBadGateway, "Failed to read Ecowitt gateway")
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(response)
+		return
+	}
+
 	if w.apiKey == "" || w.appKey == "" || w.mac == "" {
-		http.Error(rw, `{"error":"Ecowitt API not configured"}`, http.StatusServiceUnavailable)
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Ecowitt API not configured")
 		return
 	}
 
@@ -97,30 +142,32 @@ func (w *EcowittWidget) getData(rw http.ResponseWriter, r *http.Request) {
 
 	resp, err := http.Get(url)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to fetch Ecowitt data"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to fetch Ecowitt data")
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to read Ecowitt response"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to read Ecowitt response")
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		http.Error(rw, fmt.Sprintf(`{"error":"Ecowitt API error: %s"}`, string(body)), resp.StatusCode)
+		shared.WriteError(rw, resp.StatusCode, fmt.Sprintf("Ecowitt API error: %s", string(body)))
 		return
 	}
 
 	var apiResp EcowittAPIResponse
 	if err := json.Unmarshal(body, &apiResp); err != nil {
-		http.Error(rw, `{"error":"Failed to parse Ecowitt data"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to parse Ecowitt data")
 		return
 	}
 
+	u := units.For("plants")
 	response := EcowittResponse{
 		Sensors: []SoilMoistureSensor{},
+		Units:   u,
 	}
 
 	// Get sensor config from ecowitt widget config
@@ -154,38 +201,7 @@ func (w *EcowittWidget) getData(rw http.ResponseWriter, r *http.Request) {
 		}
 
 		if foundMoisture {
-			// Get sensor config for this channel
-			sensorConfig, exists := sensorConfigs[channelKey]
-			if !exists {
-				// Use defaults if not configured
-				sensorConfig = SoilSensorConfig{
-					Channel:     channelKey,
-					Name:        fmt.Sprintf("Sensor %d", i),
-					Location:    "Unknown",
-					MinMoisture: 30,
-					MaxMoisture: 70,
-				}
-			}
-
-			sensor := SoilMoistureSensor{
-				Channel:     channelKey,
-				Name:        sensorConfig.Name,
-				Location:    sensorConfig.Location,
-				Moisture:    int(moistureVal),
-				MinMoisture: sensorConfig.MinMoisture,
-				MaxMoisture: sensorConfig.MaxMoisture,
-			}
-
-			// Determine moisture status using config ranges
-			if moistureVal < sensorConfig.MinMoisture {
-				sensor.MoistureStatus = "low"
-			} else if moistureVal > sensorConfig.MaxMoisture {
-				sensor.MoistureStatus = "high"
-			} else {
-				sensor.MoistureStatus = "good"
-			}
-
-			response.Sensors = append(response.Sensors, sensor)
+			response.Sensors = append(response.Sensors, soilSensor(i, moistureVal, sensorConfigs))
 		}
 	}
 
@@ -197,11 +213,9 @@ func (w *EcowittWidget) getData(rw http.ResponseWriter, r *http.Request) {
 	if indoorData, ok := apiResp.Data["indoor"].(map[string]interface{}); ok {
 		if tempData, ok := indoorData["temperature"].(map[string]interface{}); ok {
 			switch val := tempData["value"].(type) {
-			case float64:
-				indoor.Temperature = val
-				hasIndoorData = true
-			case string:
-				indoor.Temperature = parseFloat(val)
+			case float64, string:
+				from := readingUnit(tempData, units.Fahrenheit)
+				indoor.Temperature = units.Round(u.ConvertTemperature(parseFloat(val), from), u.Temperature)
 				hasIndoorData = true
 			}
 		}
@@ -222,11 +236,9 @@ func (w *EcowittWidget) getData(rw http.ResponseWriter, r *http.Request) {
 	if pressData, ok := apiResp.Data["pressure"].(map[string]interface{}); ok {
 		if relativeData, ok := pressData["relative"].(map[string]interface{}); ok {
 			switch val := relativeData["value"].(type) {
-			case float64:
-				indoor.Pressure = val
-				hasIndoorData = true
-			case string:
-				indoor.Pressure = parseFloat(val)
+			case float64, string:
+				from := readingUnit(relativeData, units.InchesOfMercury)
+				indoor.Pressure = units.Round(u.ConvertPressure(parseFloat(val), from), u.Pressure)
 				hasIndoorData = true
 			}
 		}
@@ -294,7 +306,51 @@ func (w *EcowittWidget) getSensorConfigs() map[string]SoilSensorConfig {
 	return configs
 }
 
+// soilSensor describes the moisture of soil channel i, named and judged by its
+// config, or by defaults if it has none
+func soilSensor(i int, moisture float64, sensorConfigs map[string]SoilSensorConfig) SoilMoistureSensor {
+	channelKey := fmt.Sprintf("soil_ch%d", i)
+	sensorConfig, exists := sensorConfigs[channelKey]
+	if !exists {
+		// Use defaults if not configured
+		sensorConfig = SoilSensorConfig{
+			Channel:     channelKey,
+			Name:        fmt.Sprintf("Sensor %d", i),
+			Location:    "Unknown",
+			MinMoisture: 30,
+			MaxMoisture: 70,
+		}
+	}
+
+	sensor := SoilMoistureSensor{
+		Channel:     channelKey,
+		Name:        sensorConfig.Name,
+		Location:    sensorConfig.Location,
+		Moisture:    int(moisture),
+		MinMoisture: sensorConfig.MinMoisture,
+		MaxMoisture: sensorConfig.MaxMoisture,
+	}
+
+	// Determine moisture status using config ranges
+	if moisture < sensorConfig.MinMoisture {
+		sensor.MoistureStatus = "low"
+	} else if moisture > sensorConfig.MaxMoisture {
+		sensor.MoistureStatus = "high"
+	} else {
+		sensor.MoistureStatus = "good"
+	}
+	return sensor
+}
+
 // Helper functions
+
+// readingUnit is the unit of a reading, which the API sends along with its value
+// (e.g. "℉" or "inHg") in the units set for the device
+func readingUnit(reading map[string]interface{}, fallback string) string {
+	text, _ := reading["unit"].(string)
+	return parseUnit(text, fallback)
+}
+
 func parseFloat(val interface{}) float64 {
 	switch v := val.(type) {
 	case float64:
@@ -0,0 +1,124 @@
+package meals
+
+import (
+	"regexp"
+	"strings"
+
+	"themancavedashboard/shared/ical"
+)
+
+// linkPattern finds web addresses in free text
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// Section headings in a meal's description. Lines under an ingredients heading
+// are ingredients until the next heading.
+var (
+	ingredientHeadings = []string{"ingredients", "ingredient list", "shopping list", "groceries"}
+	otherHeadings      = []string{"directions", "instructions", "method", "steps", "preparation", "notes", "recipe"}
+)
+
+// mealLinks returns the web addresses attached to a meal: its URL, then ATTACH
+// links, then links in the description, without repeats
+func mealLinks(vevent *ical.Component, description string) []string {
+	var links []string
+	add := func(link string) {
+		link = strings.TrimRight(strings.TrimSpace(link), ".,;:!?)]")
+		if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+			return
+		}
+		for _, existing := range links {
+			if existing == link {
+				return
+			}
+		}
+		links = append(links, link)
+	}
+
+	add(vevent.Text("URL"))
+	for _, attach := range vevent.All("ATTACH") {
+		// Inline files (ENCODING=BASE64) aren't links
+		if attach.Param("ENCODING") == "" && !strings.EqualFold(attach.Param("VALUE"), "BINARY") {
+			add(attach.Value)
+		}
+	}
+	for _, link := range linkPattern.FindAllString(description, -1) {
+		add(link)
+	}
+	return links
+}
+
+// descriptionIngredients picks the ingredients out of a meal's description: the
+// lines under an "Ingredients" heading, or failing that, the bulleted lines
+func descriptionIngredients(description string) []string {
+	var section, bullets []string
+	inSection, foundSection := false, false
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		heading, rest := splitHeading(line)
+		switch {
+		case matchesAny(heading, ingredientHeadings):
+			inSection, foundSection = true, true
+			// "Ingredients: eggs, milk" lists them on the heading's line
+			for _, item := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ';' }) {
+				if item = strings.TrimSpace(item); item != "" {
+					section = append(section, item)
+				}
+			}
+			continue
+		case matchesAny(heading, otherHeadings):
+			inSection = false
+			continue
+		}
+
+		item, bulleted := trimBullet(line)
+		if item == "" || linkPattern.MatchString(item) && strings.TrimSpace(linkPattern.ReplaceAllString(item, "")) == "" {
+			// A blank line ends a section that has started, but may follow its heading
+			if line == "" && inSection && len(section) > 0 {
+				inSection = false
+			}
+			continue
+		}
+		if inSection {
+			section = append(section, item)
+		} else if bulleted {
+			bullets = append(bullets, item)
+		}
+	}
+	if foundSection {
+		return section
+	}
+	return bullets
+}
+
+// splitHeading splits "Ingredients: eggs" into a lower-case heading and the rest.
+// Lines without a colon are their own heading.
+func splitHeading(line string) (heading, rest string) {
+	line = strings.TrimLeft(line, "#* ")
+	heading, rest, _ = strings.Cut(line, ":")
+	return strings.ToLower(strings.Trim(heading, "*_ ")), strings.TrimLeft(rest, "*_ ")
+}
+
+func matchesAny(s string, values []string) bool {
+	for _, value := range values {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// trimBullet removes a list marker such as "-", "•", "[ ]" or "1." from a line
+// and reports whether it was a bullet
+func trimBullet(line string) (string, bool) {
+	for _, marker := range []string{"- [ ]", "- [x]", "[ ]", "[x]", "-", "*", "•", "–", "·"} {
+		if rest, ok := strings.CutPrefix(line, marker); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	// Numbered lines are usually steps, so they only count under a heading
+	if i := strings.IndexAny(line, ".)"); i > 0 && i <= 2 && i+1 < len(line) && line[i+1] == ' ' &&
+		strings.Trim(line[:i], "0123456789") == "" {
+		return strings.TrimSpace(line[i+1:]), false
+	}
+	return line, false
+}
@@ -0,0 +1,364 @@
+package meals
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GroceryList is the response of GET /api/meals/groceries
+type GroceryList struct {
+	Start string        `json:"start"` // YYYY-MM-DD, first day covered
+	End   string        `json:"end"`   // YYYY-MM-DD, last day covered
+	Meals []string      `json:"meals"`
+	Items []GroceryItem `json:"items"`
+	// MealsWithoutIngredients lists meals whose ingredients couldn't be found, so
+	// they can be shopped for by hand
+	MealsWithoutIngredients []string `json:"mealsWithoutIngredients"`
+}
+
+// GroceryItem is one ingredient totalled across the meals that need it
+type GroceryItem struct {
+	Name     string   `json:"name"`
+	Quantity float64  `json:"quantity,omitempty"` // 0 when no amount was given
+	Unit     string   `json:"unit,omitempty"`
+	Text     string   `json:"text"`  // e.g. "1 1/2 cups flour"
+	Meals    []string `json:"meals"` // titles of the meals that use it
+	Lines    []string `json:"lines"` // the ingredient lines as written
+}
+
+// unit is a measure ingredients are given in. Units of volume and of mass
+// convert to millilitres and grams so they can be added up; other units, such
+// as cans or cloves, only add up with themselves.
+type unit struct {
+	name    string
+	plural  string // same as name for abbreviations
+	measure string // "volume", "mass", or the unit's own name
+	factor  float64
+}
+
+var (
+	teaspoon   = unit{"tsp", "tsp", "volume", 4.92892}
+	tablespoon = unit{"tbsp", "tbsp", "volume", 14.7868}
+)
+
+// unitAliases maps the ways units are written to the unit
+var unitAliases = map[string]unit{}
+
+func init() {
+	add := func(u unit, aliases ...string) {
+		unitAliases[u.name] = u
+		for _, alias := range aliases {
+			unitAliases[alias] = u
+		}
+	}
+	add(teaspoon, "tsps", "teaspoon", "teaspoons")
+	add(tablespoon, "tbsps", "tbs", "tbl", "tablespoon", "tablespoons")
+	add(unit{"cup", "cups", "volume", 236.588}, "cups", "c")
+	add(unit{"fl oz", "fl oz", "volume", 29.5735}, "fluid ounce", "fluid ounces")
+	add(unit{"pint", "pints", "volume", 473.176}, "pints", "pt")
+	add(unit{"quart", "quarts", "volume", 946.353}, "quarts", "qt")
+	add(unit{"gallon", "gallons", "volume", 3785.41}, "gallons", "gal")
+	add(unit{"ml", "ml", "volume", 1}, "milliliter", "milliliters", "millilitre", "millilitres")
+	add(unit{"cl", "cl", "volume", 10})
+	add(unit{"dl", "dl", "volume", 100})
+	add(unit{"l", "l", "volume", 1000}, "liter", "liters", "litre", "litres")
+	add(unit{"mg", "mg", "mass", 0.001})
+	add(unit{"g", "g", "mass", 1}, "gr", "gram", "grams", "gramme", "grammes")
+	add(unit{"kg", "kg", "mass", 1000}, "kilo", "kilos", "kilogram", "kilograms")
+	add(unit{"oz", "oz", "mass", 28.3495}, "ounce", "ounces")
+	add(unit{"lb", "lb", "mass", 453.592}, "lbs", "pound", "pounds")
+	for _, name := range []string{"clove", "can", "jar", "package", "bunch", "pinch", "dash", "slice", "stick", "head", "sprig", "bottle", "bag", "box", "handful", "sheet", "stalk", "piece"} {
+		plural := name + "s"
+		if strings.HasSuffix(name, "ch") || strings.HasSuffix(name, "sh") || strings.HasSuffix(name, "x") {
+			plural = name + "es"
+		}
+		add(unit{name, plural, name, 1}, plural)
+	}
+	unitAliases["pkg"] = unitAliases["package"]
+	unitAliases["packet"] = unitAliases["package"]
+	unitAliases["packets"] = unitAliases["package"]
+}
+
+// unicodeFractions are the vulgar fraction characters recipes use
+var unicodeFractions = strings.NewReplacer(
+	"½", " 1/2", "⅓", " 1/3", "⅔", " 2/3", "¼", " 1/4", "¾", " 3/4",
+	"⅕", " 1/5", "⅛", " 1/8", "⅜", " 3/8", "⅝", " 5/8", "⅞", " 7/8", "⁄", "/",
+)
+
+// quantityPattern reads a leading amount such as "2", "1.5", "1/2", "1 1/2" or a
+// range such as "2-3", whose upper end is used so there's enough
+var quantityPattern = regexp.MustCompile(`^(\d+\s+\d+/\d+|\d+/\d+|\d+(?:[.,]\d+)?)(?:\s*(?:-|–|to)\s*(\d+\s+\d+/\d+|\d+/\d+|\d+(?:[.,]\d+)?))?\s*`)
+
+// parenPattern matches notes such as "(15 oz)" or "(about 2 cups)"
+var parenPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// ingredient is a parsed ingredient line
+type ingredient struct {
+	quantity float64 // 0 when no amount was given
+	unit     unit    // zero for counted items ("2 onions")
+	name     string  // lower case, as written
+	key      string  // name with the last word made singular, for merging
+}
+
+// parseIngredient reads an ingredient line such as "1 1/2 cups flour, sifted"
+func parseIngredient(line string) (ingredient, bool) {
+	text := unicodeFractions.Replace(line)
+	text = strings.TrimSpace(parenPattern.ReplaceAllString(text, " "))
+
+	var ing ingredient
+	if m := quantityPattern.FindStringSubmatch(text); m != nil {
+		amount := m[1]
+		if m[2] != "" {
+			amount = m[2]
+		}
+		ing.quantity = parseAmount(amount)
+		text = text[len(m[0]):]
+	} else if rest, ok := cutWord(text, "a", "an"); ok {
+		// "a pinch of salt" or "an onion"
+		ing.quantity = 1
+		text = rest
+	}
+
+	if u, rest := leadingUnit(text); u.name != "" {
+		ing.unit = u
+		text = rest
+		if ing.quantity == 0 {
+			ing.quantity = 1
+		}
+	}
+	if rest, ok := cutWord(text, "of"); ok {
+		text = rest
+	}
+
+	// Whatever follows a comma is preparation, as in "onion, finely chopped"
+	text, _, _ = strings.Cut(text, ",")
+	ing.name = strings.Join(strings.Fields(strings.ToLower(strings.Trim(text, " .-*"))), " ")
+	if ing.name == "" {
+		return ingredient{}, false
+	}
+	words := strings.Fields(ing.name)
+	words[len(words)-1] = singular(words[len(words)-1])
+	ing.key = strings.Join(words, " ")
+	return ing, true
+}
+
+// parseAmount reads "2", "1.5", "1,5", "1/2" or "1 1/2"
+func parseAmount(s string) float64 {
+	total := 0.0
+	for _, part := range strings.Fields(s) {
+		if num, den, ok := strings.Cut(part, "/"); ok {
+			n, _ := strconv.ParseFloat(num, 64)
+			d, _ := strconv.ParseFloat(den, 64)
+			if d != 0 {
+				total += n / d
+			}
+			continue
+		}
+		v, _ := strconv.ParseFloat(strings.ReplaceAll(part, ",", "."), 64)
+		total += v
+	}
+	return total
+}
+
+// leadingUnit reads a unit from the start of text, returning the text after it
+func leadingUnit(text string) (unit, string) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		// A unit with nothing after it ("2 cups") has no ingredient to measure
+		return unit{}, text
+	}
+	// Two-word units first, so "fl oz" isn't read as a mass
+	if len(fields) > 2 {
+		two := strings.ToLower(strings.TrimSuffix(fields[0], ".") + " " + strings.TrimSuffix(fields[1], "."))
+		if u, ok := unitAliases[two]; ok {
+			return u, strings.Join(fields[2:], " ")
+		}
+	}
+	word := strings.TrimSuffix(fields[0], ".")
+	// "T" is a tablespoon and "t" a teaspoon; otherwise case doesn't matter
+	switch word {
+	case "T":
+		return tablespoon, strings.Join(fields[1:], " ")
+	case "t":
+		return teaspoon, strings.Join(fields[1:], " ")
+	}
+	if u, ok := unitAliases[strings.ToLower(word)]; ok {
+		return u, strings.Join(fields[1:], " ")
+	}
+	return unit{}, text
+}
+
+// cutWord removes one of the words from the start of text
+func cutWord(text string, words ...string) (string, bool) {
+	first, rest, ok := strings.Cut(text, " ")
+	if !ok {
+		return text, false
+	}
+	for _, word := range words {
+		if strings.EqualFold(first, word) {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return text, false
+}
+
+// singular makes an English plural singular, well enough to merge "onions" with
+// "onion". Words that only look plural, such as "asparagus", are left alone.
+func singular(word string) string {
+	switch {
+	case len(word) < 4 || strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us") || strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "xes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// groceryTotal is an item being added up; amounts are in the measure's base
+// unit (ml, g or the unit itself)
+type groceryTotal struct {
+	item    GroceryItem
+	key     string
+	base    float64
+	largest unit
+}
+
+// groceryItems adds up the ingredients of each meal, keyed by meal title.
+// Amounts in different units of the same measure are added and given in the
+// largest unit used, so "1 cup" and "2 tbsp" of flour make "1 1/8 cups".
+func groceryItems(meals []MealEvent) []GroceryItem {
+	totals := make(map[string]*groceryTotal)
+	var order []string // keys in the order first seen, so the output is stable
+	for _, meal := range meals {
+		for _, line := range mealIngredients(meal) {
+			ing, ok := parseIngredient(line)
+			if !ok {
+				continue
+			}
+			key := ing.key + "|" + ing.unit.measure
+			if ing.quantity == 0 {
+				key = ing.key + "|?"
+			}
+			total := totals[key]
+			if total == nil {
+				total = &groceryTotal{item: GroceryItem{Name: ing.name}, key: ing.key}
+				totals[key] = total
+				order = append(order, key)
+			}
+			factor := ing.unit.factor
+			if factor == 0 {
+				factor = 1
+			}
+			total.base += ing.quantity * factor
+			if ing.unit.factor > total.largest.factor {
+				total.largest = ing.unit
+			}
+			total.item.Lines = append(total.item.Lines, line)
+			total.item.Meals = appendUnique(total.item.Meals, meal.Title)
+		}
+	}
+
+	// Ingredients with no amount ("salt, to taste") fold into a measured entry for
+	// the same ingredient, which covers them
+	measured := make(map[string]*groceryTotal)
+	for _, k := range order {
+		if !strings.HasSuffix(k, "|?") {
+			measured[totals[k].key] = totals[k]
+		}
+	}
+	for _, k := range order {
+		total := totals[k]
+		other := measured[total.key]
+		if !strings.HasSuffix(k, "|?") || other == nil {
+			continue
+		}
+		for _, meal := range total.item.Meals {
+			other.item.Meals = appendUnique(other.item.Meals, meal)
+		}
+		other.item.Lines = append(other.item.Lines, total.item.Lines...)
+		delete(totals, k)
+	}
+
+	items := []GroceryItem{}
+	for _, k := range order {
+		total, ok := totals[k]
+		if !ok {
+			continue
+		}
+		item := total.item
+		if total.base > 0 {
+			factor := total.largest.factor
+			if factor == 0 {
+				factor = 1
+			}
+			item.Quantity = math.Round(total.base/factor*100) / 100
+			item.Unit = total.largest.name
+		}
+		item.Text = groceryText(item, total.largest)
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].Unit < items[j].Unit
+	})
+	return items
+}
+
+// groceryText writes an item as it would go on a list, e.g. "1 1/2 cups flour"
+func groceryText(item GroceryItem, u unit) string {
+	if item.Quantity == 0 {
+		return item.Name
+	}
+	parts := []string{formatQuantity(item.Quantity)}
+	if item.Quantity > 1 && u.plural != "" {
+		parts = append(parts, u.plural)
+	} else if item.Unit != "" {
+		parts = append(parts, item.Unit)
+	}
+	return strings.Join(append(parts, item.Name), " ")
+}
+
+// commonFractions are the fractions amounts are shown as, rather than decimals
+var commonFractions = []struct {
+	value float64
+	text  string
+}{
+	{1.0 / 8, "1/8"}, {1.0 / 4, "1/4"}, {1.0 / 3, "1/3"}, {3.0 / 8, "3/8"}, {1.0 / 2, "1/2"},
+	{5.0 / 8, "5/8"}, {2.0 / 3, "2/3"}, {3.0 / 4, "3/4"}, {7.0 / 8, "7/8"},
+}
+
+// formatQuantity writes 1.5 as "1 1/2" and 0.33 as "1/3"; other amounts keep up
+// to two decimals
+func formatQuantity(q float64) string {
+	whole, frac := math.Modf(q)
+	if frac < 0.01 {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	for _, f := range commonFractions {
+		if math.Abs(frac-f.value) < 0.01 {
+			if whole == 0 {
+				return f.text
+			}
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + f.text
+		}
+	}
+	return strconv.FormatFloat(math.Round(q*100)/100, 'f', -1, 64)
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
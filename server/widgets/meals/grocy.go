@@ -0,0 +1,223 @@
+package meals
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grocyProvider reads the meal plan of a Grocy instance
+type grocyProvider struct {
+	api apiClient
+}
+
+type grocyMealPlan struct {
+	ID             apiID     `json:"id"`
+	Day            string    `json:"day"`  // YYYY-MM-DD
+	Type           string    `json:"type"` // recipe, product or note
+	RecipeID       apiID     `json:"recipe_id"`
+	Note           string    `json:"note"`
+	ProductID      apiID     `json:"product_id"`
+	ProductAmount  apiNumber `json:"product_amount"`
+	ProductUnitID  apiID     `json:"product_qu_id"`
+	SectionID      apiID     `json:"section_id"`
+	RecipeServings apiNumber `json:"recipe_servings"`
+}
+
+type grocySection struct {
+	ID       apiID  `json:"id"`
+	Name     string `json:"name"`      // e.g. Breakfast; empty for the default section
+	TimeInfo string `json:"time_info"` // HH:MM, when set
+}
+
+type grocyRecipe struct {
+	ID              apiID     `json:"id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"` // HTML
+	PictureFileName string    `json:"picture_file_name"`
+	BaseServings    apiNumber `json:"base_servings"`
+}
+
+type grocyIngredient struct {
+	ProductID      apiID     `json:"product_id"`
+	Amount         apiNumber `json:"amount"`
+	UnitID         apiID     `json:"qu_id"`
+	Note           string    `json:"note"`
+	VariableAmount string    `json:"variable_amount"` // replaces the amount, e.g. "a handful"
+}
+
+// grocyNames holds product and quantity unit names by ID
+type grocyNames struct {
+	products map[apiID]string
+	units    map[apiID][2]string // singular and plural
+}
+
+func (p *grocyProvider) Name() string {
+	return "Grocy at " + hostOf(p.api.base)
+}
+
+func (p *grocyProvider) Meals(ctx context.Context, start, end time.Time, loc *time.Location) ([]MealEvent, error) {
+	query := url.Values{"query[]": {
+		"day>=" + start.Format("2006-01-02"),
+		"day<" + end.Format("2006-01-02"),
+	}}
+	var plans []grocyMealPlan
+	if err := p.api.get(ctx, "/api/objects/meal_plan", query, &plans); err != nil {
+		return nil, err
+	}
+	var sectionList []grocySection
+	if err := p.api.get(ctx, "/api/objects/meal_plan_sections", nil, &sectionList); err != nil {
+		return nil, err
+	}
+	sections := make(map[apiID]grocySection, len(sectionList))
+	for _, section := range sectionList {
+		sections[section.ID] = section
+	}
+
+	// Product entries are named from the product list, only read when needed
+	var names *grocyNames
+	meals := []MealEvent{}
+	for _, plan := range plans {
+		section := sections[plan.SectionID]
+		meal, ok := plannedMeal("grocy-"+string(plan.ID), "", plan.Day, "", section.TimeInfo, loc)
+		if !ok {
+			continue
+		}
+		meal.MealType = mealType(section.Name)
+
+		switch plan.Type {
+		case "recipe":
+			recipe := p.recipe(ctx, plan.RecipeID)
+			if recipe == nil {
+				continue
+			}
+			meal.Title, meal.Recipe, meal.Image = recipe.Name, recipe, recipe.Image
+			meal.URL = recipe.URL
+			meal.Links = []string{meal.URL}
+		case "product":
+			if names == nil {
+				loaded, err := p.names(ctx)
+				if err != nil {
+					return nil, err
+				}
+				names = loaded
+			}
+			meal.Title = names.products[plan.ProductID]
+			if plan.ProductAmount > 0 {
+				meal.Description = names.amount(plan.ProductAmount, plan.ProductUnitID) + " " + meal.Title
+			}
+		default:
+			meal.Title = plan.Note
+		}
+		if meal.Title == "" {
+			continue
+		}
+		if plan.Type != "note" && meal.Description == "" {
+			meal.Description = plan.Note
+		}
+		meals = append(meals, meal)
+	}
+	return meals, nil
+}
+
+// recipe returns a planned recipe with its ingredients, or nil if it can't be
+// read, as the meal plan has only its ID
+func (p *grocyProvider) recipe(ctx context.Context, id apiID) *Recipe {
+	path := "/api/objects/recipes/" + url.PathEscape(string(id))
+	recipe, err := recipes.get(ctx, p.api.base+path, func(ctx context.Context) (*Recipe, error) {
+		var full grocyRecipe
+		if err := p.api.get(ctx, path, nil, &full); err != nil {
+			return nil, err
+		}
+		recipe := &Recipe{
+			Name:        full.Name,
+			URL:         p.api.base + "/recipes?recipe=" + url.QueryEscape(string(full.ID)),
+			Ingredients: []string{},
+		}
+		if full.PictureFileName != "" {
+			file := base64.StdEncoding.EncodeToString([]byte(full.PictureFileName))
+			recipe.Image = p.api.base + "/api/files/recipepictures/" + url.PathEscape(file) + "?force_serve_as=picture&best_fit_width=640"
+		}
+		if full.BaseServings > 0 {
+			recipe.Yield = strconv.FormatFloat(float64(full.BaseServings), 'f', -1, 64)
+		}
+
+		var ingredients []grocyIngredient
+		query := url.Values{"query[]": {"recipe_id=" + string(full.ID)}}
+		if err := p.api.get(ctx, "/api/objects/recipes_pos", query, &ingredients); err != nil {
+			return nil, err
+		}
+		if len(ingredients) == 0 {
+			return recipe, nil
+		}
+		names, err := p.names(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ing := range ingredients {
+			product := names.products[ing.ProductID]
+			if product == "" {
+				continue
+			}
+			amount := ing.VariableAmount
+			if amount == "" && ing.Amount > 0 {
+				amount = names.amount(ing.Amount, ing.UnitID)
+			}
+			text := strings.TrimSpace(amount + " " + product)
+			if ing.Note != "" {
+				text += ", " + ing.Note
+			}
+			recipe.Ingredients = append(recipe.Ingredients, text)
+		}
+		return recipe, nil
+	})
+	if err != nil {
+		return nil
+	}
+	return recipe
+}
+
+// names reads the product and quantity unit lists
+func (p *grocyProvider) names(ctx context.Context) (*grocyNames, error) {
+	var products []struct {
+		ID   apiID  `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := p.api.get(ctx, "/api/objects/products", nil, &products); err != nil {
+		return nil, err
+	}
+	var units []struct {
+		ID         apiID  `json:"id"`
+		Name       string `json:"name"`
+		NamePlural string `json:"name_plural"`
+	}
+	if err := p.api.get(ctx, "/api/objects/quantity_units", nil, &units); err != nil {
+		return nil, err
+	}
+
+	names := &grocyNames{products: make(map[apiID]string), units: make(map[apiID][2]string)}
+	for _, product := range products {
+		names.products[product.ID] = product.Name
+	}
+	for _, unit := range units {
+		names.units[unit.ID] = [2]string{unit.Name, unit.NamePlural}
+	}
+	return names, nil
+}
+
+// amount writes an amount in a quantity unit, e.g. "2 Pieces"
+func (n *grocyNames) amount(amount apiNumber, unitID apiID) string {
+	text := strconv.FormatFloat(float64(amount), 'f', -1, 64)
+	unit := n.units[unitID]
+	name := unit[0]
+	if amount != 1 && unit[1] != "" {
+		name = unit[1]
+	}
+	if name == "" {
+		return text
+	}
+	return text + " " + name
+}
@@ -0,0 +1,62 @@
+package meals
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGrocyMeals(t *testing.T) {
+	standIn := &plannerStandIn{t: t, header: "GROCY-API-KEY", value: "key", files: map[string]string{
+		"/api/objects/meal_plan":          "grocy-meal-plan.json",
+		"/api/objects/meal_plan_sections": "grocy-meal-plan-sections.json",
+		"/api/objects/recipes/3":          "grocy-recipe.json",
+		"/api/objects/recipes_pos":        "grocy-recipes-pos.json",
+		"/api/objects/products":           "grocy-products.json",
+		"/api/objects/quantity_units":     "grocy-quantity-units.json",
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	loc := time.FixedZone("CEST", 2*60*60)
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, loc)
+	provider := &grocyProvider{api: apiClient{base: server.URL, header: "GROCY-API-KEY", value: "key"}}
+	meals, err := provider.Meals(context.Background(), start, start.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatalf("Meals: %v", err)
+	}
+
+	if got, want := standIn.request("/api/objects/meal_plan").Query()["query[]"], []string{"day>=2024-07-08", "day<2024-07-15"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("meal plan query = %q, want %q", got, want)
+	}
+	if got := standIn.request("/api/objects/recipes_pos").Query()["query[]"]; !reflect.DeepEqual(got, []string{"recipe_id=3"}) {
+		t.Errorf("ingredient query = %q, want recipe_id=3", got)
+	}
+
+	// The entry for a recipe that can't be read is left out
+	page := server.URL + "/recipes?recipe=3"
+	image := server.URL + "/api/files/recipepictures/Y2hpbGkuanBn?force_serve_as=picture&best_fit_width=640"
+	want := []MealEvent{
+		{
+			ID: "grocy-21", Title: "Chili con carne",
+			Start: "2024-07-08T18:00:00+02:00", End: "2024-07-08T19:00:00+02:00",
+			MealType: "dinner", Image: image, URL: page, Links: []string{page},
+			Recipe: &Recipe{
+				Name: "Chili con carne", URL: page, Image: image, Yield: "4",
+				Ingredients: []string{"500 Grams Ground beef", "1 Piece Onion, chopped", "a pinch Chili powder"},
+			},
+		},
+		{
+			ID: "grocy-22", Title: "Greek yogurt", AllDay: true,
+			Start: "2024-07-09T00:00:00+02:00", End: "2024-07-10T00:00:00+02:00",
+			MealType: "breakfast", Description: "2 Cups Greek yogurt",
+		},
+		{
+			ID: "grocy-23", Title: "Eat out", AllDay: true,
+			Start: "2024-07-10T00:00:00+02:00", End: "2024-07-11T00:00:00+02:00",
+		},
+	}
+	checkMeals(t, meals, want)
+}
@@ -0,0 +1,93 @@
+package meals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/ical"
+)
+
+// defaultFeedRefresh is how often the feed is fetched unless refresh_minutes is
+// set; dashboard refreshes in between are served from the cached copy
+const defaultFeedRefresh = 15 * time.Minute
+
+// feeds keeps the last good copy of each meal feed
+var feeds = &ical.FeedCache{Client: mealsHTTPClient}
+
+// icalProvider reads the meal plan from an iCal feed, such as AnyList's or a
+// shared calendar
+type icalProvider struct {
+	url string
+}
+
+func (p *icalProvider) Name() string {
+	return "iCal feed at " + hostOf(p.url)
+}
+
+func (p *icalProvider) Meals(ctx context.Context, start, end time.Time, loc *time.Location) ([]MealEvent, error) {
+	refresh := time.Duration(shared.GetWidgetConfigNumber("meals", "refresh_minutes", defaultFeedRefresh.Minutes()) * float64(time.Minute))
+	calendar, err := feeds.Get(ctx, p.url, refresh)
+	if errors.Is(err, ical.ErrNotCalendar) {
+		return nil, errInvalidFeed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := feeds.Status(p.url); ok && status.Stale {
+		fmt.Printf("[Meals] Serving the last good copy of %s: %s\n", p.Name(), status.Error)
+	}
+
+	meals := []MealEvent{}
+	for _, event := range calendar.Events(start, end, loc) {
+		if event.Start.Before(start.Add(-time.Hour)) || event.Summary == "" {
+			continue
+		}
+		meals = append(meals, mealEvent(event, loc))
+	}
+	return meals, nil
+}
+
+// mealEvent converts a calendar occurrence. Occurrences of a recurring meal share
+// a UID, so their ID includes the original start.
+func mealEvent(event ical.Event, loc *time.Location) MealEvent {
+	id := event.UID
+	if event.Recurring {
+		id += "_" + event.RecurrenceID.UTC().Format("20060102T150405Z")
+	}
+	meal := MealEvent{
+		ID:          id,
+		Title:       event.Summary,
+		Start:       event.Start.In(loc).Format(time.RFC3339),
+		End:         event.End.In(loc).Format(time.RFC3339),
+		AllDay:      event.AllDay,
+		MealType:    icalMealType(event.Component),
+		Description: event.Description,
+		Links:       mealLinks(event.Component, event.Description),
+		Ingredients: descriptionIngredients(event.Description),
+	}
+	if len(meal.Links) > 0 {
+		meal.URL = meal.Links[0]
+	}
+	// IMAGE (RFC 7986) may also hold an inline picture, which isn't used
+	if image := strings.TrimSpace(event.Component.Text("IMAGE")); strings.HasPrefix(image, "http") {
+		meal.Image = image
+	}
+	return meal
+}
+
+// icalMealType takes the meal type from the event's CATEGORIES, when one of them
+// is a known type such as Dinner
+func icalMealType(vevent *ical.Component) string {
+	for _, p := range vevent.All("CATEGORIES") {
+		for _, category := range strings.Split(p.Value, ",") {
+			if t := mealType(category); mealTypeOrder[t] > 0 {
+				return t
+			}
+		}
+	}
+	return ""
+}
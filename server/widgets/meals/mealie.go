@@ -0,0 +1,223 @@
+package meals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mealiePageSize is how many meal plan entries are asked for at once
+const mealiePageSize = 100
+
+// mealieGroups remembers each Mealie instance's group slug, which recipe page
+// addresses include
+var mealieGroups sync.Map
+
+// mealieProvider reads the meal plan of a Mealie instance (v1 or later)
+type mealieProvider struct {
+	api apiClient
+}
+
+// mealieMealPlan is a page of Mealie's meal plan
+type mealieMealPlan struct {
+	Items      []mealieEntry `json:"items"`
+	TotalPages int           `json:"total_pages"`
+}
+
+type mealieEntry struct {
+	ID        apiID         `json:"id"`
+	Date      string        `json:"date"`      // YYYY-MM-DD
+	EntryType string        `json:"entryType"` // breakfast, lunch, dinner, side...
+	Title     string        `json:"title"`     // for entries without a recipe
+	Text      string        `json:"text"`
+	Recipe    *mealieRecipe `json:"recipe"`
+}
+
+type mealieRecipe struct {
+	ID               apiID              `json:"id"`
+	Slug             string             `json:"slug"`
+	Name             string             `json:"name"`
+	Description      string             `json:"description"`
+	Image            string             `json:"image"` // a cache key; empty when the recipe has no image
+	RecipeYield      string             `json:"recipeYield"`
+	TotalTime        string             `json:"totalTime"`
+	OrgURL           string             `json:"orgURL"` // where the recipe was imported from
+	RecipeIngredient []mealieIngredient `json:"recipeIngredient"`
+}
+
+type mealieIngredient struct {
+	Display      string    `json:"display"` // the ingredient as Mealie shows it
+	OriginalText string    `json:"originalText"`
+	Quantity     apiNumber `json:"quantity"`
+	Note         string    `json:"note"`
+	Unit         *struct {
+		Name string `json:"name"`
+	} `json:"unit"`
+	Food *struct {
+		Name string `json:"name"`
+	} `json:"food"`
+}
+
+func (p *mealieProvider) Name() string {
+	return "Mealie at " + hostOf(p.api.base)
+}
+
+func (p *mealieProvider) Meals(ctx context.Context, start, end time.Time, loc *time.Location) ([]MealEvent, error) {
+	// Mealie 2 moved meal plans from groups to households
+	path := "/api/households/mealplans"
+	var entries []mealieEntry
+	for page := 1; ; page++ {
+		query := url.Values{
+			"start_date": {start.Format("2006-01-02")},
+			"end_date":   {end.AddDate(0, 0, -1).Format("2006-01-02")},
+			"page":       {strconv.Itoa(page)},
+			"perPage":    {strconv.Itoa(mealiePageSize)},
+		}
+		var plan mealieMealPlan
+		err := p.api.get(ctx, path, query, &plan)
+		var apiErr *apiError
+		if page == 1 && errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound && path != "/api/groups/mealplans" {
+			path, page = "/api/groups/mealplans", 0
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, plan.Items...)
+		if page >= plan.TotalPages {
+			break
+		}
+	}
+
+	group := p.group(ctx)
+	meals := []MealEvent{}
+	for _, entry := range entries {
+		title := entry.Title
+		if entry.Recipe != nil && entry.Recipe.Name != "" {
+			title = entry.Recipe.Name
+		}
+		meal, ok := plannedMeal("mealie-"+string(entry.ID), title, entry.Date, "", "", loc)
+		if !ok || title == "" {
+			continue
+		}
+		meal.MealType = mealType(entry.EntryType)
+		meal.Description = entry.Text
+		if recipe := entry.Recipe; recipe != nil && recipe.Slug != "" {
+			meal.URL = p.recipePage(group, recipe.Slug)
+			meal.Links = []string{meal.URL}
+			if recipe.OrgURL != "" {
+				meal.Links = append(meal.Links, recipe.OrgURL)
+			}
+			if recipe.Image != "" {
+				meal.Image = fmt.Sprintf("%s/api/media/recipes/%s/images/original.webp", p.api.base, recipe.ID)
+			}
+			meal.Recipe = p.recipe(ctx, recipe, meal)
+			if meal.Description == "" {
+				meal.Description = recipe.Description
+			}
+		}
+		meals = append(meals, meal)
+	}
+	return meals, nil
+}
+
+// recipe returns a planned recipe with its ingredients, which the meal plan
+// leaves out. If they can't be read, the recipe is returned without them.
+func (p *mealieProvider) recipe(ctx context.Context, summary *mealieRecipe, meal MealEvent) *Recipe {
+	path := "/api/recipes/" + url.PathEscape(summary.Slug)
+	recipe, err := recipes.get(ctx, p.api.base+path, func(ctx context.Context) (*Recipe, error) {
+		var full mealieRecipe
+		if err := p.api.get(ctx, path, nil, &full); err != nil {
+			return nil, err
+		}
+		recipe := &Recipe{
+			Name:        full.Name,
+			URL:         meal.URL,
+			Image:       meal.Image,
+			Yield:       full.RecipeYield,
+			Ingredients: []string{},
+		}
+		recipe.TotalMinutes, _ = isoMinutes(full.TotalTime)
+		for _, ing := range full.RecipeIngredient {
+			if text := ing.text(); text != "" {
+				recipe.Ingredients = append(recipe.Ingredients, text)
+			}
+		}
+		return recipe, nil
+	})
+	if err != nil {
+		return &Recipe{Name: summary.Name, URL: meal.URL, Image: meal.Image, Yield: summary.RecipeYield, Ingredients: []string{}}
+	}
+	return recipe
+}
+
+// text writes an ingredient out as a line. Parsed ingredients are put together
+// with the note after a comma, so the grocery list can tell them apart; others
+// are as Mealie shows them.
+func (ing mealieIngredient) text() string {
+	if ing.Food == nil || ing.Food.Name == "" {
+		if ing.Display != "" {
+			return strings.Join(strings.Fields(ing.Display), " ")
+		}
+		if ing.OriginalText != "" {
+			return ing.OriginalText
+		}
+	}
+	var parts []string
+	if ing.Quantity > 0 {
+		parts = append(parts, strconv.FormatFloat(float64(ing.Quantity), 'f', -1, 64))
+	}
+	if ing.Unit != nil && ing.Unit.Name != "" {
+		parts = append(parts, ing.Unit.Name)
+	}
+	if ing.Food != nil && ing.Food.Name != "" {
+		parts = append(parts, ing.Food.Name)
+	}
+	text := strings.Join(parts, " ")
+	if ing.Note != "" {
+		if text == "" {
+			return ing.Note
+		}
+		text += ", " + ing.Note
+	}
+	return text
+}
+
+// group returns the slug of the token's group, or "" for Mealie versions
+// without one
+func (p *mealieProvider) group(ctx context.Context) string {
+	if group, ok := mealieGroups.Load(p.api.base); ok {
+		return group.(string)
+	}
+	var self struct {
+		GroupSlug string `json:"groupSlug"`
+	}
+	if err := p.api.get(ctx, "/api/users/self", nil, &self); err != nil {
+		return ""
+	}
+	mealieGroups.Store(p.api.base, self.GroupSlug)
+	return self.GroupSlug
+}
+
+// recipePage is the address of a recipe in Mealie's web app, which includes the
+// group's slug since Mealie 1.0
+func (p *mealieProvider) recipePage(group, slug string) string {
+	if group != "" {
+		return p.api.base + "/g/" + url.PathEscape(group) + "/r/" + url.PathEscape(slug)
+	}
+	return p.api.base + "/recipe/" + url.PathEscape(slug)
+}
+
+// hostOf returns a URL's host, for logs
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
@@ -0,0 +1,91 @@
+package meals
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMealieMeals(t *testing.T) {
+	standIn := &plannerStandIn{t: t, header: "Authorization", value: "Bearer token", files: map[string]string{
+		"/api/households/mealplans":         "mealie-households-mealplans.json",
+		"/api/users/self":                   "mealie-users-self.json",
+		"/api/recipes/chicken-tikka-masala": "mealie-recipe.json",
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	loc := time.FixedZone("EDT", -4*60*60)
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, loc)
+	provider := &mealieProvider{api: apiClient{base: server.URL, header: "Authorization", value: "Bearer token"}}
+	meals, err := provider.Meals(context.Background(), start, start.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatalf("Meals: %v", err)
+	}
+
+	query := standIn.request("/api/households/mealplans").Query()
+	if got := query.Get("start_date") + " " + query.Get("end_date"); got != "2024-07-08 2024-07-14" {
+		t.Errorf("asked for meals from %s, want 2024-07-08 2024-07-14", got)
+	}
+	if query.Get("page") != "1" || query.Get("perPage") != "100" {
+		t.Errorf("asked for page %q of %q, want page 1 of 100", query.Get("page"), query.Get("perPage"))
+	}
+
+	// The entry without a title or recipe is left out
+	page := server.URL + "/g/home/r/chicken-tikka-masala"
+	image := server.URL + "/api/media/recipes/5d1c8a4e-7b7f-4c8e-9a43-1f0c2e7d9b11/images/original.webp"
+	want := []MealEvent{
+		{
+			ID: "mealie-12", Title: "Chicken Tikka Masala", AllDay: true,
+			Start: "2024-07-08T00:00:00-04:00", End: "2024-07-09T00:00:00-04:00",
+			MealType: "dinner", Image: image, Description: "Charred chicken in a creamy tomato sauce.",
+			URL: page, Links: []string{page, "https://www.example.com/recipes/chicken-tikka-masala"},
+			Recipe: &Recipe{
+				Name: "Chicken Tikka Masala", URL: page, Image: image, Yield: "4 servings",
+				Ingredients: []string{"1.5 pound chicken thigh, cut into cubes", "2 tablespoon butter", "Salt to taste"},
+			},
+		},
+		{
+			ID: "mealie-13", Title: "Leftovers", AllDay: true,
+			Start: "2024-07-09T00:00:00-04:00", End: "2024-07-10T00:00:00-04:00",
+			MealType: "lunch", Description: "From Monday",
+		},
+	}
+	checkMeals(t, meals, want)
+}
+
+func TestMealieMealsGroups(t *testing.T) {
+	// Mealie 1 has meal plans under groups, so the households endpoint is a 404.
+	// The recipe can't be read either, leaving it without ingredients.
+	standIn := &plannerStandIn{t: t, header: "Authorization", value: "Bearer token", files: map[string]string{
+		"/api/groups/mealplans": "mealie-groups-mealplans.json",
+		"/api/users/self":       "mealie-users-self.json",
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	provider := &mealieProvider{api: apiClient{base: server.URL, header: "Authorization", value: "Bearer token"}}
+	meals, err := provider.Meals(context.Background(), start, start.AddDate(0, 0, 7), time.UTC)
+	if err != nil {
+		t.Fatalf("Meals: %v", err)
+	}
+	if query := standIn.request("/api/groups/mealplans").Query(); query.Get("page") != "1" {
+		t.Errorf("asked the groups endpoint for page %q, want 1", query.Get("page"))
+	}
+
+	page := server.URL + "/g/home/r/blueberry-pancakes"
+	want := []MealEvent{{
+		ID: "mealie-7", Title: "Blueberry Pancakes", AllDay: true,
+		Start: "2024-07-11T00:00:00Z", End: "2024-07-12T00:00:00Z",
+		MealType: "breakfast", URL: page, Links: []string{page},
+		Recipe: &Recipe{Name: "Blueberry Pancakes", URL: page, Yield: "8 pancakes", Ingredients: []string{}},
+	}}
+	checkMeals(t, meals, want)
+
+	provider.api.value = "Bearer wrong"
+	if _, err := provider.Meals(context.Background(), start, start.AddDate(0, 0, 7), time.UTC); err == nil {
+		t.Error("Meals with a wrong token succeeded")
+	}
+}
@@ -0,0 +1,474 @@
+package meals
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/ical"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits on planned meals, which are typed in by hand
+const (
+	maxPlanTitle = 200
+	maxPlanNotes = 4000
+	maxPlanBody  = 64 << 10
+)
+
+// defaultPlanSlot is the slot of meals planned without one
+const defaultPlanSlot = "dinner"
+
+// errPlanNotFound is returned for an unknown planned meal ID
+var errPlanNotFound = errors.New("planned meal not found")
+
+// PlannedMealInput is the body of POST and PUT /api/meals/plan
+type PlannedMealInput struct {
+	Date  string `json:"date"` // YYYY-MM-DD; for weekly meals, the first week
+	Slot  string `json:"slot"` // breakfast, lunch, dinner, snack...; default dinner
+	Title string `json:"title"`
+	Notes string `json:"notes,omitempty"` // ingredients listed here go on the grocery list
+	// RecipeURL is read for a schema.org recipe like links in an iCal feed
+	RecipeURL    string `json:"recipeUrl,omitempty"`
+	RepeatWeekly bool   `json:"repeatWeekly,omitempty"`
+	Until        string `json:"until,omitempty"` // YYYY-MM-DD, last day a weekly meal may fall on
+}
+
+// PlannedMeal is a meal in the built-in meal plan
+type PlannedMeal struct {
+	ID string `json:"id"`
+	PlannedMealInput
+	// Skip lists the dates a weekly meal has been removed from
+	Skip []string `json:"skip,omitempty"`
+}
+
+// planPath is where the meal plan is stored
+func planPath() string {
+	return fmt.Sprintf("/app/config/%s", shared.GetWidgetConfigValue("meals", "plan_filename", "meal-plan.json"))
+}
+
+// planStore reads and writes the meal plan file. The file is re-read when its
+// modification time changes, so hand edits are picked up.
+type planStore struct {
+	locate  func() string // the file's path, which follows the config
+	mu      sync.Mutex
+	path    string
+	meals   []PlannedMeal
+	modTime time.Time
+}
+
+var plan = &planStore{locate: planPath}
+
+// loadLocked refreshes meals from disk if the file changed. Callers hold mu.
+func (s *planStore) loadLocked() error {
+	if path := s.locate(); path != s.path {
+		s.path, s.meals = path, nil
+	}
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.meals, s.modTime = []PlannedMeal{}, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.meals != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	meals := []PlannedMeal{}
+	if err := json.Unmarshal(data, &meals); err != nil {
+		return err
+	}
+	// The file may have been edited by hand
+	for i := range meals {
+		if meals[i].Slot == "" {
+			meals[i].Slot = defaultPlanSlot
+		}
+	}
+	s.meals, s.modTime = meals, info.ModTime()
+	return nil
+}
+
+// saveLocked writes meals through a temp file so a crash can't truncate it
+func (s *planStore) saveLocked() error {
+	sort.SliceStable(s.meals, func(i, j int) bool { return s.meals[i].Date < s.meals[j].Date })
+	data, err := json.MarshalIndent(s.meals, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// all returns a copy of the planned meals, in date order
+func (s *planStore) all() ([]PlannedMeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	return append([]PlannedMeal{}, s.meals...), nil
+}
+
+// add stores a new planned meal
+func (s *planStore) add(input PlannedMealInput) (PlannedMeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return PlannedMeal{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return PlannedMeal{}, err
+	}
+	meal := PlannedMeal{ID: hex.EncodeToString(id), PlannedMealInput: input}
+	s.meals = append(s.meals, meal)
+	return meal, s.saveLocked()
+}
+
+// update replaces a planned meal's fields. Skipped dates are kept while the meal
+// still repeats.
+func (s *planStore) update(id string, input PlannedMealInput) (PlannedMeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return PlannedMeal{}, err
+	}
+	for i := range s.meals {
+		if s.meals[i].ID != id {
+			continue
+		}
+		s.meals[i].PlannedMealInput = input
+		if !input.RepeatWeekly {
+			s.meals[i].Skip = nil
+		}
+		return s.meals[i], s.saveLocked()
+	}
+	return PlannedMeal{}, errPlanNotFound
+}
+
+// remove deletes a planned meal or, given a date, only that week of a weekly one
+func (s *planStore) remove(id, date string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	for i := range s.meals {
+		if s.meals[i].ID != id {
+			continue
+		}
+		if date != "" && s.meals[i].RepeatWeekly {
+			s.meals[i].Skip = appendUnique(s.meals[i].Skip, date)
+		} else {
+			s.meals = append(s.meals[:i], s.meals[i+1:]...)
+		}
+		return s.saveLocked()
+	}
+	return errPlanNotFound
+}
+
+// validate checks a planned meal and fills in defaults, returning a message for
+// the response if it isn't valid
+func (input *PlannedMealInput) validate() string {
+	input.Title = strings.TrimSpace(input.Title)
+	input.Slot = mealType(input.Slot)
+	input.RecipeURL = strings.TrimSpace(input.RecipeURL)
+	if input.Slot == "" {
+		input.Slot = defaultPlanSlot
+	}
+	switch {
+	case input.Title == "":
+		return "title is required"
+	case len(input.Title) > maxPlanTitle:
+		return fmt.Sprintf("title can be at most %d characters", maxPlanTitle)
+	case len(input.Notes) > maxPlanNotes:
+		return fmt.Sprintf("notes can be at most %d characters", maxPlanNotes)
+	case !validDate(input.Date):
+		return "date must be YYYY-MM-DD"
+	case input.Until != "" && !validDate(input.Until):
+		return "until must be YYYY-MM-DD"
+	case input.Until != "" && input.Until < input.Date:
+		return "until can't be before date"
+	case hasControl(input.Title) || hasControl(input.Slot):
+		return "title and slot can't contain line breaks or control characters"
+	case input.RecipeURL != "" && !webAddress(input.RecipeURL):
+		return "recipeUrl must be an http or https address"
+	}
+	if !input.RepeatWeekly {
+		input.Until = ""
+	}
+	return ""
+}
+
+// hasControl reports whether s contains a control character such as a line break
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
+
+// webAddress reports whether s is an absolute http or https URL. It is written
+// to the iCal export as is, so it must not contain line breaks either, which
+// url.Parse rejects.
+func webAddress(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && !hasControl(s)
+}
+
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// dates returns the days from start to end (exclusive) a planned meal falls on
+func (m PlannedMeal) dates(start, end time.Time, loc *time.Location) []string {
+	first, err := time.ParseInLocation("2006-01-02", m.Date, loc)
+	if err != nil {
+		return nil
+	}
+	if !m.RepeatWeekly {
+		if first.Before(start) || !first.Before(end) {
+			return nil
+		}
+		return []string{m.Date}
+	}
+
+	// Skip ahead to the first week in range
+	day := first
+	if day.Before(start) {
+		weeks := int(start.Sub(first).Hours()/24) / 7
+		day = first.AddDate(0, 0, 7*weeks)
+		for day.Before(start) {
+			day = day.AddDate(0, 0, 7)
+		}
+	}
+	var dates []string
+	for ; day.Before(end); day = day.AddDate(0, 0, 7) {
+		date := day.Format("2006-01-02")
+		if m.Until != "" && date > m.Until {
+			break
+		}
+		if !contains(m.Skip, date) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// planMeals returns the built-in plan's meals from start to end
+func planMeals(start, end time.Time, loc *time.Location) ([]MealEvent, error) {
+	planned, err := plan.all()
+	if err != nil {
+		return nil, err
+	}
+	meals := []MealEvent{}
+	for _, p := range planned {
+		for _, date := range p.dates(start, end, loc) {
+			id := "plan-" + p.ID
+			if p.RepeatWeekly {
+				id += "_" + date
+			}
+			meal, ok := plannedMeal(id, p.Title, date, "", "", loc)
+			if !ok {
+				continue
+			}
+			meal.MealType = p.Slot
+			meal.Description = p.Notes
+			meal.Links = mealLinks(&ical.Component{}, p.RecipeURL+"\n"+p.Notes)
+			if len(meal.Links) > 0 {
+				meal.URL = meal.Links[0]
+			}
+			meal.Ingredients = descriptionIngredients(p.Notes)
+			meals = append(meals, meal)
+		}
+	}
+	return meals, nil
+}
+
+// getPlan handles GET /api/meals/plan
+func (w *MealsWidget) getPlan(rw http.ResponseWriter, r *http.Request) {
+	planned, err := plan.all()
+	if err != nil {
+		fmt.Printf("[Meals] Error reading meal plan: %v\n", err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to read meal plan")
+		return
+	}
+	shared.WriteJSON(rw, http.StatusOK, planned)
+}
+
+// addPlannedMeal handles POST /api/meals/plan
+func (w *MealsWidget) addPlannedMeal(rw http.ResponseWriter, r *http.Request) {
+	input, ok := readPlannedMeal(rw, r)
+	if !ok {
+		return
+	}
+	meal, err := plan.add(input)
+	if err != nil {
+		fmt.Printf("[Meals] Error saving meal plan: %v\n", err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to save meal plan")
+		return
+	}
+	shared.WriteJSON(rw, http.StatusCreated, meal)
+}
+
+// updatePlannedMeal handles PUT /api/meals/plan/{id}
+func (w *MealsWidget) updatePlannedMeal(rw http.ResponseWriter, r *http.Request) {
+	input, ok := readPlannedMeal(rw, r)
+	if !ok {
+		return
+	}
+	meal, err := plan.update(chi.URLParam(r, "id"), input)
+	if err != nil {
+		writePlanError(rw, err)
+		return
+	}
+	shared.WriteJSON(rw, http.StatusOK, meal)
+}
+
+// deletePlannedMeal handles DELETE /api/meals/plan/{id}, answering with what's
+// left of the plan
+func (w *MealsWidget) deletePlannedMeal(rw http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date != "" && !validDate(date) {
+		shared.WriteError(rw, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+	if err := plan.remove(chi.URLParam(r, "id"), date); err != nil {
+		writePlanError(rw, err)
+		return
+	}
+	w.getPlan(rw, r)
+}
+
+// readPlannedMeal decodes and validates a request body, writing an error
+// response if it isn't valid
+func readPlannedMeal(rw http.ResponseWriter, r *http.Request) (PlannedMealInput, bool) {
+	var input PlannedMealInput
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxPlanBody)).Decode(&input); err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, "Invalid JSON body")
+		return input, false
+	}
+	if message := input.validate(); message != "" {
+		shared.WriteError(rw, http.StatusBadRequest, message)
+		return input, false
+	}
+	return input, true
+}
+
+func writePlanError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, errPlanNotFound) {
+		shared.WriteError(rw, http.StatusNotFound, "Planned meal not found")
+		return
+	}
+	fmt.Printf("[Meals] Error saving meal plan: %v\n", err)
+	shared.WriteError(rw, http.StatusInternalServerError, "Failed to save meal plan")
+}
+
+// getPlanICS handles GET /api/meals/plan.ics, the meal plan as a feed phones and
+// calendar apps can subscribe to
+func (w *MealsWidget) getPlanICS(rw http.ResponseWriter, r *http.Request) {
+	planned, err := plan.all()
+	if err != nil {
+		fmt.Printf("[Meals] Error reading meal plan: %v\n", err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to read meal plan")
+		return
+	}
+
+	calendar := &ical.Component{Name: "VCALENDAR", Properties: []ical.Property{
+		{Name: "VERSION", Value: "2.0"},
+		{Name: "PRODID", Value: "-//The Man Cave Dashboard//Meal Plan//EN"},
+		{Name: "CALSCALE", Value: "GREGORIAN"},
+		{Name: "X-WR-CALNAME", Value: "Meal Plan"},
+	}}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, meal := range planned {
+		calendar.Components = append(calendar.Components, meal.vevent(stamp))
+	}
+
+	rw.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	rw.Header().Set("Content-Disposition", `inline; filename="meal-plan.ics"`)
+	io.WriteString(rw, calendar.Encode())
+}
+
+// vevent writes a planned meal as an all-day event. The slot goes in CATEGORIES,
+// where this widget reads meal types from.
+func (m PlannedMeal) vevent(stamp string) *ical.Component {
+	start, _ := time.Parse("2006-01-02", m.Date)
+	date := func(name string, t time.Time) ical.Property {
+		return ical.Property{Name: name, Params: map[string]string{"VALUE": "DATE"}, Value: t.Format("20060102")}
+	}
+	event := &ical.Component{Name: "VEVENT", Properties: []ical.Property{
+		{Name: "UID", Value: m.ID + "@meal-plan.mancave-dashboard"},
+		{Name: "DTSTAMP", Value: stamp},
+		date("DTSTART", start),
+		date("DTEND", start.AddDate(0, 0, 1)),
+		{Name: "SUMMARY", Value: ical.EscapeText(m.Title)},
+		{Name: "CATEGORIES", Value: ical.EscapeText(capitalize(m.Slot))},
+		{Name: "TRANSP", Value: "TRANSPARENT"},
+	}}
+	if m.Notes != "" {
+		event.Properties = append(event.Properties, ical.Property{Name: "DESCRIPTION", Value: ical.EscapeText(m.Notes)})
+	}
+	if m.RecipeURL != "" {
+		event.Properties = append(event.Properties, ical.Property{Name: "URL", Params: map[string]string{"VALUE": "URI"}, Value: m.RecipeURL})
+	}
+	if m.RepeatWeekly {
+		rule := "FREQ=WEEKLY"
+		if until, err := time.Parse("2006-01-02", m.Until); err == nil {
+			rule += ";UNTIL=" + until.Format("20060102")
+		}
+		event.Properties = append(event.Properties, ical.Property{Name: "RRULE", Value: rule})
+		for _, skip := range m.Skip {
+			if t, err := time.Parse("2006-01-02", skip); err == nil {
+				event.Properties = append(event.Properties, date("EXDATE", t))
+			}
+		}
+	}
+	return event
+}
+
+// capitalize upper-cases the first letter of a slot, e.g. Dinner
+func capitalize(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(first)) + s[size:]
+}
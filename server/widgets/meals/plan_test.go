@@ -0,0 +1,113 @@
+package meals
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlannedMealVEventCategories(t *testing.T) {
+	tests := []struct {
+		slot string
+		want string
+	}{
+		{"dinner", "Dinner"},
+		{"ébauche", "Ébauche"},
+		{"", ""}, // from a hand-edited plan file
+	}
+	for _, tt := range tests {
+		meal := PlannedMeal{ID: "m1", PlannedMealInput: PlannedMealInput{Date: "2024-07-08", Slot: tt.slot, Title: "Tacos"}}
+		category, _ := meal.vevent("20240701T000000Z").Get("CATEGORIES")
+		if category.Value != tt.want {
+			t.Errorf("CATEGORIES for slot %q = %q, want %q", tt.slot, category.Value, tt.want)
+		}
+	}
+}
+
+func TestPlanRemoveWeek(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "meal-plan.json")
+	store := &planStore{locate: func() string { return path }}
+
+	// Weekly on Mondays across the end of daylight saving time on November 3
+	meal, err := store.add(PlannedMealInput{Date: "2024-10-21", Slot: "dinner", Title: "Tacos", RepeatWeekly: true, Until: "2024-11-18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.remove(meal.ID, "2024-10-21"); err != nil {
+		t.Fatalf("removing the first week: %v", err)
+	}
+	if err := store.remove(meal.ID, "2024-11-11"); err != nil {
+		t.Fatalf("removing a later week: %v", err)
+	}
+
+	planned, err := store.all()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 1 {
+		t.Fatalf("got %d planned meals, want the weekly meal with weeks skipped", len(planned))
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		want       []string
+	}{
+		{"from the first week", "2024-10-21", "2024-12-31", []string{"2024-10-28", "2024-11-04", "2024-11-18"}},
+		{"from after the change", "2024-11-04", "2024-12-31", []string{"2024-11-04", "2024-11-18"}},
+		{"midweek start", "2024-11-05", "2024-11-19", []string{"2024-11-18"}},
+		{"before the first week", "2024-10-01", "2024-10-22", nil},
+		{"after until", "2024-11-19", "2024-12-31", nil},
+	}
+	for _, tt := range tests {
+		start, _ := time.ParseInLocation("2006-01-02", tt.start, loc)
+		end, _ := time.ParseInLocation("2006-01-02", tt.end, loc)
+		got := planned[0].dates(start, end, loc)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without a date the whole series goes
+	if err := store.remove(meal.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if planned, _ := store.all(); len(planned) != 0 {
+		t.Errorf("got %d planned meals after removing the series, want 0", len(planned))
+	}
+	if err := store.remove(meal.ID, ""); err != errPlanNotFound {
+		t.Errorf("removing again: got %v, want errPlanNotFound", err)
+	}
+}
+
+func TestPlannedMealValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input PlannedMealInput
+		want  string
+	}{
+		{"valid", PlannedMealInput{Date: "2024-07-08", Title: "Tacos", RecipeURL: "https://example.com/tacos"}, ""},
+		{"line break in title", PlannedMealInput{Date: "2024-07-08", Title: "Tacos\r\nX-INJECTED:1"}, "title and slot can't contain line breaks or control characters"},
+		{"line break in recipe", PlannedMealInput{Date: "2024-07-08", Title: "Tacos", RecipeURL: "https://example.com/\r\nX-INJECTED:1"}, "recipeUrl must be an http or https address"},
+		{"recipe without host", PlannedMealInput{Date: "2024-07-08", Title: "Tacos", RecipeURL: "https:tacos"}, "recipeUrl must be an http or https address"},
+		{"recipe not on the web", PlannedMealInput{Date: "2024-07-08", Title: "Tacos", RecipeURL: "javascript:alert(1)"}, "recipeUrl must be an http or https address"},
+	}
+	for _, tt := range tests {
+		if got := tt.input.validate(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlannedMealVEventNotes(t *testing.T) {
+	meal := PlannedMeal{ID: "m1", PlannedMealInput: PlannedMealInput{Date: "2024-07-08", Title: "Tacos", Notes: "Tortillas\rX-INJECTED:1"}}
+	calendar := meal.vevent("20240701T000000Z").Encode()
+	if strings.Contains(calendar, "\r\nX-INJECTED") || strings.Contains(calendar, "\rX-INJECTED") {
+		t.Errorf("notes added a line to the event:\n%s", calendar)
+	}
+}
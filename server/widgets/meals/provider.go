@@ -0,0 +1,276 @@
+package meals
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// MealProvider is somewhere the meal plan comes from
+type MealProvider interface {
+	// Name describes the provider in logs
+	Name() string
+	// Meals returns the meals planned from start to end, with times in loc
+	Meals(ctx context.Context, start, end time.Time, loc *time.Location) ([]MealEvent, error)
+}
+
+// Meal provider types
+const (
+	providerICal    = "ical"
+	providerMealie  = "mealie"
+	providerTandoor = "tandoor"
+	providerGrocy   = "grocy"
+)
+
+// defaultTokenEnv holds each planner's API token unless the config names another
+// variable
+var defaultTokenEnv = map[string]string{
+	providerMealie:  "MEALIE_API_TOKEN",
+	providerTandoor: "TANDOOR_API_TOKEN",
+	providerGrocy:   "GROCY_API_KEY",
+}
+
+// maxAPIBytes bounds one meal planner API response
+const maxAPIBytes = 10 << 20
+
+// ProviderConfig is the "provider" widget config
+type ProviderConfig struct {
+	Type     string `json:"type"`      // ical (default), mealie, tandoor or grocy
+	URL      string `json:"url"`       // ical: feed URL, default calendar_url; others: the planner's address
+	TokenEnv string `json:"token_env"` // environment variable holding the API token (Grocy: API key)
+}
+
+// mealProvider creates the configured provider. Without a "provider" config the
+// meal plan is the calendar_url iCal feed.
+func mealProvider() (MealProvider, error) {
+	var config ProviderConfig
+	shared.GetWidgetConfigObject("meals", "provider", &config)
+	if config.Type == "" {
+		config.Type = providerICal
+	}
+
+	if config.Type == providerICal {
+		icalURL := config.URL
+		if icalURL == "" {
+			// Get calendar_url from widget config, falling back to the env var
+			icalURL = shared.GetWidgetConfigValue("meals", "calendar_url", "")
+		}
+		if icalURL == "" {
+			icalURL = os.Getenv("MEAL_ICAL_URL")
+		}
+		if icalURL == "" {
+			return nil, errNotConfigured
+		}
+		return &icalProvider{url: icalURL}, nil
+	}
+
+	tokenEnv, known := defaultTokenEnv[config.Type]
+	if !known {
+		return nil, fmt.Errorf("%w: unknown provider type %q", errNotConfigured, config.Type)
+	}
+	if config.TokenEnv != "" {
+		tokenEnv = config.TokenEnv
+	}
+	token := os.Getenv(tokenEnv)
+	if config.URL == "" || token == "" {
+		return nil, fmt.Errorf("%w: %s needs a url and %s", errNotConfigured, config.Type, tokenEnv)
+	}
+
+	base := strings.TrimRight(config.URL, "/")
+	switch config.Type {
+	case providerMealie:
+		return &mealieProvider{api: apiClient{base: base, header: "Authorization", value: "Bearer " + token}}, nil
+	case providerTandoor:
+		return &tandoorProvider{api: apiClient{base: base, header: "Authorization", value: "Bearer " + token}}, nil
+	default:
+		return &grocyProvider{api: apiClient{base: base, header: "GROCY-API-KEY", value: token}}, nil
+	}
+}
+
+// apiClient calls a meal planner's JSON API
+type apiClient struct {
+	base   string // e.g. https://mealie.example.com, without a trailing slash
+	header string // authentication header
+	value  string
+}
+
+// get decodes the JSON response to GET base+path
+func (c apiClient) get(ctx context.Context, path string, query url.Values, dest interface{}) error {
+	target := c.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(c.header, c.value)
+
+	resp, err := mealsHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &apiError{path: path, status: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxAPIBytes {
+		return fmt.Errorf("%s: response is larger than %d MB", path, maxAPIBytes>>20)
+	}
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// resolve makes a link the API returns absolute; some are relative to base
+func (c apiClient) resolve(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(c.base + "/")
+	if err != nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// basePath returns the path of a base URL, for planners served under one
+func basePath(base string) string {
+	if u, err := url.Parse(base); err == nil {
+		return u.Path
+	}
+	return ""
+}
+
+// apiError is a meal planner API response other than 200 OK
+type apiError struct {
+	path   string
+	status int
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s returned %d %s", e.path, e.status, http.StatusText(e.status))
+}
+
+// apiID reads an ID that some API versions send as a number and others as a
+// string
+type apiID string
+
+func (id *apiID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = apiID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = apiID(n.String())
+	return nil
+}
+
+// apiNumber reads a number that may be sent as a string, as Grocy does
+type apiNumber float64
+
+func (n *apiNumber) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, _ := strconv.ParseFloat(s, 64)
+		*n = apiNumber(v)
+		return nil
+	}
+	var v *float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v != nil {
+		*n = apiNumber(*v)
+	}
+	return nil
+}
+
+// mealTypeOrder sorts meals on the same day; other types come last
+var mealTypeOrder = map[string]int{
+	"breakfast": 1, "brunch": 2, "lunch": 3, "snack": 4, "dinner": 5, "side": 6, "dessert": 7, "drink": 8,
+}
+
+// mealType normalizes a planner's meal type or section name, e.g. "Dinner"
+func mealType(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// plannedMeal makes a meal planned for a date (YYYY-MM-DD, or a timestamp whose
+// date is used) through lastDay. Meals with a time of day (HH:MM) last an hour;
+// others take up the whole day.
+func plannedMeal(id, title, date, lastDay, clock string, loc *time.Location) (MealEvent, bool) {
+	if len(date) < 10 {
+		return MealEvent{}, false
+	}
+	day, err := time.ParseInLocation("2006-01-02", date[:10], loc)
+	if err != nil {
+		return MealEvent{}, false
+	}
+	meal := MealEvent{ID: id, Title: title}
+
+	if len(clock) >= 5 {
+		if t, err := time.Parse("15:04", clock[:5]); err == nil {
+			start := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+			meal.Start = start.Format(time.RFC3339)
+			meal.End = start.Add(time.Hour).Format(time.RFC3339)
+			return meal, true
+		}
+	}
+
+	end := day.AddDate(0, 0, 1)
+	if len(lastDay) >= 10 {
+		if last, err := time.ParseInLocation("2006-01-02", lastDay[:10], loc); err == nil && last.After(day) {
+			end = last.AddDate(0, 0, 1)
+		}
+	}
+	meal.Start, meal.End, meal.AllDay = day.Format(time.RFC3339), end.Format(time.RFC3339), true
+	return meal, true
+}
+
+// sortMeals orders meals by start, then breakfast before lunch before dinner
+func sortMeals(meals []MealEvent) {
+	sort.SliceStable(meals, func(i, j int) bool {
+		a, _ := time.Parse(time.RFC3339, meals[i].Start)
+		b, _ := time.Parse(time.RFC3339, meals[j].Start)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		if rankA, rankB := mealRank(meals[i]), mealRank(meals[j]); rankA != rankB {
+			return rankA < rankB
+		}
+		return meals[i].Title < meals[j].Title
+	})
+}
+
+func mealRank(meal MealEvent) int {
+	if rank, ok := mealTypeOrder[meal.MealType]; ok {
+		return rank
+	}
+	return len(mealTypeOrder) + 1
+}
@@ -0,0 +1,70 @@
+package meals
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// plannerStandIn serves a meal planner's recorded API responses from testdata,
+// by request path, to requests with the right credentials. Later pages are
+// listed as path?page=N; anything not listed is a 404.
+type plannerStandIn struct {
+	t        *testing.T
+	header   string
+	value    string
+	files    map[string]string
+	requests []*url.URL
+}
+
+func (s *plannerStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.URL)
+	if r.Header.Get(s.header) != s.value {
+		http.Error(w, `{"detail":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	key := r.URL.Path
+	if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+		key += "?page=" + page
+	}
+	file, ok := s.files[key]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		s.t.Errorf("reading recorded response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// request returns the first request the stand-in had for path
+func (s *plannerStandIn) request(path string) *url.URL {
+	for _, u := range s.requests {
+		if u.Path == path {
+			return u
+		}
+	}
+	s.t.Errorf("no request for %s", path)
+	return &url.URL{}
+}
+
+// checkMeals compares meals one by one, so a difference is easy to find
+func checkMeals(t *testing.T, got, want []MealEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d meals, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("meal %d = %+v\n(recipe %+v)\nwant %+v\n(recipe %+v)", i, got[i], got[i].Recipe, want[i], want[i].Recipe)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package meals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared/ical"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Recipe pages are re-read after a day; pages without a recipe, or that failed,
+// after an hour
+const (
+	recipeCacheTTL  = 24 * time.Hour
+	recipeRetryTTL  = time.Hour
+	maxCachedRecipe = 256
+)
+
+// maxRecipePageBytes bounds a recipe page; the JSON-LD is near the top of pages
+// far smaller than this
+const maxRecipePageBytes = 5 << 20
+
+// recipeFetchLimit is how many recipe pages are fetched at once
+const recipeFetchLimit = 4
+
+// Recipe is the schema.org Recipe published by a meal's linked page
+type Recipe struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Image        string   `json:"image,omitempty"`
+	Yield        string   `json:"yield,omitempty"`
+	TotalMinutes int      `json:"totalMinutes,omitempty"`
+	Ingredients  []string `json:"ingredients"`
+}
+
+// jsonLDPattern finds the JSON-LD blocks of an HTML page
+var jsonLDPattern = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// tagPattern matches HTML tags, which some sites leave in ingredient text
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// errNoRecipe is cached for pages that don't publish a recipe
+var errNoRecipe = errors.New("page has no schema.org Recipe")
+
+// recipeCache keeps fetched recipes by page URL (or, for meal planners, recipe
+// API URL), including ones that failed, so they aren't fetched on every request
+type recipeCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRecipe
+	fetches singleflight.Group
+}
+
+type cachedRecipe struct {
+	recipe  *Recipe
+	err     error
+	fetched time.Time
+}
+
+var recipes = &recipeCache{}
+
+// get returns the recipe cached under key, calling fetch unless it was fetched
+// recently
+func (c *recipeCache) get(ctx context.Context, key string, fetch func(context.Context) (*Recipe, error)) (*Recipe, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		ttl := recipeCacheTTL
+		if entry.err != nil {
+			ttl = recipeRetryTTL
+		}
+		if time.Since(entry.fetched) < ttl {
+			return entry.recipe, entry.err
+		}
+	}
+
+	result, err, _ := c.fetches.Do(key, func() (interface{}, error) {
+		recipe, err := fetch(ctx)
+		// A cancelled request says nothing about the recipe
+		if ctx.Err() == nil {
+			if err != nil && !errors.Is(err, errNoRecipe) {
+				fmt.Printf("[Meals] Error fetching recipe from %s: %v\n", key, err)
+			}
+			c.put(key, cachedRecipe{recipe: recipe, err: err, fetched: time.Now()})
+		}
+		return recipe, err
+	})
+	recipe, _ := result.(*Recipe)
+	return recipe, err
+}
+
+func (c *recipeCache) put(key string, entry cachedRecipe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedRecipe)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedRecipe {
+		oldest := ""
+		for k, other := range c.entries {
+			if oldest == "" || other.fetched.Before(c.entries[oldest].fetched) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = entry
+}
+
+// fetchRecipe reads the schema.org Recipe from a page's JSON-LD
+func fetchRecipe(ctx context.Context, pageURL string) (*Recipe, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	// Some recipe sites turn away clients that don't look like a browser
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ManCaveDashboard)")
+
+	resp, err := mealsHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("recipe page returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRecipePageBytes))
+	if err != nil {
+		return nil, err
+	}
+	recipe := parseRecipe(string(body))
+	if recipe == nil {
+		return nil, errNoRecipe
+	}
+	if recipe.URL == "" {
+		recipe.URL = pageURL
+	}
+	return recipe, nil
+}
+
+// parseRecipe returns the first Recipe in a page's JSON-LD blocks, or nil
+func parseRecipe(page string) *Recipe {
+	for _, match := range jsonLDPattern.FindAllStringSubmatch(page, -1) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &data); err != nil {
+			continue
+		}
+		if node := findRecipe(data, 0); node != nil {
+			return recipeFromJSONLD(node)
+		}
+	}
+	return nil
+}
+
+// findRecipe looks for a node typed Recipe, which sites nest in @graph, arrays
+// or mainEntity
+func findRecipe(data interface{}, depth int) map[string]interface{} {
+	if depth > 8 {
+		return nil
+	}
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if node := findRecipe(item, depth+1); node != nil {
+				return node
+			}
+		}
+	case map[string]interface{}:
+		if isRecipeType(v["@type"]) {
+			return v
+		}
+		for _, key := range []string{"@graph", "mainEntity", "mainEntityOfPage"} {
+			if node := findRecipe(v[key], depth+1); node != nil {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
+// isRecipeType reports whether an @type, a string or a list of them, is Recipe,
+// with or without the schema.org prefix
+func isRecipeType(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == "Recipe" || strings.HasSuffix(v, "schema.org/Recipe") || v == "schema:Recipe"
+	case []interface{}:
+		for _, item := range v {
+			if isRecipeType(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func recipeFromJSONLD(node map[string]interface{}) *Recipe {
+	recipe := &Recipe{
+		Name:        jsonLDText(node["name"]),
+		URL:         jsonLDText(node["url"]),
+		Image:       jsonLDText(node["image"]),
+		Yield:       jsonLDText(node["recipeYield"]),
+		Ingredients: []string{},
+	}
+	// recipeIngredient replaced the older ingredients property
+	list := node["recipeIngredient"]
+	if list == nil {
+		list = node["ingredients"]
+	}
+	switch v := list.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if text := jsonLDText(item); text != "" {
+				recipe.Ingredients = append(recipe.Ingredients, text)
+			}
+		}
+	case string:
+		if text := jsonLDText(v); text != "" {
+			recipe.Ingredients = append(recipe.Ingredients, text)
+		}
+	}
+
+	if minutes, ok := isoMinutes(jsonLDText(node["totalTime"])); ok {
+		recipe.TotalMinutes = minutes
+	} else {
+		prep, _ := isoMinutes(jsonLDText(node["prepTime"]))
+		cook, _ := isoMinutes(jsonLDText(node["cookTime"]))
+		recipe.TotalMinutes = prep + cook
+	}
+	return recipe
+}
+
+// isoMinutes reads an ISO 8601 duration such as PT1H30M, which reads like an
+// iCalendar one, in minutes
+func isoMinutes(value string) (int, bool) {
+	d, err := ical.ParseDuration(value)
+	if err != nil {
+		return 0, false
+	}
+	return int((time.Duration(d.Days)*24*time.Hour + d.Time).Minutes()), true
+}
+
+// jsonLDText reads a text value, which may be a string, a number, a list (the
+// first item is used) or an object such as an ImageObject with a url
+func jsonLDText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.Join(strings.Fields(html.UnescapeString(tagPattern.ReplaceAllString(v, " "))), " ")
+	case float64:
+		return fmt.Sprint(v)
+	case []interface{}:
+		for _, item := range v {
+			if text := jsonLDText(item); text != "" {
+				return text
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"url", "@id", "name", "text"} {
+			if text := jsonLDText(v[key]); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// mealRecipe returns the recipe of the first of a meal's links that has one
+func mealRecipe(ctx context.Context, links []string) *Recipe {
+	for i, link := range links {
+		// Descriptions can hold many links; only the first few are tried
+		if i == 3 {
+			break
+		}
+		fetch := func(ctx context.Context) (*Recipe, error) { return fetchRecipe(ctx, link) }
+		if recipe, err := recipes.get(ctx, link, fetch); err == nil {
+			return recipe
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package meals
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/ical"
+)
+
+// MealsStatus is the response of GET /api/meals/status
+type MealsStatus struct {
+	Configured   bool   `json:"configured"`       // a provider is set up, or the built-in plan has meals
+	Source       string `json:"source,omitempty"` // the configured provider
+	PlannedMeals int    `json:"plannedMeals"`     // entries in the built-in plan
+	// LastFetch and Error are of the last time meals were read from the source
+	LastFetch *time.Time `json:"lastFetch,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Feed has the fetches of an iCal feed, which may be served from its last
+	// good copy while Error is empty
+	Feed *ical.FeedStatus `json:"feed,omitempty"`
+}
+
+// sourceStatus records the last read of each provider, by name
+type sourceStatus struct {
+	mu    sync.Mutex
+	reads map[string]sourceRead
+}
+
+type sourceRead struct {
+	at  time.Time
+	err string
+}
+
+var sources = &sourceStatus{}
+
+func (s *sourceStatus) record(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reads == nil {
+		s.reads = make(map[string]sourceRead)
+	}
+	read := sourceRead{at: time.Now()}
+	if err != nil {
+		read.err = err.Error()
+	}
+	s.reads[name] = read
+}
+
+func (s *sourceStatus) last(name string) (sourceRead, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	read, ok := s.reads[name]
+	return read, ok
+}
+
+// getStatus handles GET /api/meals/status
+func (w *MealsWidget) getStatus(rw http.ResponseWriter, r *http.Request) {
+	var status MealsStatus
+	if planned, err := plan.all(); err == nil {
+		status.PlannedMeals = len(planned)
+	}
+
+	provider, err := mealProvider()
+	switch {
+	case err == nil:
+		status.Configured = true
+		status.Source = provider.Name()
+		if read, ok := sources.last(provider.Name()); ok {
+			status.LastFetch = &read.at
+			status.Error = read.err
+		}
+		if feed, ok := provider.(*icalProvider); ok {
+			if fetches, ok := feeds.Status(feed.url); ok {
+				status.Feed = &fetches
+			}
+		}
+	case err != errNotConfigured:
+		// A provider is set up but is missing something, such as its token
+		status.Error = err.Error()
+	}
+	status.Configured = status.Configured || status.PlannedMeals > 0
+	shared.WriteJSON(rw, http.StatusOK, status)
+}
@@ -0,0 +1,204 @@
+package meals
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTandoorPages bounds how many pages of the meal plan are followed
+const maxTandoorPages = 20
+
+// tandoorProvider reads the meal plan of a Tandoor Recipes instance
+type tandoorProvider struct {
+	api apiClient
+}
+
+// tandoorPage is a page of a Tandoor list; older versions return a bare array
+type tandoorPage struct {
+	Next    string            `json:"next"`
+	Results []tandoorMealPlan `json:"results"`
+}
+
+type tandoorMealPlan struct {
+	ID       apiID          `json:"id"`
+	Title    string         `json:"title"`
+	Note     string         `json:"note"`
+	Recipe   *tandoorRecipe `json:"recipe"`
+	FromDate string         `json:"from_date"` // a date or, since 1.5, a timestamp
+	ToDate   string         `json:"to_date"`
+	Date     string         `json:"date"` // before from_date existed
+	MealType *struct {
+		Name string `json:"name"`
+		Time string `json:"time"` // HH:MM:SS, when set
+	} `json:"meal_type"`
+	MealTypeName string `json:"meal_type_name"`
+}
+
+type tandoorRecipe struct {
+	ID           apiID     `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Image        string    `json:"image"`
+	Servings     apiNumber `json:"servings"`
+	ServingsText string    `json:"servings_text"`
+	WorkingTime  int       `json:"working_time"` // minutes
+	WaitingTime  int       `json:"waiting_time"`
+	SourceURL    string    `json:"source_url"`
+	Steps        []struct {
+		Ingredients []tandoorIngredient `json:"ingredients"`
+	} `json:"steps"`
+}
+
+type tandoorIngredient struct {
+	Food *struct {
+		Name string `json:"name"`
+	} `json:"food"`
+	Unit *struct {
+		Name string `json:"name"`
+	} `json:"unit"`
+	Amount       apiNumber `json:"amount"`
+	Note         string    `json:"note"`
+	IsHeader     bool      `json:"is_header"`
+	NoAmount     bool      `json:"no_amount"`
+	OriginalText string    `json:"original_text"`
+}
+
+func (p *tandoorProvider) Name() string {
+	return "Tandoor at " + hostOf(p.api.base)
+}
+
+func (p *tandoorProvider) Meals(ctx context.Context, start, end time.Time, loc *time.Location) ([]MealEvent, error) {
+	query := url.Values{
+		"from_date": {start.Format("2006-01-02")},
+		"to_date":   {end.AddDate(0, 0, -1).Format("2006-01-02")},
+		"page_size": {"100"},
+	}
+	var plans []tandoorMealPlan
+	path := "/api/meal-plan/"
+	for page := 0; page < maxTandoorPages; page++ {
+		var raw json.RawMessage
+		if err := p.api.get(ctx, path, query, &raw); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			if err := json.Unmarshal(raw, &plans); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			break
+		}
+		var list tandoorPage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		plans = append(plans, list.Results...)
+
+		// next is an absolute URL, which includes the path Tandoor is served under
+		next, err := url.Parse(list.Next)
+		if list.Next == "" || err != nil {
+			break
+		}
+		path, query = strings.TrimPrefix(next.Path, basePath(p.api.base)), next.Query()
+	}
+
+	meals := []MealEvent{}
+	for _, plan := range plans {
+		title := plan.Title
+		if title == "" && plan.Recipe != nil {
+			title = plan.Recipe.Name
+		}
+		date := plan.FromDate
+		if date == "" {
+			date = plan.Date
+		}
+		typeName, clock := plan.MealTypeName, ""
+		if plan.MealType != nil {
+			typeName, clock = plan.MealType.Name, plan.MealType.Time
+		}
+		meal, ok := plannedMeal("tandoor-"+string(plan.ID), title, date, plan.ToDate, clock, loc)
+		if !ok || title == "" {
+			continue
+		}
+		meal.MealType = mealType(typeName)
+		meal.Description = plan.Note
+		if recipe := plan.Recipe; recipe != nil && recipe.ID != "" {
+			meal.URL = p.api.base + "/view/recipe/" + url.PathEscape(string(recipe.ID))
+			meal.Links = []string{meal.URL}
+			meal.Image = p.api.resolve(recipe.Image)
+			if recipe.SourceURL != "" {
+				meal.Links = append(meal.Links, recipe.SourceURL)
+			}
+			meal.Recipe = p.recipe(ctx, recipe, meal)
+			if meal.Description == "" {
+				meal.Description = recipe.Description
+			}
+		}
+		meals = append(meals, meal)
+	}
+	return meals, nil
+}
+
+// recipe returns a planned recipe with its ingredients, which the meal plan
+// leaves out. If they can't be read, the recipe is returned without them.
+func (p *tandoorProvider) recipe(ctx context.Context, summary *tandoorRecipe, meal MealEvent) *Recipe {
+	path := "/api/recipe/" + url.PathEscape(string(summary.ID)) + "/"
+	recipe, err := recipes.get(ctx, p.api.base+path, func(ctx context.Context) (*Recipe, error) {
+		var full tandoorRecipe
+		if err := p.api.get(ctx, path, nil, &full); err != nil {
+			return nil, err
+		}
+		recipe := &Recipe{
+			Name:         full.Name,
+			URL:          meal.URL,
+			Image:        p.api.resolve(full.Image),
+			Yield:        strings.TrimSpace(strconv.FormatFloat(float64(full.Servings), 'f', -1, 64) + " " + full.ServingsText),
+			TotalMinutes: full.WorkingTime + full.WaitingTime,
+			Ingredients:  []string{},
+		}
+		if full.Servings == 0 {
+			recipe.Yield = ""
+		}
+		for _, step := range full.Steps {
+			for _, ing := range step.Ingredients {
+				if text := ing.text(); text != "" {
+					recipe.Ingredients = append(recipe.Ingredients, text)
+				}
+			}
+		}
+		return recipe, nil
+	})
+	if err != nil {
+		return &Recipe{Name: summary.Name, URL: meal.URL, Image: meal.Image, Ingredients: []string{}}
+	}
+	return recipe
+}
+
+// text writes an ingredient out as a line. Headers, which group the ingredients
+// of a step, are left out.
+func (ing tandoorIngredient) text() string {
+	if ing.IsHeader {
+		return ""
+	}
+	if ing.OriginalText != "" {
+		return ing.OriginalText
+	}
+	var parts []string
+	if !ing.NoAmount && ing.Amount > 0 {
+		parts = append(parts, strconv.FormatFloat(float64(ing.Amount), 'f', -1, 64))
+		if ing.Unit != nil && ing.Unit.Name != "" {
+			parts = append(parts, ing.Unit.Name)
+		}
+	}
+	if ing.Food != nil && ing.Food.Name != "" {
+		parts = append(parts, ing.Food.Name)
+	}
+	text := strings.Join(parts, " ")
+	if ing.Note != "" && text != "" {
+		text += ", " + ing.Note
+	}
+	return text
+}
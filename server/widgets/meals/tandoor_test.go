@@ -0,0 +1,72 @@
+package meals
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTandoorMeals(t *testing.T) {
+	// Tandoor is served under /tandoor, and links the second page by its public address
+	standIn := &plannerStandIn{t: t, header: "Authorization", value: "Bearer token", files: map[string]string{
+		"/tandoor/api/meal-plan/":        "tandoor-meal-plan-1.json",
+		"/tandoor/api/meal-plan/?page=2": "tandoor-meal-plan-2.json",
+		"/tandoor/api/recipe/7/":         "tandoor-recipe.json",
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	loc := time.FixedZone("CEST", 2*60*60)
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, loc)
+	base := server.URL + "/tandoor"
+	provider := &tandoorProvider{api: apiClient{base: base, header: "Authorization", value: "Bearer token"}}
+	meals, err := provider.Meals(context.Background(), start, start.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatalf("Meals: %v", err)
+	}
+
+	var pages []string
+	for _, u := range standIn.requests {
+		if u.Path != "/tandoor/api/meal-plan/" {
+			continue
+		}
+		query := u.Query()
+		pages = append(pages, query.Get("page"))
+		if got := query.Get("from_date") + " " + query.Get("to_date"); got != "2024-07-08 2024-07-14" {
+			t.Errorf("asked for meals from %s, want 2024-07-08 2024-07-14", got)
+		}
+	}
+	if len(pages) != 2 || pages[0] != "" || pages[1] != "2" {
+		t.Errorf("asked for meal plan pages %q, want the first and then 2", pages)
+	}
+
+	pasta := base + "/view/recipe/7"
+	pastaImage := "https://recipes.example.com/tandoor/media/recipes/7_pasta-alla-norma.jpg"
+	oats := base + "/view/recipe/9"
+	want := []MealEvent{
+		{
+			ID: "tandoor-41", Title: "Pasta alla Norma",
+			Start: "2024-07-08T18:30:00+02:00", End: "2024-07-08T19:30:00+02:00",
+			MealType: "dinner", Image: pastaImage, Description: "Aubergine, tomato and ricotta salata.",
+			URL: pasta, Links: []string{pasta},
+			Recipe: &Recipe{
+				Name: "Pasta alla Norma", URL: pasta, Image: pastaImage, Yield: "2 plates", TotalMinutes: 45,
+				Ingredients: []string{"2 aubergine", "salt", "200g spaghetti", "50 g ricotta salata, grated"},
+			},
+		},
+		{
+			ID: "tandoor-42", Title: "Pizza night",
+			Start: "2024-07-12T18:30:00+02:00", End: "2024-07-12T19:30:00+02:00",
+			MealType: "dinner", Description: "Order from Luigi's",
+		},
+		{
+			// Planned over two days; its recipe can't be read
+			ID: "tandoor-43", Title: "Overnight Oats", AllDay: true,
+			Start: "2024-07-13T00:00:00+02:00", End: "2024-07-15T00:00:00+02:00",
+			MealType: "breakfast", URL: oats, Links: []string{oats},
+			Recipe: &Recipe{Name: "Overnight Oats", URL: oats, Ingredients: []string{}},
+		},
+	}
+	checkMeals(t, meals, want)
+}
@@ -1,21 +1,40 @@
 package meals
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"sort"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
 	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// MealsWidget handles meal calendar via iCal feed
+// mealsHTTPTimeout bounds each request for the meal plan or a recipe
+const mealsHTTPTimeout = 15 * time.Second
+
+var mealsHTTPClient = &http.Client{Timeout: mealsHTTPTimeout}
+
+// Bounds of the grocery list's days parameter
+const (
+	defaultGroceryDays = 7
+	maxGroceryDays     = 31
+)
+
+// Errors of loadMeals that aren't about reaching the feed
+var (
+	errNotConfigured = errors.New("meal calendar not configured")
+	errInvalidFeed   = errors.New("meal calendar is not a valid iCal feed")
+)
+
+// MealsWidget shows the meal plan from an iCal feed or a meal planner (Mealie,
+// Tandoor or Grocy)
 type MealsWidget struct {
 	icalURL string
 }
@@ -27,6 +46,19 @@ type MealEvent struct {
 	Start  string `json:"start"`
 	End    string `json:"end"`
 	AllDay bool   `json:"allDay"`
+	// MealType is breakfast, lunch, dinner and so on, in lower case, when the
+	// provider has one
+	MealType    string `json:"mealType,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Description string `json:"description,omitempty"`
+	// URL is the meal's recipe page. From an iCal feed, that's its first link:
+	// its URL, an attachment or a link in the description; Links has all of them.
+	URL   string   `json:"url,omitempty"`
+	Links []string `json:"links,omitempty"`
+	// Ingredients are listed in the description; Recipe.Ingredients, when there is
+	// a recipe, come from the linked page or the meal planner
+	Ingredients []string `json:"ingredients,omitempty"`
+	Recipe      *Recipe  `json:"recipe,omitempty"`
 }
 
 // ID returns the widget identifier
@@ -39,6 +71,20 @@ func (w *MealsWidget) GetRequiredEnvVars() []string {
 	return []string{}
 }
 
+// ConfigSchema describes the "meals" section of config.json
+func (w *MealsWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "meals",
+		Fields: []shared.ConfigField{
+			{Key: "calendar_url", Type: "string", Description: "iCal feed URL for the meal plan"},
+			{Key: "provider", Type: "object", Description: "Where the meal plan comes from: {\"type\", \"url\", \"token_env\"} where type is ical (default, reading calendar_url unless url is set), mealie, tandoor or grocy; token_env defaults to MEALIE_API_TOKEN, TANDOOR_API_TOKEN or GROCY_API_KEY"},
+			{Key: "plan_filename", Type: "string", Description: "File in the config directory that stores the built-in meal plan (default meal-plan.json)"},
+			{Key: "refresh_minutes", Type: "number", Description: "Minimum minutes between fetches of the iCal feed; the last copy is served in between (default 15)"},
+			{Key: "fetch_recipes", Type: "boolean", Description: "Read schema.org recipes from the pages meals link to (default true)"},
+		},
+	}
+}
+
 // Initialize loads configuration
 func (w *MealsWidget) Initialize() error {
 	// icalURL is now loaded from config.json per request
@@ -48,144 +94,240 @@ func (w *MealsWidget) Initialize() error {
 // RegisterRoutes registers HTTP endpoints
 func (w *MealsWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/meals", w.getData)
+	r.Get("/meals/groceries", w.getGroceries)
+	r.Get("/meals/status", w.getStatus)
+	r.Get("/meals/plan", w.getPlan)
+	r.Post("/meals/plan", w.addPlannedMeal)
+	r.Put("/meals/plan/{id}", w.updatePlannedMeal)
+	r.Delete("/meals/plan/{id}", w.deletePlannedMeal)
+	r.Get("/meals/plan.ics", w.getPlanICS)
 }
 
-// getData handles GET /api/meals
-func (w *MealsWidget) getData(rw http.ResponseWriter, r *http.Request) {
-	// Get calendar_url from widget config
-	icalURL := shared.GetWidgetConfigValue("meals", "calendar_url", "")
-
-	// Fall back to env var if not in config
-	if icalURL == "" {
-		icalURL = os.Getenv("MEAL_ICAL_URL")
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *MealsWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/meals",
+			Summary: "Meals planned for the next 7 days",
+			Description: "Each meal has the links, ingredients and recipe found in its calendar entry. " +
+				"Recipes are read from the schema.org JSON-LD of linked pages and cached for a day.",
+			Response: []MealEvent{},
+			Errors:   []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/meals/groceries",
+			Summary: "Grocery list for the coming days' meals",
+			Description: "Ingredients of each meal, from its recipe or else its description, added up by ingredient. " +
+				"Amounts in units of the same kind (volume or weight) are totalled in the largest unit used.",
+			Query: []openapi.Param{
+				{Name: "days", Type: "integer", Description: "Number of days from today, 1 to 31 (default 7)"},
+			},
+			Response: GroceryList{},
+			Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/meals/status",
+			Summary: "Where meals come from and how the last fetch went",
+			Description: "iCal feeds are fetched at most every refresh_minutes, with ETag and Last-Modified conditional requests. " +
+				"When a fetch fails, the last good copy is served and feed.stale is set.",
+			Response: MealsStatus{},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/meals/plan",
+			Summary:     "Meals in the built-in meal plan",
+			Description: "Meals planned on the dashboard, which /meals merges with the configured provider.",
+			Response:    []PlannedMeal{},
+			Errors:      []int{http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/meals/plan",
+			Summary: "Plan a meal",
+			Description: "Adds a meal on a day, or every week from that day when repeatWeekly is set. " +
+				"The slot defaults to dinner; ingredients listed in the notes go on the grocery list.",
+			Request:  PlannedMealInput{},
+			Response: PlannedMeal{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/meals/plan/{id}",
+			Summary:  "Change a planned meal",
+			Request:  PlannedMealInput{},
+			Response: PlannedMeal{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/meals/plan/{id}",
+			Summary: "Remove a planned meal",
+			Query: []openapi.Param{
+				{Name: "date", Type: "string", Description: "Remove only this week (YYYY-MM-DD) of a weekly meal"},
+			},
+			Response: []PlannedMeal{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/meals/plan.ics",
+			Summary: "The built-in meal plan as an iCal feed",
+			Description: "All-day events with the slot as their category, which phones and calendar apps can subscribe to. " +
+				"Weekly meals repeat with an RRULE, and removed weeks are left out with EXDATE.",
+			ContentType: "text/calendar",
+			Errors:      []int{http.StatusInternalServerError},
+		},
 	}
+}
 
-	if icalURL == "" {
-		http.Error(rw, `{"error":"Meal calendar not configured"}`, http.StatusServiceUnavailable)
+// getData handles GET /api/meals
+func (w *MealsWidget) getData(rw http.ResponseWriter, r *http.Request) {
+	upcomingMeals, err := w.loadMeals(r.Context(), 7)
+	if err != nil {
+		writeMealsError(rw, err)
 		return
 	}
 
-	fmt.Printf("[Meals] Fetching iCal from: %s\n", icalURL)
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(upcomingMeals)
+}
 
-	resp, err := http.Get(icalURL)
-	if err != nil {
-		fmt.Printf("[Meals] Error fetching: %v\n", err)
-		http.Error(rw, `{"error":"Failed to fetch meal calendar"}`, http.StatusInternalServerError)
-		return
+// getGroceries handles GET /api/meals/groceries
+func (w *MealsWidget) getGroceries(rw http.ResponseWriter, r *http.Request) {
+	days := defaultGroceryDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxGroceryDays {
+			shared.WriteError(rw, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxGroceryDays))
+			return
+		}
+		days = n
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	meals, err := w.loadMeals(r.Context(), days)
 	if err != nil {
-		fmt.Printf("[Meals] Error reading response: %v\n", err)
-		http.Error(rw, `{"error":"Failed to read meal calendar"}`, http.StatusInternalServerError)
+		writeMealsError(rw, err)
 		return
 	}
 
-	fmt.Printf("[Meals] Response length: %d bytes\n", len(body))
+	today := time.Now().In(shared.GetLocation())
+	list := GroceryList{
+		Start:                   today.Format("2006-01-02"),
+		End:                     today.AddDate(0, 0, days-1).Format("2006-01-02"),
+		Meals:                   []string{},
+		Items:                   groceryItems(meals),
+		MealsWithoutIngredients: []string{},
+	}
+	for _, meal := range meals {
+		list.Meals = append(list.Meals, meal.Title)
+		if len(mealIngredients(meal)) == 0 {
+			list.MealsWithoutIngredients = append(list.MealsWithoutIngredients, meal.Title)
+		}
+	}
+	shared.WriteJSON(rw, http.StatusOK, list)
+}
 
-	events := parseICalendar(string(body))
-	fmt.Printf("[Meals] Total events parsed: %d\n", len(events))
+// writeMealsError answers with the status that fits an error from loadMeals
+func writeMealsError(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNotConfigured):
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Meal calendar not configured")
+	case errors.Is(err, errInvalidFeed):
+		shared.WriteError(rw, http.StatusInternalServerError, "Meal calendar is not a valid iCal feed")
+	default:
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to fetch meal calendar")
+	}
+}
 
-	// Filter events to next 7 days using local timezone
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endDate := today.AddDate(0, 0, 7)
+// loadMeals fetches the meals planned from today for the given number of days,
+// in the dashboard timezone, from the configured provider and the built-in plan.
+// If the provider fails, the plan's meals are returned on their own; its error
+// is only returned when nothing is planned.
+func (w *MealsWidget) loadMeals(ctx context.Context, days int) ([]MealEvent, error) {
+	loc := shared.GetLocation()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	endDate := today.AddDate(0, 0, days)
 	fmt.Printf("[Meals] Current time: %s\n", now.Format("2006-01-02 15:04:05 MST"))
 
-	var upcomingMeals []MealEvent
-	for _, event := range events {
-		eventTime, err := time.Parse(time.RFC3339, event.Start)
+	planned, err := plan.all()
+	if err != nil {
+		fmt.Printf("[Meals] Error reading meal plan: %v\n", err)
+		return nil, err
+	}
+
+	meals := []MealEvent{}
+	provider, err := mealProvider()
+	switch {
+	case err == nil:
+		meals, err = provider.Meals(ctx, today, endDate, loc)
+		sources.record(provider.Name(), err)
 		if err != nil {
-			fmt.Printf("[Meals] Failed to parse event time '%s': %v\n", event.Start, err)
-			continue
+			fmt.Printf("[Meals] Error reading %s: %v\n", provider.Name(), err)
+			// The built-in plan is still shown without the provider's meals
+			if len(planned) == 0 {
+				return nil, err
+			}
+			meals = []MealEvent{}
 		}
-
-		if eventTime.After(today.Add(-time.Hour)) && eventTime.Before(endDate) {
-			upcomingMeals = append(upcomingMeals, event)
+	case errors.Is(err, errNotConfigured) && len(planned) > 0:
+		// The built-in plan is enough on its own
+	default:
+		// Say what's missing, unless nothing was configured at all
+		if err != errNotConfigured {
+			fmt.Printf("[Meals] %v\n", err)
 		}
+		return nil, err
 	}
 
-	// Sort meals by date (closest first)
-	sort.Slice(upcomingMeals, func(i, j int) bool {
-		timeI, _ := time.Parse(time.RFC3339, upcomingMeals[i].Start)
-		timeJ, _ := time.Parse(time.RFC3339, upcomingMeals[j].Start)
-		return timeI.Before(timeJ)
-	})
-
-	fmt.Printf("[Meals] Upcoming meals found: %d\n", len(upcomingMeals))
-
-	if upcomingMeals == nil {
-		upcomingMeals = []MealEvent{}
+	fromPlan, err := planMeals(today, endDate, loc)
+	if err != nil {
+		return nil, err
 	}
+	meals = append(meals, fromPlan...)
+	sortMeals(meals)
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(upcomingMeals)
-}
-
-func parseICalendar(icalData string) []MealEvent {
-	var events []MealEvent
-	lines := strings.Split(icalData, "\n")
+	fmt.Printf("[Meals] Upcoming meals found: %d\n", len(meals))
 
-	var currentEvent *MealEvent
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "BEGIN:VEVENT") {
-			currentEvent = &MealEvent{AllDay: false}
-		} else if strings.HasPrefix(line, "END:VEVENT") {
-			if currentEvent != nil && currentEvent.Title != "" {
-				events = append(events, *currentEvent)
-			}
-			currentEvent = nil
-		} else if currentEvent != nil {
-			if strings.HasPrefix(line, "UID:") {
-				currentEvent.ID = strings.TrimPrefix(line, "UID:")
-			} else if strings.HasPrefix(line, "SUMMARY:") {
-				currentEvent.Title = strings.TrimPrefix(line, "SUMMARY:")
-			} else if strings.HasPrefix(line, "DTSTART") {
-				parts := strings.Split(line, ":")
-				if len(parts) == 2 {
-					dateStr := parts[1]
-					parsedTime := parseICalDate(dateStr, strings.Contains(line, "VALUE=DATE"))
-					currentEvent.Start = parsedTime.Format(time.RFC3339)
-					currentEvent.AllDay = strings.Contains(line, "VALUE=DATE")
-				}
-			} else if strings.HasPrefix(line, "DTEND") {
-				parts := strings.Split(line, ":")
-				if len(parts) == 2 {
-					dateStr := parts[1]
-					parsedTime := parseICalDate(dateStr, strings.Contains(line, "VALUE=DATE"))
-					currentEvent.End = parsedTime.Format(time.RFC3339)
-				}
-			}
-		}
+	fetchRecipes := true
+	shared.GetWidgetConfigObject("meals", "fetch_recipes", &fetchRecipes)
+	if fetchRecipes {
+		addRecipes(ctx, meals)
 	}
-
-	return events
+	return meals, nil
 }
 
-func parseICalDate(dateStr string, isAllDay bool) time.Time {
-	dateStr = strings.TrimSpace(dateStr)
-
-	if isAllDay {
-		t, err := time.Parse("20060102", dateStr)
-		if err == nil {
-			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+// addRecipes looks up the recipes of meals with links, a few pages at a time
+func addRecipes(ctx context.Context, meals []MealEvent) {
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, recipeFetchLimit)
+	for i := range meals {
+		// Meal planners return their recipes with the meal
+		if meals[i].Recipe != nil || len(meals[i].Links) == 0 {
+			continue
 		}
-	} else {
-		if strings.Contains(dateStr, "Z") {
-			t, err := time.Parse("20060102T150405Z", dateStr)
-			if err == nil {
-				return t
-			}
-		} else {
-			t, err := time.Parse("20060102T150405", dateStr)
-			if err == nil {
-				return t
+		wg.Add(1)
+		go func(meal *MealEvent) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			meal.Recipe = mealRecipe(ctx, meal.Links)
+			if meal.Image == "" && meal.Recipe != nil {
+				meal.Image = meal.Recipe.Image
 			}
-		}
+		}(&meals[i])
 	}
+	wg.Wait()
+}
 
-	return time.Now()
+// mealIngredients returns the ingredients to shop for: the recipe's if it lists
+// any, otherwise the ones in the description
+func mealIngredients(meal MealEvent) []string {
+	if meal.Recipe != nil && len(meal.Recipe.Ingredients) > 0 {
+		return meal.Recipe.Ingredients
+	}
+	return meal.Ingredients
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"themancavedashboard/shared/openapi"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -36,6 +38,18 @@ func (w *PersonalWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/personal/config", w.getConfig)
 }
 
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *PersonalWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:   http.MethodGet,
+			Path:     "/personal/config",
+			Summary:  "Personal dates and household schedule",
+			Response: PersonalConfigResponse{},
+		},
+	}
+}
+
 // getConfig handles GET /api/personal/config
 func (w *PersonalWidget) getConfig(rw http.ResponseWriter, r *http.Request) {
 	response := PersonalConfigResponse{
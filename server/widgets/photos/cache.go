@@ -0,0 +1,137 @@
+package photos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// thumbnailMaxAge is how long an unused rendition stays in the cache. Entries are
+// keyed by the source mtime, so edited photos leave stale entries behind.
+const thumbnailMaxAge = 30 * 24 * time.Hour
+
+// renditionKey identifies one rendition of one version of a photo
+func renditionKey(name string, info os.FileInfo, opts resizeOptions) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d|%d|%s",
+		name, info.ModTime().UnixNano(), info.Size(), opts.Width, opts.Height, opts.Fit)))
+	return hex.EncodeToString(sum[:12])
+}
+
+// cachedRendition returns the cached file for key, rendering and storing it if needed.
+// Concurrent requests for the same key share one render.
+func (w *PhotosWidget) cachedRendition(key string, src photoSource, opts resizeOptions) (string, string, error) {
+	for _, ext := range []string{".jpg", ".png"} {
+		if path, ok := w.cachedFile(key + ext); ok {
+			return path, mimeForExt(ext), nil
+		}
+	}
+
+	result, err, _ := w.renders.Do(key, func() (interface{}, error) {
+		var buf bytes.Buffer
+		contentType, err := renderPhoto(src, opts, &buf)
+		if err != nil {
+			return nil, err
+		}
+
+		ext := ".jpg"
+		if contentType == "image/png" {
+			ext = ".png"
+		}
+		path, err := w.writeCacheFile(key+ext, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		return [2]string{path, contentType}, nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	paths := result.([2]string)
+	return paths[0], paths[1], nil
+}
+
+// cachedFile returns the path of a cache entry if it exists
+func (w *PhotosWidget) cachedFile(name string) (string, bool) {
+	path := filepath.Join(w.cacheDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	// Touch so pruning only removes entries nobody asks for
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// writeCacheFile stores data in the cache under name
+func (w *PhotosWidget) writeCacheFile(name string, data []byte) (string, error) {
+	if err := os.MkdirAll(w.cacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temp file and rename so readers never see a partial image
+	path := filepath.Join(w.cacheDir, name)
+	tmp, err := os.CreateTemp(w.cacheDir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// pruneThumbnailCache periodically removes renditions that haven't been used recently
+func (w *PhotosWidget) pruneThumbnailCache() {
+	for {
+		entries, err := os.ReadDir(w.cacheDir)
+		if err == nil {
+			removed := 0
+			for _, entry := range entries {
+				info, err := entry.Info()
+				if err != nil || entry.IsDir() {
+					continue
+				}
+				stale := time.Since(info.ModTime()) > thumbnailMaxAge
+				abandoned := strings.HasSuffix(entry.Name(), ".tmp") && time.Since(info.ModTime()) > time.Hour
+				if stale || abandoned {
+					if os.Remove(filepath.Join(w.cacheDir, entry.Name())) == nil {
+						removed++
+					}
+				}
+			}
+			if removed > 0 {
+				log.Printf("[Photos] Pruned %d cached renditions", removed)
+			}
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+func mimeForExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".heic", ".heif":
+		return "image/heic"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,295 @@
+package photos
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"math/bits"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"themancavedashboard/shared"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// defaultDuplicateThreshold is how many of the 64 bits of both perceptual hashes
+// may differ for two photos to count as near-duplicates. Re-encoded and resized
+// copies are usually within 4; burst shots of the same scene within about 10.
+const defaultDuplicateThreshold = 8
+
+// DuplicateGroup is a set of photos that are copies or near-copies of each other
+type DuplicateGroup struct {
+	ID     string  `json:"id"`     // name of the group's first photo
+	Keep   string  `json:"keep"`   // the copy shown by the carousel
+	Exact  bool    `json:"exact"`  // every photo has the same content hash
+	Photos []Photo `json:"photos"` // best copy first
+}
+
+// duplicateThreshold reads duplicate_threshold from the widget config
+func duplicateThreshold() int {
+	return int(shared.GetWidgetConfigNumber("photos", "duplicate_threshold", defaultDuplicateThreshold))
+}
+
+// photoHashes decodes a photo and returns its dHash and pHash as hex strings
+func photoHashes(src photoSource) (string, string, error) {
+	f, err := os.Open(src.Path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	decoded, err := decodeLimited(f)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Hash the upright image so rotated copies still match. The kernel scaler
+	// averages over the source, so copies at different sizes hash alike.
+	thumb := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	xdraw.BiLinear.Scale(thumb, thumb.Bounds(), decoded, decoded.Bounds(), draw.Src, nil)
+	upright := orient(thumb, src.Orientation)
+
+	return fmt.Sprintf("%016x", dHash(upright)), fmt.Sprintf("%016x", pHash(upright)), nil
+}
+
+// luminance returns the grey level of every pixel of img, row by row
+func luminance(img image.Image) [][]float64 {
+	b := img.Bounds()
+	rows := make([][]float64, b.Dy())
+	for y := range rows {
+		rows[y] = make([]float64, b.Dx())
+		for x := range rows[y] {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			rows[y][x] = 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+		}
+	}
+	return rows
+}
+
+// dHash sets one bit per pixel of a 9×8 thumbnail: whether it is brighter than
+// its right-hand neighbour
+func dHash(img image.Image) uint64 {
+	small := image.NewRGBA(image.Rect(0, 0, 9, 8))
+	xdraw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	grey := luminance(small)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pHash takes the 8×8 lowest frequencies of the 32×32 thumbnail's cosine transform
+// and sets one bit per frequency: whether it is above the median (leaving out the
+// overall brightness)
+func pHash(img image.Image) uint64 {
+	grey := luminance(img)
+	n := len(grey)
+
+	var cosines [8][]float64
+	for u := range cosines {
+		cosines[u] = make([]float64, n)
+		for x := 0; x < n; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / float64(2*n))
+		}
+	}
+
+	var coeffs [64]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += grey[y][x] * cosines[u][x] * cosines[v][y]
+				}
+			}
+			coeffs[v*8+u] = sum
+		}
+	}
+
+	// The median splits the bits evenly, whatever the image's overall contrast
+	ac := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(ac)
+	median := ac[len(ac)/2]
+
+	var hash uint64
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// findDuplicateGroups maps each photo that has duplicates to its group ID: the
+// name of the group's first photo. Photos with the same content hash are always
+// grouped; near-duplicates are grouped when both perceptual hashes are within
+// threshold bits. A threshold of 0 or less only groups exact copies.
+func findDuplicateGroups(photos []Photo, threshold int) map[string]string {
+	sort.Slice(photos, func(i, j int) bool { return photos[i].Name < photos[j].Name })
+
+	parent := make([]int, len(photos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		// The lower index becomes the root, so the group ID is its first name
+		ri, rj := find(i), find(j)
+		if ri > rj {
+			ri, rj = rj, ri
+		}
+		parent[rj] = ri
+	}
+
+	byContent := make(map[string]int)
+	for i, photo := range photos {
+		if photo.Hash == "" {
+			continue
+		}
+		if first, ok := byContent[photo.Hash]; ok {
+			union(first, i)
+		} else {
+			byContent[photo.Hash] = i
+		}
+	}
+
+	if threshold > 0 {
+		type hashes struct {
+			index int
+			d, p  uint64
+		}
+		var hashed []hashes
+		for i, photo := range photos {
+			d, errD := strconv.ParseUint(photo.DHash, 16, 64)
+			p, errP := strconv.ParseUint(photo.PHash, 16, 64)
+			// A flat image (a blank or black frame) has no brightness steps at all and
+			// would match every other flat image
+			if errD == nil && errP == nil && d != 0 {
+				hashed = append(hashed, hashes{i, d, p})
+			}
+		}
+		for a := range hashed {
+			for b := a + 1; b < len(hashed); b++ {
+				if bits.OnesCount64(hashed[a].p^hashed[b].p) <= threshold && bits.OnesCount64(hashed[a].d^hashed[b].d) <= threshold {
+					union(hashed[a].index, hashed[b].index)
+				}
+			}
+		}
+	}
+
+	sizes := make(map[int]int)
+	for i := range photos {
+		sizes[find(i)]++
+	}
+	groups := make(map[string]string)
+	for i, photo := range photos {
+		if root := find(i); sizes[root] > 1 {
+			groups[photo.Name] = photos[root].Name
+		}
+	}
+	return groups
+}
+
+// betterCopy reports whether a should be shown instead of b: visible over hidden,
+// then favorites, more pixels, a known date, a bigger file and finally by name
+func betterCopy(a, b Photo) bool {
+	switch {
+	case a.Hidden != b.Hidden:
+		return !a.Hidden
+	case a.Favorite != b.Favorite:
+		return a.Favorite
+	case a.Width*a.Height != b.Width*b.Height:
+		return a.Width*a.Height > b.Width*b.Height
+	case a.TakenAtSource != b.TakenAtSource:
+		return a.TakenAtSource == "exif"
+	case a.Size != b.Size:
+		return a.Size > b.Size
+	}
+	return a.Name < b.Name
+}
+
+// collapseDuplicates keeps only the best copy of each duplicate group
+func collapseDuplicates(photos []Photo) []Photo {
+	best := make(map[string]int) // group -> index in collapsed
+	collapsed := photos[:0:0]
+	for _, photo := range photos {
+		if photo.DuplicateGroup == "" {
+			collapsed = append(collapsed, photo)
+			continue
+		}
+		if i, ok := best[photo.DuplicateGroup]; ok {
+			if betterCopy(photo, collapsed[i]) {
+				collapsed[i] = photo
+			}
+			continue
+		}
+		best[photo.DuplicateGroup] = len(collapsed)
+		collapsed = append(collapsed, photo)
+	}
+	return collapsed
+}
+
+// getDuplicates handles GET /api/photos/duplicates
+func (w *PhotosWidget) getDuplicates(rw http.ResponseWriter, r *http.Request) {
+	if err := w.index.wait(r.Context()); err != nil {
+		return
+	}
+	photos, err := w.index.snapshot()
+	if err != nil {
+		shared.WriteError(rw, http.StatusNotFound, err.Error())
+		return
+	}
+	metadata := w.metadata.all()
+
+	byGroup := make(map[string]*DuplicateGroup)
+	for _, photo := range photos {
+		if photo.DuplicateGroup == "" {
+			continue
+		}
+		photo.PhotoMetadata = metadata[photo.Name]
+		group := byGroup[photo.DuplicateGroup]
+		if group == nil {
+			group = &DuplicateGroup{ID: photo.DuplicateGroup, Exact: true}
+			byGroup[photo.DuplicateGroup] = group
+		}
+		group.Photos = append(group.Photos, photo)
+	}
+
+	groups := make([]DuplicateGroup, 0, len(byGroup))
+	for _, group := range byGroup {
+		sort.Slice(group.Photos, func(i, j int) bool { return betterCopy(group.Photos[i], group.Photos[j]) })
+		group.Keep = group.Photos[0].Name
+		for _, photo := range group.Photos[1:] {
+			if photo.Hash != group.Photos[0].Hash {
+				group.Exact = false
+			}
+		}
+		groups = append(groups, *group)
+	}
+	// Biggest clean-ups first
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Photos) != len(groups[j].Photos) {
+			return len(groups[i].Photos) > len(groups[j].Photos)
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	shared.WriteJSON(rw, http.StatusOK, groups)
+}
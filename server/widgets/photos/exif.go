@@ -0,0 +1,353 @@
+package photos
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// EXIF tags read by the photos widget
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// maxEXIFSegment is the most TIFF data that fits in a JPEG APP1 segment
+const maxEXIFSegment = 0xFFFF - 2 - 6
+
+// exifTimeLayout is the fixed format of EXIF date fields
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// tiffTypeSizes is the byte size of each TIFF field type
+var tiffTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// tiffField is a raw IFD entry
+type tiffField struct {
+	Type  uint16
+	Count uint32
+	Data  []byte
+}
+
+// exifData is the parsed EXIF block of a photo
+type exifData struct {
+	raw   []byte // the TIFF data the IFDs were read from
+	order binary.ByteOrder
+	ifd0  map[uint16]tiffField
+	exif  map[uint16]tiffField
+	gps   map[uint16]tiffField
+}
+
+var errNoEXIF = errors.New("no EXIF data")
+
+// readJPEGEXIF finds the APP1 Exif segment of a JPEG and parses it
+func readJPEGEXIF(r io.Reader) (*exifData, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, errors.New("not a JPEG")
+	}
+
+	for {
+		marker, err := nextJPEGMarker(br)
+		if err != nil {
+			return nil, err
+		}
+		// Start of scan or end of image: no metadata past this point
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, errNoEXIF
+		}
+		if marker >= 0xD0 && marker <= 0xD7 || marker == 0x01 {
+			continue
+		}
+
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(br, lengthBytes[:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes[:])) - 2
+		if length < 0 {
+			return nil, errors.New("invalid JPEG segment length")
+		}
+
+		if marker != 0xE1 {
+			if _, err := br.Discard(length); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+	}
+}
+
+// nextJPEGMarker skips fill bytes and returns the next marker code
+func nextJPEGMarker(br *bufio.Reader) (byte, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errors.New("invalid JPEG marker")
+	}
+	for b == 0xFF {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// parseTIFF parses a TIFF header and the IFD0, Exif and GPS IFDs
+func parseTIFF(data []byte) (*exifData, error) {
+	if len(data) < 8 {
+		return nil, errNoEXIF
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid TIFF byte order")
+	}
+	// 42 is standard TIFF; Olympus and Panasonic RAW files use their own magic numbers
+	switch order.Uint16(data[2:4]) {
+	case 42, 0x4F52, 0x5352, 0x55:
+	default:
+		return nil, errors.New("invalid TIFF header")
+	}
+
+	e := &exifData{raw: data, order: order}
+	e.ifd0, _ = e.readIFD(data, order.Uint32(data[4:8]))
+	if e.ifd0 == nil {
+		return nil, errNoEXIF
+	}
+	if offset, ok := e.uint(e.ifd0, tagExifIFD); ok {
+		e.exif, _ = e.readIFD(data, uint32(offset))
+	}
+	if offset, ok := e.uint(e.ifd0, tagGPSIFD); ok {
+		e.gps, _ = e.readIFD(data, uint32(offset))
+	}
+	return e, nil
+}
+
+// readIFD reads the entries of one IFD and returns the offset of the next one
+func (e *exifData) readIFD(data []byte, offset uint32) (map[uint16]tiffField, uint32) {
+	if int(offset)+2 > len(data) {
+		return nil, 0
+	}
+	count := int(e.order.Uint16(data[offset:]))
+	fields := make(map[uint16]tiffField, count)
+
+	pos := int(offset) + 2
+	for i := 0; i < count && pos+12 <= len(data); i, pos = i+1, pos+12 {
+		tag := e.order.Uint16(data[pos:])
+		fieldType := e.order.Uint16(data[pos+2:])
+		n := e.order.Uint32(data[pos+4:])
+
+		size, known := tiffTypeSizes[fieldType]
+		if !known || int64(n)*int64(size) > int64(len(data)) {
+			continue
+		}
+		total := size * int(n)
+
+		var value []byte
+		if total <= 4 {
+			value = data[pos+8 : pos+8+total]
+		} else {
+			valueOffset := int(e.order.Uint32(data[pos+8:]))
+			if valueOffset < 0 || valueOffset+total > len(data) {
+				continue
+			}
+			value = data[valueOffset : valueOffset+total]
+		}
+		fields[tag] = tiffField{Type: fieldType, Count: n, Data: value}
+	}
+
+	var next uint32
+	if pos+4 <= len(data) {
+		next = e.order.Uint32(data[pos:])
+	}
+	return fields, next
+}
+
+// uint returns an integer field (BYTE, SHORT or LONG)
+func (e *exifData) uint(ifd map[uint16]tiffField, tag uint16) (uint64, bool) {
+	field, ok := ifd[tag]
+	if !ok || field.Count == 0 {
+		return 0, false
+	}
+	switch field.Type {
+	case 1, 7:
+		return uint64(field.Data[0]), true
+	case 3:
+		return uint64(e.order.Uint16(field.Data)), true
+	case 4, 9, 13:
+		return uint64(e.order.Uint32(field.Data)), true
+	}
+	return 0, false
+}
+
+// string returns an ASCII field without its NUL terminator and padding
+func (e *exifData) string(ifd map[uint16]tiffField, tag uint16) string {
+	field, ok := ifd[tag]
+	if !ok || field.Type != 2 {
+		return ""
+	}
+	value := string(field.Data)
+	if i := strings.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// rationals returns an unsigned RATIONAL field as floats
+func (e *exifData) rationals(ifd map[uint16]tiffField, tag uint16) []float64 {
+	field, ok := ifd[tag]
+	if !ok || field.Type != 5 {
+		return nil
+	}
+	values := make([]float64, 0, field.Count)
+	for i := 0; i+8 <= len(field.Data); i += 8 {
+		num := e.order.Uint32(field.Data[i:])
+		den := e.order.Uint32(field.Data[i+4:])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+// Orientation returns the EXIF orientation (1-8), defaulting to 1 (upright)
+func (e *exifData) Orientation() int {
+	if e == nil {
+		return 1
+	}
+	if v, ok := e.uint(e.ifd0, tagOrientation); ok && v >= 1 && v <= 8 {
+		return int(v)
+	}
+	return 1
+}
+
+// Camera returns "Make Model", dropping the make when the model already includes it
+func (e *exifData) Camera() string {
+	if e == nil {
+		return ""
+	}
+	maker, model := e.string(e.ifd0, tagMake), e.string(e.ifd0, tagModel)
+	if maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		return model
+	}
+	return strings.TrimSpace(maker + " " + model)
+}
+
+// DateTaken returns when the photo was taken. EXIF dates are local wall-clock time,
+// so loc is used unless the camera recorded an offset.
+func (e *exifData) DateTaken(loc *time.Location) (time.Time, bool) {
+	if e == nil {
+		return time.Time{}, false
+	}
+	value := e.string(e.exif, tagDateTimeOriginal)
+	if value == "" {
+		value = e.string(e.ifd0, tagDateTime)
+	}
+	// Cameras without a clock write "0000:00:00 00:00:00"
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+
+	if offset := e.string(e.exif, tagOffsetTimeOriginal); offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.ParseInLocation(exifTimeLayout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// GPS returns the location the photo was taken in decimal degrees
+func (e *exifData) GPS() (lat, lon float64, ok bool) {
+	if e == nil || e.gps == nil {
+		return 0, 0, false
+	}
+	lat, latOK := e.degrees(tagGPSLatitude, tagGPSLatitudeRef, "S")
+	lon, lonOK := e.degrees(tagGPSLongitude, tagGPSLongitudeRef, "W")
+	if !latOK || !lonOK || (lat == 0 && lon == 0) {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// degrees converts a degrees/minutes/seconds GPS field to a signed decimal value
+func (e *exifData) degrees(tag, refTag uint16, negativeRef string) (float64, bool) {
+	dms := e.rationals(e.gps, tag)
+	if len(dms) != 3 {
+		return 0, false
+	}
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+	if e.string(e.gps, refTag) == negativeRef {
+		value = -value
+	}
+	return value, true
+}
+
+// uprightSegment returns the EXIF block as a JPEG APP1 payload with the orientation
+// reset to 1, for copies whose pixels have already been rotated. It returns nil
+// when there is nothing to copy or the block is too big for one segment.
+func (e *exifData) uprightSegment() []byte {
+	if e == nil || len(e.raw) == 0 || len(e.raw) > maxEXIFSegment {
+		return nil
+	}
+	data := append([]byte(nil), e.raw...)
+	upright, err := parseTIFF(data)
+	if err != nil {
+		return nil
+	}
+	// Field data points into data, so this edits the copy in place
+	if field, ok := upright.ifd0[tagOrientation]; ok && field.Type == 3 && len(field.Data) >= 2 {
+		upright.order.PutUint16(field.Data, 1)
+	}
+	return append([]byte("Exif\x00\x00"), data...)
+}
+
+// insertJPEGSegment adds an APP1 segment right after the start-of-image marker
+func insertJPEGSegment(jpegData, payload []byte) []byte {
+	if len(payload) == 0 || len(jpegData) < 2 {
+		return jpegData
+	}
+	out := make([]byte, 0, len(jpegData)+len(payload)+4)
+	out = append(out, jpegData[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(payload)+2))
+	out = append(out, payload...)
+	return append(out, jpegData[2:]...)
+}
@@ -0,0 +1,250 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// maxHEIFMetaSize bounds the "meta" box read into memory
+const maxHEIFMetaSize = 16 << 20
+
+// bmffBox is one ISO base media file format box
+type bmffBox struct {
+	Type   string
+	Offset int64 // of the payload
+	Size   int64 // of the payload
+}
+
+// readBMFFBoxes lists the boxes in r between start and end
+func readBMFFBoxes(r io.ReaderAt, start, end int64) ([]bmffBox, error) {
+	var boxes []bmffBox
+	var header [16]byte
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return boxes, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+				return boxes, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || pos+size > end {
+			return boxes, errors.New("invalid HEIF box size")
+		}
+		boxes = append(boxes, bmffBox{Type: boxType, Offset: pos + headerSize, Size: size - headerSize})
+		pos += size
+	}
+	return boxes, nil
+}
+
+// readHEIFEXIF finds the Exif item of a HEIF file (iPhone .heic) and parses it
+func readHEIFEXIF(path string) (*exifData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := readBMFFBoxes(f, 0, info.Size())
+	if err != nil && len(top) == 0 {
+		return nil, err
+	}
+	var meta []byte
+	for _, box := range top {
+		if box.Type == "meta" && box.Size <= maxHEIFMetaSize {
+			meta = make([]byte, box.Size)
+			if _, err := f.ReadAt(meta, box.Offset); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	// meta is a full box: skip version and flags
+	if len(meta) < 4 {
+		return nil, errNoEXIF
+	}
+	meta = meta[4:]
+
+	children, _ := readBMFFBoxes(bytes.NewReader(meta), 0, int64(len(meta)))
+	var exifID uint32
+	locations := make(map[uint32][]heifExtent)
+	for _, box := range children {
+		payload := meta[box.Offset : box.Offset+box.Size]
+		switch box.Type {
+		case "iinf":
+			exifID = findHEIFItem(payload, "Exif")
+		case "iloc":
+			locations = parseHEIFItemLocations(payload)
+		}
+	}
+	extents := locations[exifID]
+	if exifID == 0 || len(extents) == 0 {
+		return nil, errNoEXIF
+	}
+
+	var data []byte
+	for _, extent := range extents {
+		if extent.Length <= 0 || extent.Length > maxHEIFMetaSize || len(data)+int(extent.Length) > maxHEIFMetaSize {
+			return nil, errNoEXIF
+		}
+		chunk := make([]byte, extent.Length)
+		if _, err := f.ReadAt(chunk, extent.Offset); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+
+	// The item starts with the offset of the TIFF header, usually past "Exif\0\0"
+	if len(data) < 4 {
+		return nil, errNoEXIF
+	}
+	skip := int(binary.BigEndian.Uint32(data[:4]))
+	if 4+skip > len(data) {
+		return nil, errNoEXIF
+	}
+	return parseTIFF(data[4+skip:])
+}
+
+// findHEIFItem returns the ID of the first item of the given type in an iinf payload
+func findHEIFItem(iinf []byte, itemType string) uint32 {
+	if len(iinf) < 6 {
+		return 0
+	}
+	version := iinf[0]
+	pos := 6
+	if version > 0 {
+		pos = 8
+	}
+	if pos > len(iinf) {
+		return 0
+	}
+
+	entries, _ := readBMFFBoxes(bytes.NewReader(iinf), int64(pos), int64(len(iinf)))
+	for _, entry := range entries {
+		if entry.Type != "infe" {
+			continue
+		}
+		infe := iinf[entry.Offset : entry.Offset+entry.Size]
+		// Only versions 2 and 3 carry an item type
+		if len(infe) < 4 || infe[0] < 2 {
+			continue
+		}
+		var id uint32
+		var typeAt int
+		if infe[0] == 2 {
+			if len(infe) < 12 {
+				continue
+			}
+			id, typeAt = uint32(binary.BigEndian.Uint16(infe[4:])), 8
+		} else {
+			if len(infe) < 14 {
+				continue
+			}
+			id, typeAt = binary.BigEndian.Uint32(infe[4:]), 10
+		}
+		if string(infe[typeAt:typeAt+4]) == itemType {
+			return id
+		}
+	}
+	return 0
+}
+
+// heifExtent is a byte range in the file holding part of an item
+type heifExtent struct {
+	Offset int64
+	Length int64
+}
+
+// parseHEIFItemLocations reads an iloc payload into file extents per item. Items
+// stored inside the meta box (construction method 1) are not supported.
+func parseHEIFItemLocations(iloc []byte) map[uint32][]heifExtent {
+	locations := make(map[uint32][]heifExtent)
+	if len(iloc) < 8 {
+		return locations
+	}
+	version := iloc[0]
+	offsetSize, lengthSize := int(iloc[4]>>4), int(iloc[4]&0x0F)
+	baseOffsetSize, indexSize := int(iloc[5]>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0F)
+	}
+
+	pos := 6
+	readN := func(n int) (uint64, bool) {
+		if pos+n > len(iloc) {
+			return 0, false
+		}
+		var v uint64
+		for i := 0; i < n; i++ {
+			v = v<<8 | uint64(iloc[pos+i])
+		}
+		pos += n
+		return v, true
+	}
+
+	itemCountSize := 2
+	if version == 2 {
+		itemCountSize = 4
+	}
+	count, ok := readN(itemCountSize)
+	if !ok {
+		return locations
+	}
+
+	for i := uint64(0); i < count; i++ {
+		id, ok := readN(itemCountSize)
+		if !ok {
+			return locations
+		}
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			if constructionMethod, ok = readN(2); !ok {
+				return locations
+			}
+			constructionMethod &= 0x0F
+		}
+		if _, ok = readN(2); !ok { // data reference index
+			return locations
+		}
+		baseOffset, ok := readN(baseOffsetSize)
+		if !ok {
+			return locations
+		}
+		extentCount, ok := readN(2)
+		if !ok {
+			return locations
+		}
+
+		var extents []heifExtent
+		for j := uint64(0); j < extentCount; j++ {
+			if _, ok = readN(indexSize); !ok {
+				return locations
+			}
+			offset, ok1 := readN(offsetSize)
+			length, ok2 := readN(lengthSize)
+			if !ok1 || !ok2 {
+				return locations
+			}
+			extents = append(extents, heifExtent{Offset: int64(baseOffset + offset), Length: int64(length)})
+		}
+		if constructionMethod == 0 {
+			locations[uint32(id)] = extents
+		}
+	}
+	return locations
+}
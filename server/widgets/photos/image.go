@@ -0,0 +1,188 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxResizeDimension bounds ?w= and ?h= so a request can't allocate huge images
+const maxResizeDimension = 4096
+
+// maxDecodePixels bounds the images decoded in full. A small file can declare
+// huge dimensions, and decoding it would allocate gigabytes.
+const maxDecodePixels = 100_000_000
+
+var errTooManyPixels = errors.New("image is too large to decode")
+
+// Fit modes for resized photos
+const (
+	fitContain = "contain" // scale to fit inside w×h, keeping aspect ratio
+	fitCover   = "cover"   // scale to cover w×h, center-cropping the overflow
+	fitFill    = "fill"    // stretch to exactly w×h
+)
+
+// resizeOptions describes a requested rendition of a photo
+type resizeOptions struct {
+	Width  int
+	Height int
+	Fit    string
+}
+
+// renderPhoto decodes a photo, corrects its EXIF orientation, resizes it and
+// writes it to out. It returns the MIME type of the encoded image.
+func renderPhoto(src photoSource, opts resizeOptions, out io.Writer) (string, error) {
+	f, err := os.Open(src.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	decoded, err := decodeLimited(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Orientations 5-8 rotate by 90°, so resize against the swapped box and rotate
+	// the small result rather than the full-size original
+	target := opts
+	if src.Orientation >= 5 {
+		target.Width, target.Height = opts.Height, opts.Width
+	}
+	img := orient(resize(decoded, target), src.Orientation)
+
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		return "image/png", png.Encode(out, img)
+	}
+	return "image/jpeg", jpeg.Encode(out, img, &jpeg.Options{Quality: 85})
+}
+
+// decodeLimited decodes an image once its header shows it isn't larger than
+// maxDecodePixels
+func decodeLimited(r io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// checkPixels rejects dimensions over maxDecodePixels
+func checkPixels(width, height int) error {
+	if int64(width)*int64(height) > maxDecodePixels {
+		return fmt.Errorf("%w: %d×%d is over %d megapixels", errTooManyPixels, width, height, maxDecodePixels/1_000_000)
+	}
+	return nil
+}
+
+// resize scales src according to opts. Images are never enlarged.
+func resize(src image.Image, opts resizeOptions) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	w, h := opts.Width, opts.Height
+
+	// A single dimension keeps the aspect ratio
+	switch {
+	case w == 0 && h == 0:
+		return src
+	case w == 0:
+		w = srcW * h / srcH
+	case h == 0:
+		h = srcH * w / srcW
+	}
+
+	srcRect := bounds
+	switch opts.Fit {
+	case fitFill:
+	case fitCover:
+		// Crop the source to the target aspect ratio around its center
+		if srcW*h > srcH*w {
+			cropW := srcH * w / h
+			x0 := bounds.Min.X + (srcW-cropW)/2
+			srcRect = image.Rect(x0, bounds.Min.Y, x0+cropW, bounds.Max.Y)
+		} else {
+			cropH := srcW * h / w
+			y0 := bounds.Min.Y + (srcH-cropH)/2
+			srcRect = image.Rect(bounds.Min.X, y0, bounds.Max.X, y0+cropH)
+		}
+	default:
+		if srcW*h > srcH*w {
+			h = srcH * w / srcW
+		} else {
+			w = srcW * h / srcH
+		}
+	}
+
+	// Don't upscale; cropping alone is still applied for cover
+	if w >= srcRect.Dx() || h >= srcRect.Dy() {
+		w, h = srcRect.Dx(), srcRect.Dy()
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, srcRect, draw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation so the image is upright
+func orient(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			si := rgba.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], rgba.Pix[si:si+4])
+		}
+	}
+	return dst
+}
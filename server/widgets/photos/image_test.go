@@ -0,0 +1,68 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hugePNG is a small PNG whose header declares width x height pixels
+func hugePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	data := testImage(t, 8, 8, "png")
+	// The IHDR chunk follows the 8-byte signature: length, type, then the
+	// dimensions, with a CRC of the type and data after them
+	ihdr := data[8:]
+	if string(ihdr[4:8]) != "IHDR" {
+		t.Fatal("PNG doesn't start with IHDR")
+	}
+	binary.BigEndian.PutUint32(ihdr[8:12], width)
+	binary.BigEndian.PutUint32(ihdr[12:16], height)
+	binary.BigEndian.PutUint32(ihdr[21:25], crc32.ChecksumIEEE(ihdr[4:21]))
+	return data
+}
+
+func TestDecodeLimited(t *testing.T) {
+	if _, err := decodeLimited(bytes.NewReader(hugePNG(t, 50000, 50000))); !errors.Is(err, errTooManyPixels) {
+		t.Errorf("decoding a 50000×50000 PNG: err = %v, want errTooManyPixels", err)
+	}
+	img, err := decodeLimited(bytes.NewReader(testImage(t, 40, 30, "jpeg")))
+	if err != nil {
+		t.Fatalf("decoding a 40×30 JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 30 {
+		t.Errorf("decoded a %d×%d image, want 40×30", b.Dx(), b.Dy())
+	}
+}
+
+func TestIndexRejectsHugeImages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bomb.png"), hugePNG(t, 50000, 50000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := newPhotoIndex(dir, func(path, name string, info os.FileInfo) (photoSource, error) {
+		return photoSource{Path: path, Orientation: 1}, nil
+	})
+	idx.scan()
+
+	// The photo is listed as undecodable, so it is neither shown nor hashed
+	photo := idx.photos["bomb.png"]
+	if photo == nil {
+		t.Fatal("bomb.png isn't in the index")
+	}
+	if !strings.Contains(photo.Error, errTooManyPixels.Error()) {
+		t.Errorf("Error = %q, want it to say the image is too large", photo.Error)
+	}
+	if photo.PHash != "" || photo.hashError != "" {
+		t.Errorf("the undecodable photo was hashed (pHash %q, hash error %q)", photo.PHash, photo.hashError)
+	}
+	if _, _, err := photoHashes(photoSource{Path: filepath.Join(dir, "bomb.png")}); !errors.Is(err, errTooManyPixels) {
+		t.Errorf("photoHashes: err = %v, want errTooManyPixels", err)
+	}
+}
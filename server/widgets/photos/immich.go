@@ -0,0 +1,116 @@
+package photos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// immichSource reads the photos of one album from an Immich server
+type immichSource struct {
+	baseURL string
+	albumID string
+	apiKey  string
+	client  *http.Client
+}
+
+func newImmichSource(baseURL, albumID, apiKey string) *immichSource {
+	return &immichSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		albumID: albumID,
+		apiKey:  apiKey,
+		client:  &http.Client{},
+	}
+}
+
+func (s *immichSource) Name() string {
+	return fmt.Sprintf("Immich album %s at %s", s.albumID, s.baseURL)
+}
+
+// immichAlbum is the part of GET /api/albums/{id} used here
+type immichAlbum struct {
+	Assets []struct {
+		ID               string    `json:"id"`
+		Type             string    `json:"type"` // IMAGE or VIDEO
+		OriginalFileName string    `json:"originalFileName"`
+		Checksum         string    `json:"checksum"`
+		FileModifiedAt   time.Time `json:"fileModifiedAt"`
+		IsTrashed        bool      `json:"isTrashed"`
+	} `json:"assets"`
+}
+
+func (s *immichSource) List(ctx context.Context) ([]SourcePhoto, error) {
+	ctx, cancel := context.WithTimeout(ctx, sourceHTTPTimeout)
+	defer cancel()
+
+	resp, err := s.get(ctx, "/api/albums/"+url.PathEscape(s.albumID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var album immichAlbum
+	if err := json.NewDecoder(resp.Body).Decode(&album); err != nil {
+		return nil, fmt.Errorf("invalid Immich album response: %w", err)
+	}
+
+	photos := make([]SourcePhoto, 0, len(album.Assets))
+	names := make(map[string]bool)
+	for _, asset := range album.Assets {
+		if asset.Type != "IMAGE" || asset.IsTrashed || asset.ID == "" {
+			continue
+		}
+		name := sanitizeFilename(asset.OriginalFileName)
+		if name == "" || !isPhotoFile(filepath.Ext(name)) {
+			continue
+		}
+		// Albums often hold several IMG_0001.JPG from different phones; the asset
+		// ID keeps the names apart and stable between syncs
+		if names[strings.ToLower(name)] {
+			ext := filepath.Ext(name)
+			name = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(name, ext), asset.ID[:min(8, len(asset.ID))], ext)
+		}
+		names[strings.ToLower(name)] = true
+
+		photos = append(photos, SourcePhoto{
+			Name:    name,
+			ID:      asset.ID,
+			Version: asset.Checksum,
+			ModTime: asset.FileModifiedAt,
+		})
+	}
+	return photos, nil
+}
+
+func (s *immichSource) Open(ctx context.Context, photo SourcePhoto) (io.ReadCloser, error) {
+	resp, err := s.get(ctx, "/api/assets/"+url.PathEscape(photo.ID)+"/original")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get sends an authenticated request, returning an error unless the status is 200
+func (s *immichSource) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", s.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Immich %s returned %s", path, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,106 @@
+package photos
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// immichAlbumJSON is an album as Immich returns it, trimmed to a few assets
+const immichAlbumJSON = `{
+  "id": "album-1",
+  "albumName": "Fridge",
+  "assetCount": 5,
+  "assets": [
+    {"id": "6f1c2a9e-aaaa-4b1f-9c1e-000000000001", "type": "IMAGE", "originalFileName": "IMG_0001.JPG",
+     "checksum": "b64sum1", "fileModifiedAt": "2024-07-06T10:00:00.000Z", "isTrashed": false},
+    {"id": "7a2d3b8f-bbbb-4c2e-8d2f-000000000002", "type": "IMAGE", "originalFileName": "IMG_0001.JPG",
+     "checksum": "b64sum2", "fileModifiedAt": "2024-07-07T10:00:00.000Z", "isTrashed": false},
+    {"id": "8b3e4c7a-cccc-4d3f-9e3a-000000000003", "type": "VIDEO", "originalFileName": "clip.mov",
+     "checksum": "b64sum3", "fileModifiedAt": "2024-07-08T10:00:00.000Z", "isTrashed": false},
+    {"id": "9c4f5d6b-dddd-4e4a-8f4b-000000000004", "type": "IMAGE", "originalFileName": "gone.jpg",
+     "checksum": "b64sum4", "fileModifiedAt": "2024-07-09T10:00:00.000Z", "isTrashed": true},
+    {"id": "ad5a6e5c-eeee-4f5b-9a5c-000000000005", "type": "IMAGE", "originalFileName": "../../etc/pic.heic",
+     "checksum": "b64sum5", "fileModifiedAt": "2024-07-10T10:00:00.000Z", "isTrashed": false}
+  ]
+}`
+
+// immichStandIn serves one album and the originals of its assets
+func immichStandIn(t *testing.T, originals map[string][]byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/albums/album-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, immichAlbumJSON)
+	})
+	mux.HandleFunc("GET /api/assets/{id}/original", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := originals[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" {
+			http.Error(w, `{"message":"Invalid API key"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestImmichList(t *testing.T) {
+	server := immichStandIn(t, map[string][]byte{"6f1c2a9e-aaaa-4b1f-9c1e-000000000001": []byte("jpeg")})
+	defer server.Close()
+
+	source := newImmichSource(server.URL+"/", "album-1", "key")
+	photos, err := source.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := []SourcePhoto{
+		{Name: "IMG_0001.JPG", ID: "6f1c2a9e-aaaa-4b1f-9c1e-000000000001", Version: "b64sum1",
+			ModTime: time.Date(2024, 7, 6, 10, 0, 0, 0, time.UTC)},
+		{Name: "IMG_0001-7a2d3b8f.JPG", ID: "7a2d3b8f-bbbb-4c2e-8d2f-000000000002", Version: "b64sum2",
+			ModTime: time.Date(2024, 7, 7, 10, 0, 0, 0, time.UTC)},
+		{Name: "_.._etc_pic.heic", ID: "ad5a6e5c-eeee-4f5b-9a5c-000000000005", Version: "b64sum5",
+			ModTime: time.Date(2024, 7, 10, 10, 0, 0, 0, time.UTC)},
+	}
+	if len(photos) != len(want) {
+		t.Fatalf("List returned %d photos, want %d: %+v", len(photos), len(want), photos)
+	}
+	for i, photo := range photos {
+		if photo.Name != want[i].Name || photo.ID != want[i].ID || photo.Version != want[i].Version || !photo.ModTime.Equal(want[i].ModTime) {
+			t.Errorf("photo %d = %+v, want %+v", i, photo, want[i])
+		}
+		if !validRelativePath(photo.Name) {
+			t.Errorf("%q is not a safe photo name", photo.Name)
+		}
+	}
+
+	body, err := source.Open(context.Background(), photos[0])
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "jpeg" {
+		t.Errorf("Open returned %q, want %q", data, "jpeg")
+	}
+	if _, err := source.Open(context.Background(), photos[1]); err == nil {
+		t.Error("Open of a missing original succeeded")
+	}
+}
+
+func TestImmichListWrongKey(t *testing.T) {
+	server := immichStandIn(t, nil)
+	defer server.Close()
+
+	if _, err := newImmichSource(server.URL, "album-1", "wrong").List(context.Background()); err == nil {
+		t.Error("List with a wrong API key succeeded")
+	}
+}
@@ -0,0 +1,584 @@
+package photos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// indexInterval is how often the photos folder is rescanned. Polling is used
+// instead of inotify because it also works on network shares and Docker bind mounts.
+const indexInterval = time.Minute
+
+// regroupDelay is how long an upload or delete waits before duplicate groups are
+// recomputed, so a batch of them shares one pass over every pair of photos
+var regroupDelay = 2 * time.Second
+
+// Photo orientations, after applying EXIF rotation
+const (
+	orientationLandscape = "landscape"
+	orientationPortrait  = "portrait"
+	orientationSquare    = "square"
+)
+
+// squareTolerance is how far from 1:1 an aspect ratio can be and still count as square
+const squareTolerance = 0.1
+
+// errPhotosDirNotFound is returned until the photos folder exists
+var errPhotosDirNotFound = errors.New("Photos directory not found")
+
+// Photo is an indexed image and its metadata
+type Photo struct {
+	Name          string    `json:"name"`  // path relative to the photos folder, "/"-separated
+	Album         string    `json:"album"` // subfolder the photo is in, "" for the top level
+	Width         int       `json:"width"` // after EXIF rotation; 0 if unknown
+	Height        int       `json:"height"`
+	Orientation   string    `json:"orientation,omitempty"` // landscape, portrait or square
+	TakenAt       time.Time `json:"takenAt"`
+	TakenAtSource string    `json:"takenAtSource"` // "exif", or "file" when the modification time was used
+	Camera        string    `json:"camera,omitempty"`
+	Latitude      *float64  `json:"latitude,omitempty"`
+	Longitude     *float64  `json:"longitude,omitempty"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"modTime"`
+	Hash          string    `json:"hash,omitempty"`  // SHA-256 of the file
+	DHash         string    `json:"dHash,omitempty"` // perceptual hashes, computed after the photo is indexed
+	PHash         string    `json:"pHash,omitempty"`
+	Error         string    `json:"error,omitempty"` // why the photo can't be shown
+
+	// hashError is why the perceptual hashes couldn't be computed. The photo isn't
+	// tried again until the file changes, which replaces its entry.
+	hashError string
+
+	// DuplicateGroup is the name of the first photo of the group of copies this
+	// photo belongs to, if any
+	DuplicateGroup string `json:"duplicateGroup,omitempty"`
+
+	PhotoMetadata
+}
+
+// Album is a subfolder of the photos folder
+type Album struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"` // photos directly in the album or its subfolders
+}
+
+// prepareFunc returns a decodable source for a photo, converting it if needed
+type prepareFunc func(path, name string, info os.FileInfo) (photoSource, error)
+
+// photoIndex keeps the metadata of every photo under root, rescanning periodically.
+// Unchanged files (same size and modification time) are not re-read.
+type photoIndex struct {
+	root    string
+	prepare prepareFunc
+
+	mu     sync.RWMutex
+	photos map[string]*Photo
+	err    error
+
+	ready     chan struct{}
+	readyOnce sync.Once
+	refresh   chan struct{}
+
+	// groupMu serializes regroup so the last one to finish saw the latest photos
+	groupMu        sync.Mutex
+	groupThreshold int
+
+	regroupMu    sync.Mutex
+	regroupTimer *time.Timer // pending regroup after an upload or delete
+}
+
+func newPhotoIndex(root string, prepare prepareFunc) *photoIndex {
+	return &photoIndex{
+		root:    root,
+		prepare: prepare,
+		photos:  make(map[string]*Photo),
+		ready:   make(chan struct{}),
+		refresh: make(chan struct{}, 1),
+
+		groupThreshold: -1,
+	}
+}
+
+// run scans the folder now and then every indexInterval, or sooner when Refresh is called
+func (idx *photoIndex) run() {
+	ticker := time.NewTicker(indexInterval)
+	defer ticker.Stop()
+	for {
+		idx.scan()
+		select {
+		case <-ticker.C:
+		case <-idx.refresh:
+		}
+	}
+}
+
+// Refresh asks for a rescan without waiting for the next interval
+func (idx *photoIndex) Refresh() {
+	select {
+	case idx.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// scan walks the photos folder and replaces the index with what it finds. HEIC and
+// RAW files are converted, and perceptual hashes computed, after the rest of the
+// index is published, since that can take a while the first time.
+func (idx *photoIndex) scan() {
+	defer idx.readyOnce.Do(func() { close(idx.ready) })
+
+	if info, err := os.Stat(idx.root); err != nil || !info.IsDir() {
+		idx.mu.Lock()
+		idx.photos = make(map[string]*Photo)
+		idx.err = errPhotosDirNotFound
+		idx.mu.Unlock()
+		return
+	}
+
+	idx.mu.RLock()
+	previous := idx.photos
+	idx.mu.RUnlock()
+
+	loc := shared.GetLocation()
+	photos := make(map[string]*Photo, len(previous))
+	added, updated := 0, 0
+	var deferred []string
+
+	err := filepath.WalkDir(idx.root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subfolders are skipped rather than failing the whole scan
+			if p != idx.root && entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if p == idx.root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isPhotoFile(filepath.Ext(entry.Name())) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(idx.root, p)
+		if err != nil {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		if old, ok := previous[name]; ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			photos[name] = old
+			return nil
+		}
+		if _, ok := previous[name]; ok {
+			updated++
+		} else {
+			added++
+		}
+		if needsTranscode(filepath.Ext(name)) {
+			photos[name] = basicPhoto(name, info)
+			deferred = append(deferred, name)
+			return nil
+		}
+		photos[name] = readPhotoMetadata(p, name, info, loc, idx.prepare)
+		return nil
+	})
+
+	removed := 0
+	for name := range previous {
+		if _, ok := photos[name]; !ok {
+			removed++
+		}
+	}
+
+	idx.mu.Lock()
+	idx.photos = photos
+	idx.err = err
+	idx.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[Photos] Index scan failed: %v", err)
+	}
+	if added > 0 || updated > 0 || removed > 0 {
+		log.Printf("[Photos] Indexed %d photos (%d added, %d updated, %d removed)", len(photos), added, updated, removed)
+	}
+
+	// Let the first request through before converting
+	idx.readyOnce.Do(func() { close(idx.ready) })
+
+	failed := 0
+	for _, name := range deferred {
+		placeholder := photos[name]
+		info, err := os.Stat(filepath.Join(idx.root, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		photo := readPhotoMetadata(filepath.Join(idx.root, filepath.FromSlash(name)), name, info, loc, idx.prepare)
+		if photo.Error != "" {
+			failed++
+		}
+
+		idx.mu.Lock()
+		// Keep the placeholder's identity check so a newer scan's entry isn't overwritten
+		if idx.photos[name] == placeholder {
+			*placeholder = *photo
+		}
+		idx.mu.Unlock()
+	}
+	if failed > 0 {
+		log.Printf("[Photos] %d photos could not be decoded, see /api/photos/index?failed=true", failed)
+	}
+
+	hashed := idx.hashPhotos(previous)
+	if added > 0 || updated > 0 || removed > 0 || hashed > 0 || duplicateThreshold() != idx.groupThreshold {
+		idx.regroup()
+	}
+}
+
+// hashPhotos computes the perceptual hashes of photos that don't have them yet,
+// reusing those of photos with the same content. It returns how many it set.
+func (idx *photoIndex) hashPhotos(previous map[string]*Photo) int {
+	idx.mu.RLock()
+	known := make(map[string][2]string)
+	var pending []*Photo
+	for _, photos := range []map[string]*Photo{previous, idx.photos} {
+		for _, photo := range photos {
+			if photo.PHash != "" {
+				known[photo.Hash] = [2]string{photo.DHash, photo.PHash}
+			}
+		}
+	}
+	for _, photo := range idx.photos {
+		if photo.PHash == "" && photo.Error == "" && photo.hashError == "" {
+			pending = append(pending, photo)
+		}
+	}
+	idx.mu.RUnlock()
+
+	hashed := 0
+	for _, photo := range pending {
+		idx.mu.RLock()
+		name, hash := photo.Name, photo.Hash
+		idx.mu.RUnlock()
+
+		hashes, ok := known[hash]
+		if !ok || hash == "" {
+			d, p, err := idx.hashFile(name)
+			if err != nil {
+				log.Printf("[Photos] Failed to hash %s: %v", name, err)
+				idx.mu.Lock()
+				photo.hashError = err.Error()
+				idx.mu.Unlock()
+				continue
+			}
+			hashes = [2]string{d, p}
+			known[hash] = hashes
+		}
+
+		idx.mu.Lock()
+		if idx.photos[name] == photo {
+			photo.DHash, photo.PHash = hashes[0], hashes[1]
+			hashed++
+		}
+		idx.mu.Unlock()
+	}
+	return hashed
+}
+
+// hashFile computes the perceptual hashes of one indexed photo
+func (idx *photoIndex) hashFile(name string) (string, string, error) {
+	filePath := filepath.Join(idx.root, filepath.FromSlash(name))
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	src, err := idx.prepare(filePath, name, info)
+	if err != nil {
+		return "", "", err
+	}
+	return photoHashes(src)
+}
+
+// regroup recomputes the duplicate groups of every photo
+func (idx *photoIndex) regroup() {
+	idx.groupMu.Lock()
+	defer idx.groupMu.Unlock()
+
+	threshold := duplicateThreshold()
+	idx.mu.RLock()
+	photos := make([]Photo, 0, len(idx.photos))
+	for _, photo := range idx.photos {
+		photos = append(photos, *photo)
+	}
+	idx.mu.RUnlock()
+
+	// Comparing every pair takes a moment for large libraries, so it is done
+	// without holding the index lock
+	groups := findDuplicateGroups(photos, threshold)
+
+	idx.mu.Lock()
+	for name, photo := range idx.photos {
+		photo.DuplicateGroup = groups[name]
+	}
+	idx.groupThreshold = threshold
+	idx.mu.Unlock()
+
+	if len(groups) > 0 {
+		log.Printf("[Photos] %d photos have duplicates, see /api/photos/duplicates", len(groups))
+	}
+}
+
+// scheduleRegroup recomputes duplicate groups in the background once no other
+// photo has been added or removed for regroupDelay
+func (idx *photoIndex) scheduleRegroup() {
+	idx.regroupMu.Lock()
+	defer idx.regroupMu.Unlock()
+	if idx.regroupTimer == nil {
+		idx.regroupTimer = time.AfterFunc(regroupDelay, idx.regroup)
+		return
+	}
+	idx.regroupTimer.Reset(regroupDelay)
+}
+
+// indexFile adds or refreshes a single photo right away, e.g. after an upload
+func (idx *photoIndex) indexFile(name string) {
+	filePath := filepath.Join(idx.root, filepath.FromSlash(name))
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	photo := readPhotoMetadata(filePath, name, info, shared.GetLocation(), idx.prepare)
+	if photo.Error == "" {
+		var err error
+		if photo.DHash, photo.PHash, err = idx.hashFile(name); err != nil {
+			photo.hashError = err.Error()
+		}
+	}
+	defer idx.scheduleRegroup()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	// Copy the map so a scan in progress keeps working on its own
+	photos := make(map[string]*Photo, len(idx.photos)+1)
+	for key, value := range idx.photos {
+		photos[key] = value
+	}
+	photos[name] = photo
+	idx.photos = photos
+	idx.err = nil
+}
+
+// remove drops a photo from the index, e.g. after it was deleted
+func (idx *photoIndex) remove(name string) {
+	defer idx.scheduleRegroup()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	photos := make(map[string]*Photo, len(idx.photos))
+	for key, value := range idx.photos {
+		if key != name {
+			photos[key] = value
+		}
+	}
+	idx.photos = photos
+}
+
+// findByHash returns the name of an indexed photo with the given content hash
+func (idx *photoIndex) findByHash(hash string) (string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for name, photo := range idx.photos {
+		if photo.Hash == hash {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// failure returns why a photo failed to decode, or "" if it didn't
+func (idx *photoIndex) failure(name string) string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if photo, ok := idx.photos[name]; ok {
+		return photo.Error
+	}
+	return ""
+}
+
+// markFailed records a decode failure found while serving a photo
+func (idx *photoIndex) markFailed(name string, err error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if photo, ok := idx.photos[name]; ok {
+		photo.Error = err.Error()
+	}
+}
+
+// wait blocks until the first scan has finished
+func (idx *photoIndex) wait(ctx context.Context) error {
+	select {
+	case <-idx.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// snapshot returns a copy of every indexed photo, sorted by name
+func (idx *photoIndex) snapshot() ([]Photo, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.err != nil && len(idx.photos) == 0 {
+		return nil, idx.err
+	}
+	photos := make([]Photo, 0, len(idx.photos))
+	for _, photo := range idx.photos {
+		photos = append(photos, *photo)
+	}
+	sort.Slice(photos, func(i, j int) bool { return photos[i].Name < photos[j].Name })
+	return photos, nil
+}
+
+// albums returns every album with its photo count, sorted by name
+func (idx *photoIndex) albums() ([]Album, error) {
+	photos, err := idx.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, photo := range photos {
+		// A photo in "2023/Beach" also counts towards "2023"
+		for album := photo.Album; album != ""; album = parentAlbum(album) {
+			counts[album]++
+		}
+	}
+
+	albums := make([]Album, 0, len(counts))
+	for name, count := range counts {
+		albums = append(albums, Album{Name: name, Count: count})
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].Name < albums[j].Name })
+	return albums, nil
+}
+
+func parentAlbum(album string) string {
+	if i := strings.LastIndex(album, "/"); i >= 0 {
+		return album[:i]
+	}
+	return ""
+}
+
+// basicPhoto is an index entry with only what the file system knows
+func basicPhoto(name string, info os.FileInfo) *Photo {
+	photo := &Photo{
+		Name:          name,
+		Album:         path.Dir(name),
+		Size:          info.Size(),
+		ModTime:       info.ModTime(),
+		TakenAt:       info.ModTime(),
+		TakenAtSource: "file",
+	}
+	if photo.Album == "." {
+		photo.Album = ""
+	}
+	return photo
+}
+
+// readPhotoMetadata reads dimensions and EXIF data from a photo. Metadata that
+// can't be read is left empty; a photo that can't be decoded at all gets Error.
+func readPhotoMetadata(filePath, name string, info os.FileInfo, loc *time.Location, prepare prepareFunc) *Photo {
+	photo := basicPhoto(name, info)
+	photo.Hash, _ = hashFile(filePath)
+
+	exif := readPhotoEXIF(filePath)
+	if takenAt, ok := exif.DateTaken(loc); ok {
+		photo.TakenAt = takenAt
+		photo.TakenAtSource = "exif"
+	}
+	photo.Camera = exif.Camera()
+	if lat, lon, ok := exif.GPS(); ok {
+		photo.Latitude, photo.Longitude = &lat, &lon
+	}
+
+	src, err := prepare(filePath, name, info)
+	if err != nil {
+		photo.Error = err.Error()
+		return photo
+	}
+
+	f, err := os.Open(src.Path)
+	if err != nil {
+		photo.Error = err.Error()
+		return photo
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err == nil {
+		err = checkPixels(config.Width, config.Height)
+	}
+	if err != nil {
+		photo.Error = "failed to decode image: " + err.Error()
+		return photo
+	}
+	if config.Width > 0 && config.Height > 0 {
+		photo.Width, photo.Height = config.Width, config.Height
+		if src.Orientation >= 5 {
+			photo.Width, photo.Height = photo.Height, photo.Width
+		}
+		photo.Orientation = orientationFor(float64(photo.Width) / float64(photo.Height))
+	}
+	return photo
+}
+
+// orientationFor classifies an aspect ratio (width / height)
+func orientationFor(aspect float64) string {
+	switch {
+	case aspect > 1+squareTolerance:
+		return orientationLandscape
+	case aspect < 1-squareTolerance:
+		return orientationPortrait
+	default:
+		return orientationSquare
+	}
+}
+
+// hashFile returns the hex SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,101 @@
+package photos
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashFailureNotRetried(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(path, testImage(t, 16, 16, "jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file can be read for its metadata, but the second look, for its hashes,
+	// fails, as when a photo is replaced or a converter crashes partway
+	calls := 0
+	idx := newPhotoIndex(dir, func(path, name string, info os.FileInfo) (photoSource, error) {
+		calls++
+		if calls%2 == 0 {
+			return photoSource{}, errors.New("converter crashed")
+		}
+		return photoSource{Path: path, Orientation: 1}, nil
+	})
+
+	idx.scan()
+	if calls != 2 {
+		t.Fatalf("first scan prepared the photo %d times, want 2", calls)
+	}
+	idx.scan()
+	idx.scan()
+	if calls != 2 {
+		t.Errorf("later scans prepared the unchanged photo again (%d calls)", calls)
+	}
+
+	// A changed file gets a new entry and is hashed again
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx.scan()
+	if calls != 4 {
+		t.Errorf("scan after the photo changed prepared it %d times in total, want 4", calls)
+	}
+}
+
+func TestIndexFileRegroupsInBackground(t *testing.T) {
+	defer func(delay time.Duration) { regroupDelay = delay }(regroupDelay)
+	regroupDelay = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	photo := testImage(t, 16, 16, "jpeg")
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), photo, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := newPhotoIndex(dir, func(path, name string, info os.FileInfo) (photoSource, error) {
+		return photoSource{Path: path, Orientation: 1}, nil
+	})
+	idx.scan()
+
+	group := func(name string) string {
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+		if photo, ok := idx.photos[name]; ok {
+			return photo.DuplicateGroup
+		}
+		return ""
+	}
+
+	// An upload is indexed right away, but grouped with its copies a moment later
+	if err := os.WriteFile(filepath.Join(dir, "c.jpg"), photo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx.indexFile("c.jpg")
+	if got := group("c.jpg"); got != "" {
+		t.Errorf("c.jpg was grouped before indexFile returned (group %q)", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for group("c.jpg") == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := group("c.jpg"), group("a.jpg"); got == "" || got != want {
+		t.Errorf("c.jpg is in group %q, want a.jpg's group %q", got, want)
+	}
+
+	// Deleting the copies leaves the last one without a group
+	idx.remove("a.jpg")
+	idx.remove("b.jpg")
+	deadline = time.Now().Add(2 * time.Second)
+	for group("c.jpg") != "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := group("c.jpg"); got != "" {
+		t.Errorf("c.jpg is still in group %q after its copies were removed", got)
+	}
+}
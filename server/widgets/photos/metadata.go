@@ -0,0 +1,145 @@
+package photos
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// metadataFileName is the sidecar file in the photos folder holding captions and
+// flags. It starts with a dot so the indexer skips it.
+const metadataFileName = ".metadata.json"
+
+// PhotoMetadata is what the family adds to a photo
+type PhotoMetadata struct {
+	Caption  string `json:"caption,omitempty"`
+	Favorite bool   `json:"favorite,omitempty"`
+	Hidden   bool   `json:"hidden,omitempty"` // skipped by the carousel
+}
+
+// PhotoMetadataUpdate is the body of PATCH /api/photos/{name}; omitted fields are unchanged
+type PhotoMetadataUpdate struct {
+	Caption  *string `json:"caption,omitempty"`
+	Favorite *bool   `json:"favorite,omitempty"`
+	Hidden   *bool   `json:"hidden,omitempty"`
+}
+
+// metadataStore reads and writes the sidecar file. The file is re-read when its
+// modification time changes, so hand edits are picked up.
+type metadataStore struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]PhotoMetadata // keyed by photo name
+	modTime time.Time
+}
+
+func newMetadataStore(photosDir string) *metadataStore {
+	return &metadataStore{path: filepath.Join(photosDir, metadataFileName)}
+}
+
+// loadLocked refreshes entries from disk if the file changed. Callers hold mu.
+func (s *metadataStore) loadLocked() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.entries, s.modTime = make(map[string]PhotoMetadata), time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.entries != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]PhotoMetadata)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	s.entries, s.modTime = entries, info.ModTime()
+	return nil
+}
+
+// saveLocked writes entries through a temp file so a crash can't truncate it
+func (s *metadataStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// all returns a copy of every photo's metadata
+func (s *metadataStore) all() map[string]PhotoMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make(map[string]PhotoMetadata)
+	if err := s.loadLocked(); err != nil {
+		return entries
+	}
+	for name, meta := range s.entries {
+		entries[name] = meta
+	}
+	return entries
+}
+
+// update applies a change to one photo's metadata and saves it
+func (s *metadataStore) update(name string, change PhotoMetadataUpdate) (PhotoMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadLocked(); err != nil {
+		return PhotoMetadata{}, err
+	}
+	meta := s.entries[name]
+	if change.Caption != nil {
+		meta.Caption = *change.Caption
+	}
+	if change.Favorite != nil {
+		meta.Favorite = *change.Favorite
+	}
+	if change.Hidden != nil {
+		meta.Hidden = *change.Hidden
+	}
+
+	// Don't keep empty entries around
+	if meta == (PhotoMetadata{}) {
+		delete(s.entries, name)
+	} else {
+		s.entries[name] = meta
+	}
+	return meta, s.saveLocked()
+}
+
+// remove drops a photo's metadata, e.g. when it is deleted
+func (s *metadataStore) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.entries[name]; !ok {
+		return nil
+	}
+	delete(s.entries, name)
+	return s.saveLocked()
+}
@@ -0,0 +1,279 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults for the source config
+const (
+	defaultSourceSyncMinutes  = 15
+	defaultSourceCacheMaxSize = 2560
+)
+
+// mirrorManifestName records which version of each source photo is mirrored.
+// It starts with a dot so the indexer skips it.
+const mirrorManifestName = ".source.json"
+
+// mirrorQuality is the JPEG quality of mirrored copies
+const mirrorQuality = 90
+
+// maxSourcePhotoBytes bounds one download from a source
+const maxSourcePhotoBytes = 200 << 20
+
+// sourceDownloadTimeout bounds fetching and converting one photo
+const sourceDownloadTimeout = 5 * time.Minute
+
+// mirrorEntry is one mirrored photo in the manifest
+type mirrorEntry struct {
+	Version string `json:"version"`
+	File    string `json:"file"` // name in the mirror folder
+}
+
+// sourceMirror keeps a folder of downsized JPEG copies of a source's photos. The
+// index and photo endpoints work on that folder, so photos already copied keep
+// showing while the source is unreachable.
+type sourceMirror struct {
+	source   PhotoSource
+	dir      string
+	maxSize  int
+	interval time.Duration
+	onChange func()
+}
+
+func newSourceMirror(source PhotoSource, dir string, config SourceConfig, onChange func()) *sourceMirror {
+	m := &sourceMirror{
+		source:   source,
+		dir:      dir,
+		maxSize:  config.CacheMaxSize,
+		interval: time.Duration(config.SyncMinutes) * time.Minute,
+		onChange: onChange,
+	}
+	if m.maxSize <= 0 {
+		m.maxSize = defaultSourceCacheMaxSize
+	}
+	if m.interval <= 0 {
+		m.interval = defaultSourceSyncMinutes * time.Minute
+	}
+	return m
+}
+
+// run syncs now and then every interval
+func (m *sourceMirror) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.sync(context.Background())
+		<-ticker.C
+	}
+}
+
+// sync copies new and changed photos from the source and removes photos that
+// were deleted there. Nothing is removed when the source can't be listed.
+func (m *sourceMirror) sync(ctx context.Context) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		log.Printf("[Photos] Failed to create mirror folder: %v", err)
+		return
+	}
+
+	photos, err := m.source.List(ctx)
+	if err != nil {
+		log.Printf("[Photos] Failed to list %s, showing cached photos: %v", m.source.Name(), err)
+		return
+	}
+
+	manifest := m.loadManifest()
+	changed := false
+	copied, failed := 0, 0
+	listed := make(map[string]bool, len(photos))
+
+	for _, photo := range photos {
+		if !validRelativePath(photo.Name) {
+			continue
+		}
+		listed[photo.Name] = true
+		file := mirrorFileName(photo.Name)
+		if entry, ok := manifest[photo.Name]; ok && entry.Version == photo.Version {
+			if _, err := os.Stat(filepath.Join(m.dir, filepath.FromSlash(entry.File))); err == nil {
+				continue
+			}
+		}
+
+		if err := m.copyPhoto(ctx, photo, file); err != nil {
+			// Retried on the next sync, since the version isn't recorded
+			log.Printf("[Photos] Failed to copy %s from %s: %v", photo.Name, m.source.Name(), err)
+			failed++
+			continue
+		}
+		if entry, ok := manifest[photo.Name]; ok && entry.File != file {
+			m.removeFile(entry.File)
+		}
+		manifest[photo.Name] = mirrorEntry{Version: photo.Version, File: file}
+		changed = true
+		copied++
+	}
+
+	removed := 0
+	for name, entry := range manifest {
+		if listed[name] {
+			continue
+		}
+		m.removeFile(entry.File)
+		delete(manifest, name)
+		changed = true
+		removed++
+	}
+
+	if copied > 0 || removed > 0 || failed > 0 {
+		log.Printf("[Photos] Synced %s: %d copied, %d removed, %d failed", m.source.Name(), copied, removed, failed)
+	}
+	if !changed {
+		return
+	}
+	if err := m.saveManifest(manifest); err != nil {
+		log.Printf("[Photos] Failed to save mirror manifest: %v", err)
+	}
+	m.onChange()
+}
+
+// copyPhoto downloads a photo and stores a downsized, upright JPEG copy of it
+func (m *sourceMirror) copyPhoto(ctx context.Context, photo SourcePhoto, file string) error {
+	ctx, cancel := context.WithTimeout(ctx, sourceDownloadTimeout)
+	defer cancel()
+
+	body, err := m.source.Open(ctx, photo)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Decoders and converters need a file with the original extension
+	ext := strings.ToLower(filepath.Ext(photo.Name))
+	tmp, err := os.CreateTemp("", "photo-source-*"+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, io.LimitReader(body, maxSourcePhotoBytes+1))
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	if written > maxSourcePhotoBytes {
+		return fmt.Errorf("larger than %d MB", maxSourcePhotoBytes>>20)
+	}
+
+	data, err := m.downsize(tmp.Name(), ext)
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(m.dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	// Written under a name the indexer ignores, then renamed into place
+	partial := dest + ".tmp"
+	if err := os.WriteFile(partial, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	// Photos without EXIF dates fall back to the file time, so keep the source's
+	if !photo.ModTime.IsZero() {
+		os.Chtimes(dest, photo.ModTime, photo.ModTime)
+	}
+	return nil
+}
+
+// downsize decodes a photo, shrinks it to fit maxSize and encodes it as an upright
+// JPEG, keeping its EXIF block for the date, camera and location
+func (m *sourceMirror) downsize(path, ext string) ([]byte, error) {
+	exif := readPhotoEXIF(path)
+
+	var img image.Image
+	orientation := 1
+	if needsTranscode(ext) {
+		decoded, err := decodeForTranscode(path, ext)
+		if err != nil {
+			return nil, err
+		}
+		img = decoded
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeLimited(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		img, orientation = decoded, exif.Orientation()
+	}
+
+	img = orient(resize(img, resizeOptions{Width: m.maxSize, Height: m.maxSize, Fit: fitContain}), orientation)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: mirrorQuality}); err != nil {
+		return nil, err
+	}
+	return insertJPEGSegment(buf.Bytes(), exif.uprightSegment()), nil
+}
+
+// removeFile deletes a mirrored copy and any album folders it leaves empty
+func (m *sourceMirror) removeFile(file string) {
+	p := filepath.Join(m.dir, filepath.FromSlash(file))
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		log.Printf("[Photos] Failed to remove mirrored %s: %v", file, err)
+		return
+	}
+	for dir := filepath.Dir(p); dir != m.dir && strings.HasPrefix(dir, m.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+func (m *sourceMirror) loadManifest() map[string]mirrorEntry {
+	manifest := make(map[string]mirrorEntry)
+	data, err := os.ReadFile(filepath.Join(m.dir, mirrorManifestName))
+	if err == nil {
+		json.Unmarshal(data, &manifest)
+	}
+	return manifest
+}
+
+func (m *sourceMirror) saveManifest(manifest map[string]mirrorEntry) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.dir, mirrorManifestName)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// mirrorFileName is the mirror's name for a source photo. Copies are always JPEG,
+// so other formats get .jpg appended, which also keeps IMG_1.PNG and IMG_1.JPG apart.
+func mirrorFileName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return name
+	}
+	return name + ".jpg"
+}
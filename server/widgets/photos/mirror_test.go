@@ -0,0 +1,145 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testImage encodes a width x height gradient as JPEG or PNG
+func testImage(t *testing.T, width, height int, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// imageSize decodes the dimensions of a mirrored file
+func imageSize(t *testing.T, path string) (int, int) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	if format != "jpeg" {
+		t.Errorf("%s is %s, want jpeg", path, format)
+	}
+	return config.Width, config.Height
+}
+
+func TestSourceMirrorSync(t *testing.T) {
+	standIn := &webdavStandIn{files: map[string][]byte{
+		"wide.jpg":        testImage(t, 400, 200, "jpeg"),
+		"Album/small.png": testImage(t, 40, 30, "png"),
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	dir := t.TempDir()
+	changes := 0
+	mirror := newSourceMirror(newWebDAVSource(server.URL+"/dav/Photos/", "me", "secret"), dir,
+		SourceConfig{CacheMaxSize: 100}, func() { changes++ })
+
+	mirror.sync(context.Background())
+	if changes != 1 {
+		t.Fatalf("first sync reported %d changes, want 1", changes)
+	}
+	// Copies are shrunk to fit the cache size, never enlarged, and always JPEG
+	if w, h := imageSize(t, filepath.Join(dir, "wide.jpg")); w != 100 || h != 50 {
+		t.Errorf("wide.jpg mirrored at %dx%d, want 100x50", w, h)
+	}
+	if w, h := imageSize(t, filepath.Join(dir, "Album", "small.png.jpg")); w != 40 || h != 30 {
+		t.Errorf("small.png mirrored at %dx%d, want 40x30", w, h)
+	}
+	manifest := mirror.loadManifest()
+	if entry := manifest["Album/small.png"]; entry.File != "Album/small.png.jpg" || entry.Version == "" {
+		t.Errorf("manifest entry for Album/small.png = %+v", entry)
+	}
+
+	// Nothing changed, so nothing is copied again
+	mirror.sync(context.Background())
+	if changes != 1 {
+		t.Errorf("unchanged sync reported a change")
+	}
+
+	// While the source is down, the copies stay
+	standIn.down = true
+	mirror.sync(context.Background())
+	for _, file := range []string{"wide.jpg", "Album/small.png.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("%s was removed while the source was down: %v", file, err)
+		}
+	}
+	if changes != 1 {
+		t.Errorf("sync of a source that is down reported a change")
+	}
+
+	// Photos deleted from the source are removed, along with their empty album
+	standIn.down = false
+	delete(standIn.files, "Album/small.png")
+	standIn.files["wide.jpg"] = testImage(t, 300, 600, "jpeg")
+	mirror.sync(context.Background())
+	if changes != 2 {
+		t.Errorf("sync after changes reported %d changes, want 2", changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Album")); !os.IsNotExist(err) {
+		t.Errorf("Album folder still exists after its only photo was deleted")
+	}
+	if w, h := imageSize(t, filepath.Join(dir, "wide.jpg")); w != 50 || h != 100 {
+		t.Errorf("changed wide.jpg mirrored at %dx%d, want 50x100", w, h)
+	}
+}
+
+func TestSourceMirrorImmich(t *testing.T) {
+	server := immichStandIn(t, map[string][]byte{
+		"6f1c2a9e-aaaa-4b1f-9c1e-000000000001": testImage(t, 64, 48, "jpeg"),
+		"7a2d3b8f-bbbb-4c2e-8d2f-000000000002": []byte("not an image"),
+	})
+	defer server.Close()
+
+	dir := t.TempDir()
+	mirror := newSourceMirror(newImmichSource(server.URL, "album-1", "key"), dir, SourceConfig{}, func() {})
+	mirror.sync(context.Background())
+
+	if w, h := imageSize(t, filepath.Join(dir, "IMG_0001.JPG")); w != 64 || h != 48 {
+		t.Errorf("IMG_0001.JPG mirrored at %dx%d, want 64x48", w, h)
+	}
+	// The undecodable photo and the missing one are left for the next sync
+	manifest := mirror.loadManifest()
+	if len(manifest) != 1 {
+		t.Errorf("manifest has %d entries, want 1: %+v", len(manifest), manifest)
+	}
+	info, err := os.Stat(filepath.Join(dir, "IMG_0001.JPG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.ModTime().UTC().Format("2006-01-02"); got != "2024-07-06" {
+		t.Errorf("mirrored copy dated %s, want the source's 2024-07-06", got)
+	}
+}
@@ -0,0 +1,234 @@
+package photos
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// Orders supported by /api/photos/list
+const (
+	orderShuffle       = "shuffle"       // random, without repeats until every photo was shown
+	orderChronological = "chronological" // oldest first
+	orderNewest        = "newest"        // newest first
+	orderOnThisDay     = "on-this-day"   // taken on today's date in past years, newest first
+	orderName          = "name"          // by path
+)
+
+// orientationMatch filters by the aspect ratio passed in ?aspect=
+const orientationMatch = "match"
+
+// maxShuffleDecks bounds how many shuffle sessions are remembered
+const maxShuffleDecks = 32
+
+// listOptions selects and orders photos for /api/photos/list
+type listOptions struct {
+	Order       string
+	Album       string
+	Orientation string // landscape, portrait, square or "" for all
+	Window      int    // on-this-day tolerance in days
+	Limit       int    // 0 for all
+	Session     string // shuffle deck to draw from when Limit is set
+
+	IncludeFailed bool // include photos that failed to decode
+	OnlyFailed    bool // only photos that failed to decode
+	IncludeHidden bool // include photos hidden from the carousel
+	Favorites     bool // only favorite photos
+	Duplicates    bool // include every copy of duplicate photos, not just the best
+}
+
+// parseListOptions reads the query string, falling back to the widget config
+func parseListOptions(r *http.Request) (listOptions, error) {
+	query := r.URL.Query()
+	value := func(name, configKey, fallback string) string {
+		if v := query.Get(name); v != "" {
+			return v
+		}
+		return shared.GetWidgetConfigValue("photos", configKey, fallback)
+	}
+
+	opts := listOptions{
+		Order:       value("order", "order", orderShuffle),
+		Album:       strings.Trim(value("album", "album", ""), "/"),
+		Orientation: value("orientation", "orientation", ""),
+		Session:     query.Get("session"),
+		OnlyFailed:  query.Get("failed") == "true",
+		Favorites:   query.Get("favorites") == "true",
+		Duplicates:  query.Get("duplicates") == "true",
+	}
+
+	switch opts.Order {
+	case orderShuffle, orderChronological, orderNewest, orderOnThisDay, orderName:
+	default:
+		return opts, fmt.Errorf("order must be shuffle, chronological, newest, on-this-day or name")
+	}
+
+	switch opts.Orientation {
+	case "", orientationLandscape, orientationPortrait, orientationSquare:
+	case orientationMatch:
+		// The tile's aspect ratio; without it there is nothing to match
+		aspect, err := strconv.ParseFloat(query.Get("aspect"), 64)
+		opts.Orientation = ""
+		if err == nil && aspect > 0 {
+			opts.Orientation = orientationFor(aspect)
+		}
+	default:
+		return opts, fmt.Errorf("orientation must be landscape, portrait, square or match")
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *int
+		max  int
+	}{{"window", &opts.Window, 31}, {"limit", &opts.Limit, 10000}} {
+		v := query.Get(param.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > param.max {
+			return opts, fmt.Errorf("%s must be between 0 and %d", param.name, param.max)
+		}
+		*param.dest = n
+	}
+	return opts, nil
+}
+
+// filterPhotos applies the album, orientation, flag and decode failure filters
+func filterPhotos(photos []Photo, opts listOptions) []Photo {
+	filtered := photos[:0:0]
+	for _, photo := range photos {
+		failed := photo.Error != ""
+		if failed && !opts.IncludeFailed || opts.OnlyFailed && !failed {
+			continue
+		}
+		if photo.Hidden && !opts.IncludeHidden || opts.Favorites && !photo.Favorite {
+			continue
+		}
+		if opts.Album != "" && photo.Album != opts.Album && !strings.HasPrefix(photo.Album, opts.Album+"/") {
+			continue
+		}
+		if opts.Orientation != "" && photo.Orientation != opts.Orientation {
+			continue
+		}
+		filtered = append(filtered, photo)
+	}
+	return filtered
+}
+
+// onThisDay returns photos taken within window days of today's date in an earlier
+// year, newest first. Only EXIF dates are used; file times are usually copy dates.
+func onThisDay(photos []Photo, now time.Time, window int) []Photo {
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var matches []Photo
+	for _, photo := range photos {
+		taken := photo.TakenAt.In(loc)
+		if photo.TakenAtSource != "exif" || taken.Year() >= now.Year() {
+			continue
+		}
+		// Check the anniversary in the neighbouring years too so windows can span New Year
+		for _, year := range []int{now.Year() - 1, now.Year(), now.Year() + 1} {
+			anniversary := time.Date(year, taken.Month(), taken.Day(), 0, 0, 0, 0, loc)
+			if days := anniversary.Sub(today).Hours() / 24; days >= -float64(window) && days <= float64(window) {
+				matches = append(matches, photo)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].TakenAt.After(matches[j].TakenAt) })
+	return matches
+}
+
+// shuffleDecks remembers which photos each session has already been given so that
+// repeated ?limit= requests go through the whole library before repeating
+type shuffleDecks struct {
+	mu    sync.Mutex
+	decks map[string]map[string]bool // session+filters -> names already drawn
+}
+
+// draw returns up to limit photos not yet drawn by the session, starting a new
+// round once all have been shown
+func (d *shuffleDecks) draw(key string, photos []Photo, limit int) []Photo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.decks == nil || len(d.decks) >= maxShuffleDecks && d.decks[key] == nil {
+		d.decks = make(map[string]map[string]bool)
+	}
+	seen := d.decks[key]
+	if seen == nil {
+		seen = make(map[string]bool)
+		d.decks[key] = seen
+	}
+
+	var unseen []Photo
+	for _, photo := range photos {
+		if !seen[photo.Name] {
+			unseen = append(unseen, photo)
+		}
+	}
+	rand.Shuffle(len(unseen), func(i, j int) { unseen[i], unseen[j] = unseen[j], unseen[i] })
+
+	drawn := unseen
+	if len(unseen) >= limit {
+		drawn = unseen[:limit]
+	} else {
+		// Start the next round with the photos that weren't just drawn
+		clear(seen)
+		for _, photo := range unseen {
+			seen[photo.Name] = true
+		}
+		rest := make([]Photo, 0, len(photos))
+		for _, photo := range photos {
+			if !seen[photo.Name] {
+				rest = append(rest, photo)
+			}
+		}
+		rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+		drawn = append(drawn, rest[:min(limit-len(unseen), len(rest))]...)
+		clear(seen)
+	}
+
+	for _, photo := range drawn {
+		seen[photo.Name] = true
+	}
+	return drawn
+}
+
+// orderPhotos filters and orders photos according to opts
+func (w *PhotosWidget) orderPhotos(photos []Photo, opts listOptions) []Photo {
+	photos = filterPhotos(photos, opts)
+	if !opts.Duplicates {
+		photos = collapseDuplicates(photos)
+	}
+
+	switch opts.Order {
+	case orderChronological:
+		sort.SliceStable(photos, func(i, j int) bool { return photos[i].TakenAt.Before(photos[j].TakenAt) })
+	case orderNewest:
+		sort.SliceStable(photos, func(i, j int) bool { return photos[i].TakenAt.After(photos[j].TakenAt) })
+	case orderOnThisDay:
+		photos = onThisDay(photos, time.Now().In(shared.GetLocation()), opts.Window)
+	case orderShuffle:
+		if opts.Limit > 0 {
+			key := strings.Join([]string{opts.Session, opts.Album, opts.Orientation}, "|")
+			return w.decks.draw(key, photos, opts.Limit)
+		}
+		rand.Shuffle(len(photos), func(i, j int) { photos[i], photos[j] = photos[j], photos[i] })
+	}
+
+	if opts.Limit > 0 && len(photos) > opts.Limit {
+		photos = photos[:opts.Limit]
+	}
+	return photos
+}
@@ -0,0 +1,159 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"io"
+	"os"
+	"sort"
+)
+
+// rawExtensions are camera RAW formats. Decoding the sensor data is out of scope;
+// every one of these embeds a JPEG preview, which is what gets shown.
+var rawExtensions = map[string]bool{
+	".dng": true,
+	".cr2": true,
+	".nef": true,
+	".nrw": true,
+	".arw": true,
+	".orf": true,
+	".rw2": true,
+	".pef": true,
+	".srw": true,
+	".raf": true,
+}
+
+// maxRAWSize bounds how much of a RAW file is read into memory
+const maxRAWSize = 256 << 20
+
+// TIFF tags that locate embedded previews
+const (
+	tagCompression                 = 0x0103
+	tagStripOffsets                = 0x0111
+	tagStripByteCounts             = 0x0117
+	tagSubIFDs                     = 0x014A
+	tagJPEGInterchangeFormat       = 0x0201
+	tagJPEGInterchangeFormatLength = 0x0202
+)
+
+var errNoRAWPreview = errors.New("no embedded JPEG preview")
+
+// readRAW reads a RAW file and returns its largest decodable JPEG preview and its
+// EXIF data. The preview usually has no orientation of its own, so the RAW's is used.
+func readRAW(path string) ([]byte, *exifData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxRAWSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fujifilm RAF has its own header pointing at a complete JPEG with EXIF
+	if bytes.HasPrefix(data, []byte("FUJIFILMCCD-RAW")) {
+		if len(data) < 92 {
+			return nil, nil, errNoRAWPreview
+		}
+		offset := int(binary.BigEndian.Uint32(data[84:]))
+		length := int(binary.BigEndian.Uint32(data[88:]))
+		if offset <= 0 || length <= 0 || offset+length > len(data) {
+			return nil, nil, errNoRAWPreview
+		}
+		preview := data[offset : offset+length]
+		exif, _ := readJPEGEXIF(bytes.NewReader(preview))
+		return preview, exif, nil
+	}
+
+	exif, err := parseTIFF(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	preview, err := exif.largestPreview(data)
+	if err != nil {
+		return nil, exif, err
+	}
+	return preview, exif, nil
+}
+
+// largestPreview collects the JPEGs referenced from every IFD and SubIFD and returns
+// the biggest one Go can decode (DNG raw data is lossless JPEG, which it can't)
+func (e *exifData) largestPreview(data []byte) ([]byte, error) {
+	var candidates [][]byte
+	add := func(offset, length uint64) {
+		if offset > 0 && length > 0 && offset+length <= uint64(len(data)) {
+			candidates = append(candidates, data[offset:offset+length])
+		}
+	}
+
+	visited := make(map[uint32]bool)
+	var walk func(offset uint32)
+	walk = func(offset uint32) {
+		for offset != 0 && !visited[offset] && len(visited) < 64 {
+			visited[offset] = true
+			ifd, next := e.readIFD(data, offset)
+			if ifd == nil {
+				return
+			}
+
+			start, ok1 := e.uint(ifd, tagJPEGInterchangeFormat)
+			length, ok2 := e.uint(ifd, tagJPEGInterchangeFormatLength)
+			if ok1 && ok2 {
+				add(start, length)
+			}
+			if compression, _ := e.uint(ifd, tagCompression); compression == 6 || compression == 7 {
+				start, ok1 := e.uint(ifd, tagStripOffsets)
+				length, ok2 := e.uint(ifd, tagStripByteCounts)
+				if ok1 && ok2 {
+					add(start, length)
+				}
+			}
+			// Panasonic stores the preview inline as an UNDEFINED field
+			for _, field := range ifd {
+				if field.Type == 7 && bytes.HasPrefix(field.Data, []byte{0xFF, 0xD8}) {
+					candidates = append(candidates, field.Data)
+				}
+			}
+			for _, sub := range e.uints(ifd, tagSubIFDs) {
+				walk(uint32(sub))
+			}
+			offset = next
+		}
+	}
+	walk(e.order.Uint32(data[4:8]))
+
+	sort.Slice(candidates, func(i, j int) bool { return len(candidates[i]) > len(candidates[j]) })
+	for _, candidate := range candidates {
+		if !bytes.HasPrefix(candidate, []byte{0xFF, 0xD8}) {
+			continue
+		}
+		if _, err := jpeg.DecodeConfig(bytes.NewReader(candidate)); err == nil {
+			return candidate, nil
+		}
+	}
+	return nil, errNoRAWPreview
+}
+
+// uints returns every value of a SHORT or LONG array field
+func (e *exifData) uints(ifd map[uint16]tiffField, tag uint16) []uint64 {
+	field, ok := ifd[tag]
+	if !ok {
+		return nil
+	}
+	var values []uint64
+	switch field.Type {
+	case 3:
+		for i := 0; i+2 <= len(field.Data); i += 2 {
+			values = append(values, uint64(e.order.Uint16(field.Data[i:])))
+		}
+	case 4, 13:
+		for i := 0; i+4 <= len(field.Data); i += 4 {
+			values = append(values, uint64(e.order.Uint32(field.Data[i:])))
+		}
+	}
+	return values
+}
@@ -0,0 +1,135 @@
+package photos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PhotoSource is somewhere photos are kept other than the local photos folder.
+// Remote sources are mirrored into a local cache so the carousel keeps working
+// when they are unreachable.
+type PhotoSource interface {
+	// Name describes the source in logs
+	Name() string
+	// List returns every photo in the source
+	List(ctx context.Context) ([]SourcePhoto, error)
+	// Open returns the contents of a photo returned by List
+	Open(ctx context.Context, photo SourcePhoto) (io.ReadCloser, error)
+}
+
+// SourcePhoto is one photo in a source
+type SourcePhoto struct {
+	Name    string    // "/"-separated path, used as the photo name
+	ID      string    // how the source addresses the photo
+	Version string    // changes whenever the photo does (ETag, checksum, size and time)
+	ModTime time.Time // when the photo was last modified, if the source knows
+}
+
+// SourceConfig is the "source" object of the photos widget config
+type SourceConfig struct {
+	Type         string `json:"type"`           // local (default), folder, webdav or immich
+	Path         string `json:"path"`           // folder: directory to mirror, e.g. a NAS mount
+	URL          string `json:"url"`            // webdav: collection URL; immich: server URL
+	Username     string `json:"username"`       // webdav
+	AlbumID      string `json:"album_id"`       // immich
+	SyncMinutes  int    `json:"sync_minutes"`   // how often to check the source, default 15
+	CacheMaxSize int    `json:"cache_max_size"` // longest side of cached copies in pixels, default 2560
+}
+
+// Environment variables holding source credentials
+const (
+	webdavPasswordEnv = "PHOTOS_WEBDAV_PASSWORD"
+	immichAPIKeyEnv   = "IMMICH_API_KEY"
+)
+
+// sourceHTTPTimeout bounds listing requests; downloads use the sync's context
+const sourceHTTPTimeout = 30 * time.Second
+
+// newPhotoSource creates the configured source, or nil for the local photos folder
+func newPhotoSource(config SourceConfig) (PhotoSource, error) {
+	switch config.Type {
+	case "", "local":
+		return nil, nil
+	case "folder":
+		if config.Path == "" {
+			return nil, fmt.Errorf("folder source needs a path")
+		}
+		return &folderSource{dir: config.Path}, nil
+	case "webdav":
+		if config.URL == "" {
+			return nil, fmt.Errorf("webdav source needs a url")
+		}
+		return newWebDAVSource(config.URL, config.Username, os.Getenv(webdavPasswordEnv)), nil
+	case "immich":
+		if config.URL == "" || config.AlbumID == "" {
+			return nil, fmt.Errorf("immich source needs a url and album_id")
+		}
+		apiKey := os.Getenv(immichAPIKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf("immich source needs %s", immichAPIKeyEnv)
+		}
+		return newImmichSource(config.URL, config.AlbumID, apiKey), nil
+	}
+	return nil, fmt.Errorf("unknown photo source type %q", config.Type)
+}
+
+// folderSource is a directory outside the config folder, such as an SMB or NFS
+// mount, which may go away when the NAS is off
+type folderSource struct {
+	dir string
+}
+
+func (s *folderSource) Name() string {
+	return "folder " + s.dir
+}
+
+func (s *folderSource) List(ctx context.Context) ([]SourcePhoto, error) {
+	if _, err := os.Stat(s.dir); err != nil {
+		return nil, err
+	}
+
+	var photos []SourcePhoto
+	err := filepath.WalkDir(s.dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p != s.dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isPhotoFile(filepath.Ext(entry.Name())) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return nil
+		}
+		photos = append(photos, SourcePhoto{
+			Name:    filepath.ToSlash(rel),
+			ID:      p,
+			Version: fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	return photos, err
+}
+
+func (s *folderSource) Open(ctx context.Context, photo SourcePhoto) (io.ReadCloser, error) {
+	return os.Open(photo.ID)
+}
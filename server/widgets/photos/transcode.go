@@ -0,0 +1,200 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// heifExtensions are HEIF images (iPhone photos). Go has no HEVC decoder, so these
+// are converted with an external tool.
+var heifExtensions = map[string]bool{
+	".heic": true,
+	".heif": true,
+}
+
+// transcodeTimeout bounds one external conversion
+const transcodeTimeout = 2 * time.Minute
+
+// transcodeQuality is the JPEG quality of transcoded copies. They are resized
+// again before display, so it is kept high.
+const transcodeQuality = 92
+
+// photoSource is a file Go can decode for a photo, and the EXIF orientation still
+// to apply to it
+type photoSource struct {
+	Path        string
+	Orientation int
+}
+
+// heifConverter is an external program that converts HEIF to JPEG
+type heifConverter struct {
+	Name string
+	Args func(in, out string) []string
+}
+
+// heifConverters are tried in order; the first one installed is used
+var heifConverters = []heifConverter{
+	{"heif-convert", func(in, out string) []string { return []string{"-q", "95", in, out} }},
+	{"magick", func(in, out string) []string { return []string{in, "-quality", "95", out} }},
+	{"convert", func(in, out string) []string { return []string{in, "-quality", "95", out} }},
+	{"vips", func(in, out string) []string { return []string{"copy", in, out + "[Q=95]"} }},
+}
+
+var (
+	heifConverterOnce sync.Once
+	heifConverterPath string
+	heifConverterTool *heifConverter
+)
+
+// errNoHEIFConverter is reported for HEIC files when no converter is installed
+var errNoHEIFConverter = errors.New("HEIC support needs heif-convert (libheif) or ImageMagick installed")
+
+// findHEIFConverter looks up the first available converter once
+func findHEIFConverter() (string, *heifConverter) {
+	heifConverterOnce.Do(func() {
+		for i := range heifConverters {
+			if path, err := exec.LookPath(heifConverters[i].Name); err == nil {
+				heifConverterPath, heifConverterTool = path, &heifConverters[i]
+				return
+			}
+		}
+	})
+	return heifConverterPath, heifConverterTool
+}
+
+// needsTranscode reports whether a file type has to be converted before it can be
+// decoded here or shown in a browser
+func needsTranscode(ext string) bool {
+	ext = strings.ToLower(ext)
+	return heifExtensions[ext] || rawExtensions[ext]
+}
+
+// sourceKey identifies the transcoded copy of one version of a photo
+func sourceKey(name string, info os.FileInfo) string {
+	return renditionKey(name, info, resizeOptions{Fit: "source"})
+}
+
+// prepareSource returns a file that can be decoded for the photo. HEIF and RAW
+// files are converted once into an upright full-size JPEG kept in the cache.
+func (w *PhotosWidget) prepareSource(path, name string, info os.FileInfo) (photoSource, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !needsTranscode(ext) {
+		var exif *exifData
+		if f, err := os.Open(path); err == nil {
+			exif, _ = readJPEGEXIF(f)
+			f.Close()
+		}
+		return photoSource{Path: path, Orientation: exif.Orientation()}, nil
+	}
+
+	key := sourceKey(name, info)
+	if cached, ok := w.cachedFile(key + ".jpg"); ok {
+		return photoSource{Path: cached, Orientation: 1}, nil
+	}
+
+	result, err, _ := w.renders.Do(key, func() (interface{}, error) {
+		img, err := decodeForTranscode(path, ext)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: transcodeQuality}); err != nil {
+			return nil, err
+		}
+		return w.writeCacheFile(key+".jpg", buf.Bytes())
+	})
+	if err != nil {
+		return photoSource{}, err
+	}
+	return photoSource{Path: result.(string), Orientation: 1}, nil
+}
+
+// decodeForTranscode decodes a HEIF or RAW file into an upright image
+func decodeForTranscode(path, ext string) (image.Image, error) {
+	if rawExtensions[ext] {
+		preview, exif, err := readRAW(path)
+		if err != nil {
+			return nil, err
+		}
+		img, err := decodeLimited(bytes.NewReader(preview))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode RAW preview: %w", err)
+		}
+		return orient(img, exif.Orientation()), nil
+	}
+
+	data, err := convertHEIF(path)
+	if err != nil {
+		return nil, err
+	}
+	// Converters apply the HEIF rotation themselves, so the EXIF orientation they
+	// copy into the JPEG must not be applied again
+	img, err := decodeLimited(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode converted HEIC: %w", err)
+	}
+	return img, nil
+}
+
+// convertHEIF runs the external converter and returns the JPEG it wrote
+func convertHEIF(path string) ([]byte, error) {
+	converterPath, converter := findHEIFConverter()
+	if converter == nil {
+		return nil, errNoHEIFConverter
+	}
+
+	dir, err := os.MkdirTemp("", "heif-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "photo.jpg")
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, converterPath, converter.Args(path, out)...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", converter.Name, err, strings.TrimSpace(string(output)))
+	}
+
+	// Multi-image files may be written as photo-1.jpg, photo-2.jpg, ...; the first is the primary
+	data, err := os.ReadFile(out)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join(dir, "photo-1.jpg"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s wrote no image", converter.Name)
+	}
+	return data, nil
+}
+
+// readPhotoEXIF returns the EXIF data of an original photo, whatever its format
+func readPhotoEXIF(path string) *exifData {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case rawExtensions[ext]:
+		_, exif, _ := readRAW(path)
+		return exif
+	case heifExtensions[ext]:
+		exif, _ := readHEIFEXIF(path)
+		return exif
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	exif, _ := readJPEGEXIF(f)
+	return exif
+}
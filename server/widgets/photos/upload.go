@@ -0,0 +1,422 @@
+package photos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"themancavedashboard/shared"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultMaxUploadMB is the per-file upload limit unless max_upload_mb is set
+const defaultMaxUploadMB = 50
+
+// defaultMaxUploadTotalMB limits a whole upload request unless
+// max_upload_total_mb is set
+const defaultMaxUploadTotalMB = 250
+
+// maxUploadFiles bounds how many photos one request can upload
+const maxUploadFiles = 50
+
+// Deleted photos are kept in trashDirName next to the thumbnail cache, outside the
+// photos folder, until they expire. legacyTrashDirName is where older versions
+// kept them, inside the photos folder; it is still emptied.
+const (
+	trashDirName       = "photos-trash"
+	legacyTrashDirName = ".trash"
+	trashMaxAge        = 30 * 24 * time.Hour
+)
+
+// UploadResult is the outcome for one uploaded file
+type UploadResult struct {
+	Filename  string `json:"filename"`            // as sent by the client
+	Name      string `json:"name,omitempty"`      // photo name in the library
+	Duplicate bool   `json:"duplicate,omitempty"` // an identical photo already existed; Name is that photo
+	Error     string `json:"error,omitempty"`
+}
+
+// UploadResponse is returned by POST /api/photos
+type UploadResponse struct {
+	Photos []UploadResult `json:"photos"`
+}
+
+// PhotoDeleteResponse is returned by DELETE /api/photos/{name}
+type PhotoDeleteResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// uploadPhotos handles POST /api/photos
+func (w *PhotosWidget) uploadPhotos(rw http.ResponseWriter, r *http.Request) {
+	if !w.writable(rw) {
+		return
+	}
+	maxBytes := int64(shared.GetWidgetConfigNumber("photos", "max_upload_mb", defaultMaxUploadMB) * (1 << 20))
+	maxTotal := int64(shared.GetWidgetConfigNumber("photos", "max_upload_total_mb", defaultMaxUploadTotalMB) * (1 << 20))
+	// Leave room for the multipart headers around a single file of the largest size
+	r.Body = http.MaxBytesReader(rw, r.Body, max(maxTotal, maxBytes)+(1<<20))
+
+	album := strings.Trim(r.URL.Query().Get("album"), "/")
+	if album != "" && !validRelativePath(album) {
+		shared.WriteError(rw, http.StatusBadRequest, "Invalid album name")
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, "Expected a multipart/form-data upload")
+		return
+	}
+
+	response := UploadResponse{Photos: []UploadResult{}}
+	created := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				shared.WriteError(rw, http.StatusRequestEntityTooLarge, "Upload too large")
+				return
+			}
+			shared.WriteError(rw, http.StatusBadRequest, "Invalid multipart body")
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		if len(response.Photos) >= maxUploadFiles {
+			part.Close()
+			shared.WriteError(rw, http.StatusBadRequest, fmt.Sprintf("At most %d photos per upload", maxUploadFiles))
+			return
+		}
+
+		result, err := w.saveUpload(part, album, maxBytes)
+		part.Close()
+		if err != nil {
+			shared.WriteError(rw, http.StatusRequestEntityTooLarge, "Upload too large")
+			return
+		}
+		if result.Error == "" && !result.Duplicate {
+			created = true
+			log.Printf("[Photos] Uploaded %s", result.Name)
+		}
+		response.Photos = append(response.Photos, result)
+	}
+
+	if len(response.Photos) == 0 {
+		shared.WriteError(rw, http.StatusBadRequest, "No files in upload")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	shared.WriteJSON(rw, status, response)
+}
+
+// saveUpload stores one uploaded file in the album, unless an identical photo exists.
+// Problems with the file are reported in the result; the error is only set when the
+// request as a whole went over its size limit.
+func (w *PhotosWidget) saveUpload(part *multipart.Part, album string, maxBytes int64) (UploadResult, error) {
+	// Some browsers send the full client path
+	filename := sanitizeFilename(path.Base(strings.ReplaceAll(part.FileName(), "\\", "/")))
+	result := UploadResult{Filename: part.FileName()}
+	if filename == "" || !isPhotoFile(filepath.Ext(filename)) {
+		result.Error = "Not a supported photo type"
+		return result, nil
+	}
+
+	// Write inside the photos folder (dot-prefixed so the indexer ignores it) so the
+	// final rename is atomic
+	tmp, err := os.CreateTemp(w.photosDir, ".upload-*")
+	if err != nil {
+		result.Error = "Failed to store photo"
+		return result, nil
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	var head bytes.Buffer
+	written, err := io.Copy(io.MultiWriter(tmp, hash, &limitedBuffer{&head, 512}), io.LimitReader(part, maxBytes+1))
+	tmp.Close()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return result, err
+		}
+		result.Error = "Upload interrupted"
+		return result, nil
+	}
+	if written > maxBytes {
+		result.Error = fmt.Sprintf("File is larger than %d MB", maxBytes>>20)
+		return result, nil
+	}
+	if !looksLikePhoto(head.Bytes()) {
+		result.Error = "File content is not a supported photo type"
+		return result, nil
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if existing, ok := w.index.findByHash(sum); ok {
+		result.Name, result.Duplicate = existing, true
+		return result, nil
+	}
+
+	dir := filepath.Join(w.photosDir, filepath.FromSlash(album))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Error = "Failed to create album"
+		return result, nil
+	}
+	final := uniquePath(filepath.Join(dir, filename))
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		result.Error = "Failed to store photo"
+		return result, nil
+	}
+	if err := os.Rename(tmp.Name(), final); err != nil {
+		result.Error = "Failed to store photo"
+		return result, nil
+	}
+
+	rel, _ := filepath.Rel(w.photosDir, final)
+	result.Name = filepath.ToSlash(rel)
+	w.index.indexFile(result.Name)
+	return result, nil
+}
+
+// sanitizeFilename keeps a file name safe to write: no leading dots, no separators
+// or control characters
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimLeft(strings.TrimSpace(name), ".")
+}
+
+// validRelativePath reports whether a "/"-separated path stays inside its root
+// and has no hidden segments
+func validRelativePath(p string) bool {
+	if p == "" || strings.ContainsRune(p, 0) || strings.Contains(p, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// uniquePath appends -2, -3, ... to the file name until it doesn't exist
+func uniquePath(p string) string {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	candidate := p
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// looksLikePhoto checks the file's magic bytes against the supported formats
+func looksLikePhoto(head []byte) bool {
+	switch http.DetectContentType(head) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	// HEIF: an ftyp box with a HEIF brand
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+			return true
+		}
+	}
+	// RAW: TIFF-based (including the Olympus and Panasonic variants) or Fujifilm
+	for _, prefix := range []string{"II*\x00", "MM\x00*", "IIRO", "IIRS", "IIU\x00", "FUJIFILMCCD-RAW"} {
+		if bytes.HasPrefix(head, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer keeps the first n bytes written to it
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	n   int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.n - b.buf.Len(); remaining > 0 {
+		b.buf.Write(p[:min(remaining, len(p))])
+	}
+	return len(p), nil
+}
+
+// deletePhoto handles DELETE /api/photos/{name}. Photos are moved to the trash
+// outside the photos folder and removed for good after 30 days.
+func (w *PhotosWidget) deletePhoto(rw http.ResponseWriter, r *http.Request) {
+	if !w.writable(rw) {
+		return
+	}
+	// The resolved path is only for the check that the photo is in the folder. A
+	// symlinked photo is deleted by moving the link, leaving its target alone.
+	name, _, ok := w.photoFromRequest(rw, r)
+	if !ok {
+		return
+	}
+	filePath := filepath.Join(w.photosDir, filepath.FromSlash(name))
+
+	trashPath := uniquePath(filepath.Join(w.trashDir, filepath.FromSlash(name)))
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to delete photo")
+		return
+	}
+	if err := moveFile(filePath, trashPath); err != nil {
+		log.Printf("[Photos] Failed to delete %s: %v", name, err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to delete photo")
+		return
+	}
+	// Mark the move time so the trash expires 30 days after deletion
+	now := time.Now()
+	os.Chtimes(trashPath, now, now)
+
+	w.index.remove(name)
+	if err := w.metadata.remove(name); err != nil {
+		log.Printf("[Photos] Failed to remove metadata for %s: %v", name, err)
+	}
+	log.Printf("[Photos] Deleted %s", name)
+
+	shared.WriteJSON(rw, http.StatusOK, PhotoDeleteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Moved %s to the trash", name),
+	})
+}
+
+// updatePhoto handles PATCH /api/photos/{name}
+func (w *PhotosWidget) updatePhoto(rw http.ResponseWriter, r *http.Request) {
+	name, _, ok := w.photoFromRequest(rw, r)
+	if !ok {
+		return
+	}
+
+	var change PhotoMetadataUpdate
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&change); err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	meta, err := w.metadata.update(name, change)
+	if err != nil {
+		log.Printf("[Photos] Failed to save metadata for %s: %v", name, err)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to save photo metadata")
+		return
+	}
+	shared.WriteJSON(rw, http.StatusOK, meta)
+}
+
+// writable reports whether photos can be added and removed here, writing an error
+// response if they can't. Mirrored photos would come back on the next sync.
+func (w *PhotosWidget) writable(rw http.ResponseWriter) bool {
+	if w.source == nil {
+		return true
+	}
+	shared.WriteError(rw, http.StatusConflict, "Photos come from "+w.source.Name()+"; add or remove them there")
+	return false
+}
+
+// photoFromRequest resolves the {name} URL parameter to an existing photo, writing
+// an error response if it doesn't
+func (w *PhotosWidget) photoFromRequest(rw http.ResponseWriter, r *http.Request) (string, string, bool) {
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, "Invalid photo name")
+		return "", "", false
+	}
+	filePath, err := w.resolvePhotoPath(name)
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	if info, err := os.Lstat(filePath); err != nil || info.IsDir() {
+		shared.WriteError(rw, http.StatusNotFound, "Photo not found")
+		return "", "", false
+	}
+	return strings.TrimPrefix(path.Clean("/"+name), "/"), filePath, true
+}
+
+// moveFile renames a file, copying it when the destination is on another filesystem,
+// as the trash can be when the photos folder is its own mount
+func moveFile(from, to string) error {
+	err := os.Rename(from, to)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
+
+// pruneTrash periodically removes deleted photos older than trashMaxAge
+func (w *PhotosWidget) pruneTrash() {
+	for {
+		removed := 0
+		for _, trashDir := range []string{w.trashDir, filepath.Join(w.photosDir, legacyTrashDirName)} {
+			filepath.WalkDir(trashDir, func(p string, entry os.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return nil
+				}
+				if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > trashMaxAge {
+					if os.Remove(p) == nil {
+						removed++
+					}
+				}
+				return nil
+			})
+		}
+		if removed > 0 {
+			log.Printf("[Photos] Emptied %d photos from the trash", removed)
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
@@ -0,0 +1,64 @@
+package photos
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDeleteSymlinkedPhoto(t *testing.T) {
+	base := t.TempDir()
+	photosDir := filepath.Join(base, "photos")
+	trashDir := filepath.Join(base, "photos-trash")
+	if err := os.Mkdir(photosDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A second name for a photo, as a link to it
+	target := filepath.Join(photosDir, "real.jpg")
+	if err := os.WriteFile(target, testImage(t, 16, 16, "jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(photosDir, "link.jpg")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	prepare := func(path, name string, info os.FileInfo) (photoSource, error) {
+		return photoSource{Path: path, Orientation: 1}, nil
+	}
+	w := &PhotosWidget{
+		photosDir: photosDir,
+		trashDir:  trashDir,
+		index:     newPhotoIndex(photosDir, prepare),
+		metadata:  newMetadataStore(photosDir),
+	}
+
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("name", "link.jpg")
+	r := httptest.NewRequest(http.MethodDelete, "/api/photos/link.jpg", nil)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+	rw := httptest.NewRecorder()
+	w.deletePhoto(rw, r)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("DELETE returned %d: %s", rw.Code, rw.Body)
+	}
+
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("link.jpg is still in the photos folder")
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("the link's target was moved: %v", err)
+	}
+	info, err := os.Lstat(filepath.Join(trashDir, "link.jpg"))
+	if err != nil {
+		t.Fatalf("link.jpg isn't in the trash: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the trash holds a file rather than the link")
+	}
+}
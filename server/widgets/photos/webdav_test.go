@@ -0,0 +1,136 @@
+package photos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// webdavStandIn answers PROPFIND and GET like a Nextcloud folder holding a few
+// photos, a subfolder, a hidden folder and a file that isn't a photo
+type webdavStandIn struct {
+	files map[string][]byte // decoded path under /dav/Photos/ to contents
+	down  bool
+}
+
+func (s *webdavStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		if depth := r.Header.Get("Depth"); depth != "1" {
+			http.Error(w, "Depth must be 1, got "+depth, http.StatusForbidden)
+			return
+		}
+		dir := strings.TrimPrefix(r.URL.Path, "/dav/Photos/")
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		fmt.Fprint(w, collectionResponse(r.URL.EscapedPath()))
+		children := map[string]bool{}
+		for name, data := range s.files {
+			if !strings.HasPrefix(name, dir) {
+				continue
+			}
+			rest := strings.TrimPrefix(name, dir)
+			if i := strings.Index(rest, "/"); i >= 0 {
+				sub := dir + rest[:i+1]
+				if !children[sub] {
+					children[sub] = true
+					fmt.Fprint(w, collectionResponse(escapeWebDAVPath("/dav/Photos/"+sub)))
+				}
+				continue
+			}
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype/>`+
+				`<d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>Sat, 06 Jul 2024 10:00:00 GMT</d:getlastmodified>`+
+				`<d:getetag>"%x"</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+				escapeWebDAVPath("/dav/Photos/"+name), len(data), len(data))
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	case http.MethodGet:
+		data, ok := s.files[strings.TrimPrefix(r.URL.Path, "/dav/Photos/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func collectionResponse(href string) string {
+	return `<d:response><d:href>` + href + `</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype>` +
+		`</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`
+}
+
+func TestWebDAVList(t *testing.T) {
+	standIn := &webdavStandIn{files: map[string][]byte{
+		"beach.jpg":                 []byte("one"),
+		"notes.txt":                 []byte("not a photo"),
+		"2024/Summer Trip/boat.png": []byte("three"),
+		"2024/Summer Trip/dog.heic": []byte("four!"),
+		".trash/old.jpg":            []byte("deleted"),
+	}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	source := newWebDAVSource(server.URL+"/dav/Photos", "me", "secret")
+	photos, err := source.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Slice(photos, func(i, j int) bool { return photos[i].Name < photos[j].Name })
+
+	want := []SourcePhoto{
+		{Name: "2024/Summer Trip/boat.png", ID: "/dav/Photos/2024/Summer Trip/boat.png", Version: `"5"`},
+		{Name: "2024/Summer Trip/dog.heic", ID: "/dav/Photos/2024/Summer Trip/dog.heic", Version: `"5"`},
+		{Name: "beach.jpg", ID: "/dav/Photos/beach.jpg", Version: `"3"`},
+	}
+	if len(photos) != len(want) {
+		t.Fatalf("List returned %d photos, want %d: %+v", len(photos), len(want), photos)
+	}
+	for i, photo := range photos {
+		if photo.Name != want[i].Name || photo.ID != want[i].ID || photo.Version != want[i].Version {
+			t.Errorf("photo %d = %+v, want %+v", i, photo, want[i])
+		}
+		if photo.ModTime.IsZero() {
+			t.Errorf("%s has no modification time", photo.Name)
+		}
+	}
+
+	body, err := source.Open(context.Background(), photos[0])
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "three" {
+		t.Errorf("Open returned %q, want %q", data, "three")
+	}
+}
+
+func TestWebDAVListErrors(t *testing.T) {
+	standIn := &webdavStandIn{files: map[string][]byte{"a.jpg": []byte("a")}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	if _, err := newWebDAVSource(server.URL+"/dav/Photos", "me", "wrong").List(context.Background()); err == nil {
+		t.Error("List with a wrong password succeeded")
+	}
+	standIn.down = true
+	if _, err := newWebDAVSource(server.URL+"/dav/Photos", "me", "secret").List(context.Background()); err == nil {
+		t.Error("List of a server that is down succeeded")
+	}
+}
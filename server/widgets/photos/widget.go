@@ -3,19 +3,31 @@ package photos
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/sync/singleflight"
 )
 
-// PhotosWidget handles listing photos from the mounted photos directory
+// PhotosWidget handles listing and serving photos from the mounted photos directory
 type PhotosWidget struct {
 	photosDir string
+	cacheDir  string
+	trashDir  string
+	index     *photoIndex
+	metadata  *metadataStore
+	source    PhotoSource // nil when photos are kept in photosDir itself
+	decks     shuffleDecks
+	renders   singleflight.Group
 }
 
 // ID returns the widget identifier
@@ -28,54 +40,415 @@ func (w *PhotosWidget) GetRequiredEnvVars() []string {
 	return []string{} // Uses mounted volume
 }
 
+// ConfigSchema describes the "photos" section of config.json
+func (w *PhotosWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "photos",
+		Fields: []shared.ConfigField{
+			{Key: "photo_rotation_seconds", Type: "number", Description: "Seconds between photos"},
+			{Key: "photos_folder", Type: "string", Description: "Photos folder inside the config directory"},
+			{Key: "order", Type: "string", Description: "shuffle, chronological, newest, on-this-day or name"},
+			{Key: "album", Type: "string", Description: "Only show photos from this subfolder"},
+			{Key: "orientation", Type: "string", Description: "landscape, portrait, square, or match to follow the tile's shape"},
+			{Key: "max_upload_mb", Type: "number", Description: "Largest photo that can be uploaded, in MB"},
+			{Key: "max_upload_total_mb", Type: "number", Description: "Largest upload request, all photos together, in MB"},
+			{Key: "duplicate_threshold", Type: "number", Description: "How different (0-64) near-duplicate photos can be and still be shown only once; 0 only hides exact copies"},
+			{Key: "source", Type: "object", Description: "Where photos come from: {\"type\": \"folder\", \"path\"}, {\"type\": \"webdav\", \"url\", \"username\"} or {\"type\": \"immich\", \"url\", \"album_id\"}, plus optional sync_minutes and cache_max_size. Defaults to the photos folder."},
+		},
+	}
+}
+
 // Initialize loads configuration
 func (w *PhotosWidget) Initialize() error {
 	// Get photos folder from widget config, default to "photos"
 	photosFolder := shared.GetWidgetConfigValue("photos", "photos_folder", "photos")
 	w.photosDir = fmt.Sprintf("/app/config/%s", photosFolder)
+
+	// Resized renditions are cached outside the photos folder so they don't show up in it
+	w.cacheDir = os.Getenv("PHOTO_CACHE_DIR")
+	if w.cacheDir == "" {
+		w.cacheDir = "/app/config/.cache/photos"
+	}
+	go w.pruneThumbnailCache()
+
+	// Deleted photos go next to the cache too, so they can't be reached through the
+	// photos folder
+	w.trashDir = filepath.Join(filepath.Dir(w.cacheDir), trashDirName)
+
+	// Remote sources are copied into a local folder, which is then used like the
+	// photos folder
+	var sourceConfig SourceConfig
+	var mirror *sourceMirror
+	if shared.GetWidgetConfigObject("photos", "source", &sourceConfig) {
+		source, err := newPhotoSource(sourceConfig)
+		if err != nil {
+			log.Printf("[Photos] Ignoring photo source, using %s: %v", w.photosDir, err)
+		} else if source != nil {
+			w.source = source
+			w.photosDir = filepath.Join(filepath.Dir(w.cacheDir), "photos-source")
+			os.MkdirAll(w.photosDir, 0755)
+			mirror = newSourceMirror(source, w.photosDir, sourceConfig, func() { w.index.Refresh() })
+			log.Printf("[Photos] Showing photos from %s", source.Name())
+		}
+	}
+
+	w.index = newPhotoIndex(w.photosDir, w.prepareSource)
+	go w.index.run()
+	if mirror != nil {
+		go mirror.run()
+	}
+
+	w.metadata = newMetadataStore(w.photosDir)
+	go w.pruneTrash()
+
 	return nil
 }
 
 // RegisterRoutes registers HTTP endpoints
 func (w *PhotosWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/photos/list", w.listPhotos)
+	r.Get("/photos/index", w.getIndex)
+	r.Get("/photos/albums", w.getAlbums)
+	r.Get("/photos/duplicates", w.getDuplicates)
+	r.Get("/photos/{name}", w.getPhoto)
+	r.Post("/photos", w.uploadPhotos)
+	r.Patch("/photos/{name}", w.updatePhoto)
+	r.Delete("/photos/{name}", w.deletePhoto)
+}
+
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *PhotosWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/photos/list",
+			Summary:     "Photo names to display, filtered and ordered",
+			Description: "Names are paths relative to the photos folder. Defaults come from the widget config. on-this-day falls back to shuffle when no photos match.",
+			Query:       listQueryParams,
+			Response:    []string{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/photos/index",
+			Summary:     "Indexed photos with their metadata",
+			Description: "Takes the same filters and orders as /photos/list, but also includes hidden photos, every copy of duplicates and photos that failed to decode, with the reason in error.",
+			Query: append(listQueryParams, openapi.Param{
+				Name: "failed", Type: "boolean", Description: "Only photos that failed to decode",
+			}),
+			Response: []Photo{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/photos/albums",
+			Summary:  "Subfolders of the photos folder and their photo counts",
+			Response: []Album{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/photos/duplicates",
+			Summary: "Groups of duplicate and near-duplicate photos, for cleaning up",
+			Description: "Photos with the same content, or whose perceptual hashes (dHash and pHash) differ by at most duplicate_threshold bits, are grouped. " +
+				"/photos/list shows only the first photo of each group, keep. Largest groups first.",
+			Response: []DuplicateGroup{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/photos/{name}",
+			Summary:     "A photo, optionally resized and with EXIF orientation applied",
+			Description: "Without w or h the original file is returned, except HEIC and RAW files, which are always converted to JPEG. Resized renditions and converted copies are cached on disk.",
+			Query: []openapi.Param{
+				{Name: "w", Type: "integer", Description: "Maximum width in pixels"},
+				{Name: "h", Type: "integer", Description: "Maximum height in pixels"},
+				{Name: "fit", Description: "contain (default), cover or fill"},
+			},
+			ContentType: "image/jpeg",
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/photos",
+			Summary: "Upload photos",
+			Description: "multipart/form-data with one or more files. Each file is limited to max_upload_mb (default 50) and the request to max_upload_total_mb (default 250). " +
+				"Files identical to an existing photo are not stored again; their result has duplicate set. Returns 201 if any photo was added. " +
+				"Not available when photos come from a remote source.",
+			Query: []openapi.Param{
+				{Name: "album", Description: "Subfolder to upload into, created if needed"},
+			},
+			Response: UploadResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge},
+		},
+		{
+			Method:      http.MethodPatch,
+			Path:        "/photos/{name}",
+			Summary:     "Set a photo's caption, favorite or hidden flag",
+			Description: "Stored in .metadata.json in the photos folder. Hidden photos are left out of /photos/list.",
+			Request:     PhotoMetadataUpdate{},
+			Response:    PhotoMetadata{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/photos/{name}",
+			Summary:     "Delete a photo",
+			Description: "The photo is moved to the trash, next to the thumbnail cache outside the photos folder, and removed for good after 30 days.",
+			Response:    PhotoDeleteResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+	}
+}
+
+// listQueryParams are the filters and orders shared by /photos/list and /photos/index
+var listQueryParams = []openapi.Param{
+	{Name: "order", Description: "shuffle (default), chronological, newest, on-this-day or name"},
+	{Name: "album", Description: "Only photos in this subfolder, including nested folders"},
+	{Name: "orientation", Description: "landscape, portrait, square, or match to use aspect"},
+	{Name: "aspect", Type: "number", Description: "Tile width / height, used with orientation=match"},
+	{Name: "window", Type: "integer", Description: "Days either side of today for on-this-day (default 0)"},
+	{Name: "limit", Type: "integer", Description: "Maximum number of photos"},
+	{Name: "session", Description: "With order=shuffle and limit, photos are not repeated for this session until all were shown"},
+	{Name: "favorites", Type: "boolean", Description: "Only favorite photos"},
+	{Name: "duplicates", Type: "boolean", Description: "Include every copy of duplicate photos instead of only the best one"},
+}
+
+// imageExtensions are the file types listed and served by the widget
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".heic": true,
+	".heif": true,
+	".webp": true,
+}
+
+// isPhotoFile reports whether a file extension is listed and served by the widget
+func isPhotoFile(ext string) bool {
+	ext = strings.ToLower(ext)
+	return imageExtensions[ext] || rawExtensions[ext]
 }
 
 // listPhotos handles GET /api/photos/list
 func (w *PhotosWidget) listPhotos(rw http.ResponseWriter, r *http.Request) {
-	entries, err := os.ReadDir(w.photosDir)
+	photos, ok := w.selectPhotos(rw, r, false)
+	if !ok {
+		return
+	}
+
+	photoFiles := make([]string, 0, len(photos))
+	for _, photo := range photos {
+		photoFiles = append(photoFiles, photo.Name)
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(photoFiles)
+}
+
+// getIndex handles GET /api/photos/index
+func (w *PhotosWidget) getIndex(rw http.ResponseWriter, r *http.Request) {
+	photos, ok := w.selectPhotos(rw, r, true)
+	if !ok {
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(photos)
+}
+
+// getAlbums handles GET /api/photos/albums
+func (w *PhotosWidget) getAlbums(rw http.ResponseWriter, r *http.Request) {
+	if err := w.index.wait(r.Context()); err != nil {
+		return
+	}
+	albums, err := w.index.albums()
 	if err != nil {
-		http.Error(rw, `{"error":"Photos directory not found"}`, http.StatusNotFound)
+		shared.WriteError(rw, http.StatusNotFound, err.Error())
 		return
 	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(albums)
+}
 
-	// Filter for image files
-	var photoFiles []string
-	imageExtensions := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".heic": true,
-		".webp": true,
+// selectPhotos applies the request's filters and order to the index, writing an
+// error response and returning false if that fails. The list endpoint leaves out
+// hidden and undecodable photos and extra copies of duplicates; the index endpoint
+// includes them.
+func (w *PhotosWidget) selectPhotos(rw http.ResponseWriter, r *http.Request, includeFailed bool) ([]Photo, bool) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, err.Error())
+		return nil, false
 	}
+	opts.IncludeFailed, opts.IncludeHidden = includeFailed, includeFailed
+	opts.Duplicates = opts.Duplicates || includeFailed
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	// The first scan runs at startup; wait for it rather than returning an empty list
+	if err := w.index.wait(r.Context()); err != nil {
+		return nil, false
+	}
+	photos, err := w.index.snapshot()
+	if err != nil {
+		shared.WriteError(rw, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	metadata := w.metadata.all()
+	for i := range photos {
+		photos[i].PhotoMetadata = metadata[photos[i].Name]
+	}
+
+	ordered := w.orderPhotos(photos, opts)
+	// An empty screen is worse than a random photo on days with no anniversaries
+	if len(ordered) == 0 && opts.Order == orderOnThisDay {
+		opts.Order = orderShuffle
+		ordered = w.orderPhotos(photos, opts)
+	}
+	return ordered, true
+}
+
+// getPhoto handles GET /api/photos/{name}
+func (w *PhotosWidget) getPhoto(rw http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, "Invalid photo name")
+		return
+	}
+
+	path, err := w.resolvePhotoPath(name)
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		shared.WriteError(rw, http.StatusNotFound, "Photo not found")
+		return
+	}
+
+	opts, err := parseResizeOptions(r)
+	if err != nil {
+		shared.WriteError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Files that failed to decode are reported in the index instead of being served
+	if reason := w.index.failure(name); reason != "" {
+		shared.WriteError(rw, http.StatusUnsupportedMediaType, "Photo could not be decoded: "+reason)
+		return
+	}
+
+	// The key covers the file version and the requested size, so it doubles as the ETag
+	key := renditionKey(name, info, opts)
+	etag := `"` + key + `"`
+	rw.Header().Set("ETag", etag)
+	rw.Header().Set("Cache-Control", "public, max-age=86400")
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Browsers can't show HEIC or RAW, so those always go through a transcoded copy
+	servePath, contentType := path, mimeForExt(filepath.Ext(path))
+	if needsTranscode(filepath.Ext(path)) || opts.Width > 0 || opts.Height > 0 {
+		src, err := w.prepareSource(path, name, info)
+		if err != nil {
+			log.Printf("[Photos] Failed to transcode %s: %v", name, err)
+			w.index.markFailed(name, err)
+			shared.WriteError(rw, http.StatusUnsupportedMediaType, "Photo could not be decoded: "+err.Error())
+			return
 		}
+		servePath, contentType = src.Path, "image/jpeg"
 
-		// Skip hidden files and non-images
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
+		if opts.Width > 0 || opts.Height > 0 {
+			servePath, contentType, err = w.cachedRendition(key, src, opts)
+			if err != nil {
+				log.Printf("[Photos] Failed to resize %s: %v", name, err)
+				shared.WriteError(rw, http.StatusInternalServerError, "Failed to resize photo")
+				return
+			}
+		}
+	}
+
+	file, err := os.Open(servePath)
+	if err != nil {
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to open photo")
+		return
+	}
+	defer file.Close()
+
+	rw.Header().Set("Content-Type", contentType)
+	http.ServeContent(rw, r, "", info.ModTime(), file)
+}
+
+// resolvePhotoPath maps a request name to a file inside the photos folder,
+// rejecting anything that would escape it
+func (w *PhotosWidget) resolvePhotoPath(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("Invalid photo name")
+	}
+
+	cleaned := filepath.Clean("/" + name)
+	for _, part := range strings.Split(cleaned, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("Invalid photo name")
 		}
+	}
+	if !isPhotoFile(filepath.Ext(cleaned)) {
+		return "", fmt.Errorf("Not an image")
+	}
+
+	path := filepath.Join(w.photosDir, cleaned)
 
-		ext := strings.ToLower(filepath.Ext(entry.Name()))
-		if imageExtensions[ext] {
-			photoFiles = append(photoFiles, entry.Name())
+	// Symlinks must not point outside the photos folder either
+	root, err := filepath.EvalSymlinks(w.photosDir)
+	if err != nil {
+		return path, nil
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path, nil
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("Invalid photo name")
+	}
+	return resolved, nil
+}
+
+// parseResizeOptions reads ?w=, ?h= and ?fit=
+func parseResizeOptions(r *http.Request) (resizeOptions, error) {
+	opts := resizeOptions{Fit: fitContain}
+	query := r.URL.Query()
+
+	for _, param := range []struct {
+		name string
+		dest *int
+	}{{"w", &opts.Width}, {"h", &opts.Height}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxResizeDimension {
+			return opts, fmt.Errorf("%s must be between 0 and %d", param.name, maxResizeDimension)
 		}
+		*param.dest = n
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(photoFiles)
+	if fit := query.Get("fit"); fit != "" {
+		switch fit {
+		case fitContain, fitCover, fitFill:
+			opts.Fit = fit
+		default:
+			return opts, fmt.Errorf("fit must be contain, cover or fill")
+		}
+	}
+	// cover and fill need both dimensions
+	if opts.Fit != fitContain && (opts.Width == 0 || opts.Height == 0) {
+		opts.Fit = fitContain
+	}
+	if opts.Width == 0 && opts.Height == 0 {
+		opts.Fit = ""
+	}
+	return opts, nil
 }
@@ -0,0 +1,52 @@
+package widgets
+
+import (
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+)
+
+// SchemaProvider is implemented by widgets that describe their config.json section.
+// It is optional; widgets without a schema are only checked for structure.
+type SchemaProvider interface {
+	ConfigSchema() shared.ConfigSchema
+}
+
+// GetSchemas returns the config schemas of all registered widgets, keyed by section
+func GetSchemas() map[string]shared.ConfigSchema {
+	schemas := make(map[string]shared.ConfigSchema)
+	for _, widget := range registry {
+		if provider, ok := widget.(SchemaProvider); ok {
+			schema := provider.ConfigSchema()
+			if schema.Section == "" {
+				schema.Section = widget.ID()
+			}
+			schemas[schema.Section] = schema
+		}
+	}
+	return schemas
+}
+
+// APIDescriber is implemented by widgets that document their HTTP endpoints.
+// The routes are published in the OpenAPI document at /api/openapi.json.
+type APIDescriber interface {
+	APIRoutes() []openapi.Route
+}
+
+// GetAPIRoutes returns the documented routes of all registered widgets,
+// tagged with the widget ID. Paths are relative to the /api prefix.
+func GetAPIRoutes() []openapi.Route {
+	var routes []openapi.Route
+	for id, widget := range registry {
+		describer, ok := widget.(APIDescriber)
+		if !ok {
+			continue
+		}
+		for _, route := range describer.APIRoutes() {
+			if len(route.Tags) == 0 {
+				route.Tags = []string{id}
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
@@ -7,6 +7,10 @@ import (
 	"net/http"
 	"os"
 
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+	"themancavedashboard/shared/units"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -18,12 +22,13 @@ type TeslaWidget struct {
 
 // TeslaResponse is the data sent to the frontend
 type TeslaResponse struct {
-	BatteryLevel     int     `json:"batteryLevel"`
-	ChargingState    string  `json:"chargingState"`
-	IsCharging       bool    `json:"isCharging"`
-	EstimatedRange   float64 `json:"estimatedRange"`
-	ChargeLimit      int     `json:"chargeLimit"`
-	TimeToFullCharge float64 `json:"timeToFullCharge"`
+	BatteryLevel     int         `json:"batteryLevel"`
+	ChargingState    string      `json:"chargingState"`
+	IsCharging       bool        `json:"isCharging"`
+	EstimatedRange   float64     `json:"estimatedRange"` // in units.distance
+	ChargeLimit      int         `json:"chargeLimit"`
+	TimeToFullCharge float64     `json:"timeToFullCharge"`
+	Units            units.Units `json:"units"`
 }
 
 // TessieAPIResponse is the response from Tessie API
@@ -36,7 +41,7 @@ type TessieAPIResponse struct {
 		ChargerPilotCurrent  int     `json:"charger_pilot_current"`
 		ChargerActualCurrent int     `json:"charger_actual_current"`
 		ChargePortDoorOpen   bool    `json:"charge_port_door_open"`
-		EstBatteryRange      float64 `json:"est_battery_range"`
+		EstBatteryRange      float64 `json:"est_battery_range"` // miles
 		UsableBatteryLevel   int     `json:"usable_battery_level"`
 		ChargeCurrentRequest int     `json:"charge_current_request"`
 		ChargeLimit          int     `json:"charge_limit_soc"`
@@ -58,6 +63,16 @@ func (w *TeslaWidget) GetRequiredEnvVars() []string {
 	}
 }
 
+// ConfigSchema describes the "tesla" section of config.json
+func (w *TeslaWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "tesla",
+		Fields: append([]shared.ConfigField{
+			{Key: "tesla_name", Type: "string", Description: "Display name for the vehicle"},
+		}, units.ConfigFields(units.DistanceKey)...),
+	}
+}
+
 // Initialize loads configuration
 func (w *TeslaWidget) Initialize() error {
 	w.apiKey = os.Getenv("TESSIE_API_KEY")
@@ -70,17 +85,30 @@ func (w *TeslaWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/tesla", w.getStatus)
 }
 
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *TeslaWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:   http.MethodGet,
+			Path:     "/tesla",
+			Summary:  "Vehicle charge state from Tessie",
+			Response: TeslaResponse{},
+			Errors:   []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+	}
+}
+
 // getStatus handles GET /api/tesla
 func (w *TeslaWidget) getStatus(rw http.ResponseWriter, r *http.Request) {
 	if w.apiKey == "" || w.vin == "" {
-		http.Error(rw, `{"error":"Tessie API not configured"}`, http.StatusServiceUnavailable)
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Tessie API not configured")
 		return
 	}
 
 	url := fmt.Sprintf("https://api.tessie.com/%s/state", w.vin)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to create request"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
@@ -89,36 +117,38 @@ func (w *TeslaWidget) getStatus(rw http.ResponseWriter, r *http.Request) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to fetch Tesla data"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to fetch Tesla data")
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to read response"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to read response")
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		http.Error(rw, fmt.Sprintf(`{"error":"Tessie API error: %s"}`, string(body)), resp.StatusCode)
+		shared.WriteError(rw, resp.StatusCode, fmt.Sprintf("Tessie API error: %s", string(body)))
 		return
 	}
 
 	var tessieData TessieAPIResponse
 	if err := json.Unmarshal(body, &tessieData); err != nil {
-		http.Error(rw, `{"error":"Failed to parse Tesla data"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "Failed to parse Tesla data")
 		return
 	}
 
 	// Transform to frontend format
+	u := units.For("tesla")
 	response := TeslaResponse{
 		BatteryLevel:     tessieData.ChargeState.BatteryLevel,
 		ChargingState:    tessieData.ChargeState.ChargingState,
 		IsCharging:       tessieData.ChargeState.ChargingState == "Charging",
-		EstimatedRange:   tessieData.ChargeState.EstBatteryRange,
+		EstimatedRange:   units.Round(u.ConvertDistance(tessieData.ChargeState.EstBatteryRange, units.Miles), u.Distance),
 		ChargeLimit:      tessieData.ChargeState.ChargeLimit,
 		TimeToFullCharge: tessieData.ChargeState.TimeToFullCharge,
+		Units:            u,
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
@@ -11,6 +11,10 @@ import (
 	"sync"
 	"time"
 
+	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+	"themancavedashboard/shared/units"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/redis/go-redis/v9"
 )
@@ -25,6 +29,54 @@ type TraegerWidget struct {
 	grillThingName string
 }
 
+// GrillStatusResponse is the data sent to the frontend for GET /api/traeger
+type GrillStatusResponse struct {
+	GrillTemp    float64       `json:"grill_temp"`
+	SetTemp      float64       `json:"set_temp"`
+	PelletLevel  float64       `json:"pellet_level"`
+	Connected    bool          `json:"connected"`
+	SystemStatus float64       `json:"system_status"`
+	Probes       []ProbeStatus `json:"probes"`
+	Units        units.Units   `json:"units"` // temperatures are in units.temperature
+}
+
+// ProbeStatus is a single temperature probe on the grill
+type ProbeStatus struct {
+	Name      string  `json:"name"`
+	Connected float64 `json:"connected"`
+	GetTemp   float64 `json:"get_temp"`
+	SetTemp   float64 `json:"set_temp"`
+}
+
+// GrillNotFoundResponse is returned when grill_name doesn't match a grill on the account
+type GrillNotFoundResponse struct {
+	Error     string   `json:"error"`
+	Requested string   `json:"requested"`
+	Available []string `json:"available"`
+}
+
+// HistoryResponse is the data sent to the frontend for GET /api/traeger/history
+type HistoryResponse struct {
+	History []HistoryPoint `json:"history"`
+	Units   units.Units    `json:"units"`
+}
+
+// HistoryPoint is one recorded temperature sample
+type HistoryPoint struct {
+	Timestamp   int64         `json:"timestamp"`
+	GrillTemp   float64       `json:"grill_temp"`
+	SetTemp     float64       `json:"set_temp"`
+	PelletLevel float64       `json:"pellet_level"`
+	Probes      []ProbeSample `json:"probes,omitempty"`
+	Unit        string        `json:"unit,omitempty"` // of the temperatures; F for samples recorded without one
+}
+
+// ProbeSample is a probe reading within a HistoryPoint
+type ProbeSample struct {
+	GetTemp float64 `json:"get_temp"`
+	SetTemp float64 `json:"set_temp"`
+}
+
 // ID returns the unique identifier for this widget
 func (w *TraegerWidget) ID() string {
 	return "traeger"
@@ -38,6 +90,16 @@ func (w *TraegerWidget) GetRequiredEnvVars() []string {
 	}
 }
 
+// ConfigSchema describes the "traeger" section of config.json
+func (w *TraegerWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "traeger",
+		Fields: append([]shared.ConfigField{
+			{Key: "grill_name", Type: "string", Required: true, Description: "Friendly name of the grill in the Traeger app"},
+		}, units.ConfigFields(units.TemperatureKey)...),
+	}
+}
+
 // Initialize sets up the widget on startup
 func (w *TraegerWidget) Initialize() error {
 	username := os.Getenv("TRAEGER_USERNAME")
@@ -89,16 +151,43 @@ func (w *TraegerWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/traeger/history", w.getTemperatureHistory)
 }
 
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *TraegerWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/traeger",
+			Summary: "Current grill and probe temperatures",
+			Query: []openapi.Param{
+				{Name: "grill_name", Description: "Friendly name of the grill", Required: true},
+			},
+			Response: GrillStatusResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/traeger/history",
+			Summary: "Recorded temperature history for a grill",
+			Query: []openapi.Param{
+				{Name: "grill_name", Description: "Friendly name of the grill", Required: true},
+				{Name: "duration", Type: "integer", Description: "Seconds of history to return (default 3600)"},
+			},
+			Response: HistoryResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+	}
+}
+
 // getGrillStatus handles GET /api/traeger
 func (w *TraegerWidget) getGrillStatus(rw http.ResponseWriter, r *http.Request) {
 	if w.client == nil {
-		http.Error(rw, `{"error":"Traeger widget not configured"}`, http.StatusServiceUnavailable)
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Traeger widget not configured")
 		return
 	}
 
 	grillName := r.URL.Query().Get("grill_name")
 	if grillName == "" {
-		http.Error(rw, `{"error":"grill_name parameter required"}`, http.StatusBadRequest)
+		shared.WriteError(rw, http.StatusBadRequest, "grill_name parameter required")
 		return
 	}
 
@@ -117,10 +206,12 @@ func (w *TraegerWidget) getGrillStatus(rw http.ResponseWriter, r *http.Request)
 	}
 
 	if thingName == "" {
-		availableJSON, _ := json.Marshal(availableGrills)
-		errorMsg := fmt.Sprintf(`{"error":"Grill '%s' not found","requested":"%s","available":%s}`, grillName, grillName, string(availableJSON))
 		log.Printf("[Traeger] Grill '%s' not found. Available grills: %v", grillName, availableGrills)
-		http.Error(rw, errorMsg, http.StatusNotFound)
+		shared.WriteJSON(rw, http.StatusNotFound, GrillNotFoundResponse{
+			Error:     fmt.Sprintf("Grill '%s' not found", grillName),
+			Requested: grillName,
+			Available: availableGrills,
+		})
 		return
 	}
 
@@ -131,45 +222,52 @@ func (w *TraegerWidget) getGrillStatus(rw http.ResponseWriter, r *http.Request)
 		ctx := context.Background()
 		if err := w.client.UpdateState(ctx, thingName); err != nil {
 			log.Printf("[Traeger] Failed to update state: %v", err)
-			http.Error(rw, `{"error":"failed to get grill status"}`, http.StatusInternalServerError)
+			shared.WriteError(rw, http.StatusInternalServerError, "failed to get grill status")
 			return
 		}
 		status = w.client.GetStateForDevice(thingName)
 	}
 
 	if status == nil {
-		http.Error(rw, `{"error":"no status available"}`, http.StatusNotFound)
+		shared.WriteError(rw, http.StatusNotFound, "no status available")
 		return
 	}
 
 	statusMap := status.(map[string]interface{})
 
-	// Extract relevant data
-	response := map[string]interface{}{
-		"grill_temp":    statusMap["grill"],
-		"set_temp":      statusMap["set"],
-		"pellet_level":  statusMap["pellet_level"],
-		"connected":     statusMap["connected"],
-		"system_status": statusMap["system_status"],
-		"probes":        []interface{}{},
+	// Extract relevant data, with temperatures in the configured unit rather
+	// than the one set on the grill
+	connected, _ := statusMap["connected"].(bool)
+	u := units.For("traeger")
+	from := grillUnit(statusMap)
+	temp := func(val interface{}) float64 {
+		return units.Round(u.ConvertTemperature(toFloat(val), from), u.Temperature)
+	}
+	response := GrillStatusResponse{
+		GrillTemp:    temp(statusMap["grill"]),
+		SetTemp:      temp(statusMap["set"]),
+		PelletLevel:  toFloat(statusMap["pellet_level"]),
+		Connected:    connected,
+		SystemStatus: toFloat(statusMap["system_status"]),
+		Probes:       []ProbeStatus{},
+		Units:        u,
 	}
 
 	// Extract probe data
 	if acc, ok := statusMap["acc"].([]interface{}); ok {
-		probes := []map[string]interface{}{}
 		for _, accessory := range acc {
 			accMap := accessory.(map[string]interface{})
 			if accMap["type"] == "probe" {
 				probeData := accMap["probe"].(map[string]interface{})
-				probes = append(probes, map[string]interface{}{
-					"name":      accMap["uuid"],
-					"connected": accMap["con"],
-					"get_temp":  probeData["get_temp"],
-					"set_temp":  probeData["set_temp"],
+				name, _ := accMap["uuid"].(string)
+				response.Probes = append(response.Probes, ProbeStatus{
+					Name:      name,
+					Connected: toFloat(accMap["con"]),
+					GetTemp:   temp(probeData["get_temp"]),
+					SetTemp:   temp(probeData["set_temp"]),
 				})
 			}
 		}
-		response["probes"] = probes
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
@@ -179,13 +277,13 @@ func (w *TraegerWidget) getGrillStatus(rw http.ResponseWriter, r *http.Request)
 // getTemperatureHistory handles GET /api/traeger/history
 func (w *TraegerWidget) getTemperatureHistory(rw http.ResponseWriter, r *http.Request) {
 	if w.redis == nil {
-		http.Error(rw, `{"error":"redis not configured"}`, http.StatusServiceUnavailable)
+		shared.WriteError(rw, http.StatusServiceUnavailable, "redis not configured")
 		return
 	}
 
 	grillName := r.URL.Query().Get("grill_name")
 	if grillName == "" {
-		http.Error(rw, `{"error":"grill_name parameter required"}`, http.StatusBadRequest)
+		shared.WriteError(rw, http.StatusBadRequest, "grill_name parameter required")
 		return
 	}
 
@@ -212,24 +310,34 @@ func (w *TraegerWidget) getTemperatureHistory(rw http.ResponseWriter, r *http.Re
 
 	if err != nil {
 		log.Printf("[Traeger] Failed to get history from Redis: %v", err)
-		http.Error(rw, `{"error":"failed to get history"}`, http.StatusInternalServerError)
+		shared.WriteError(rw, http.StatusInternalServerError, "failed to get history")
 		return
 	}
 
-	// Parse results
-	history := []map[string]interface{}{}
+	// Parse results, converting each sample from the unit it was recorded in
+	u := units.For("traeger")
+	history := []HistoryPoint{}
 	for _, result := range results {
-		var data map[string]interface{}
-		if err := json.Unmarshal([]byte(result.Member.(string)), &data); err == nil {
-			data["timestamp"] = int64(result.Score)
-			history = append(history, data)
+		var point HistoryPoint
+		if err := json.Unmarshal([]byte(result.Member.(string)), &point); err == nil {
+			point.Timestamp = int64(result.Score)
+			if point.Unit == "" {
+				point.Unit = units.Fahrenheit
+			}
+			temp := func(value float64) float64 {
+				return units.Round(u.ConvertTemperature(value, point.Unit), u.Temperature)
+			}
+			point.GrillTemp, point.SetTemp = temp(point.GrillTemp), temp(point.SetTemp)
+			for i := range point.Probes {
+				point.Probes[i].GetTemp, point.Probes[i].SetTemp = temp(point.Probes[i].GetTemp), temp(point.Probes[i].SetTemp)
+			}
+			point.Unit = u.Temperature
+			history = append(history, point)
 		}
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(map[string]interface{}{
-		"history": history,
-	})
+	json.NewEncoder(rw).Encode(HistoryResponse{History: history, Units: u})
 }
 
 // recordTemperatureHistory runs in the background to store temperature data in Redis
@@ -257,34 +365,33 @@ func (w *TraegerWidget) recordTemperatureHistory() {
 
 			statusMap := status.(map[string]interface{})
 
-			// Store temperature data point
-			dataPoint := map[string]interface{}{
-				"grill_temp":   statusMap["grill"],
-				"set_temp":     statusMap["set"],
-				"pellet_level": statusMap["pellet_level"],
+			// Store temperature data point. The timestamp is part of the member so
+			// identical readings at different times aren't collapsed by the sorted set.
+			timestamp := time.Now().Unix()
+			dataPoint := HistoryPoint{
+				Timestamp:   timestamp,
+				GrillTemp:   toFloat(statusMap["grill"]),
+				SetTemp:     toFloat(statusMap["set"]),
+				PelletLevel: toFloat(statusMap["pellet_level"]),
+				Unit:        grillUnit(statusMap),
 			}
 
 			// Add probe temps if available
 			if acc, ok := statusMap["acc"].([]interface{}); ok {
-				probes := []map[string]interface{}{}
 				for _, accessory := range acc {
 					accMap := accessory.(map[string]interface{})
 					if accMap["type"] == "probe" && accMap["con"] == float64(1) {
 						probeData := accMap["probe"].(map[string]interface{})
-						probes = append(probes, map[string]interface{}{
-							"get_temp": probeData["get_temp"],
-							"set_temp": probeData["set_temp"],
+						dataPoint.Probes = append(dataPoint.Probes, ProbeSample{
+							GetTemp: toFloat(probeData["get_temp"]),
+							SetTemp: toFloat(probeData["set_temp"]),
 						})
 					}
 				}
-				if len(probes) > 0 {
-					dataPoint["probes"] = probes
-				}
 			}
 
 			dataJSON, _ := json.Marshal(dataPoint)
 			key := fmt.Sprintf("traeger:history:%s", grillName)
-			timestamp := time.Now().Unix()
 
 			// Add to sorted set with timestamp as score
 			w.redis.ZAdd(ctx, key, redis.Z{
@@ -298,3 +405,27 @@ func (w *TraegerWidget) recordTemperatureHistory() {
 		}
 	}
 }
+
+// grillUnit is the temperature unit the grill reports in, as set on the grill:
+// its "units" status is 0 for Celsius and 1 for Fahrenheit
+func grillUnit(statusMap map[string]interface{}) string {
+	if setting, ok := statusMap["units"].(float64); ok && setting == 0 {
+		return units.Celsius
+	}
+	return units.Fahrenheit
+}
+
+// toFloat converts a numeric value from the grill's MQTT payload
+func toFloat(val interface{}) float64 {
+	switch v := val.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
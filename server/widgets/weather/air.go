@@ -0,0 +1,410 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// airTTL is how long a fetched air quality reading is reused. Providers update
+// hourly, and a PurpleAir sensor every two minutes.
+const airTTL = 10 * time.Minute
+
+// Air quality provider types; the others are shared with the weather providers
+const airProviderPurpleAir = "purpleair"
+
+// Pollutants the AQI is computed from
+const (
+	pollutantPM25 = "pm2_5"
+	pollutantPM10 = "pm10"
+	pollutantO3   = "o3"
+)
+
+// AirQuality is the response of GET /api/weather/air
+type AirQuality struct {
+	Provider   string     `json:"provider"` // providers the reading came from, comma-separated
+	Time       *time.Time `json:"time,omitempty"`
+	Scale      string     `json:"scale"` // us or eu, the index the dashboard shows
+	Pollutants Pollutants `json:"pollutants"`
+	AQI        struct {
+		US *AQI `json:"us,omitempty"`
+		EU *AQI `json:"eu,omitempty"`
+	} `json:"aqi"`
+	Pollen []Pollen `json:"pollen"`
+}
+
+// Pollutants are concentrations in µg/m³, null when the provider doesn't
+// measure them
+type Pollutants struct {
+	PM25 *float64 `json:"pm2_5,omitempty"`
+	PM10 *float64 `json:"pm10,omitempty"`
+	O3   *float64 `json:"o3,omitempty"`
+}
+
+// AQI is an air quality index and the pollutant that sets it
+type AQI struct {
+	Value     int    `json:"value"`     // 0 to 500 for the US index; the EU index has only levels, so its value is the level
+	Level     int    `json:"level"`     // 1 (good) to 6 (hazardous or extremely poor)
+	Category  string `json:"category"`  // e.g. Moderate
+	Pollutant string `json:"pollutant"` // pm2_5, pm10 or o3
+}
+
+// Pollen is the pollen count of a plant
+type Pollen struct {
+	Type  string  `json:"type"` // e.g. grass
+	Name  string  `json:"name"`
+	Count float64 `json:"count"` // grains/m³
+	Level string  `json:"level"` // none, low, moderate, high or very high
+}
+
+// airReading is what an air quality provider measures
+type airReading struct {
+	Time       time.Time
+	Pollutants Pollutants
+	Pollen     []Pollen // nil when the provider has no pollen counts
+}
+
+// airSource is somewhere air quality comes from
+type airSource interface {
+	// Name describes the provider in logs and responses
+	Name() string
+	// AirQuality returns the current air quality at a location
+	AirQuality(ctx context.Context, lat, lon float64) (*airReading, error)
+}
+
+// configuredAirSources returns the air quality provider chosen by the
+// "air_provider" config, followed by Open-Meteo, which needs no key and has
+// pollen. Without an "air_provider", a PurpleAir sensor is used when
+// "purpleair_url" is set, then OpenWeatherMap when its key is set.
+func configuredAirSources() ([]airSource, error) {
+	purpleAirURL := shared.GetWidgetConfigValue("weather", "purpleair_url", "")
+	primary := shared.GetWidgetConfigValue("weather", "air_provider", "")
+	if primary == "" {
+		switch {
+		case purpleAirURL != "":
+			primary = airProviderPurpleAir
+		case os.Getenv(openWeatherKeyEnv) != "":
+			primary = providerOpenWeather
+		default:
+			primary = providerOpenMeteo
+		}
+	}
+
+	var source airSource
+	switch primary {
+	case airProviderPurpleAir:
+		if purpleAirURL == "" {
+			return nil, fmt.Errorf("%w: purpleair_url is not set", errNotConfigured)
+		}
+		source = &purpleAirProvider{url: purpleAirURL}
+	case providerOpenWeather, providerOpenMeteo:
+		provider, err := newProvider(primary)
+		if err != nil {
+			return nil, err
+		}
+		source = provider.(airSource)
+	default:
+		return nil, fmt.Errorf("%w: unknown air quality provider %q", errNotConfigured, primary)
+	}
+
+	sources := []airSource{source}
+	if primary != providerOpenMeteo {
+		sources = append(sources, &openMeteoProvider{})
+	}
+	return sources, nil
+}
+
+// fetchAir asks each source in turn for the air quality. The first reading is
+// used, and what it lacks, such as ozone or pollen, is filled in from the
+// sources after it. If they all fail, the first source's error is returned.
+func fetchAir(ctx context.Context, sources []airSource, lat, lon float64, located bool) (*AirQuality, error) {
+	var reading *airReading
+	var names []string
+	var firstErr error
+	for i, source := range sources {
+		if _, isPurpleAir := source.(*purpleAirProvider); !isPurpleAir && !located {
+			continue
+		}
+		found, err := source.AirQuality(ctx, lat, lon)
+		if err != nil {
+			if reading == nil && i < len(sources)-1 {
+				log.Printf("[Weather] Error fetching air quality from %s, trying %s: %v", source.Name(), sources[i+1].Name(), err)
+			} else {
+				log.Printf("[Weather] Error fetching air quality from %s: %v", source.Name(), err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if reading == nil {
+			reading = found
+			names = append(names, source.Name())
+			if !reading.lacksData() {
+				break
+			}
+			continue
+		}
+		if reading.fillFrom(found) {
+			names = append(names, source.Name())
+		}
+	}
+	if reading == nil {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w: no location", errNotConfigured)
+		}
+		return nil, firstErr
+	}
+
+	air := &AirQuality{
+		Provider:   strings.Join(names, ", "),
+		Pollutants: reading.Pollutants,
+		Pollen:     reading.Pollen,
+	}
+	if !reading.Time.IsZero() {
+		air.Time = &reading.Time
+	}
+	if air.Pollen == nil {
+		air.Pollen = []Pollen{}
+	}
+	air.AQI.US = usAQI(reading.Pollutants)
+	air.AQI.EU = euAQI(reading.Pollutants)
+	return air, nil
+}
+
+// lacksData reports whether a reading is missing a pollutant or pollen
+func (r *airReading) lacksData() bool {
+	p := r.Pollutants
+	return p.PM25 == nil || p.PM10 == nil || p.O3 == nil || r.Pollen == nil
+}
+
+// fillFrom copies the pollutants and pollen r lacks from another reading,
+// reporting whether any were
+func (r *airReading) fillFrom(other *airReading) bool {
+	filled := false
+	fill := func(dest **float64, value *float64) {
+		if *dest == nil && value != nil {
+			*dest, filled = value, true
+		}
+	}
+	fill(&r.Pollutants.PM25, other.Pollutants.PM25)
+	fill(&r.Pollutants.PM10, other.Pollutants.PM10)
+	fill(&r.Pollutants.O3, other.Pollutants.O3)
+	if r.Pollen == nil && other.Pollen != nil {
+		r.Pollen, filled = other.Pollen, true
+	}
+	return filled
+}
+
+// airCache keeps the last air quality for each location
+type airCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedAir
+}
+
+type cachedAir struct {
+	air     *AirQuality
+	fetched time.Time
+}
+
+var airQuality = &airCache{}
+
+// get returns a location's air quality, calling fetch when there is none
+// younger than airTTL
+func (c *airCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (*AirQuality, error)) (*AirQuality, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok && time.Since(entry.fetched) < airTTL {
+		return entry.air, nil
+	}
+	air, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cachedAir)
+	}
+	c.entries[key] = cachedAir{air: air, fetched: time.Now()}
+	return air, nil
+}
+
+// aqiBreakpoint maps a range of concentrations linearly onto a range of the
+// US index
+type aqiBreakpoint struct {
+	low, high           float64
+	indexLow, indexHigh int
+}
+
+// usBreakpoints are the EPA's breakpoints, as revised in 2024: PM2.5 and PM10
+// in µg/m³ and ozone in ppm. The ozone index is defined on 8-hour averages up to
+// 0.200 ppm and on 1-hour averages above.
+var usBreakpoints = map[string][]aqiBreakpoint{
+	pollutantPM25: {
+		{0, 9.0, 0, 50},
+		{9.1, 35.4, 51, 100},
+		{35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200},
+		{125.5, 225.4, 201, 300},
+		{225.5, 325.4, 301, 500},
+	},
+	pollutantPM10: {
+		{0, 54, 0, 50},
+		{55, 154, 51, 100},
+		{155, 254, 101, 150},
+		{255, 354, 151, 200},
+		{355, 424, 201, 300},
+		{425, 604, 301, 500},
+	},
+	pollutantO3: {
+		{0, 0.054, 0, 50},
+		{0.055, 0.070, 51, 100},
+		{0.071, 0.085, 101, 150},
+		{0.086, 0.105, 151, 200},
+		{0.106, 0.200, 201, 300},
+		{0.405, 0.504, 301, 400},
+		{0.505, 0.604, 401, 500},
+	},
+}
+
+// usDecimals are the decimals the EPA truncates each concentration to
+var usDecimals = map[string]int{pollutantPM25: 1, pollutantPM10: 0, pollutantO3: 3}
+
+var usCategories = []string{"Good", "Moderate", "Unhealthy for Sensitive Groups", "Unhealthy", "Very Unhealthy", "Hazardous"}
+
+// euBands are the upper bounds of the first five levels of the European
+// Environment Agency's index, as revised in 2024, in µg/m³
+var euBands = map[string][]float64{
+	pollutantPM25: {5, 15, 50, 90, 140},
+	pollutantPM10: {15, 45, 120, 195, 270},
+	pollutantO3:   {60, 100, 120, 160, 180},
+}
+
+var euCategories = []string{"Good", "Fair", "Moderate", "Poor", "Very poor", "Extremely poor"}
+
+// ozonePPM converts ozone from µg/m³ to ppm at 25 °C
+func ozonePPM(microgramsPerCubicMeter float64) float64 {
+	return microgramsPerCubicMeter * 24.45 / 48.00 / 1000
+}
+
+// usAQI computes the EPA's AQI, the highest of the pollutants' sub-indexes.
+// The EPA averages PM over 24 hours and ozone over 8, but the current
+// concentrations are used, as on most live sensor maps.
+func usAQI(p Pollutants) *AQI {
+	var result *AQI
+	for _, pollutant := range p.list() {
+		c := *pollutant.value
+		if pollutant.name == pollutantO3 {
+			c = ozonePPM(c)
+		}
+		scale := math.Pow(10, float64(usDecimals[pollutant.name]))
+		index := usIndex(math.Floor(c*scale+1e-9)/scale, usBreakpoints[pollutant.name])
+		if result == nil || index > result.Value {
+			result = &AQI{Value: index, Pollutant: pollutant.name}
+		}
+	}
+	if result != nil {
+		result.Level = usLevel(result.Value)
+		result.Category = usCategories[result.Level-1]
+	}
+	return result
+}
+
+// usIndex interpolates a truncated concentration between its breakpoints.
+// Concentrations between two ranges get the top of the lower one, and those
+// beyond the last one 500.
+func usIndex(c float64, breakpoints []aqiBreakpoint) int {
+	for i, bp := range breakpoints {
+		if c > bp.high {
+			continue
+		}
+		if c < bp.low {
+			return breakpoints[i-1].indexHigh
+		}
+		return int(math.Round(float64(bp.indexHigh-bp.indexLow)/(bp.high-bp.low)*(c-bp.low))) + bp.indexLow
+	}
+	return 500
+}
+
+// usLevel is the category of a US index, from 1 (Good) to 6 (Hazardous)
+func usLevel(index int) int {
+	for level, top := range []int{50, 100, 150, 200, 300} {
+		if index <= top {
+			return level + 1
+		}
+	}
+	return 6
+}
+
+// euAQI computes the European index, the worst of the pollutants' levels
+func euAQI(p Pollutants) *AQI {
+	var result *AQI
+	for _, pollutant := range p.list() {
+		level := len(euBands[pollutant.name]) + 1
+		for i, top := range euBands[pollutant.name] {
+			if *pollutant.value <= top {
+				level = i + 1
+				break
+			}
+		}
+		if result == nil || level > result.Level {
+			result = &AQI{Value: level, Level: level, Category: euCategories[level-1], Pollutant: pollutant.name}
+		}
+	}
+	return result
+}
+
+type pollutantValue struct {
+	name  string
+	value *float64
+}
+
+// list returns the measured pollutants, in the order ties are settled
+func (p Pollutants) list() []pollutantValue {
+	var list []pollutantValue
+	for _, pollutant := range []pollutantValue{{pollutantPM25, p.PM25}, {pollutantPM10, p.PM10}, {pollutantO3, p.O3}} {
+		if pollutant.value != nil && *pollutant.value >= 0 {
+			list = append(list, pollutant)
+		}
+	}
+	return list
+}
+
+// pollenPlants are the plants providers count pollen of, with the group
+// whose levels apply
+var pollenPlants = []struct {
+	kind, name, group string
+}{
+	{"alder", "Alder", "tree"},
+	{"birch", "Birch", "tree"},
+	{"olive", "Olive", "tree"},
+	{"grass", "Grass", "grass"},
+	{"mugwort", "Mugwort", "weed"},
+	{"ragweed", "Ragweed", "weed"},
+}
+
+// pollenLevels are the lowest counts of the low, moderate, high and very high
+// levels of each group, in grains/m³, as the National Allergy Bureau uses
+var pollenLevels = map[string][4]float64{
+	"tree":  {1, 15, 90, 1500},
+	"grass": {1, 5, 20, 200},
+	"weed":  {1, 10, 50, 500},
+}
+
+// pollenLevel names the level of a pollen count for a group of plants
+func pollenLevel(group string, count float64) string {
+	names := []string{"none", "low", "moderate", "high", "very high"}
+	level := 0
+	for i, lowest := range pollenLevels[group] {
+		if count >= lowest {
+			level = i + 1
+		}
+	}
+	return names[level]
+}
@@ -0,0 +1,121 @@
+package weather
+
+import "testing"
+
+func TestUSIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		pollutant string
+		c         float64
+		want      int
+	}{
+		{"PM2.5 zero", pollutantPM25, 0, 0},
+		{"PM2.5 top of good", pollutantPM25, 9.0, 50},
+		{"PM2.5 bottom of moderate", pollutantPM25, 9.1, 51},
+		{"PM2.5 within moderate", pollutantPM25, 12.0, 56},
+		{"PM2.5 top of moderate", pollutantPM25, 35.4, 100},
+		{"PM2.5 bottom of sensitive groups", pollutantPM25, 35.5, 101},
+		{"PM2.5 top of unhealthy", pollutantPM25, 125.4, 200},
+		{"PM2.5 bottom of very unhealthy", pollutantPM25, 125.5, 201},
+		{"PM2.5 bottom of hazardous", pollutantPM25, 225.5, 301},
+		{"PM2.5 top of the scale", pollutantPM25, 325.4, 500},
+		{"PM2.5 beyond the scale", pollutantPM25, 500, 500},
+		{"PM2.5 between bands", pollutantPM25, 9.05, 50},
+		{"PM10 top of good", pollutantPM10, 54, 50},
+		{"PM10 bottom of moderate", pollutantPM10, 55, 51},
+		{"PM10 within moderate", pollutantPM10, 60, 53},
+		{"PM10 bottom of very unhealthy", pollutantPM10, 355, 201},
+		{"ozone top of good", pollutantO3, 0.054, 50},
+		{"ozone bottom of moderate", pollutantO3, 0.055, 51},
+		{"ozone bottom of sensitive groups", pollutantO3, 0.071, 101},
+		{"ozone top of the 8-hour scale", pollutantO3, 0.200, 300},
+		{"ozone in the 8-hour to 1-hour gap", pollutantO3, 0.300, 300},
+		{"ozone at the end of the gap", pollutantO3, 0.404, 300},
+		{"ozone bottom of the 1-hour scale", pollutantO3, 0.405, 301},
+		{"ozone top of 301-400", pollutantO3, 0.504, 400},
+		{"ozone bottom of 401-500", pollutantO3, 0.505, 401},
+		{"ozone top of the scale", pollutantO3, 0.604, 500},
+	}
+	for _, tt := range tests {
+		if got := usIndex(tt.c, usBreakpoints[tt.pollutant]); got != tt.want {
+			t.Errorf("%s: usIndex(%v) = %d, want %d", tt.name, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestUSAQI(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name       string
+		pollutants Pollutants
+		want       *AQI
+	}{
+		{"nothing measured", Pollutants{}, nil},
+		{"highest pollutant wins", Pollutants{PM25: value(12), PM10: value(60), O3: value(20)}, &AQI{Value: 56, Level: 2, Category: "Moderate", Pollutant: pollutantPM25}},
+		{"PM10 highest", Pollutants{PM25: value(5), PM10: value(160)}, &AQI{Value: 103, Level: 3, Category: "Unhealthy for Sensitive Groups", Pollutant: pollutantPM10}},
+		{"PM2.5 truncated to a decimal", Pollutants{PM25: value(9.09)}, &AQI{Value: 50, Level: 1, Category: "Good", Pollutant: pollutantPM25}},
+		{"a tie goes to PM2.5", Pollutants{PM25: value(9.0), PM10: value(54)}, &AQI{Value: 50, Level: 1, Category: "Good", Pollutant: pollutantPM25}},
+		// 500 µg/m³ is 0.254 ppm, between the 8-hour and 1-hour scales
+		{"ozone in the gap", Pollutants{O3: value(500)}, &AQI{Value: 300, Level: 5, Category: "Very Unhealthy", Pollutant: pollutantO3}},
+		{"ozone past the gap", Pollutants{O3: value(900)}, &AQI{Value: 354, Level: 6, Category: "Hazardous", Pollutant: pollutantO3}},
+		{"negative readings are left out", Pollutants{PM25: value(-1), PM10: value(20)}, &AQI{Value: 19, Level: 1, Category: "Good", Pollutant: pollutantPM10}},
+	}
+	for _, tt := range tests {
+		got := usAQI(tt.pollutants)
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEUAQI(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name       string
+		pollutants Pollutants
+		want       *AQI
+	}{
+		{"nothing measured", Pollutants{}, nil},
+		{"PM2.5 top of good", Pollutants{PM25: value(5)}, &AQI{Value: 1, Level: 1, Category: "Good", Pollutant: pollutantPM25}},
+		{"PM2.5 just over good", Pollutants{PM25: value(5.1)}, &AQI{Value: 2, Level: 2, Category: "Fair", Pollutant: pollutantPM25}},
+		{"PM10 top of moderate", Pollutants{PM10: value(120)}, &AQI{Value: 3, Level: 3, Category: "Moderate", Pollutant: pollutantPM10}},
+		{"PM10 just over moderate", Pollutants{PM10: value(121)}, &AQI{Value: 4, Level: 4, Category: "Poor", Pollutant: pollutantPM10}},
+		{"ozone top of very poor", Pollutants{O3: value(180)}, &AQI{Value: 5, Level: 5, Category: "Very poor", Pollutant: pollutantO3}},
+		{"ozone over the last band", Pollutants{O3: value(181)}, &AQI{Value: 6, Level: 6, Category: "Extremely poor", Pollutant: pollutantO3}},
+		{"worst pollutant wins", Pollutants{PM25: value(10), PM10: value(130), O3: value(50)}, &AQI{Value: 4, Level: 4, Category: "Poor", Pollutant: pollutantPM10}},
+	}
+	for _, tt := range tests {
+		got := euAQI(tt.pollutants)
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPollenLevel(t *testing.T) {
+	tests := []struct {
+		group string
+		count float64
+		want  string
+	}{
+		{"tree", 0, "none"},
+		{"tree", 1, "low"},
+		{"tree", 14.9, "low"},
+		{"tree", 15, "moderate"},
+		{"tree", 90, "high"},
+		{"tree", 1500, "very high"},
+		{"grass", 4, "low"},
+		{"grass", 5, "moderate"},
+		{"grass", 20, "high"},
+		{"grass", 200, "very high"},
+		{"weed", 9, "low"},
+		{"weed", 10, "moderate"},
+		{"weed", 499, "high"},
+		{"weed", 500, "very high"},
+	}
+	for _, tt := range tests {
+		if got := pollenLevel(tt.group, tt.count); got != tt.want {
+			t.Errorf("pollenLevel(%s, %v) = %q, want %q", tt.group, tt.count, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package weather
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertsTTL is how long fetched alerts are reused. It is shorter than
+// reportTTL, as a new warning shouldn't wait for the next forecast.
+const alertsTTL = 5 * time.Minute
+
+// Alert severities, as in the Common Alerting Protocol, from most to least severe
+const (
+	severityExtreme  = "extreme"
+	severitySevere   = "severe"
+	severityModerate = "moderate"
+	severityMinor    = "minor"
+	severityUnknown  = "unknown"
+)
+
+var severityRank = map[string]int{
+	severityExtreme:  0,
+	severitySevere:   1,
+	severityModerate: 2,
+	severityMinor:    3,
+	severityUnknown:  4,
+}
+
+// Alert is an active weather alert for the configured location
+type Alert struct {
+	ID          string     `json:"id"`
+	Event       string     `json:"event"`    // e.g. Tornado Warning
+	Severity    string     `json:"severity"` // extreme, severe, moderate, minor or unknown
+	Warning     bool       `json:"warning"`  // a warning rather than a watch or advisory, worth a banner
+	Headline    string     `json:"headline"`
+	Description string     `json:"description"`
+	Instruction string     `json:"instruction,omitempty"`
+	Sender      string     `json:"sender,omitempty"`
+	Onset       *time.Time `json:"onset,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+
+	// replaces are the IDs of earlier versions of this alert
+	replaces []string
+}
+
+// AlertsResponse is the response of GET /api/weather/alerts
+type AlertsResponse struct {
+	Provider string  `json:"provider"`
+	Alerts   []Alert `json:"alerts"`
+}
+
+// alertProvider is a WeatherProvider that also has alerts
+type alertProvider interface {
+	WeatherProvider
+	// Alerts returns the alerts in effect or expected at a location
+	Alerts(ctx context.Context, lat, lon float64) ([]Alert, error)
+}
+
+// alertCache keeps the last alerts for each location
+type alertCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedAlerts
+}
+
+type cachedAlerts struct {
+	response AlertsResponse
+	fetched  time.Time
+}
+
+var alerts = &alertCache{}
+
+// get returns a location's alerts, calling fetch when there are none younger
+// than alertsTTL. Expired alerts are dropped from cached responses too.
+func (c *alertCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (AlertsResponse, error)) (AlertsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.fetched) >= alertsTTL {
+		response, err := fetch(ctx)
+		if err != nil {
+			return AlertsResponse{}, err
+		}
+		if c.entries == nil {
+			c.entries = make(map[string]cachedAlerts)
+		}
+		entry = cachedAlerts{response: response, fetched: time.Now()}
+		c.entries[key] = entry
+	}
+	response := entry.response
+	response.Alerts = activeAlerts(response.Alerts, time.Now())
+	return response, nil
+}
+
+// alertProviders returns the configured providers that have alerts, followed by
+// the NWS, which has them for any US location without a key
+func alertProviders(providers []WeatherProvider) []alertProvider {
+	var result []alertProvider
+	hasNWS := false
+	for _, provider := range providers {
+		if provider, ok := provider.(alertProvider); ok {
+			result = append(result, provider)
+		}
+		_, isNWS := provider.(*nwsProvider)
+		hasNWS = hasNWS || isNWS
+	}
+	if !hasNWS {
+		result = append(result, &nwsProvider{})
+	}
+	return result
+}
+
+// fetchAlerts asks each provider in turn, returning the first one's alerts. If
+// they all fail, the first provider's error is returned.
+func fetchAlerts(ctx context.Context, providers []alertProvider, lat, lon float64) (AlertsResponse, error) {
+	var firstErr error
+	for i, provider := range providers {
+		found, err := provider.Alerts(ctx, lat, lon)
+		if err == nil {
+			return AlertsResponse{Provider: provider.Name(), Alerts: activeAlerts(found, time.Now())}, nil
+		}
+		if i < len(providers)-1 {
+			log.Printf("[Weather] Error fetching alerts from %s, trying %s: %v", provider.Name(), providers[i+1].Name(), err)
+		} else {
+			log.Printf("[Weather] Error fetching alerts from %s: %v", provider.Name(), err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return AlertsResponse{}, firstErr
+}
+
+// activeAlerts drops expired alerts, repeated IDs and alerts a later version
+// replaces, then sorts warnings first, by severity and onset
+func activeAlerts(found []Alert, now time.Time) []Alert {
+	replaced := make(map[string]bool)
+	for _, alert := range found {
+		for _, id := range alert.replaces {
+			replaced[id] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	active := []Alert{}
+	for _, alert := range found {
+		if seen[alert.ID] || replaced[alert.ID] || (alert.Expires != nil && alert.Expires.Before(now)) {
+			continue
+		}
+		seen[alert.ID] = true
+		active = append(active, alert)
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		a, b := active[i], active[j]
+		if a.Warning != b.Warning {
+			return a.Warning
+		}
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.Onset == nil || b.Onset == nil {
+			return a.Onset != nil
+		}
+		return a.Onset.Before(*b.Onset)
+	})
+	return active
+}
+
+// isWarning reports whether an alert is a severe warning: the hazard is
+// happening or about to, as opposed to a watch, advisory or statement
+func isWarning(event, severity string) bool {
+	return severity == severityExtreme ||
+		severity == severitySevere && slices.Contains(strings.Fields(strings.ToLower(event)), "warning")
+}
+
+// eventSeverity guesses the severity of an alert that has none from its name:
+// the MeteoAlarm colour European senders start it with, or else the NWS kind
+// of alert it is
+func eventSeverity(event string) string {
+	words := strings.Fields(strings.ToLower(event))
+	if len(words) > 1 && words[1] != "flag" { // a Red Flag Warning is about fire danger
+		switch words[0] {
+		case "red":
+			return severityExtreme
+		case "orange", "amber":
+			return severitySevere
+		case "yellow":
+			return severityModerate
+		}
+	}
+	for _, kind := range []struct{ word, severity string }{
+		{"extreme", severityExtreme},
+		{"warning", severitySevere},
+		{"watch", severityModerate},
+		{"advisory", severityMinor},
+		{"statement", severityMinor},
+	} {
+		if slices.Contains(words, kind.word) {
+			return kind.severity
+		}
+	}
+	return severityUnknown
+}
+
+// alertID makes a stable ID for an alert that has none, from its sender, event
+// and start
+func alertID(sender, event string, start int64) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d", sender, event, start)))
+	return hex.EncodeToString(sum[:8])
+}
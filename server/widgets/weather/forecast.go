@@ -0,0 +1,201 @@
+package weather
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared/units"
+)
+
+// reportTTL is how long a fetched report is reused, so the current conditions
+// and the forecast share one request
+const reportTTL = 10 * time.Minute
+
+// Bounds of the forecast's hours and days parameters
+const (
+	maxForecastHours = 48
+	maxForecastDays  = 10
+)
+
+// Report is the weather for a location: current conditions and the forecast.
+// Temperatures are in °F, speeds in mph and precipitation in inches; responses
+// are converted into the configured units.
+type Report struct {
+	Provider string // name of the provider it came from
+	Current  Conditions
+	Hourly   []HourlyForecast
+	Daily    []DailyForecast
+}
+
+// Conditions are the current conditions
+type Conditions struct {
+	Time        time.Time
+	Temp        float64
+	FeelsLike   float64
+	Humidity    int
+	WindSpeed   float64
+	WindGust    float64
+	UVIndex     *float64
+	Condition   string
+	Description string
+	Icon        string
+}
+
+// Forecast is the response of GET /api/weather/forecast
+type Forecast struct {
+	Provider string           `json:"provider"`
+	Units    units.Units      `json:"units"`
+	Hourly   []HourlyForecast `json:"hourly"`
+	Daily    []DailyForecast  `json:"daily"`
+}
+
+// HourlyForecast is the forecast for an hour, or for a few hours when the
+// provider's steps are longer
+type HourlyForecast struct {
+	Time         time.Time `json:"time"` // start of the hour, in the dashboard timezone
+	Temp         int       `json:"temp"`
+	FeelsLike    int       `json:"feelsLike"`
+	Humidity     int       `json:"humidity"`
+	WindSpeed    float64   `json:"windSpeed"`
+	WindGust     float64   `json:"windGust,omitempty"`
+	PrecipChance int       `json:"precipChance"` // percent
+	PrecipAmount float64   `json:"precipAmount"` // rain and melted snow, in inches until converted
+	UVIndex      *float64  `json:"uvIndex,omitempty"`
+	Condition    string    `json:"condition"` // e.g. Rain
+	Description  string    `json:"description"`
+	Icon         string    `json:"icon"` // OpenWeatherMap icon code, e.g. 10d
+}
+
+// DailyForecast is the forecast for a day
+type DailyForecast struct {
+	Date         string     `json:"date"` // YYYY-MM-DD in the dashboard timezone
+	High         int        `json:"high"`
+	Low          int        `json:"low"`
+	Humidity     int        `json:"humidity"`
+	WindSpeed    float64    `json:"windSpeed"`
+	WindGust     float64    `json:"windGust,omitempty"`
+	PrecipChance int        `json:"precipChance"`
+	PrecipAmount float64    `json:"precipAmount"`
+	UVIndex      *float64   `json:"uvIndex,omitempty"`
+	Sunrise      *time.Time `json:"sunrise,omitempty"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Condition    string     `json:"condition"`
+	Description  string     `json:"description"`
+	Icon         string     `json:"icon"`
+}
+
+// reportCache keeps the last report for each location
+type reportCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedReport
+}
+
+type cachedReport struct {
+	report  *Report
+	fetched time.Time
+}
+
+var reports = &reportCache{}
+
+// get returns a location's report, calling fetch when there is none younger than
+// reportTTL. The lock is held while fetching, so concurrent requests share one.
+func (c *reportCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (*Report, error)) (*Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok && time.Since(entry.fetched) < reportTTL {
+		return entry.report, nil
+	}
+	report, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cachedReport)
+	}
+	c.entries[key] = cachedReport{report: report, fetched: time.Now()}
+	return report, nil
+}
+
+// today returns the report's forecast for the current day, if it has one
+func (r *Report) today(loc *time.Location) (DailyForecast, bool) {
+	date := time.Now().In(loc).Format("2006-01-02")
+	for _, day := range r.Daily {
+		if day.Date == date {
+			return day, true
+		}
+	}
+	return DailyForecast{}, false
+}
+
+// forecast returns the hours from the current one and the days from today, up
+// to the given numbers
+func (r *Report) forecast(hours, days int, loc *time.Location) Forecast {
+	now := time.Now().In(loc)
+	thisHour := now.Truncate(time.Hour)
+	end := thisHour.Add(time.Duration(hours) * time.Hour)
+	forecast := Forecast{Provider: r.Provider, Hourly: []HourlyForecast{}, Daily: []DailyForecast{}}
+	for _, hour := range r.Hourly {
+		if hour.Time.Before(thisHour) || !hour.Time.Before(end) {
+			continue
+		}
+		hour.Time = hour.Time.In(loc)
+		forecast.Hourly = append(forecast.Hourly, hour)
+	}
+
+	today := now.Format("2006-01-02")
+	for _, day := range r.Daily {
+		if day.Date < today || len(forecast.Daily) >= days {
+			continue
+		}
+		day.Sunrise, day.Sunset = inLocation(day.Sunrise, loc), inLocation(day.Sunset, loc)
+		forecast.Daily = append(forecast.Daily, day)
+	}
+	return forecast
+}
+
+// convert converts a forecast from the report's °F, mph and inches into u
+func (f *Forecast) convert(u units.Units) {
+	f.Units = u
+	temp := func(value int) int {
+		return int(math.Round(u.ConvertTemperature(float64(value), units.Fahrenheit)))
+	}
+	speed := func(value float64) float64 {
+		return round(u.ConvertSpeed(value, units.MilesPerHour), 1)
+	}
+	rainfall := func(value float64) float64 {
+		return units.Round(u.ConvertRainfall(value, units.Inches), u.Rainfall)
+	}
+	for i := range f.Hourly {
+		hour := &f.Hourly[i]
+		hour.Temp, hour.FeelsLike = temp(hour.Temp), temp(hour.FeelsLike)
+		hour.WindSpeed, hour.WindGust = speed(hour.WindSpeed), speed(hour.WindGust)
+		hour.PrecipAmount = rainfall(hour.PrecipAmount)
+	}
+	for i := range f.Daily {
+		day := &f.Daily[i]
+		day.High, day.Low = temp(day.High), temp(day.Low)
+		day.WindSpeed, day.WindGust = speed(day.WindSpeed), speed(day.WindGust)
+		day.PrecipAmount = rainfall(day.PrecipAmount)
+	}
+}
+
+func inLocation(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(loc)
+	return &local
+}
+
+// round rounds to the given number of decimals
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// mmToInches converts precipitation, which providers give in millimeters
+func mmToInches(mm float64) float64 {
+	return round(mm/25.4, 2)
+}
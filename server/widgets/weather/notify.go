@@ -0,0 +1,225 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// alertNotifyTokenEnv holds an optional bearer token for the notification URL,
+// such as an ntfy access token
+const alertNotifyTokenEnv = "ALERT_NOTIFY_TOKEN"
+
+// notifiedRetention is how long a sent alert is remembered after it is no
+// longer active, in case a provider briefly leaves it out
+const notifiedRetention = 24 * time.Hour
+
+// Notification formats
+const (
+	notifyJSON = "json"
+	notifyNtfy = "ntfy"
+)
+
+// AlertNotification is the body POSTed to alert_notify_url for each new alert
+type AlertNotification struct {
+	Location string `json:"location,omitempty"` // the widget's location_name
+	Alert
+}
+
+// notifyTarget is where and how alerts are sent, from the widget config
+type notifyTarget struct {
+	url         string
+	format      string // json or ntfy
+	minSeverity string // least severe alert that is sent
+	location    string
+	token       string
+	loc         *time.Location // for onset and expiry in JSON
+}
+
+// configuredNotifyTarget reads the notification settings, returning false when
+// no URL is set
+func configuredNotifyTarget() (notifyTarget, bool) {
+	target := notifyTarget{
+		url:         shared.GetWidgetConfigValue("weather", "alert_notify_url", ""),
+		format:      shared.GetWidgetConfigValue("weather", "alert_notify_format", notifyJSON),
+		minSeverity: shared.GetWidgetConfigValue("weather", "alert_notify_min_severity", severitySevere),
+		location:    shared.GetWidgetConfigValue("weather", "location_name", ""),
+		token:       os.Getenv(alertNotifyTokenEnv),
+		loc:         shared.GetLocation(),
+	}
+	if _, ok := severityRank[target.minSeverity]; !ok {
+		target.minSeverity = severitySevere
+	}
+	return target, target.url != ""
+}
+
+// alertNotifier sends each new alert once. The IDs of sent alerts are kept in
+// a file, so a restart doesn't send the alerts still active again.
+type alertNotifier struct {
+	mu   sync.Mutex
+	path string
+	sent map[string]time.Time // alert ID to when it was last seen active
+}
+
+var notifier = &alertNotifier{path: "/app/config/.cache/weather-alerts-notified.json"}
+
+// watchAlerts checks the alerts every alertsTTL while a notification URL is
+// configured. The checks share the alert cache with the dashboard.
+func (w *WeatherWidget) watchAlerts() {
+	ticker := time.NewTicker(alertsTTL)
+	defer ticker.Stop()
+	for {
+		if target, ok := configuredNotifyTarget(); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if response, err := w.alerts(ctx); err == nil {
+				notifier.notify(ctx, target, response.Alerts)
+			}
+			cancel()
+		}
+		<-ticker.C
+	}
+}
+
+// notify sends the active alerts at least as severe as the target asks for
+// that weren't sent before. Alerts that fail to send are tried again next time.
+func (n *alertNotifier) notify(ctx context.Context, target notifyTarget, active []Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sent == nil {
+		n.load()
+	}
+
+	now := time.Now()
+	for _, alert := range active {
+		if _, ok := n.sent[alert.ID]; ok {
+			n.sent[alert.ID] = now
+			continue
+		}
+		if severityRank[alert.Severity] > severityRank[target.minSeverity] {
+			continue
+		}
+		if err := target.send(ctx, alert); err != nil {
+			log.Printf("[Weather] Error sending alert %q: %v", alert.Event, err)
+			continue
+		}
+		log.Printf("[Weather] Sent alert %q", alert.Event)
+		n.sent[alert.ID] = now
+	}
+	for id, seen := range n.sent {
+		if now.Sub(seen) > notifiedRetention {
+			delete(n.sent, id)
+		}
+	}
+	n.save()
+}
+
+func (n *alertNotifier) load() {
+	n.sent = make(map[string]time.Time)
+	if data, err := os.ReadFile(n.path); err == nil {
+		json.Unmarshal(data, &n.sent)
+	}
+}
+
+func (n *alertNotifier) save() {
+	data, err := json.Marshal(n.sent)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(n.path), 0755); err == nil {
+		err = os.WriteFile(n.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("[Weather] Error saving sent alerts: %v", err)
+	}
+}
+
+// send POSTs an alert: as JSON, or for ntfy as a message with its title,
+// priority and tags in headers
+func (t notifyTarget) send(ctx context.Context, alert Alert) error {
+	var body []byte
+	var err error
+	if t.format == notifyNtfy {
+		message := alert.Headline
+		if alert.Instruction != "" {
+			message += "\n\n" + alert.Instruction
+		}
+		body = []byte(message)
+	} else {
+		if t.loc != nil {
+			alert.Onset, alert.Expires = inLocation(alert.Onset, t.loc), inLocation(alert.Expires, t.loc)
+		}
+		body, err = json.Marshal(AlertNotification{Location: t.location, Alert: alert})
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	if t.format == notifyNtfy {
+		title := alert.Event
+		if t.location != "" {
+			title += " for " + t.location
+		}
+		req.Header.Set("Title", headerText(title))
+		req.Header.Set("Priority", ntfyPriority(alert.Severity))
+		if alert.Warning {
+			req.Header.Set("Tags", "warning")
+		}
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification URL returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ntfyPriority maps an alert's severity to an ntfy priority
+func ntfyPriority(severity string) string {
+	switch severity {
+	case severityExtreme:
+		return "urgent"
+	case severitySevere:
+		return "high"
+	}
+	return "default"
+}
+
+// headerText keeps a value on one line, as a header must be
+func headerText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// withoutURL drops the address from a request error, since notification URLs
+// such as ntfy topics are secrets
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
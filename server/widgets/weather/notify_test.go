@@ -0,0 +1,131 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// notifyStandIn records the notifications POSTed to it, failing while fail is set
+type notifyStandIn struct {
+	mu       sync.Mutex
+	fail     bool
+	received []*http.Request
+	bodies   []string
+}
+
+func (s *notifyStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.received = append(s.received, r)
+	s.bodies = append(s.bodies, string(body))
+}
+
+func (s *notifyStandIn) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+func TestAlertNotifierSendsEachAlertOnce(t *testing.T) {
+	standIn := &notifyStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "notified.json")
+	target := notifyTarget{url: server.URL + "/hooks/weather", format: notifyJSON, minSeverity: severitySevere, location: "Home"}
+
+	warning := Alert{ID: "w1", Event: "Tornado Warning", Severity: severityExtreme, Warning: true, Headline: "Tornado Warning issued"}
+	advisory := Alert{ID: "a1", Event: "Wind Advisory", Severity: severityMinor, Headline: "Wind Advisory issued"}
+	ctx := context.Background()
+
+	n := &alertNotifier{path: path}
+	n.notify(ctx, target, []Alert{warning, advisory})
+	if got := standIn.count(); got != 1 {
+		t.Fatalf("got %d notifications, want 1 for the warning and none for the minor advisory", got)
+	}
+	var sent AlertNotification
+	if err := json.Unmarshal([]byte(standIn.bodies[0]), &sent); err != nil {
+		t.Fatalf("notification isn't JSON: %v", err)
+	}
+	if sent.ID != "w1" || sent.Event != "Tornado Warning" || sent.Location != "Home" {
+		t.Errorf("got notification %+v, want the tornado warning for Home", sent)
+	}
+	if got := standIn.received[0].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+
+	// The same alert isn't sent again, while a new one is
+	update := Alert{ID: "w2", Event: "Severe Thunderstorm Warning", Severity: severitySevere, Warning: true}
+	n.notify(ctx, target, []Alert{warning, advisory, update})
+	if got := standIn.count(); got != 2 {
+		t.Fatalf("got %d notifications after a new alert, want 2", got)
+	}
+
+	// Nor after a restart
+	restarted := &alertNotifier{path: path}
+	restarted.notify(ctx, target, []Alert{warning, update})
+	if got := standIn.count(); got != 2 {
+		t.Errorf("got %d notifications after a restart, want still 2", got)
+	}
+}
+
+func TestAlertNotifierRetries(t *testing.T) {
+	standIn := &notifyStandIn{fail: true}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+	target := notifyTarget{url: server.URL, format: notifyJSON, minSeverity: severitySevere}
+	warning := Alert{ID: "w1", Event: "Tornado Warning", Severity: severityExtreme, Warning: true}
+
+	n := &alertNotifier{path: filepath.Join(t.TempDir(), "notified.json")}
+	n.notify(context.Background(), target, []Alert{warning})
+	standIn.mu.Lock()
+	standIn.fail = false
+	standIn.mu.Unlock()
+	n.notify(context.Background(), target, []Alert{warning})
+	if got := standIn.count(); got != 1 {
+		t.Errorf("got %d notifications, want the failed one sent on the next check", got)
+	}
+}
+
+func TestAlertNotifierNtfy(t *testing.T) {
+	standIn := &notifyStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+	target := notifyTarget{url: server.URL + "/weather-alerts", format: notifyNtfy, minSeverity: severityModerate, location: "Home", token: "tk_secret"}
+	expires := time.Date(2024, 7, 8, 21, 0, 0, 0, time.UTC)
+	alert := Alert{
+		ID: "w1", Event: "Tornado\nWarning", Severity: severityExtreme, Warning: true,
+		Headline: "Tornado Warning issued July 8 at 3:05PM CDT", Instruction: "Take cover now.", Expires: &expires,
+	}
+
+	n := &alertNotifier{path: filepath.Join(t.TempDir(), "notified.json")}
+	n.notify(context.Background(), target, []Alert{alert})
+	if standIn.count() != 1 {
+		t.Fatalf("got %d notifications, want 1", standIn.count())
+	}
+	r := standIn.received[0]
+	for header, want := range map[string]string{
+		"Title":         "Tornado Warning for Home",
+		"Priority":      "urgent",
+		"Tags":          "warning",
+		"Authorization": "Bearer tk_secret",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("got %s %q, want %q", header, got, want)
+		}
+	}
+	if want := "Tornado Warning issued July 8 at 3:05PM CDT\n\nTake cover now."; standIn.bodies[0] != want {
+		t.Errorf("got message %q, want %q", standIn.bodies[0], want)
+	}
+}
@@ -0,0 +1,503 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const nwsBase = "https://api.weather.gov"
+
+// nwsUserAgent identifies the dashboard, which the NWS API requires
+const nwsUserAgent = "TheManCaveDashboard/1.0 (home dashboard)"
+
+// maxObservationAge is the oldest station observation used for the current
+// conditions; older ones are replaced by the current hour's forecast
+const maxObservationAge = 3 * time.Hour
+
+// nwsPoints remembers the forecast office grid of each location, by "lat,lon"
+var nwsPoints sync.Map
+
+// nwsProvider reads the weather from the US National Weather Service, which
+// needs no key but only covers the US
+type nwsProvider struct{}
+
+// nwsPoint has the addresses of a location's forecasts
+type nwsPoint struct {
+	Forecast            string `json:"forecast"`
+	ForecastHourly      string `json:"forecastHourly"`
+	ForecastGridData    string `json:"forecastGridData"`
+	ObservationStations string `json:"observationStations"`
+}
+
+// nwsPeriod is a period of a forecast: an hour, or a day or night
+type nwsPeriod struct {
+	StartTime                  time.Time `json:"startTime"`
+	IsDaytime                  bool      `json:"isDaytime"`
+	Temperature                float64   `json:"temperature"` // °F
+	ProbabilityOfPrecipitation nwsValue  `json:"probabilityOfPrecipitation"`
+	RelativeHumidity           nwsValue  `json:"relativeHumidity"`
+	WindSpeed                  string    `json:"windSpeed"` // e.g. "10 mph" or "5 to 10 mph"
+	Icon                       string    `json:"icon"`
+	ShortForecast              string    `json:"shortForecast"`
+}
+
+// nwsValue is a quantity, null when unknown
+type nwsValue struct {
+	Value *float64 `json:"value"`
+}
+
+// nwsGridValues is a layer of gridpoint data: values over ISO 8601 intervals
+type nwsGridValues struct {
+	Values []struct {
+		ValidTime string   `json:"validTime"` // e.g. 2026-10-18T12:00:00+00:00/PT6H
+		Value     *float64 `json:"value"`
+	} `json:"values"`
+}
+
+func (p *nwsProvider) Name() string {
+	return "National Weather Service"
+}
+
+func (p *nwsProvider) Report(ctx context.Context, lat, lon float64, loc *time.Location) (*Report, error) {
+	point, err := p.point(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	var hourly struct {
+		Properties struct {
+			Periods []nwsPeriod `json:"periods"`
+		} `json:"properties"`
+	}
+	if err := getNWS(ctx, point.ForecastHourly, &hourly); err != nil {
+		return nil, err
+	}
+	var daily struct {
+		Properties struct {
+			Periods []nwsPeriod `json:"periods"`
+		} `json:"properties"`
+	}
+	if err := getNWS(ctx, point.Forecast, &daily); err != nil {
+		return nil, err
+	}
+	// Precipitation amounts and gusts are only in the raw grid, in SI units
+	var grid struct {
+		Properties struct {
+			QuantitativePrecipitation nwsGridValues `json:"quantitativePrecipitation"` // mm
+			WindGust                  nwsGridValues `json:"windGust"`                  // km/h
+		} `json:"properties"`
+	}
+	if err := getNWS(ctx, point.ForecastGridData, &grid); err != nil {
+		return nil, err
+	}
+	precip := hourlyValues(grid.Properties.QuantitativePrecipitation, true)
+	gusts := hourlyValues(grid.Properties.WindGust, false)
+
+	report := &Report{}
+	for _, period := range hourly.Properties.Periods {
+		hour := HourlyForecast{
+			Time:         period.StartTime,
+			Temp:         int(math.Round(period.Temperature)),
+			FeelsLike:    int(math.Round(period.Temperature)),
+			Humidity:     int(math.Round(value(period.RelativeHumidity.Value))),
+			WindSpeed:    nwsWindSpeed(period.WindSpeed),
+			WindGust:     round(kmhToMph(gusts[period.StartTime.Unix()]), 1),
+			PrecipChance: int(math.Round(value(period.ProbabilityOfPrecipitation.Value))),
+			PrecipAmount: mmToInches(precip[period.StartTime.Unix()]),
+		}
+		hour.Condition, hour.Description, hour.Icon = nwsCondition(period)
+		report.Hourly = append(report.Hourly, hour)
+	}
+	report.Daily = nwsDays(daily.Properties.Periods, report.Hourly, loc)
+	report.Current = p.current(ctx, point, report.Hourly)
+	return report, nil
+}
+
+// point looks up the forecast addresses of a location, which don't change
+func (p *nwsProvider) point(ctx context.Context, lat, lon float64) (*nwsPoint, error) {
+	// The API redirects more precise coordinates to four decimals
+	key := formatCoordinate(lat, 4) + "," + formatCoordinate(lon, 4)
+	if point, ok := nwsPoints.Load(key); ok {
+		return point.(*nwsPoint), nil
+	}
+	var points struct {
+		Properties nwsPoint `json:"properties"`
+	}
+	if err := getNWS(ctx, nwsBase+"/points/"+key, &points); err != nil {
+		return nil, err
+	}
+	point := &points.Properties
+	if point.ForecastHourly == "" || point.Forecast == "" {
+		return nil, fmt.Errorf("no NWS forecast for %s", key)
+	}
+	nwsPoints.Store(key, point)
+	return point, nil
+}
+
+// Alerts reads the active alerts for the point. Tests, exercises and
+// cancellations are left out.
+func (p *nwsProvider) Alerts(ctx context.Context, lat, lon float64) ([]Alert, error) {
+	var active struct {
+		Features []struct {
+			Properties struct {
+				ID          string     `json:"id"`
+				Event       string     `json:"event"`
+				Severity    string     `json:"severity"` // Extreme, Severe, Moderate, Minor or Unknown
+				Headline    string     `json:"headline"`
+				Description string     `json:"description"`
+				Instruction string     `json:"instruction"`
+				SenderName  string     `json:"senderName"`
+				Status      string     `json:"status"`      // Actual, Exercise, System, Test or Draft
+				MessageType string     `json:"messageType"` // Alert, Update or Cancel
+				Effective   *time.Time `json:"effective"`
+				Onset       *time.Time `json:"onset"`
+				Expires     *time.Time `json:"expires"`
+				Ends        *time.Time `json:"ends"`
+				References  []struct {
+					Identifier string `json:"identifier"`
+				} `json:"references"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	query := url.Values{"point": {formatCoordinate(lat, 4) + "," + formatCoordinate(lon, 4)}}
+	if err := getNWS(ctx, nwsBase+"/alerts/active?"+query.Encode(), &active); err != nil {
+		return nil, err
+	}
+
+	var found []Alert
+	for _, feature := range active.Features {
+		props := feature.Properties
+		if props.Status != "Actual" || props.MessageType == "Cancel" {
+			continue
+		}
+		severity := strings.ToLower(props.Severity)
+		if _, ok := severityRank[severity]; !ok {
+			severity = severityUnknown
+		}
+		alert := Alert{
+			ID:          props.ID,
+			Event:       props.Event,
+			Severity:    severity,
+			Warning:     isWarning(props.Event, severity),
+			Headline:    props.Headline,
+			Description: props.Description,
+			Instruction: props.Instruction,
+			Sender:      props.SenderName,
+			Onset:       props.Onset,
+			Expires:     props.Ends, // the end of the event, which the message often outlives
+		}
+		if alert.Headline == "" {
+			alert.Headline = props.Event
+		}
+		if alert.Onset == nil {
+			alert.Onset = props.Effective
+		}
+		if alert.Expires == nil {
+			alert.Expires = props.Expires
+		}
+		for _, ref := range props.References {
+			alert.replaces = append(alert.replaces, ref.Identifier)
+		}
+		found = append(found, alert)
+	}
+	return found, nil
+}
+
+// current returns the latest observation of the nearest station, or the current
+// hour's forecast when the station has none
+func (p *nwsProvider) current(ctx context.Context, point *nwsPoint, hours []HourlyForecast) Conditions {
+	var conditions Conditions
+	if len(hours) > 0 {
+		hour := hours[0]
+		conditions = Conditions{
+			Time:        hour.Time,
+			Temp:        float64(hour.Temp),
+			FeelsLike:   float64(hour.FeelsLike),
+			Humidity:    hour.Humidity,
+			WindSpeed:   hour.WindSpeed,
+			WindGust:    hour.WindGust,
+			Condition:   hour.Condition,
+			Description: hour.Description,
+			Icon:        hour.Icon,
+		}
+	}
+
+	var stations struct {
+		ObservationStations []string `json:"observationStations"`
+	}
+	if err := getNWS(ctx, point.ObservationStations, &stations); err != nil || len(stations.ObservationStations) == 0 {
+		return conditions
+	}
+	var latest struct {
+		Properties struct {
+			Timestamp        time.Time `json:"timestamp"`
+			TextDescription  string    `json:"textDescription"`
+			Icon             *string   `json:"icon"`
+			Temperature      nwsValue  `json:"temperature"` // °C
+			RelativeHumidity nwsValue  `json:"relativeHumidity"`
+			WindSpeed        nwsValue  `json:"windSpeed"` // km/h
+			WindGust         nwsValue  `json:"windGust"`
+			HeatIndex        nwsValue  `json:"heatIndex"`
+			WindChill        nwsValue  `json:"windChill"`
+		} `json:"properties"`
+	}
+	if err := getNWS(ctx, stations.ObservationStations[0]+"/observations/latest", &latest); err != nil {
+		return conditions
+	}
+	observed := latest.Properties
+	if observed.Temperature.Value == nil || time.Since(observed.Timestamp) > maxObservationAge {
+		return conditions
+	}
+
+	conditions.Time = observed.Timestamp
+	conditions.Temp = round(celsiusToFahrenheit(*observed.Temperature.Value), 1)
+	conditions.FeelsLike = conditions.Temp
+	for _, feels := range []nwsValue{observed.HeatIndex, observed.WindChill} {
+		if feels.Value != nil {
+			conditions.FeelsLike = round(celsiusToFahrenheit(*feels.Value), 1)
+		}
+	}
+	if observed.RelativeHumidity.Value != nil {
+		conditions.Humidity = int(math.Round(*observed.RelativeHumidity.Value))
+	}
+	if observed.WindSpeed.Value != nil {
+		conditions.WindSpeed = round(kmhToMph(*observed.WindSpeed.Value), 1)
+	}
+	conditions.WindGust = round(kmhToMph(value(observed.WindGust.Value)), 1)
+	if observed.Icon != nil && *observed.Icon != "" {
+		conditions.Condition, _, conditions.Icon = nwsIconCondition(*observed.Icon)
+		conditions.Description = observed.TextDescription
+	}
+	return conditions
+}
+
+// nwsDays turns the day and night periods of the forecast into days: the high
+// is the day's and the low the following night's. Precipitation amounts and
+// gusts come from the hours, as do the high of tonight alone and the low of a
+// last day without its night.
+func nwsDays(periods []nwsPeriod, hours []HourlyForecast, loc *time.Location) []DailyForecast {
+	type nwsDay struct {
+		DailyForecast
+		hasHigh, hasLow bool
+		hourHigh        int
+		hourLow         int
+		hasHours        bool
+	}
+	byDate := make(map[string]*nwsDay)
+	var dates []string
+	for _, period := range periods {
+		date := period.StartTime.In(loc).Format("2006-01-02")
+		day, ok := byDate[date]
+		if !ok {
+			day = &nwsDay{DailyForecast: DailyForecast{Date: date}}
+			byDate[date] = day
+			dates = append(dates, date)
+		}
+		temp := int(math.Round(period.Temperature))
+		day.PrecipChance = max(day.PrecipChance, int(math.Round(value(period.ProbabilityOfPrecipitation.Value))))
+		day.WindSpeed = max(day.WindSpeed, nwsWindSpeed(period.WindSpeed))
+		if period.IsDaytime {
+			day.High, day.hasHigh = temp, true
+			day.Condition, day.Description, day.Icon = nwsCondition(period)
+		} else {
+			day.Low, day.hasLow = temp, true
+			if day.Condition == "" {
+				day.Condition, day.Description, day.Icon = nwsCondition(period)
+			}
+		}
+	}
+
+	for _, hour := range hours {
+		day, ok := byDate[hour.Time.In(loc).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		day.PrecipAmount = round(day.PrecipAmount+hour.PrecipAmount, 2)
+		day.WindGust = max(day.WindGust, hour.WindGust)
+		day.Humidity = max(day.Humidity, hour.Humidity)
+		if !day.hasHours {
+			day.hourHigh, day.hourLow, day.hasHours = hour.Temp, hour.Temp, true
+		}
+		day.hourHigh, day.hourLow = max(day.hourHigh, hour.Temp), min(day.hourLow, hour.Temp)
+	}
+
+	days := make([]DailyForecast, 0, len(dates))
+	for _, date := range dates {
+		day := byDate[date]
+		if !day.hasHigh && day.hasHours {
+			day.High, day.hasHigh = max(day.hourHigh, day.Low), true
+		}
+		if !day.hasLow && day.hasHours {
+			day.Low, day.hasLow = min(day.hourLow, day.High), true
+		}
+		if !day.hasHigh || !day.hasLow {
+			continue
+		}
+		days = append(days, day.DailyForecast)
+	}
+	return days
+}
+
+// isoInterval matches a gridpoint interval: a start time and a duration of days
+// and hours
+var isoInterval = regexp.MustCompile(`^([^/]+)/P(?:(\d+)D)?(?:T(?:(\d+)H)?)?$`)
+
+// hourlyValues spreads gridpoint values over the hours of their intervals, by
+// Unix time. Amounts are divided between the hours; other values apply to each.
+func hourlyValues(layer nwsGridValues, amount bool) map[int64]float64 {
+	values := make(map[int64]float64)
+	for _, v := range layer.Values {
+		match := isoInterval.FindStringSubmatch(v.ValidTime)
+		if match == nil || v.Value == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, match[1])
+		if err != nil {
+			continue
+		}
+		days, _ := strconv.Atoi(match[2])
+		hours, _ := strconv.Atoi(match[3])
+		hours += days * 24
+		if hours == 0 {
+			continue
+		}
+		each := *v.Value
+		if amount {
+			each /= float64(hours)
+		}
+		for h := 0; h < hours; h++ {
+			values[start.Add(time.Duration(h)*time.Hour).Unix()] = each
+		}
+	}
+	return values
+}
+
+// getNWS reads an NWS API address
+func getNWS(ctx context.Context, address string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", nwsUserAgent)
+	req.Header.Set("Accept", "application/geo+json")
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWeatherBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors are problem details, e.g. for points outside the US
+		var problem struct {
+			Detail string `json:"detail"`
+		}
+		json.Unmarshal(body, &problem)
+		return fmt.Errorf("NWS returned %d for %s: %s", resp.StatusCode, strings.TrimPrefix(address, nwsBase), problem.Detail)
+	}
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("NWS %s: %w", strings.TrimPrefix(address, nwsBase), err)
+	}
+	return nil
+}
+
+// nwsWindSpeed reads a forecast wind speed, taking the higher end of a range
+func nwsWindSpeed(text string) float64 {
+	var speed float64
+	for _, field := range strings.Fields(text) {
+		if n, err := strconv.ParseFloat(field, 64); err == nil {
+			speed = max(speed, n)
+		}
+	}
+	return speed
+}
+
+// nwsCondition returns a forecast period's condition, its short forecast as the
+// description, and an icon code
+func nwsCondition(period nwsPeriod) (string, string, string) {
+	condition, _, icon := nwsIconCondition(period.Icon)
+	return condition, period.ShortForecast, icon
+}
+
+// nwsIcons maps the NWS icon names to an OpenWeatherMap condition, description
+// and icon (without its day or night suffix)
+var nwsIcons = map[string][3]string{
+	"skc":             {"Clear", "clear sky", "01"},
+	"few":             {"Clouds", "few clouds", "02"},
+	"sct":             {"Clouds", "scattered clouds", "03"},
+	"bkn":             {"Clouds", "broken clouds", "04"},
+	"ovc":             {"Clouds", "overcast", "04"},
+	"wind_skc":        {"Clear", "clear and windy", "01"},
+	"wind_few":        {"Clouds", "few clouds and windy", "02"},
+	"wind_sct":        {"Clouds", "scattered clouds and windy", "03"},
+	"wind_bkn":        {"Clouds", "broken clouds and windy", "04"},
+	"wind_ovc":        {"Clouds", "overcast and windy", "04"},
+	"snow":            {"Snow", "snow", "13"},
+	"rain_snow":       {"Snow", "rain and snow", "13"},
+	"rain_sleet":      {"Snow", "rain and sleet", "13"},
+	"snow_sleet":      {"Snow", "snow and sleet", "13"},
+	"fzra":            {"Rain", "freezing rain", "13"},
+	"rain_fzra":       {"Rain", "rain and freezing rain", "13"},
+	"snow_fzra":       {"Snow", "snow and freezing rain", "13"},
+	"sleet":           {"Snow", "sleet", "13"},
+	"rain":            {"Rain", "rain", "10"},
+	"rain_showers":    {"Rain", "rain showers", "09"},
+	"rain_showers_hi": {"Rain", "isolated rain showers", "09"},
+	"tsra":            {"Thunderstorm", "thunderstorms", "11"},
+	"tsra_sct":        {"Thunderstorm", "scattered thunderstorms", "11"},
+	"tsra_hi":         {"Thunderstorm", "isolated thunderstorms", "11"},
+	"tornado":         {"Tornado", "tornado", "50"},
+	"hurricane":       {"Tornado", "hurricane", "50"},
+	"tropical_storm":  {"Thunderstorm", "tropical storm", "11"},
+	"dust":            {"Dust", "dust", "50"},
+	"smoke":           {"Smoke", "smoke", "50"},
+	"haze":            {"Haze", "haze", "50"},
+	"hot":             {"Clear", "hot", "01"},
+	"cold":            {"Clear", "cold", "01"},
+	"blizzard":        {"Snow", "blizzard", "13"},
+	"fog":             {"Fog", "fog", "50"},
+}
+
+// nwsIconCondition reads an NWS icon address such as
+// https://api.weather.gov/icons/land/night/tsra_hi,40/sct?size=medium, whose
+// first name after day or night is the main condition
+func nwsIconCondition(icon string) (string, string, string) {
+	suffix := "d"
+	name := ""
+	parts := strings.Split(strings.SplitN(icon, "?", 2)[0], "/")
+	for i, part := range parts {
+		if (part == "day" || part == "night") && i+1 < len(parts) {
+			if part == "night" {
+				suffix = "n"
+			}
+			name = strings.SplitN(parts[i+1], ",", 2)[0]
+			break
+		}
+	}
+	condition, ok := nwsIcons[name]
+	if !ok {
+		condition = nwsIcons["skc"]
+	}
+	return condition[0], condition[1], condition[2] + suffix
+}
+
+func celsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func kmhToMph(kmh float64) float64 {
+	return kmh / 1.609344
+}
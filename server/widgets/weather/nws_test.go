@@ -0,0 +1,107 @@
+package weather
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nwsFiles are the recorded responses for a point in Chicago
+var nwsFiles = map[string]string{
+	"api.weather.gov/points/41.8781,-87.6298":              "nws-points.json",
+	"api.weather.gov/gridpoints/LOT/76,73/forecast":        "nws-forecast.json",
+	"api.weather.gov/gridpoints/LOT/76,73/forecast/hourly": "nws-forecast-hourly.json",
+	"api.weather.gov/gridpoints/LOT/76,73":                 "nws-gridpoints.json",
+	"api.weather.gov/gridpoints/LOT/76,73/stations":        "nws-stations.json",
+	"api.weather.gov/stations/KMDW/observations/latest":    "nws-observation.json",
+}
+
+// nwsHours are the hourly forecast of the recorded responses, with gusts and
+// precipitation from the grid
+func nwsHours(t *testing.T) []HourlyForecast {
+	hour := func(at string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	return []HourlyForecast{
+		{
+			Time: hour("2024-07-08T14:00:00-05:00"), Temp: 88, FeelsLike: 88, Humidity: 58, WindSpeed: 10, WindGust: 15,
+			PrecipChance: 10, Condition: "Clouds", Description: "Sunny", Icon: "02d",
+		},
+		{
+			// The grid's 5.08 mm over two hours is split between them
+			Time: hour("2024-07-08T15:00:00-05:00"), Temp: 87, FeelsLike: 87, Humidity: 61, WindSpeed: 15, WindGust: 25,
+			PrecipChance: 60, PrecipAmount: 0.1, Condition: "Thunderstorm", Description: "Chance Showers And Thunderstorms", Icon: "11d",
+		},
+		{
+			Time: hour("2024-07-08T16:00:00-05:00"), Temp: 85, FeelsLike: 85, Humidity: 66, WindSpeed: 5, WindGust: 25,
+			PrecipChance: 40, PrecipAmount: 0.1, Condition: "Rain", Description: "Chance Rain Showers", Icon: "09d",
+		},
+	}
+}
+
+func TestNWSReport(t *testing.T) {
+	nwsPoints.Delete("41.8781,-87.6298")
+	t.Cleanup(func() { nwsPoints.Delete("41.8781,-87.6298") })
+	standIn := &weatherStandIn{t: t, files: nwsFiles}
+	useStandIn(t, standIn)
+	loc := chicago(t)
+
+	report, err := (&nwsProvider{}).Report(context.Background(), 41.8781, -87.6298, loc)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	// The current conditions come from the nearest station's observation, in °C
+	// and km/h
+	if age := time.Since(report.Current.Time); age < 0 || age > time.Minute {
+		t.Errorf("current conditions are from %v, want the observation's time", report.Current.Time)
+	}
+	report.Current.Time = time.Time{}
+	hours := nwsHours(t)
+	checkReport(t, report, &Report{
+		Current: Conditions{
+			Temp: 88, FeelsLike: 94.1, Humidity: 52, WindSpeed: 10,
+			Condition: "Clouds", Description: "Partly Cloudy", Icon: "03d",
+		},
+		Hourly: hours,
+		Daily: []DailyForecast{
+			{
+				// The high is the afternoon's and the low tonight's
+				Date: "2024-07-08", High: 89, Low: 72, Humidity: 66, WindSpeed: 15, WindGust: 25,
+				PrecipChance: 60, PrecipAmount: 0.2, Condition: "Thunderstorm", Description: "Chance Showers And Thunderstorms", Icon: "11d",
+			},
+			{
+				Date: "2024-07-09", High: 84, Low: 68, WindSpeed: 10,
+				Condition: "Clear", Description: "Sunny", Icon: "01d",
+			},
+			// Wednesday has no night and no hours to take a low from
+		},
+	})
+}
+
+func TestNWSReportWithoutObservation(t *testing.T) {
+	nwsPoints.Delete("41.8781,-87.6298")
+	t.Cleanup(func() { nwsPoints.Delete("41.8781,-87.6298") })
+	files := make(map[string]string)
+	for path, file := range nwsFiles {
+		files[path] = file
+	}
+	delete(files, "api.weather.gov/stations/KMDW/observations/latest")
+	useStandIn(t, &weatherStandIn{t: t, files: files})
+
+	report, err := (&nwsProvider{}).Report(context.Background(), 41.8781, -87.6298, chicago(t))
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	// The current hour's forecast stands in for the observation
+	first := nwsHours(t)[0]
+	want := Conditions{
+		Time: first.Time, Temp: 88, FeelsLike: 88, Humidity: 58, WindSpeed: 10, WindGust: 15,
+		Condition: "Clouds", Description: "Sunny", Icon: "02d",
+	}
+	checkReport(t, &Report{Current: report.Current}, &Report{Current: want})
+}
@@ -0,0 +1,294 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	openMeteoURL    = "https://api.open-meteo.com/v1/forecast"
+	openMeteoAirURL = "https://air-quality-api.open-meteo.com/v1/air-quality"
+)
+
+// openMeteoProvider reads the weather from Open-Meteo, which needs no key
+type openMeteoProvider struct{}
+
+// openMeteoResponse holds Open-Meteo's variables as parallel arrays, with times
+// as Unix seconds. Values the model doesn't have are null.
+type openMeteoResponse struct {
+	Current struct {
+		Time        int64    `json:"time"`
+		Temp        float64  `json:"temperature_2m"`
+		FeelsLike   float64  `json:"apparent_temperature"`
+		Humidity    float64  `json:"relative_humidity_2m"`
+		WeatherCode int      `json:"weather_code"`
+		WindSpeed   float64  `json:"wind_speed_10m"`
+		WindGust    float64  `json:"wind_gusts_10m"`
+		UVIndex     *float64 `json:"uv_index"`
+		IsDay       int      `json:"is_day"`
+	} `json:"current"`
+	Hourly struct {
+		Time         []int64    `json:"time"`
+		Temp         []float64  `json:"temperature_2m"`
+		FeelsLike    []float64  `json:"apparent_temperature"`
+		Humidity     []float64  `json:"relative_humidity_2m"`
+		PrecipChance []*float64 `json:"precipitation_probability"`
+		Precip       []float64  `json:"precipitation"`
+		WeatherCode  []int      `json:"weather_code"`
+		WindSpeed    []float64  `json:"wind_speed_10m"`
+		WindGust     []float64  `json:"wind_gusts_10m"`
+		UVIndex      []*float64 `json:"uv_index"`
+		IsDay        []int      `json:"is_day"`
+	} `json:"hourly"`
+	Daily struct {
+		Time         []int64    `json:"time"` // local midnight
+		WeatherCode  []int      `json:"weather_code"`
+		High         []float64  `json:"temperature_2m_max"`
+		Low          []float64  `json:"temperature_2m_min"`
+		Humidity     []float64  `json:"relative_humidity_2m_mean"`
+		PrecipChance []*float64 `json:"precipitation_probability_max"`
+		Precip       []float64  `json:"precipitation_sum"`
+		WindSpeed    []float64  `json:"wind_speed_10m_max"`
+		WindGust     []float64  `json:"wind_gusts_10m_max"`
+		UVIndex      []*float64 `json:"uv_index_max"`
+		Sunrise      []int64    `json:"sunrise"`
+		Sunset       []int64    `json:"sunset"`
+	} `json:"daily"`
+}
+
+// openMeteoVariables are asked for in each section
+var openMeteoVariables = map[string][]string{
+	"current": {"temperature_2m", "apparent_temperature", "relative_humidity_2m", "weather_code", "wind_speed_10m", "wind_gusts_10m", "uv_index", "is_day"},
+	"hourly":  {"temperature_2m", "apparent_temperature", "relative_humidity_2m", "precipitation_probability", "precipitation", "weather_code", "wind_speed_10m", "wind_gusts_10m", "uv_index", "is_day"},
+	"daily":   {"weather_code", "temperature_2m_max", "temperature_2m_min", "relative_humidity_2m_mean", "precipitation_probability_max", "precipitation_sum", "wind_speed_10m_max", "wind_gusts_10m_max", "uv_index_max", "sunrise", "sunset"},
+}
+
+func (p *openMeteoProvider) Name() string {
+	return "Open-Meteo"
+}
+
+func (p *openMeteoProvider) Report(ctx context.Context, lat, lon float64, loc *time.Location) (*Report, error) {
+	// Days follow the dashboard timezone; "Local" isn't a zone name Open-Meteo knows
+	timezone := loc.String()
+	if timezone == "Local" {
+		timezone = "auto"
+	}
+	query := url.Values{
+		"latitude":           {formatCoordinate(lat, 4)},
+		"longitude":          {formatCoordinate(lon, 4)},
+		"temperature_unit":   {"fahrenheit"},
+		"wind_speed_unit":    {"mph"},
+		"precipitation_unit": {"inch"},
+		"timeformat":         {"unixtime"},
+		"timezone":           {timezone},
+		"forecast_days":      {fmt.Sprint(maxForecastDays)},
+		"forecast_hours":     {fmt.Sprint(maxForecastHours + 1)},
+	}
+	for section, variables := range openMeteoVariables {
+		query.Set(section, strings.Join(variables, ","))
+	}
+
+	var data openMeteoResponse
+	if err := getOpenMeteo(ctx, openMeteoURL, query, &data); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	current := data.Current
+	report.Current = Conditions{
+		Time:      time.Unix(current.Time, 0),
+		Temp:      current.Temp,
+		FeelsLike: current.FeelsLike,
+		Humidity:  int(math.Round(current.Humidity)),
+		WindSpeed: current.WindSpeed,
+		WindGust:  current.WindGust,
+		UVIndex:   roundedUV(current.UVIndex),
+	}
+	report.Current.Condition, report.Current.Description, report.Current.Icon = wmoCondition(current.WeatherCode, current.IsDay == 1)
+
+	hourly := data.Hourly
+	for i, t := range hourly.Time {
+		hour := HourlyForecast{
+			Time:         time.Unix(t, 0),
+			Temp:         int(math.Round(at(hourly.Temp, i))),
+			FeelsLike:    int(math.Round(at(hourly.FeelsLike, i))),
+			Humidity:     int(math.Round(at(hourly.Humidity, i))),
+			WindSpeed:    at(hourly.WindSpeed, i),
+			WindGust:     at(hourly.WindGust, i),
+			PrecipChance: int(math.Round(value(at(hourly.PrecipChance, i)))),
+			PrecipAmount: round(at(hourly.Precip, i), 2),
+			UVIndex:      roundedUV(at(hourly.UVIndex, i)),
+		}
+		hour.Condition, hour.Description, hour.Icon = wmoCondition(at(hourly.WeatherCode, i), at(hourly.IsDay, i) == 1)
+		report.Hourly = append(report.Hourly, hour)
+	}
+
+	daily := data.Daily
+	for i, t := range daily.Time {
+		day := DailyForecast{
+			Date:         time.Unix(t, 0).In(loc).Format("2006-01-02"),
+			High:         int(math.Round(at(daily.High, i))),
+			Low:          int(math.Round(at(daily.Low, i))),
+			Humidity:     int(math.Round(at(daily.Humidity, i))),
+			WindSpeed:    at(daily.WindSpeed, i),
+			WindGust:     at(daily.WindGust, i),
+			PrecipChance: int(math.Round(value(at(daily.PrecipChance, i)))),
+			PrecipAmount: round(at(daily.Precip, i), 2),
+			UVIndex:      roundedUV(at(daily.UVIndex, i)),
+			Sunrise:      unixTime(at(daily.Sunrise, i)),
+			Sunset:       unixTime(at(daily.Sunset, i)),
+		}
+		day.Condition, day.Description, day.Icon = wmoCondition(at(daily.WeatherCode, i), true)
+		report.Daily = append(report.Daily, day)
+	}
+	return report, nil
+}
+
+// AirQuality reads the current particles, ozone and pollen from the CAMS models
+// Open-Meteo serves. Pollen is only forecast for Europe, and is null elsewhere.
+func (p *openMeteoProvider) AirQuality(ctx context.Context, lat, lon float64) (*airReading, error) {
+	variables := []string{"pm2_5", "pm10", "ozone"}
+	for _, plant := range pollenPlants {
+		variables = append(variables, plant.kind+"_pollen")
+	}
+	query := url.Values{
+		"latitude":   {formatCoordinate(lat, 4)},
+		"longitude":  {formatCoordinate(lon, 4)},
+		"current":    {strings.Join(variables, ",")},
+		"timeformat": {"unixtime"},
+	}
+	var data struct {
+		Current map[string]*float64 `json:"current"`
+	}
+	if err := getOpenMeteo(ctx, openMeteoAirURL, query, &data); err != nil {
+		return nil, err
+	}
+
+	reading := &airReading{
+		Pollutants: Pollutants{
+			PM25: data.Current["pm2_5"],
+			PM10: data.Current["pm10"],
+			O3:   data.Current["ozone"],
+		},
+	}
+	if t := data.Current["time"]; t != nil {
+		reading.Time = time.Unix(int64(*t), 0)
+	}
+	for _, plant := range pollenPlants {
+		count := data.Current[plant.kind+"_pollen"]
+		if count == nil {
+			continue
+		}
+		reading.Pollen = append(reading.Pollen, Pollen{
+			Type:  plant.kind,
+			Name:  plant.name,
+			Count: round(*count, 1),
+			Level: pollenLevel(plant.group, *count),
+		})
+	}
+	return reading, nil
+}
+
+// getOpenMeteo calls an Open-Meteo API, decoding its response into dest
+func getOpenMeteo(ctx context.Context, endpoint string, query url.Values, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWeatherBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Reason string `json:"reason"`
+		}
+		json.Unmarshal(body, &failure)
+		return fmt.Errorf("Open-Meteo returned %d: %s", resp.StatusCode, failure.Reason)
+	}
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("Open-Meteo: %w", err)
+	}
+	return nil
+}
+
+// at returns values[i], or the zero value if the array is short
+func at[T any](values []T, i int) T {
+	var zero T
+	if i < len(values) {
+		return values[i]
+	}
+	return zero
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func roundedUV(uvi *float64) *float64 {
+	if uvi == nil {
+		return nil
+	}
+	return uvIndex(*uvi)
+}
+
+// wmoConditions maps WMO weather codes to an OpenWeatherMap condition,
+// description and icon (without its day or night suffix)
+var wmoConditions = map[int][3]string{
+	0:  {"Clear", "clear sky", "01"},
+	1:  {"Clouds", "mainly clear", "02"},
+	2:  {"Clouds", "partly cloudy", "03"},
+	3:  {"Clouds", "overcast", "04"},
+	45: {"Fog", "fog", "50"},
+	48: {"Fog", "freezing fog", "50"},
+	51: {"Drizzle", "light drizzle", "09"},
+	53: {"Drizzle", "drizzle", "09"},
+	55: {"Drizzle", "heavy drizzle", "09"},
+	56: {"Drizzle", "light freezing drizzle", "09"},
+	57: {"Drizzle", "freezing drizzle", "09"},
+	61: {"Rain", "light rain", "10"},
+	63: {"Rain", "moderate rain", "10"},
+	65: {"Rain", "heavy rain", "10"},
+	66: {"Rain", "light freezing rain", "13"},
+	67: {"Rain", "freezing rain", "13"},
+	71: {"Snow", "light snow", "13"},
+	73: {"Snow", "snow", "13"},
+	75: {"Snow", "heavy snow", "13"},
+	77: {"Snow", "snow grains", "13"},
+	80: {"Rain", "light rain showers", "09"},
+	81: {"Rain", "rain showers", "09"},
+	82: {"Rain", "violent rain showers", "09"},
+	85: {"Snow", "light snow showers", "13"},
+	86: {"Snow", "snow showers", "13"},
+	95: {"Thunderstorm", "thunderstorm", "11"},
+	96: {"Thunderstorm", "thunderstorm with light hail", "11"},
+	99: {"Thunderstorm", "thunderstorm with hail", "11"},
+}
+
+// wmoCondition returns the condition, description and icon code of a WMO code
+func wmoCondition(code int, day bool) (string, string, string) {
+	condition, ok := wmoConditions[code]
+	if !ok {
+		condition = wmoConditions[0]
+	}
+	suffix := "n"
+	if day {
+		suffix = "d"
+	}
+	return condition[0], condition[1], condition[2] + suffix
+}
@@ -0,0 +1,64 @@
+package weather
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenMeteoReport(t *testing.T) {
+	standIn := &weatherStandIn{t: t, files: map[string]string{
+		"api.open-meteo.com/v1/forecast": "openmeteo-forecast.json",
+	}}
+	useStandIn(t, standIn)
+	loc := chicago(t)
+
+	report, err := (&openMeteoProvider{}).Report(context.Background(), 41.8781, -87.6298, loc)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	query := standIn.request("api.open-meteo.com/v1/forecast").Query()
+	for name, want := range map[string]string{
+		"latitude": "41.8781", "longitude": "-87.6298", "timezone": "America/Chicago", "timeformat": "unixtime",
+		"temperature_unit": "fahrenheit", "wind_speed_unit": "mph", "precipitation_unit": "inch",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("asked with %s=%q, want %q", name, got, want)
+		}
+	}
+
+	// Null probabilities count as 0, and a null UV index stays unknown
+	checkReport(t, report, &Report{
+		Current: Conditions{
+			Time: unix(t, "2024-07-09T02:00:00Z"), Temp: 78.4, FeelsLike: 80.9, Humidity: 64,
+			WindSpeed: 6.8, WindGust: 14.3, Condition: "Rain", Description: "light rain", Icon: "10n",
+		},
+		Hourly: []HourlyForecast{
+			{
+				Time: unix(t, "2024-07-09T02:00:00Z"), Temp: 78, FeelsLike: 81, Humidity: 64, WindSpeed: 6.8, WindGust: 14.3,
+				PrecipChance: 45, PrecipAmount: 0.03, UVIndex: uv(0), Condition: "Rain", Description: "light rain", Icon: "10n",
+			},
+			{
+				Time: unix(t, "2024-07-09T03:00:00Z"), Temp: 77, FeelsLike: 79, Humidity: 69, WindSpeed: 5.9, WindGust: 12.1,
+				UVIndex: uv(0), Condition: "Clouds", Description: "overcast", Icon: "04n",
+			},
+			{
+				Time: unix(t, "2024-07-09T04:00:00Z"), Temp: 76, FeelsLike: 77, Humidity: 73, WindSpeed: 5.1, WindGust: 10,
+				PrecipChance: 20, Condition: "Clouds", Description: "partly cloudy", Icon: "03n",
+			},
+		},
+		Daily: []DailyForecast{
+			{
+				Date: "2024-07-08", High: 90, Low: 72, Humidity: 61, WindSpeed: 13.2, WindGust: 28.9,
+				PrecipChance: 80, PrecipAmount: 0.47, UVIndex: uv(8.1),
+				Sunrise: unixPtr(t, "2024-07-08T10:25:00Z"), Sunset: unixPtr(t, "2024-07-09T01:28:00Z"),
+				Condition: "Thunderstorm", Description: "thunderstorm", Icon: "11d",
+			},
+			{
+				Date: "2024-07-09", High: 84, Low: 69, Humidity: 49, WindSpeed: 9.4, WindGust: 17,
+				Sunrise: unixPtr(t, "2024-07-09T10:26:00Z"), Sunset: unixPtr(t, "2024-07-10T01:28:00Z"),
+				Condition: "Clouds", Description: "mainly clear", Icon: "02d",
+			},
+		},
+	})
+}
@@ -0,0 +1,479 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const openWeatherBase = "https://api.openweathermap.org"
+
+// oneCallRetry is how long to use the free endpoints after One Call refused the
+// key, before trying it again
+const oneCallRetry = 6 * time.Hour
+
+// maxWeatherBytes bounds a weather API response
+const maxWeatherBytes = 2 << 20
+
+// oneCallRefused is when One Call last answered that the key has no subscription
+var oneCallRefused struct {
+	sync.Mutex
+	at time.Time
+}
+
+// openWeatherError is a non-200 answer from OpenWeatherMap
+type openWeatherError struct {
+	path    string
+	status  int
+	message string
+}
+
+func (e *openWeatherError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.path, e.status, e.message)
+}
+
+// owWeather is the condition in OpenWeatherMap responses
+type owWeather struct {
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// owPrecip is the rain or snow of the last hour or three, in mm
+type owPrecip struct {
+	OneHour   float64 `json:"1h"`
+	ThreeHour float64 `json:"3h"`
+}
+
+// oneCallResponse is the response of One Call 3.0
+type oneCallResponse struct {
+	Current struct {
+		Dt        int64       `json:"dt"`
+		Temp      float64     `json:"temp"`
+		FeelsLike float64     `json:"feels_like"`
+		Humidity  int         `json:"humidity"`
+		UVI       float64     `json:"uvi"`
+		WindSpeed float64     `json:"wind_speed"`
+		WindGust  float64     `json:"wind_gust"`
+		Weather   []owWeather `json:"weather"`
+	} `json:"current"`
+	Hourly []struct {
+		Dt        int64       `json:"dt"`
+		Temp      float64     `json:"temp"`
+		FeelsLike float64     `json:"feels_like"`
+		Humidity  int         `json:"humidity"`
+		UVI       float64     `json:"uvi"`
+		WindSpeed float64     `json:"wind_speed"`
+		WindGust  float64     `json:"wind_gust"`
+		Pop       float64     `json:"pop"`
+		Rain      owPrecip    `json:"rain"`
+		Snow      owPrecip    `json:"snow"`
+		Weather   []owWeather `json:"weather"`
+	} `json:"hourly"`
+	Daily []struct {
+		Dt      int64 `json:"dt"`
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+		Temp    struct {
+			Min float64 `json:"min"`
+			Max float64 `json:"max"`
+		} `json:"temp"`
+		Humidity  int         `json:"humidity"`
+		WindSpeed float64     `json:"wind_speed"`
+		WindGust  float64     `json:"wind_gust"`
+		Pop       float64     `json:"pop"`
+		Rain      float64     `json:"rain"` // mm for the day
+		Snow      float64     `json:"snow"`
+		UVI       float64     `json:"uvi"`
+		Weather   []owWeather `json:"weather"`
+	} `json:"daily"`
+}
+
+// currentResponse is the response of /data/2.5/weather
+type currentResponse struct {
+	Dt   int64 `json:"dt"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Weather []owWeather `json:"weather"`
+	Wind    struct {
+		Speed float64 `json:"speed"`
+		Gust  float64 `json:"gust"`
+	} `json:"wind"`
+	Sys struct {
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+	} `json:"sys"`
+}
+
+// forecastResponse is the response of /data/2.5/forecast, in 3-hour steps
+type forecastResponse struct {
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp      float64 `json:"temp"`
+			FeelsLike float64 `json:"feels_like"`
+			Humidity  int     `json:"humidity"`
+		} `json:"main"`
+		Weather []owWeather `json:"weather"`
+		Wind    struct {
+			Speed float64 `json:"speed"`
+			Gust  float64 `json:"gust"`
+		} `json:"wind"`
+		Pop  float64  `json:"pop"`
+		Rain owPrecip `json:"rain"`
+		Snow owPrecip `json:"snow"`
+	} `json:"list"`
+}
+
+// openWeatherProvider reads the weather from OpenWeatherMap
+type openWeatherProvider struct {
+	apiKey string
+}
+
+func (p *openWeatherProvider) Name() string {
+	return "OpenWeatherMap"
+}
+
+// Report reads the weather from One Call 3.0, which has hourly and daily
+// forecasts with UV and sunrise. Keys without a One Call subscription get the
+// free current weather and 5-day, 3-hour forecast instead.
+func (p *openWeatherProvider) Report(ctx context.Context, latitude, longitude float64, loc *time.Location) (*Report, error) {
+	lat, lon := formatCoordinate(latitude, 4), formatCoordinate(longitude, 4)
+	oneCallRefused.Lock()
+	refused := time.Since(oneCallRefused.at) < oneCallRetry
+	oneCallRefused.Unlock()
+
+	if !refused {
+		report, err := fetchOneCall(ctx, p.apiKey, lat, lon, loc)
+		var apiErr *openWeatherError
+		if !errors.As(err, &apiErr) || apiErr.status != http.StatusUnauthorized {
+			return report, err
+		}
+		log.Printf("[Weather] One Call is not available for this key, using the 5-day forecast: %s", apiErr.message)
+		oneCallRefused.Lock()
+		oneCallRefused.at = time.Now()
+		oneCallRefused.Unlock()
+	}
+	return fetchFreeForecast(ctx, p.apiKey, lat, lon, loc)
+}
+
+// Alerts reads the alerts of One Call 3.0, which keys without a subscription
+// can't use
+func (p *openWeatherProvider) Alerts(ctx context.Context, latitude, longitude float64) ([]Alert, error) {
+	oneCallRefused.Lock()
+	refused := time.Since(oneCallRefused.at) < oneCallRetry
+	oneCallRefused.Unlock()
+	if refused {
+		return nil, errors.New("One Call is not available for this key")
+	}
+
+	var data struct {
+		Alerts []struct {
+			SenderName  string `json:"sender_name"`
+			Event       string `json:"event"`
+			Start       int64  `json:"start"`
+			End         int64  `json:"end"`
+			Description string `json:"description"`
+		} `json:"alerts"`
+	}
+	lat, lon := formatCoordinate(latitude, 4), formatCoordinate(longitude, 4)
+	query := url.Values{"exclude": {"current,minutely,hourly,daily"}}
+	if err := getOpenWeather(ctx, "/data/3.0/onecall", p.apiKey, lat, lon, query, &data); err != nil {
+		var apiErr *openWeatherError
+		if errors.As(err, &apiErr) && apiErr.status == http.StatusUnauthorized {
+			oneCallRefused.Lock()
+			oneCallRefused.at = time.Now()
+			oneCallRefused.Unlock()
+		}
+		return nil, err
+	}
+
+	found := make([]Alert, 0, len(data.Alerts))
+	for _, a := range data.Alerts {
+		// One Call alerts have no ID, severity or headline
+		severity := eventSeverity(a.Event)
+		alert := Alert{
+			ID:          alertID(a.SenderName, a.Event, a.Start),
+			Event:       a.Event,
+			Severity:    severity,
+			Warning:     isWarning(a.Event, severity),
+			Headline:    a.Event,
+			Description: strings.TrimSpace(a.Description),
+			Sender:      a.SenderName,
+			Onset:       unixTime(a.Start),
+			Expires:     unixTime(a.End),
+		}
+		if a.SenderName != "" {
+			alert.Headline = a.Event + " issued by " + a.SenderName
+		}
+		found = append(found, alert)
+	}
+	return found, nil
+}
+
+// AirQuality reads the Air Pollution API, which every key can use. It has no
+// pollen counts.
+func (p *openWeatherProvider) AirQuality(ctx context.Context, latitude, longitude float64) (*airReading, error) {
+	var data struct {
+		List []struct {
+			Dt         int64 `json:"dt"`
+			Components struct {
+				PM25 *float64 `json:"pm2_5"`
+				PM10 *float64 `json:"pm10"`
+				O3   *float64 `json:"o3"`
+			} `json:"components"` // µg/m³
+		} `json:"list"`
+	}
+	lat, lon := formatCoordinate(latitude, 4), formatCoordinate(longitude, 4)
+	if err := getOpenWeather(ctx, "/data/2.5/air_pollution", p.apiKey, lat, lon, nil, &data); err != nil {
+		return nil, err
+	}
+	if len(data.List) == 0 {
+		return nil, errors.New("/data/2.5/air_pollution: no readings")
+	}
+	current := data.List[0]
+	return &airReading{
+		Time: time.Unix(current.Dt, 0),
+		Pollutants: Pollutants{
+			PM25: current.Components.PM25,
+			PM10: current.Components.PM10,
+			O3:   current.Components.O3,
+		},
+	}, nil
+}
+
+func fetchOneCall(ctx context.Context, apiKey, lat, lon string, loc *time.Location) (*Report, error) {
+	var data oneCallResponse
+	query := url.Values{"exclude": {"minutely"}}
+	if err := getOpenWeather(ctx, "/data/3.0/onecall", apiKey, lat, lon, query, &data); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	current := data.Current
+	report.Current = Conditions{
+		Time:      time.Unix(current.Dt, 0),
+		Temp:      current.Temp,
+		FeelsLike: current.FeelsLike,
+		Humidity:  current.Humidity,
+		WindSpeed: current.WindSpeed,
+		WindGust:  current.WindGust,
+		UVIndex:   uvIndex(current.UVI),
+	}
+	report.Current.Condition, report.Current.Description, report.Current.Icon = condition(current.Weather)
+
+	for _, h := range data.Hourly {
+		hour := HourlyForecast{
+			Time:         time.Unix(h.Dt, 0),
+			Temp:         int(math.Round(h.Temp)),
+			FeelsLike:    int(math.Round(h.FeelsLike)),
+			Humidity:     h.Humidity,
+			WindSpeed:    h.WindSpeed,
+			WindGust:     h.WindGust,
+			PrecipChance: int(math.Round(h.Pop * 100)),
+			PrecipAmount: mmToInches(h.Rain.OneHour + h.Snow.OneHour),
+			UVIndex:      uvIndex(h.UVI),
+		}
+		hour.Condition, hour.Description, hour.Icon = condition(h.Weather)
+		report.Hourly = append(report.Hourly, hour)
+	}
+
+	for _, d := range data.Daily {
+		// A day's dt is its local noon, so it falls on the right date
+		day := DailyForecast{
+			Date:         time.Unix(d.Dt, 0).In(loc).Format("2006-01-02"),
+			High:         int(math.Round(d.Temp.Max)),
+			Low:          int(math.Round(d.Temp.Min)),
+			Humidity:     d.Humidity,
+			WindSpeed:    d.WindSpeed,
+			WindGust:     d.WindGust,
+			PrecipChance: int(math.Round(d.Pop * 100)),
+			PrecipAmount: mmToInches(d.Rain + d.Snow),
+			UVIndex:      uvIndex(d.UVI),
+			Sunrise:      unixTime(d.Sunrise),
+			Sunset:       unixTime(d.Sunset),
+		}
+		day.Condition, day.Description, day.Icon = condition(d.Weather)
+		report.Daily = append(report.Daily, day)
+	}
+	return report, nil
+}
+
+// fetchFreeForecast builds a report from the endpoints every key can use. The
+// forecast comes in 3-hour steps, which are added up into days; only today has
+// sunrise and sunset, and there is no UV index.
+func fetchFreeForecast(ctx context.Context, apiKey, lat, lon string, loc *time.Location) (*Report, error) {
+	var current currentResponse
+	if err := getOpenWeather(ctx, "/data/2.5/weather", apiKey, lat, lon, nil, &current); err != nil {
+		return nil, err
+	}
+	var forecast forecastResponse
+	if err := getOpenWeather(ctx, "/data/2.5/forecast", apiKey, lat, lon, nil, &forecast); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	report.Current = Conditions{
+		Time:      time.Unix(current.Dt, 0),
+		Temp:      current.Main.Temp,
+		FeelsLike: current.Main.FeelsLike,
+		Humidity:  current.Main.Humidity,
+		WindSpeed: current.Wind.Speed,
+		WindGust:  current.Wind.Gust,
+	}
+	report.Current.Condition, report.Current.Description, report.Current.Icon = condition(current.Weather)
+
+	for _, step := range forecast.List {
+		hour := HourlyForecast{
+			Time:         time.Unix(step.Dt, 0),
+			Temp:         int(math.Round(step.Main.Temp)),
+			FeelsLike:    int(math.Round(step.Main.FeelsLike)),
+			Humidity:     step.Main.Humidity,
+			WindSpeed:    step.Wind.Speed,
+			WindGust:     step.Wind.Gust,
+			PrecipChance: int(math.Round(step.Pop * 100)),
+			PrecipAmount: mmToInches(step.Rain.ThreeHour + step.Snow.ThreeHour),
+		}
+		hour.Condition, hour.Description, hour.Icon = condition(step.Weather)
+		report.Hourly = append(report.Hourly, hour)
+	}
+
+	report.Daily = dailyFromHours(report.Hourly, report.Current, loc)
+	if len(report.Daily) > 0 && report.Daily[0].Date == report.Current.Time.In(loc).Format("2006-01-02") {
+		report.Daily[0].Sunrise, report.Daily[0].Sunset = unixTime(current.Sys.Sunrise), unixTime(current.Sys.Sunset)
+	}
+	return report, nil
+}
+
+// dailyFromHours adds up forecast steps into days. Today's high and low include
+// the current temperature, as its earlier hours aren't in the forecast; later
+// days the forecast only partly covers are left out. A day's condition is that
+// of the step nearest its midday.
+func dailyFromHours(hours []HourlyForecast, current Conditions, loc *time.Location) []DailyForecast {
+	byDate := make(map[string]*DailyForecast)
+	middayGap := make(map[string]time.Duration)
+	steps := make(map[string]int)
+	var dates []string
+	add := func(t time.Time, temp int) *DailyForecast {
+		local := t.In(loc)
+		date := local.Format("2006-01-02")
+		day, ok := byDate[date]
+		if !ok {
+			day = &DailyForecast{Date: date, High: temp, Low: temp}
+			byDate[date] = day
+			dates = append(dates, date)
+			middayGap[date] = time.Duration(math.MaxInt64)
+		}
+		day.High, day.Low = max(day.High, temp), min(day.Low, temp)
+		return day
+	}
+
+	add(current.Time, int(math.Round(current.Temp)))
+	for _, hour := range hours {
+		day := add(hour.Time, hour.Temp)
+		steps[day.Date]++
+		day.Humidity = max(day.Humidity, hour.Humidity)
+		day.WindSpeed = max(day.WindSpeed, hour.WindSpeed)
+		day.WindGust = max(day.WindGust, hour.WindGust)
+		day.PrecipChance = max(day.PrecipChance, hour.PrecipChance)
+		day.PrecipAmount = round(day.PrecipAmount+hour.PrecipAmount, 2)
+
+		local := hour.Time.In(loc)
+		midday := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, loc)
+		if gap := local.Sub(midday).Abs(); gap < middayGap[day.Date] {
+			middayGap[day.Date] = gap
+			day.Condition, day.Description, day.Icon = hour.Condition, hour.Description, hour.Icon
+		}
+	}
+
+	sort.Strings(dates)
+	days := make([]DailyForecast, 0, len(dates))
+	for i, date := range dates {
+		// A whole day has 7 to 9 steps of 3 hours, depending on daylight saving
+		if i > 0 && steps[date] < 7 {
+			continue
+		}
+		day := byDate[date]
+		if day.Condition == "" {
+			day.Condition, day.Description, day.Icon = current.Condition, current.Description, current.Icon
+		}
+		days = append(days, *day)
+	}
+	return days
+}
+
+// getOpenWeather calls an OpenWeatherMap endpoint in imperial units
+func getOpenWeather(ctx context.Context, path, apiKey, lat, lon string, query url.Values, dest interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("lat", lat)
+	query.Set("lon", lon)
+	query.Set("appid", apiKey)
+	query.Set("units", "imperial")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openWeatherBase+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		// The error's URL includes the key, so only the cause is kept
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWeatherBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &failure)
+		return &openWeatherError{path: path, status: resp.StatusCode, message: failure.Message}
+	}
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// condition returns the main condition, its description and icon code
+func condition(weather []owWeather) (string, string, string) {
+	if len(weather) == 0 {
+		return "Clear", "", ""
+	}
+	return weather[0].Main, weather[0].Description, weather[0].Icon
+}
+
+func uvIndex(uvi float64) *float64 {
+	value := round(uvi, 1)
+	return &value
+}
+
+func unixTime(seconds int64) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0)
+	return &t
+}
@@ -0,0 +1,188 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// unix reads an RFC 3339 time as time.Unix returns it, in the local zone
+func unix(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Unix(parsed.Unix(), 0)
+}
+
+func unixPtr(t *testing.T, value string) *time.Time {
+	parsed := unix(t, value)
+	return &parsed
+}
+
+func uv(value float64) *float64 {
+	return &value
+}
+
+func chicago(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	return loc
+}
+
+// resetOneCall forgets that One Call refused the key, before and after a test
+func resetOneCall(t *testing.T) {
+	reset := func() {
+		oneCallRefused.Lock()
+		oneCallRefused.at = time.Time{}
+		oneCallRefused.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// checkReport compares a report's parts one by one, so a difference is easy to find
+func checkReport(t *testing.T, got, want *Report) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Current, want.Current) {
+		t.Errorf("current = %+v\nwant %+v", got.Current, want.Current)
+	}
+	if len(got.Hourly) != len(want.Hourly) {
+		t.Errorf("got %d hours, want %d", len(got.Hourly), len(want.Hourly))
+	}
+	for i := range min(len(got.Hourly), len(want.Hourly)) {
+		if !reflect.DeepEqual(got.Hourly[i], want.Hourly[i]) {
+			t.Errorf("hour %d = %+v\nwant %+v", i, got.Hourly[i], want.Hourly[i])
+		}
+	}
+	if len(got.Daily) != len(want.Daily) {
+		t.Errorf("got %d days, want %d: %+v", len(got.Daily), len(want.Daily), got.Daily)
+	}
+	for i := range min(len(got.Daily), len(want.Daily)) {
+		if !reflect.DeepEqual(got.Daily[i], want.Daily[i]) {
+			t.Errorf("day %d = %+v\nwant %+v", i, got.Daily[i], want.Daily[i])
+		}
+	}
+}
+
+func TestOpenWeatherOneCall(t *testing.T) {
+	resetOneCall(t)
+	standIn := &weatherStandIn{t: t, files: map[string]string{
+		"api.openweathermap.org/data/3.0/onecall": "owm-onecall.json",
+	}}
+	useStandIn(t, standIn)
+	loc := chicago(t)
+
+	provider := &openWeatherProvider{apiKey: "key"}
+	report, err := provider.Report(context.Background(), 41.87811, -87.62979, loc)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	query := standIn.request("api.openweathermap.org/data/3.0/onecall").Query()
+	for name, want := range map[string]string{"lat": "41.8781", "lon": "-87.6298", "appid": "key", "units": "imperial", "exclude": "minutely"} {
+		if got := query.Get(name); got != want {
+			t.Errorf("asked with %s=%q, want %q", name, got, want)
+		}
+	}
+
+	checkReport(t, report, &Report{
+		Current: Conditions{
+			Time: unix(t, "2024-07-08T19:00:00Z"), Temp: 88.63, FeelsLike: 94.1, Humidity: 58,
+			WindSpeed: 9.22, WindGust: 17.27, UVIndex: uv(7.8),
+			Condition: "Clouds", Description: "few clouds", Icon: "02d",
+		},
+		Hourly: []HourlyForecast{
+			{
+				Time: unix(t, "2024-07-08T19:00:00Z"), Temp: 89, FeelsLike: 94, Humidity: 58,
+				WindSpeed: 9.22, WindGust: 17.27, PrecipChance: 10, UVIndex: uv(7.8),
+				Condition: "Clouds", Description: "few clouds", Icon: "02d",
+			},
+			{
+				Time: unix(t, "2024-07-08T20:00:00Z"), Temp: 87, FeelsLike: 93, Humidity: 61,
+				WindSpeed: 11.5, WindGust: 21.9, PrecipChance: 62, PrecipAmount: 0.1, UVIndex: uv(5.1),
+				Condition: "Rain", Description: "light rain", Icon: "10d",
+			},
+		},
+		Daily: []DailyForecast{
+			{
+				Date: "2024-07-08", High: 90, Low: 72, Humidity: 55, WindSpeed: 12.1, WindGust: 24.6,
+				PrecipChance: 80, PrecipAmount: 0.25, UVIndex: uv(8.1),
+				Sunrise: unixPtr(t, "2024-07-08T10:25:00Z"), Sunset: unixPtr(t, "2024-07-09T01:28:00Z"),
+				Condition: "Rain", Description: "light rain", Icon: "10d",
+			},
+			{
+				Date: "2024-07-09", High: 84, Low: 68, Humidity: 48, WindSpeed: 8.3, WindGust: 14,
+				UVIndex: uv(9), Sunrise: unixPtr(t, "2024-07-09T10:26:00Z"), Sunset: unixPtr(t, "2024-07-10T01:28:00Z"),
+				Condition: "Clear", Description: "clear sky", Icon: "01d",
+			},
+		},
+	})
+}
+
+func TestOpenWeatherFreeForecast(t *testing.T) {
+	resetOneCall(t)
+	// A key without a One Call subscription gets a 401
+	standIn := &weatherStandIn{t: t,
+		fail: map[string]int{"api.openweathermap.org/data/3.0/onecall": http.StatusUnauthorized},
+		files: map[string]string{
+			"api.openweathermap.org/data/2.5/weather":  "owm-weather.json",
+			"api.openweathermap.org/data/2.5/forecast": "owm-forecast.json",
+		},
+	}
+	useStandIn(t, standIn)
+	loc := chicago(t)
+
+	provider := &openWeatherProvider{apiKey: "key"}
+	report, err := provider.Report(context.Background(), 41.8781, -87.6298, loc)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if query := standIn.request("api.openweathermap.org/data/2.5/forecast").Query(); query.Get("units") != "imperial" {
+		t.Errorf("asked for the forecast in %q units, want imperial", query.Get("units"))
+	}
+
+	wantCurrent := Conditions{
+		Time: unix(t, "2024-07-08T19:00:00Z"), Temp: 91.4, FeelsLike: 97.2, Humidity: 52,
+		WindSpeed: 8.05, WindGust: 15.01, Condition: "Clear", Description: "clear sky", Icon: "01d",
+	}
+	if !reflect.DeepEqual(report.Current, wantCurrent) {
+		t.Errorf("current = %+v\nwant %+v", report.Current, wantCurrent)
+	}
+	if len(report.Hourly) != 13 {
+		t.Errorf("got %d forecast steps, want 13", len(report.Hourly))
+	}
+
+	// Today's high is the current temperature, above the rest of the day's
+	// forecast. The 10th only has two steps, so it is left out.
+	want := []DailyForecast{
+		{
+			Date: "2024-07-08", High: 91, Low: 79, Humidity: 54, WindSpeed: 7, WindGust: 11, PrecipChance: 20,
+			Sunrise: unixPtr(t, "2024-07-08T10:25:00Z"), Sunset: unixPtr(t, "2024-07-09T01:28:00Z"),
+			Condition: "Clouds", Description: "scattered clouds", Icon: "03d",
+		},
+		{
+			// The condition is the 13:00 step's, the nearest to midday
+			Date: "2024-07-09", High: 89, Low: 71, Humidity: 70, WindSpeed: 15, WindGust: 19,
+			PrecipChance: 70, PrecipAmount: 0.17, Condition: "Rain", Description: "light rain", Icon: "10d",
+		},
+	}
+	checkReport(t, &Report{Current: report.Current, Daily: report.Daily}, &Report{Current: wantCurrent, Daily: want})
+
+	// One Call isn't asked again for a while
+	standIn.requests = nil
+	if _, err := provider.Report(context.Background(), 41.8781, -87.6298, loc); err != nil {
+		t.Fatalf("Report again: %v", err)
+	}
+	for _, u := range standIn.requests {
+		if u.Path == "/data/3.0/onecall" {
+			t.Errorf("One Call was asked again right after refusing the key")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"themancavedashboard/shared"
+)
+
+// WeatherProvider is somewhere the weather comes from. Each converts its data
+// into a Report, in °F, mph and inches.
+type WeatherProvider interface {
+	// Name describes the provider in logs and responses
+	Name() string
+	// Report returns the current conditions and forecast for a location, with
+	// days in loc
+	Report(ctx context.Context, lat, lon float64, loc *time.Location) (*Report, error)
+}
+
+// Weather provider types
+const (
+	providerOpenWeather = "openweathermap"
+	providerOpenMeteo   = "open-meteo"
+	providerNWS         = "nws"
+)
+
+// openWeatherKeyEnv holds the OpenWeatherMap API key
+const openWeatherKeyEnv = "OPENWEATHER_API_KEY"
+
+// configuredProviders creates the configured provider and fallback, if one is
+// set. Without a "provider" config, OpenWeatherMap is used when its key is set
+// and Open-Meteo, which needs none, otherwise.
+func configuredProviders() ([]WeatherProvider, error) {
+	primary := shared.GetWidgetConfigValue("weather", "provider", "")
+	if primary == "" {
+		primary = providerOpenMeteo
+		if os.Getenv(openWeatherKeyEnv) != "" {
+			primary = providerOpenWeather
+		}
+	}
+	provider, err := newProvider(primary)
+	if err != nil {
+		return nil, err
+	}
+	providers := []WeatherProvider{provider}
+
+	if fallback := shared.GetWidgetConfigValue("weather", "fallback_provider", ""); fallback != "" && fallback != primary {
+		provider, err := newProvider(fallback)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+func newProvider(name string) (WeatherProvider, error) {
+	switch name {
+	case providerOpenWeather:
+		apiKey := os.Getenv(openWeatherKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf("%w: %s is not set", errNotConfigured, openWeatherKeyEnv)
+		}
+		return &openWeatherProvider{apiKey: apiKey}, nil
+	case providerOpenMeteo:
+		return &openMeteoProvider{}, nil
+	case providerNWS:
+		return &nwsProvider{}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown provider %q", errNotConfigured, name)
+}
+
+// fetchReport asks each provider in turn, returning the first report. If they
+// all fail, the first provider's error is returned.
+func fetchReport(ctx context.Context, providers []WeatherProvider, lat, lon float64, loc *time.Location) (*Report, error) {
+	var firstErr error
+	for i, provider := range providers {
+		report, err := provider.Report(ctx, lat, lon, loc)
+		if err == nil {
+			report.Provider = provider.Name()
+			return report, nil
+		}
+		if i < len(providers)-1 {
+			log.Printf("[Weather] Error fetching from %s, trying %s: %v", provider.Name(), providers[i+1].Name(), err)
+		} else {
+			log.Printf("[Weather] Error fetching from %s: %v", provider.Name(), err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// cacheKey identifies what is fetched from providers for a location
+func cacheKey[P interface{ Name() string }](providers []P, lat, lon float64) string {
+	key := ""
+	for _, provider := range providers {
+		key += provider.Name() + ","
+	}
+	return key + formatCoordinate(lat, 4) + "," + formatCoordinate(lon, 4)
+}
+
+// formatCoordinate writes a coordinate with at most the given decimals, without
+// trailing zeros
+func formatCoordinate(value float64, decimals int) string {
+	return strconv.FormatFloat(round(value, decimals), 'f', -1, 64)
+}
@@ -0,0 +1,60 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFetchReportFallback(t *testing.T) {
+	resetOneCall(t)
+	standIn := &weatherStandIn{t: t,
+		fail:  map[string]int{"api.openweathermap.org/data/3.0/onecall": http.StatusInternalServerError},
+		files: map[string]string{"api.open-meteo.com/v1/forecast": "openmeteo-forecast.json"},
+	}
+	useStandIn(t, standIn)
+	loc := chicago(t)
+	providers := []WeatherProvider{&openWeatherProvider{apiKey: "key"}, &openMeteoProvider{}}
+
+	report, err := fetchReport(context.Background(), providers, 41.8781, -87.6298, loc)
+	if err != nil {
+		t.Fatalf("fetchReport: %v", err)
+	}
+	if report.Provider != "Open-Meteo" {
+		t.Errorf("got the report from %q, want the fallback Open-Meteo", report.Provider)
+	}
+	if len(report.Daily) != 2 || report.Daily[0].High != 90 {
+		t.Errorf("got days %+v, want Open-Meteo's", report.Daily)
+	}
+
+	// When every provider fails, the first one's error is the one returned
+	delete(standIn.files, "api.open-meteo.com/v1/forecast")
+	_, err = fetchReport(context.Background(), providers, 41.8781, -87.6298, loc)
+	if err == nil || !strings.Contains(err.Error(), "/data/3.0/onecall returned 500") {
+		t.Errorf("got error %v, want OpenWeatherMap's", err)
+	}
+}
+
+func TestFetchReportPrimary(t *testing.T) {
+	resetOneCall(t)
+	standIn := &weatherStandIn{t: t, files: map[string]string{
+		"api.openweathermap.org/data/3.0/onecall": "owm-onecall.json",
+		"api.open-meteo.com/v1/forecast":          "openmeteo-forecast.json",
+	}}
+	useStandIn(t, standIn)
+	providers := []WeatherProvider{&openWeatherProvider{apiKey: "key"}, &openMeteoProvider{}}
+
+	report, err := fetchReport(context.Background(), providers, 41.8781, -87.6298, chicago(t))
+	if err != nil {
+		t.Fatalf("fetchReport: %v", err)
+	}
+	if report.Provider != "OpenWeatherMap" {
+		t.Errorf("got the report from %q, want OpenWeatherMap", report.Provider)
+	}
+	for _, u := range standIn.requests {
+		if u.Host == "api.open-meteo.com" {
+			t.Errorf("the fallback was asked although OpenWeatherMap answered")
+		}
+	}
+}
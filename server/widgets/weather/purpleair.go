@@ -0,0 +1,98 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// purpleAirProvider reads the air quality from a PurpleAir sensor on the local
+// network, which measures particles but not ozone or pollen
+type purpleAirProvider struct {
+	url string // the sensor's JSON endpoint, e.g. http://192.168.1.50/json
+}
+
+// purpleAirResponse is part of the sensor's /json response. Sensors with two
+// laser counters report the second one's values with a _b suffix.
+type purpleAirResponse struct {
+	DateTime string   `json:"DateTime"` // e.g. 2026/10/18T17:04:36z
+	PM25A    *float64 `json:"pm2_5_atm"`
+	PM25B    *float64 `json:"pm2_5_atm_b"`
+	PM10A    *float64 `json:"pm10_0_atm"`
+	PM10B    *float64 `json:"pm10_0_atm_b"`
+}
+
+func (p *purpleAirProvider) Name() string {
+	return "PurpleAir"
+}
+
+// AirQuality reads the sensor, averaging its counters. The location is that of
+// the sensor, so lat and lon are unused.
+func (p *purpleAirProvider) AirQuality(ctx context.Context, lat, lon float64) (*airReading, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, purpleAirEndpoint(p.url), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWeatherBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PurpleAir sensor returned %d", resp.StatusCode)
+	}
+	var data purpleAirResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("PurpleAir sensor: %w", err)
+	}
+
+	reading := &airReading{
+		Pollutants: Pollutants{
+			PM25: average(data.PM25A, data.PM25B),
+			PM10: average(data.PM10A, data.PM10B),
+		},
+	}
+	if reading.Pollutants.PM25 == nil && reading.Pollutants.PM10 == nil {
+		return nil, fmt.Errorf("PurpleAir sensor has no particle readings")
+	}
+	if t, err := time.Parse("2006/01/02T15:04:05z", data.DateTime); err == nil {
+		reading.Time = t
+	}
+	return reading, nil
+}
+
+// purpleAirEndpoint completes a sensor address like 192.168.1.50 into the URL
+// of its JSON endpoint
+func purpleAirEndpoint(address string) string {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	if strings.Count(address, "/") == 2 {
+		address += "/json"
+	}
+	return address
+}
+
+// average returns the mean of the values that are set, or nil if none are
+func average(values ...*float64) *float64 {
+	sum, n := 0.0, 0
+	for _, value := range values {
+		if value != nil {
+			sum += *value
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	mean := round(sum/float64(n), 1)
+	return &mean
+}
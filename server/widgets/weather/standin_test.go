@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// weatherStandIn serves weather APIs' recorded responses from testdata, by host
+// and path, e.g. api.weather.gov/points/41.8781,-87.6298. Paths listed in fail
+// answer with that status; anything else is a 404. "{{now}}" in a response is
+// replaced by the current time, for data that must be recent.
+type weatherStandIn struct {
+	t        *testing.T
+	files    map[string]string
+	fail     map[string]int
+	requests []*url.URL
+}
+
+func (s *weatherStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Host + r.URL.Path
+	s.requests = append(s.requests, &url.URL{Host: r.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery})
+	if status, ok := s.fail[key]; ok {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"recorded failure","reason":"recorded failure","detail":"recorded failure"}`))
+		return
+	}
+	file, ok := s.files[key]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		s.t.Errorf("reading recorded response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body := strings.ReplaceAll(string(data), "{{now}}", time.Now().UTC().Format(time.RFC3339))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+// request returns the first request the stand-in had for host and path
+func (s *weatherStandIn) request(key string) *url.URL {
+	for _, u := range s.requests {
+		if u.Host+u.Path == key {
+			return u
+		}
+	}
+	s.t.Errorf("no request for %s", key)
+	return &url.URL{}
+}
+
+// useStandIn sends the weather HTTP client's requests, whatever their host, to
+// a server running the stand-in until the test ends
+func useStandIn(t *testing.T, standIn *weatherStandIn) {
+	t.Helper()
+	server := httptest.NewServer(standIn)
+	target, _ := url.Parse(server.URL)
+	client := weatherHTTPClient
+	weatherHTTPClient = &http.Client{Transport: redirectTransport{target}}
+	t.Cleanup(func() {
+		weatherHTTPClient = client
+		server.Close()
+	})
+}
+
+// redirectTransport sends requests to target, keeping their Host header
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme, req.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
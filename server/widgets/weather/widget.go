@@ -1,51 +1,45 @@
 package weather
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
+	"time"
 
 	"themancavedashboard/shared"
+	"themancavedashboard/shared/openapi"
+	"themancavedashboard/shared/units"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// WeatherWidget handles weather data via OpenWeatherMap API
-type WeatherWidget struct {
-	apiKey string
-	lat    string
-	lon    string
-}
+// weatherHTTPTimeout bounds one weather API request
+const weatherHTTPTimeout = 15 * time.Second
+
+var weatherHTTPClient = &http.Client{Timeout: weatherHTTPTimeout}
+
+// errNotConfigured is returned when the provider or location is missing
+var errNotConfigured = errors.New("weather API not configured")
+
+// WeatherWidget handles weather data from the configured WeatherProvider
+type WeatherWidget struct{}
 
 // WeatherResponse is the data sent to the frontend
 type WeatherResponse struct {
-	Temp      int     `json:"temp"`
-	FeelsLike int     `json:"feelsLike"`
-	High      int     `json:"high"`
-	Low       int     `json:"low"`
-	Humidity  int     `json:"humidity"`
-	WindSpeed float64 `json:"windSpeed"`
-	Condition string  `json:"condition"`
-}
-
-// OpenWeatherResponse is the response from OpenWeatherMap API
-type OpenWeatherResponse struct {
-	Main struct {
-		Temp      float64 `json:"temp"`
-		FeelsLike float64 `json:"feels_like"`
-		TempMin   float64 `json:"temp_min"`
-		TempMax   float64 `json:"temp_max"`
-		Humidity  int     `json:"humidity"`
-	} `json:"main"`
-	Weather []struct {
-		Main        string `json:"main"`
-		Description string `json:"description"`
-	} `json:"weather"`
-	Wind struct {
-		Speed float64 `json:"speed"`
-	} `json:"wind"`
+	Temp      int         `json:"temp"`
+	FeelsLike int         `json:"feelsLike"`
+	High      int         `json:"high"` // today's forecast high and low
+	Low       int         `json:"low"`
+	Humidity  int         `json:"humidity"`
+	WindSpeed float64     `json:"windSpeed"`
+	Condition string      `json:"condition"`
+	Icon      string      `json:"icon,omitempty"` // OpenWeatherMap icon code, e.g. 10d, which every provider maps to
+	Provider  string      `json:"provider,omitempty"`
+	Units     units.Units `json:"units"`
 }
 
 // ID returns the widget identifier
@@ -53,85 +47,248 @@ func (w *WeatherWidget) ID() string {
 	return "weather"
 }
 
-// GetRequiredEnvVars returns required environment variables
+// GetRequiredEnvVars returns required environment variables. Only
+// OpenWeatherMap needs a key, and it is only required once chosen explicitly.
 func (w *WeatherWidget) GetRequiredEnvVars() []string {
-	return []string{
-		"OPENWEATHER_API_KEY",
+	if shared.GetWidgetConfigValue("weather", "provider", "") == providerOpenWeather {
+		return []string{openWeatherKeyEnv}
 	}
+	return []string{}
 }
 
-// Initialize loads configuration
+// ConfigSchema describes the "weather" section of config.json
+func (w *WeatherWidget) ConfigSchema() shared.ConfigSchema {
+	return shared.ConfigSchema{
+		Section: "weather",
+		Fields: append([]shared.ConfigField{
+			{Key: "latitude", Type: "string", Required: true, Description: "Location latitude"},
+			{Key: "longitude", Type: "string", Required: true, Description: "Location longitude"},
+			{Key: "location_name", Type: "string", Description: "Display name for the location"},
+			{Key: "provider", Type: "string", Description: "openweathermap, open-meteo or nws (default openweathermap when OPENWEATHER_API_KEY is set, open-meteo otherwise)"},
+			{Key: "fallback_provider", Type: "string", Description: "Provider to use when the first one fails"},
+			{Key: "air_provider", Type: "string", Description: "openweathermap, open-meteo or purpleair (default purpleair when purpleair_url is set, then openweathermap when OPENWEATHER_API_KEY is set, open-meteo otherwise)"},
+			{Key: "purpleair_url", Type: "string", Description: "Address of a PurpleAir sensor on the local network, e.g. http://192.168.1.50/json"},
+			{Key: "aqi_scale", Type: "string", Description: "Air quality index to show: us or eu (default us)"},
+			{Key: "alert_notify_url", Type: "string", Description: "Webhook or ntfy topic URL each new alert is POSTed to once; a bearer token can be set in ALERT_NOTIFY_TOKEN"},
+			{Key: "alert_notify_format", Type: "string", Description: "json (default) to POST the alert as JSON, or ntfy to send it as an ntfy message"},
+			{Key: "alert_notify_min_severity", Type: "string", Description: "Least severe alert to notify about: extreme, severe, moderate, minor or unknown (default severe)"},
+		}, units.ConfigFields(units.TemperatureKey, units.SpeedKey, units.RainfallKey)...),
+	}
+}
+
+// Initialize starts checking for alerts to notify about
 func (w *WeatherWidget) Initialize() error {
-	w.apiKey = os.Getenv("OPENWEATHER_API_KEY")
+	go w.watchAlerts()
 	return nil
 }
 
 // RegisterRoutes registers HTTP endpoints
 func (w *WeatherWidget) RegisterRoutes(r chi.Router) {
 	r.Get("/weather", w.getData)
+	r.Get("/weather/forecast", w.getForecast)
+	r.Get("/weather/alerts", w.getAlerts)
+	r.Get("/weather/air", w.getAir)
+}
+
+// APIRoutes documents the endpoints registered in RegisterRoutes
+func (w *WeatherWidget) APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/weather",
+			Summary:     "Current weather conditions",
+			Description: "high and low are today's forecast. Temperatures and wind speeds are in the configured units, named in units. provider names the provider the data came from.",
+			Response:    WeatherResponse{},
+			Errors:      []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/weather/forecast",
+			Summary: "Hourly and daily forecast",
+			Description: "Up to 48 hours and 10 days in the configured units, with precipitation chance and amount, wind gusts, UV index and sunrise/sunset, as far as the provider has them. " +
+				"OpenWeatherMap keys without a One Call subscription get the free 5-day forecast, in 3-hour steps with no UV index and sunrise/sunset for today only; NWS has no UV index or sunrise/sunset. " +
+				"If the provider fails, the fallback provider is asked. Fetched weather is reused for 10 minutes.",
+			Query: []openapi.Param{
+				{Name: "hours", Type: "integer", Description: fmt.Sprintf("Hours from the current one, 0 to %d (default %d)", maxForecastHours, maxForecastHours)},
+				{Name: "days", Type: "integer", Description: fmt.Sprintf("Days from today, 0 to %d (default %d)", maxForecastDays, maxForecastDays)},
+			},
+			Response: Forecast{},
+			Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/weather/alerts",
+			Summary: "Active weather alerts",
+			Description: "Alerts in effect or expected at the location, from OpenWeatherMap One Call 3.0 or the US National Weather Service, which is asked when the provider has no alerts. " +
+				"Each alert appears once, in its latest version, with warnings first and then by severity. warning marks severe warnings, which the dashboard shows as a banner. " +
+				"Fetched alerts are reused for 5 minutes. With alert_notify_url set, new alerts are also sent there once each, checked every 5 minutes.",
+			Response: AlertsResponse{},
+			Errors:   []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/weather/air",
+			Summary: "Air quality and pollen",
+			Description: "Current PM2.5, PM10 and ozone in µg/m³ from OpenWeatherMap, Open-Meteo or a local PurpleAir sensor, with the US EPA AQI (0-500) and the European index (levels 1-6) computed from them, and pollen counts where Open-Meteo has them (Europe). " +
+				"What the provider lacks, such as ozone from a PurpleAir sensor, is filled in from Open-Meteo, which is also asked if the provider fails. scale is the index the dashboard shows. " +
+				"Fetched air quality is reused for 10 minutes.",
+			Response: AirQuality{},
+			Errors:   []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+	}
 }
 
 // getData handles GET /api/weather
 func (w *WeatherWidget) getData(rw http.ResponseWriter, r *http.Request) {
-	// Get lat/lon from widget config using shared helper
-	lat := shared.GetWidgetConfigValue("weather", "latitude", "")
-	lon := shared.GetWidgetConfigValue("weather", "longitude", "")
+	loc := shared.GetLocation()
+	report, err := w.report(r.Context(), loc)
+	if err != nil {
+		writeWeatherError(rw, err)
+		return
+	}
 
-	// Fall back to env vars if not in config
-	if lat == "" {
-		lat = os.Getenv("WEATHER_LAT")
+	u := units.For("weather")
+	temp := func(fahrenheit float64) int {
+		return int(math.Round(u.ConvertTemperature(fahrenheit, units.Fahrenheit)))
 	}
-	if lon == "" {
-		lon = os.Getenv("WEATHER_LON")
+	current := report.Current
+	response := WeatherResponse{
+		Temp:      temp(current.Temp),
+		FeelsLike: temp(current.FeelsLike),
+		High:      temp(current.Temp),
+		Low:       temp(current.Temp),
+		Humidity:  current.Humidity,
+		WindSpeed: round(u.ConvertSpeed(current.WindSpeed, units.MilesPerHour), 1),
+		Condition: current.Condition,
+		Icon:      current.Icon,
+		Provider:  report.Provider,
+		Units:     u,
+	}
+	if today, ok := report.today(loc); ok {
+		response.High, response.Low = temp(float64(today.High)), temp(float64(today.Low))
 	}
 
-	if w.apiKey == "" || lat == "" || lon == "" {
-		http.Error(rw, `{"error":"Weather API not configured"}`, http.StatusServiceUnavailable)
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+// getForecast handles GET /api/weather/forecast
+func (w *WeatherWidget) getForecast(rw http.ResponseWriter, r *http.Request) {
+	hours, ok := countParam(rw, r, "hours", maxForecastHours)
+	if !ok {
+		return
+	}
+	days, ok := countParam(rw, r, "days", maxForecastDays)
+	if !ok {
 		return
 	}
 
-	url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/weather?lat=%s&lon=%s&appid=%s&units=imperial", lat, lon, w.apiKey)
-
-	resp, err := http.Get(url)
+	loc := shared.GetLocation()
+	report, err := w.report(r.Context(), loc)
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to fetch weather data"}`, http.StatusInternalServerError)
+		writeWeatherError(rw, err)
 		return
 	}
-	defer resp.Body.Close()
+	forecast := report.forecast(hours, days, loc)
+	forecast.convert(units.For("weather"))
+	shared.WriteJSON(rw, http.StatusOK, forecast)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// getAlerts handles GET /api/weather/alerts
+func (w *WeatherWidget) getAlerts(rw http.ResponseWriter, r *http.Request) {
+	response, err := w.alerts(r.Context())
 	if err != nil {
-		http.Error(rw, `{"error":"Failed to read weather response"}`, http.StatusInternalServerError)
+		writeWeatherError(rw, err)
 		return
 	}
+	loc := shared.GetLocation()
+	for i := range response.Alerts {
+		alert := &response.Alerts[i]
+		alert.Onset, alert.Expires = inLocation(alert.Onset, loc), inLocation(alert.Expires, loc)
+	}
+	shared.WriteJSON(rw, http.StatusOK, response)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		http.Error(rw, fmt.Sprintf(`{"error":"Weather API error: %s"}`, string(body)), resp.StatusCode)
+// getAir handles GET /api/weather/air
+func (w *WeatherWidget) getAir(rw http.ResponseWriter, r *http.Request) {
+	sources, err := configuredAirSources()
+	if err != nil {
+		writeWeatherError(rw, err)
 		return
 	}
-
-	var owData OpenWeatherResponse
-	if err := json.Unmarshal(body, &owData); err != nil {
-		http.Error(rw, `{"error":"Failed to parse weather data"}`, http.StatusInternalServerError)
+	lat, lon, located := shared.GetCoordinates()
+	// A PurpleAir sensor is told apart by its address rather than the location
+	key := cacheKey(sources, lat, lon) + "," + shared.GetWidgetConfigValue("weather", "purpleair_url", "")
+	cached, err := airQuality.get(r.Context(), key, func(ctx context.Context) (*AirQuality, error) {
+		return fetchAir(ctx, sources, lat, lon, located)
+	})
+	if err != nil {
+		writeWeatherError(rw, err)
 		return
 	}
 
-	// Transform to frontend format
-	condition := "Clear"
-	if len(owData.Weather) > 0 {
-		condition = owData.Weather[0].Main
+	air := *cached
+	air.Time = inLocation(air.Time, shared.GetLocation())
+	air.Scale = "us"
+	if shared.GetWidgetConfigValue("weather", "aqi_scale", "") == "eu" {
+		air.Scale = "eu"
 	}
+	shared.WriteJSON(rw, http.StatusOK, air)
+}
 
-	response := WeatherResponse{
-		Temp:      int(owData.Main.Temp),
-		FeelsLike: int(owData.Main.FeelsLike),
-		High:      int(owData.Main.TempMax),
-		Low:       int(owData.Main.TempMin),
-		Humidity:  owData.Main.Humidity,
-		WindSpeed: owData.Wind.Speed,
-		Condition: condition,
+// countParam reads an optional query parameter from 0 to limit, which is also
+// its default, writing an error response if it isn't valid
+func countParam(rw http.ResponseWriter, r *http.Request, name string, limit int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return limit, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > limit {
+		shared.WriteError(rw, http.StatusBadRequest, fmt.Sprintf("%s must be between 0 and %d", name, limit))
+		return 0, false
 	}
+	return n, true
+}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(response)
+// report returns the weather for the configured location, fetched at most every
+// reportTTL
+func (w *WeatherWidget) report(ctx context.Context, loc *time.Location) (*Report, error) {
+	lat, lon, ok := shared.GetCoordinates()
+	if !ok {
+		return nil, fmt.Errorf("%w: no location", errNotConfigured)
+	}
+	providers, err := configuredProviders()
+	if err != nil {
+		return nil, err
+	}
+	return reports.get(ctx, cacheKey(providers, lat, lon), func(ctx context.Context) (*Report, error) {
+		return fetchReport(ctx, providers, lat, lon, loc)
+	})
+}
+
+// alerts returns the alerts for the configured location, fetched at most every
+// alertsTTL
+func (w *WeatherWidget) alerts(ctx context.Context) (AlertsResponse, error) {
+	lat, lon, ok := shared.GetCoordinates()
+	if !ok {
+		return AlertsResponse{}, fmt.Errorf("%w: no location", errNotConfigured)
+	}
+	providers, err := configuredProviders()
+	if err != nil {
+		return AlertsResponse{}, err
+	}
+	sources := alertProviders(providers)
+	return alerts.get(ctx, cacheKey(sources, lat, lon), func(ctx context.Context) (AlertsResponse, error) {
+		return fetchAlerts(ctx, sources, lat, lon)
+	})
+}
+
+// writeWeatherError answers with the status that fits an error from report
+func writeWeatherError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, errNotConfigured) {
+		shared.WriteError(rw, http.StatusServiceUnavailable, "Weather API not configured")
+		return
+	}
+	shared.WriteError(rw, http.StatusInternalServerError, "Failed to fetch weather data")
 }
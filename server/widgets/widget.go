@@ -3,6 +3,8 @@ package widgets
 import (
 	"net/http"
 
+	"themancavedashboard/shared"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -65,8 +67,5 @@ func InitializeAll() error {
 
 // Helper function to write JSON responses
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	// Note: You'll need to import encoding/json and use json.NewEncoder(w).Encode(data)
-	return nil
+	return shared.WriteJSON(w, status, data)
 }
@@ -5,10 +5,12 @@ import EditModeToggle from './components/EditModeToggle';
 import WidgetSelector from './components/WidgetSelector';
 import GridBackground from './components/GridBackground';
 import DraggableWidget from './components/DraggableWidget';
+import WeatherAlertBanner from './widgets/Weather/WeatherAlertBanner';
 
 import type { DashboardLayout, WidgetInstance, GridPosition } from './types/dashboard';
 import { loadLayout, saveLayout } from './services/layoutApi';
 import { getWidgetMetadata } from './config/widgetRegistry';
+import { setLocale } from './services/units';
 
 function App() {
   const [isNightMode, setIsNightMode] = useState(false);
@@ -30,6 +32,7 @@ function App() {
         const startTime = loadedLayout.global.night_mode_start as string;
         const endTime = loadedLayout.global.night_mode_end as string;
         const refreshMinutes = loadedLayout.global.refresh_interval_minutes as number;
+        const locale = loadedLayout.global.locale as string;
         
         console.log('[App] Global config loaded:', loadedLayout.global);
         
@@ -45,6 +48,10 @@ function App() {
           console.log(`[App] Night mode end: ${endTime} -> ${parsedEnd}`);
           setNightModeEnd(parsedEnd);
         }
+        if (locale) {
+          console.log(`[App] Locale: ${locale}`);
+          setLocale(locale);
+        }
         if (refreshMinutes) {
           console.log(`[App] Refresh interval: ${refreshMinutes} minutes`);
           setRefreshIntervalMinutes(refreshMinutes);
@@ -226,56 +233,62 @@ function App() {
     );
   }
 
+  // Severe weather warnings cover the dashboard when it shows the weather
+  const hasWeather = layout.widgets.some(w => w.widgetId === 'weather');
+
   return (
-    <div className={`dashboard ${isNightMode ? 'night-mode' : ''} ${isEditMode ? 'edit-mode' : ''}`}>
-      <DateTime />
+    <>
+      <div className={`dashboard ${isNightMode ? 'night-mode' : ''} ${isEditMode ? 'edit-mode' : ''}`}>
+        <DateTime />
       
-      <EditModeToggle isEditMode={isEditMode} onToggle={handleToggleEditMode} />
+        <EditModeToggle isEditMode={isEditMode} onToggle={handleToggleEditMode} />
       
-      {isEditMode && (
-        <>
-          <WidgetSelector onSelectWidget={handleAddWidget} />
-          <GridBackground
-            columns={layout.gridColumns}
-            rows={layout.gridRows}
-            visible={true}
-          />
-        </>
-      )}
-
-      <div 
-        className="dashboard-grid-editable"
-        style={{
-          display: 'grid',
-          gridTemplateColumns: `repeat(${layout.gridColumns}, 1fr)`,
-          gridTemplateRows: `repeat(${layout.gridRows}, 1fr)`,
-          gap: 'var(--space-4)',
-          padding: 'var(--space-6)',
-          minHeight: 'calc(100vh - 200px)'
-        }}
-      >
-        {layout.widgets.map(widget => {
-          const metadata = getWidgetMetadata(widget.widgetId);
-          if (!metadata) return null;
-
-          const WidgetComponent = metadata.component;
-
-          return (
-            <DraggableWidget
-              key={widget.id}
-              widget={widget}
-              isEditMode={isEditMode}
-              gridColumns={layout.gridColumns}
-              gridRows={layout.gridRows}
-              onPositionChange={handlePositionChange}
-              onRemove={handleRemoveWidget}
-            >
-              <WidgetComponent />
-            </DraggableWidget>
-          );
-        })}
+        {isEditMode && (
+          <>
+            <WidgetSelector onSelectWidget={handleAddWidget} />
+            <GridBackground
+              columns={layout.gridColumns}
+              rows={layout.gridRows}
+              visible={true}
+            />
+          </>
+        )}
+
+        <div 
+          className="dashboard-grid-editable"
+          style={{
+            display: 'grid',
+            gridTemplateColumns: `repeat(${layout.gridColumns}, 1fr)`,
+            gridTemplateRows: `repeat(${layout.gridRows}, 1fr)`,
+            gap: 'var(--space-4)',
+            padding: 'var(--space-6)',
+            minHeight: 'calc(100vh - 200px)'
+          }}
+        >
+          {layout.widgets.map(widget => {
+            const metadata = getWidgetMetadata(widget.widgetId);
+            if (!metadata) return null;
+
+            const WidgetComponent = metadata.component;
+
+            return (
+              <DraggableWidget
+                key={widget.id}
+                widget={widget}
+                isEditMode={isEditMode}
+                gridColumns={layout.gridColumns}
+                gridRows={layout.gridRows}
+                onPositionChange={handlePositionChange}
+                onRemove={handleRemoveWidget}
+              >
+                <WidgetComponent />
+              </DraggableWidget>
+            );
+          })}
+        </div>
       </div>
-    </div>
+      <WeatherAlertBanner enabled={hasWeather && !isEditMode} />
+    </>
   );
 }
 
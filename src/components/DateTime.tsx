@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import './DateTime.css';
+import { getLocale } from '../services/units';
 
 const DateTime: React.FC = () => {
   const [currentTime, setCurrentTime] = useState(new Date());
@@ -13,15 +14,14 @@ const DateTime: React.FC = () => {
   }, []);
 
   const formatTime = (date: Date) => {
-    return date.toLocaleTimeString('en-US', {
+    return date.toLocaleTimeString(getLocale(), {
       hour: 'numeric',
-      minute: '2-digit',
-      hour12: true
+      minute: '2-digit'
     });
   };
 
   const formatDate = (date: Date) => {
-    return date.toLocaleDateString('en-US', {
+    return date.toLocaleDateString(getLocale(), {
       weekday: 'long',
       month: 'long',
       day: 'numeric',
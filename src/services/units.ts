@@ -0,0 +1,60 @@
+/**
+ * Units and locale
+ * Widget API responses carry the units their values are in; these helpers
+ * label them. The locale comes from the global config and formats dates,
+ * times and numbers.
+ */
+
+export interface Units {
+  temperature: 'F' | 'C';
+  distance: 'mi' | 'km';
+  speed: 'mph' | 'km/h' | 'm/s' | 'kn';
+  pressure: 'inHg' | 'hPa' | 'mmHg';
+  rainfall: 'in' | 'mm';
+  locale: string;
+}
+
+// The US customary units the dashboard has always shown
+export const DEFAULT_UNITS: Units = {
+  temperature: 'F',
+  distance: 'mi',
+  speed: 'mph',
+  pressure: 'inHg',
+  rainfall: 'in',
+  locale: 'en-US'
+};
+
+let currentLocale = DEFAULT_UNITS.locale;
+
+/**
+ * Set the locale from the global config
+ */
+export const setLocale = (locale?: string) => {
+  if (!locale) return;
+  try {
+    // Throws a RangeError for tags the browser can't use
+    new Intl.DateTimeFormat(locale);
+    currentLocale = locale;
+  } catch {
+    console.error(`[Units] Unsupported locale "${locale}", using ${currentLocale}`);
+  }
+};
+
+/**
+ * The dashboard locale, for toLocaleString and friends
+ */
+export const getLocale = () => currentLocale;
+
+/**
+ * How a unit is written after a value, e.g. °F
+ */
+export const unitSymbol = (unit: string) =>
+  unit === 'F' || unit === 'C' ? `°${unit}` : unit;
+
+/**
+ * Format a value with its unit in the dashboard locale, e.g. "72°F" or "1,013 hPa"
+ */
+export const formatValue = (value: number, unit: string, maximumFractionDigits = 0) => {
+  const number = value.toLocaleString(currentLocale, { maximumFractionDigits });
+  return unit === 'F' || unit === 'C' ? `${number}${unitSymbol(unit)}` : `${number} ${unit}`;
+};
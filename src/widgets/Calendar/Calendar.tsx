@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import './Calendar.css';
+import { getLocale } from '../../services/units';
 import { fetchGoogleCalendarEvents, isCalendarConnected, type ProcessedEvent } from './googleCalendarApi';
 import ConfigurableWidget from '../../components/ConfigurableWidget';
 import { getWidgetMetadata, widgetMetadataToLegacyConfig } from '../../config/widgetRegistryHelper';
@@ -63,7 +64,7 @@ const CalendarWidget: React.FC = () => {
     loadEvents();
   }, []);
 
-  const currentMonth = currentDate.toLocaleString('default', { month: 'long' });
+  const currentMonth = currentDate.toLocaleString(getLocale(), { month: 'long' });
   const currentYear = currentDate.getFullYear();
   
   // Get first day of month and total days
@@ -88,6 +89,27 @@ const CalendarWidget: React.FC = () => {
   const eventPositions: { [key: string]: { day: number; position: 'start' | 'middle' | 'end' | 'single' } } = {};
   
   events.forEach(event => {
+    // The server lists the days an event covers in the dashboard timezone
+    if (event.days && event.days.length > 0) {
+      event.days.forEach((date, index) => {
+        const [year, month, dayOfMonth] = date.split('-').map(Number);
+        if (month - 1 !== currentDate.getMonth() || year !== currentDate.getFullYear()) {
+          return;
+        }
+        if (!eventsByDay[dayOfMonth]) {
+          eventsByDay[dayOfMonth] = [];
+        }
+        eventsByDay[dayOfMonth].push(event);
+
+        let position: 'start' | 'middle' | 'end' | 'single' = 'single';
+        if (event.days!.length > 1) {
+          position = index === 0 ? 'start' : index === event.days!.length - 1 ? 'end' : 'middle';
+        }
+        eventPositions[`${event.id}-${dayOfMonth}`] = { day: dayOfMonth, position };
+      });
+      return;
+    }
+
     const eventStartDate = new Date(event.start);
     const eventEndDate = new Date(event.end);
     
@@ -143,10 +165,9 @@ const CalendarWidget: React.FC = () => {
       return ''; // All-day event, no time displayed
     }
     
-    return date.toLocaleTimeString('en-US', { 
+    return date.toLocaleTimeString(getLocale(), { 
       hour: 'numeric', 
-      minute: '2-digit',
-      hour12: true 
+      minute: '2-digit'
     });
   };
 
@@ -292,7 +313,12 @@ const CalendarWidget: React.FC = () => {
                     {eventsByDay[day] && eventsByDay[day].length > 0 && (
                       <div className="event-text">
                         {eventsByDay[day].map((event) => (
-                          <div key={event.id} className="event-item">
+                          <div
+                            key={event.id}
+                            className="event-item"
+                            title={event.calendar}
+                            style={event.color ? { borderLeft: `3px solid ${event.color}`, paddingLeft: '0.2rem' } : undefined}
+                          >
                             <div className="event-name">{event.title}</div>
                             {(position === 'start' || position === 'single') && (
                               <div className="event-time">{formatEventTime(event.start)}</div>
@@ -6,6 +6,9 @@ export type ProcessedEvent = {
   start: string;
   end: string;
   allDay?: boolean;
+  color?: string;
+  calendar?: string;
+  days?: string[];
 };
 
 /**
@@ -44,6 +47,9 @@ export async function fetchGoogleCalendarEvents(): Promise<ProcessedEvent[]> {
       start: event.start,
       end: event.end,
       allDay: event.allDay || false,
+      color: event.color || undefined,
+      calendar: event.calendar || undefined,
+      days: event.days || undefined,
     }));
   } catch (error) {
     console.error('Error fetching calendar events:', error);
@@ -4,7 +4,7 @@ import Calendar from './Calendar.tsx';
 export const widgetConfig: WidgetMetadata = {
   id: 'calendar',
   name: 'Calendar',
-  description: 'Google Calendar with events and reminders',
+  description: 'Google, CalDAV and ICS calendars with events and reminders',
   icon: '📅',
   defaultSize: {
     width: 4,
@@ -22,6 +22,11 @@ export const widgetConfig: WidgetMetadata = {
       label: 'Reminders',
       description: 'Array of reminder objects with name and date'
     },
+    {
+      key: 'calendars',
+      label: 'Calendars (Optional)',
+      description: 'Array of calendars with type (google, caldav or ics), id or url, name and color (default: primary Google calendar)'
+    },
     {
       key: 'google_credentials_filename',
       label: 'Google Credentials Filename (Optional)',
@@ -35,7 +40,7 @@ export const widgetConfig: WidgetMetadata = {
   ],
   requiredEnv: [],
   configMessage: 'Google Calendar Not Connected',
-  configHint: 'Add credentials.json and token.json to your CONFIG_DIR, and configure trash_day and reminders. Customize filenames in widget config with "google_credentials_filename" and "google_token_filename".'
+  configHint: 'Add credentials.json to your CONFIG_DIR, then open /api/google/auth/start to connect your Google account. Configure trash_day and reminders, and list extra calendars from /api/google/calendars in "calendars". Customize filenames in widget config with "google_credentials_filename" and "google_token_filename".'
 };
 
 // Auto-register widget
@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import './MealCalendar.css';
+import { getLocale } from '../../services/units';
 import { fetchMealCalendarEvents } from '../Calendar/googleCalendarApi';
 import ConfigurableWidget from '../../components/ConfigurableWidget';
 import { getWidgetMetadata, widgetMetadataToLegacyConfig } from '../../config/widgetRegistryHelper';
@@ -12,21 +13,28 @@ interface Meal {
 const MealCalendar: React.FC = () => {
   const [meals, setMeals] = useState<Meal[]>([]);
   const [loading, setLoading] = useState(true);
-  const [calendarUrl, setCalendarUrl] = useState<string | undefined>();
+  // Meals come from calendar_url, a meal planner set up under provider or the built-in plan
+  const [configured, setConfigured] = useState(false);
 
   // Get widget configuration
   const metadata = getWidgetMetadata('meals');
   const config = metadata ? widgetMetadataToLegacyConfig(metadata) : null;
 
-  // Load calendar URL from layout
+  // Load meal source from layout
   useEffect(() => {
     const loadConfig = async () => {
       try {
         const { loadLayout } = await import('../../services/layoutApi');
         const layout = await loadLayout();
         const mealsWidget = layout.widgets.find(w => w.widgetId === 'meals');
-        if (mealsWidget?.config?.calendar_url) {
-          setCalendarUrl(mealsWidget.config.calendar_url);
+        if (mealsWidget?.config?.calendar_url || mealsWidget?.config?.provider) {
+          setConfigured(true);
+          return;
+        }
+        const response = await fetch('/api/meals/plan');
+        if (response.ok) {
+          const plan = await response.json();
+          setConfigured(plan.length > 0);
         }
       } catch (error) {
         console.error('Error loading meal calendar config:', error);
@@ -37,7 +45,7 @@ const MealCalendar: React.FC = () => {
 
   // Check if meal calendar is configured
   const checkMealsConfig = async (): Promise<boolean> => {
-    if (!calendarUrl) {
+    if (!configured) {
       return false;
     }
     try {
@@ -51,7 +59,7 @@ const MealCalendar: React.FC = () => {
 
   // Load meals
   const loadMeals = async () => {
-    if (!calendarUrl) {
+    if (!configured) {
       setLoading(false);
       return;
     }
@@ -80,9 +88,9 @@ const MealCalendar: React.FC = () => {
         } else if (diffDays === 1) {
           dayLabel = 'Tomorrow';
         } else if (diffDays < 7) {
-          dayLabel = mealDate.toLocaleDateString('en-US', { weekday: 'long' });
+          dayLabel = mealDate.toLocaleDateString(getLocale(), { weekday: 'long' });
         } else {
-          dayLabel = mealDate.toLocaleDateString('en-US', { month: 'short', day: 'numeric' });
+          dayLabel = mealDate.toLocaleDateString(getLocale(), { month: 'short', day: 'numeric' });
         }
         
         return {
@@ -100,12 +108,12 @@ const MealCalendar: React.FC = () => {
     }
   };
 
-  // Load meals when component mounts and calendar URL is available
+  // Load meals when component mounts and the meal source is configured
   useEffect(() => {
-    if (calendarUrl) {
+    if (configured) {
       loadMeals();
     }
-  }, [calendarUrl]);
+  }, [configured]);
 
   if (!config) {
     return <div>Widget configuration not found</div>;
@@ -20,7 +20,7 @@ export const widgetConfig: WidgetMetadata = {
   ],
   requiredEnv: [],
   configMessage: 'Meal Calendar Not Connected',
-  configHint: 'Add calendar_url to your widget configuration'
+  configHint: 'Add calendar_url or a meal planner provider to your widget configuration, or plan meals through /api/meals/plan'
 };
 
 // Auto-register widget
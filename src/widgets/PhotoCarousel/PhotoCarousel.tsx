@@ -17,6 +17,7 @@ const PhotoCarousel: React.FC = () => {
   const [erroredPhotos, setErroredPhotos] = useState<Set<string>>(new Set());
   const configLoadedRef = useRef(false);
   const lastErrorTimeRef = useRef<number>(0);
+  const contentRef = useRef<HTMLDivElement>(null);
 
   // Get widget configuration
   const metadata = getWidgetMetadata('photos');
@@ -37,39 +38,33 @@ const PhotoCarousel: React.FC = () => {
     }
   };
 
-  // Shuffle array using Fisher-Yates algorithm
-  const shuffleArray = <T,>(array: T[]): T[] => {
-    const shuffled = [...array];
-    for (let i = shuffled.length - 1; i > 0; i--) {
-      const j = Math.floor(Math.random() * (i + 1));
-      [shuffled[i], shuffled[j]] = [shuffled[j], shuffled[i]];
-    }
-    return shuffled;
-  };
-
   // Load photos from backend API
   const loadPhotos = async () => {
     setLoading(true);
     try {
-      // Fetch the photo list from the backend API
-      const response = await fetch('/api/photos/list');
+      // The backend orders and filters the list (see the widget's order, album and
+      // orientation settings); the tile's shape is sent for orientation "match"
+      const params = new URLSearchParams();
+      const rect = contentRef.current?.getBoundingClientRect();
+      if (rect && rect.width > 0 && rect.height > 0) {
+        params.set('aspect', (rect.width / rect.height).toFixed(3));
+      }
+      const response = await fetch(`/api/photos/list?${params}`);
       if (!response.ok) {
         throw new Error('Failed to load photos');
       }
       
       const filenames: string[] = await response.json();
-      // Add cache-busting timestamp to force fresh image loads
-      const cacheBuster = Date.now();
+      // Ask the backend for a copy sized to the screen instead of the full-resolution original.
+      // Changed files get a new ETag, so no cache-busting is needed.
+      const size = Math.round(Math.max(window.screen.width, window.screen.height) * (window.devicePixelRatio || 1));
       const photoList: Photo[] = filenames.map((filename) => ({
-        url: `/photos/${filename}?v=${cacheBuster}`,
+        url: `/api/photos/${encodeURIComponent(filename)}?w=${size}&h=${size}`,
         filename,
       }));
 
-      // Shuffle the photos randomly
-      const shuffledPhotos = shuffleArray(photoList);
-
-      console.log('[PhotoCarousel] Loaded and shuffled photos:', shuffledPhotos);
-      setPhotos(shuffledPhotos);
+      console.log('[PhotoCarousel] Loaded photos:', photoList);
+      setPhotos(photoList);
     } catch (error) {
       console.error('[PhotoCarousel] Error loading photos:', error);
       setPhotos([]);
@@ -145,7 +140,7 @@ const PhotoCarousel: React.FC = () => {
           <span className="card-icon">📸</span>
           <h2 className="card-title">Photos</h2>
         </div>
-        <div className="card-content">
+        <div className="card-content" ref={contentRef}>
           <div className="photo-loading">
             <div className="loading-spinner"></div>
             <span>Loading photos...</span>
@@ -174,7 +169,7 @@ const PhotoCarousel: React.FC = () => {
             <div className="prompt-icon">📁</div>
             <h3>No Photos Found</h3>
             <p>Add photos to your photos folder to see them here</p>
-            <p className="hint">Supported formats: JPG, PNG, GIF, WebP</p>
+            <p className="hint">Supported formats: JPG, PNG, GIF, WebP, HEIC, RAW</p>
           </div>
         </div>
       </ConfigurableWidget>
@@ -185,6 +180,27 @@ const PhotoCarousel: React.FC = () => {
     return <div>Widget configuration not found</div>;
   }
 
+  // Hide the current photo from the wall; it stays in the library and can be
+  // un-hidden with PATCH /api/photos/{name}
+  const handleHidePhoto = async () => {
+    const photo = photos[currentIndex];
+    try {
+      const response = await fetch(`/api/photos/${encodeURIComponent(photo.filename)}`, {
+        method: 'PATCH',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ hidden: true }),
+      });
+      if (!response.ok) {
+        throw new Error('Failed to hide photo');
+      }
+      console.log('[PhotoCarousel] Hid photo:', photo.filename);
+      setPhotos(prev => prev.filter(p => p.filename !== photo.filename));
+      setCurrentIndex(prev => (photos.length > 1 ? prev % (photos.length - 1) : 0));
+    } catch (error) {
+      console.error('[PhotoCarousel] Error hiding photo:', error);
+    }
+  };
+
   const handleImageError = () => {
     const currentPhoto = photos[currentIndex];
     console.error('[PhotoCarousel] Error loading image:', currentPhoto.url);
@@ -236,6 +252,9 @@ const PhotoCarousel: React.FC = () => {
         />
         
         <div className="photo-controls">
+          <button className="photo-hide-button" onClick={handleHidePhoto} title="Don't show this photo again">
+            Hide
+          </button>
           <div className="photo-indicator">
             {currentIndex + 1} / {photos.length}
             {erroredPhotos.size > 0 && (
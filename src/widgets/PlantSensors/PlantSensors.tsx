@@ -7,11 +7,13 @@ import {
   type IndoorSensor
 } from './ecowittApi';
 import ConfigurableWidget from '../../components/ConfigurableWidget';
+import { DEFAULT_UNITS, formatValue } from '../../services/units';
 import { getWidgetMetadata, widgetMetadataToLegacyConfig } from '../../config/widgetRegistryHelper';
 
 const PlantSensors: React.FC = () => {
   const [sensors, setSensors] = useState<SoilMoistureSensor[]>([]);
   const [indoor, setIndoor] = useState<IndoorSensor | null>(null);
+  const [units, setUnits] = useState(DEFAULT_UNITS);
   const [loading, setLoading] = useState(true);
 
   // Get widget configuration
@@ -35,6 +37,7 @@ const PlantSensors: React.FC = () => {
     try {
       const data = await fetchEcowittData();
       setSensors(data.sensors);
+      setUnits(data.units);
       if (data.indoor) {
         setIndoor(data.indoor);
       }
@@ -101,7 +104,7 @@ const PlantSensors: React.FC = () => {
                 <div className="indoor-stats">
                   <div className="indoor-stat">
                     <div className="indoor-stat-label">TEMP</div>
-                    <div className="indoor-value">{indoor.temperature}°F</div>
+                    <div className="indoor-value">{formatValue(indoor.temperature, units.temperature)}</div>
                   </div>
                   <div className="indoor-stat">
                     <div className="indoor-stat-label">HUMIDITY</div>
@@ -109,7 +112,7 @@ const PlantSensors: React.FC = () => {
                   </div>
                   <div className="indoor-stat">
                     <div className="indoor-stat-label">PRESSURE</div>
-                    <div className="indoor-value">{indoor.pressure} hPa</div>
+                    <div className="indoor-value">{formatValue(indoor.pressure, units.pressure, units.pressure === 'inHg' ? 2 : 0)}</div>
                   </div>
                 </div>
               </div>
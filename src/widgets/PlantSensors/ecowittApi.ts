@@ -1,4 +1,5 @@
 // Ecowitt API integration - calls backend
+import { DEFAULT_UNITS, type Units } from '../../services/units';
 
 export interface SoilMoistureSensor {
   channel: string;
@@ -11,14 +12,15 @@ export interface SoilMoistureSensor {
 }
 
 export interface IndoorSensor {
-  temperature: number; // Fahrenheit
+  temperature: number; // in units.temperature
   humidity: number; // percentage
-  pressure: number; // inHg
+  pressure: number; // in units.pressure
 }
 
 export interface EcowittDeviceData {
   sensors: SoilMoistureSensor[];
   indoor?: IndoorSensor;
+  units: Units;
   lastUpdate?: string;
 }
 
@@ -38,6 +40,7 @@ export async function fetchEcowittData(): Promise<EcowittDeviceData> {
     return {
       sensors: data.sensors || [],
       indoor: data.indoor,
+      units: data.units || DEFAULT_UNITS,
       lastUpdate: new Date().toISOString(),
     };
   } catch (error) {
@@ -49,6 +52,7 @@ export async function fetchEcowittData(): Promise<EcowittDeviceData> {
 function getPlaceholderData(): EcowittDeviceData {
   return {
     sensors: [],
+    units: DEFAULT_UNITS,
     lastUpdate: new Date().toISOString(),
   };
 }
@@ -12,25 +12,8 @@ export const widgetConfig: WidgetMetadata = {
   },
   component: PlantSensors,
   requiredConfig: [],
-  requiredEnv: [
-    {
-      key: 'ECOWITT_API_KEY',
-      label: 'Ecowitt API Key',
-      description: 'Your Ecowitt API key'
-    },
-    {
-      key: 'ECOWITT_APPLICATION_KEY',
-      label: 'Ecowitt Application Key',
-      description: 'Your Ecowitt application key'
-    },
-    {
-      key: 'ECOWITT_GATEWAY_MAC',
-      label: 'Gateway MAC Address',
-      description: 'Your Ecowitt gateway MAC address'
-    }
-  ],
   configMessage: 'Plant Sensors Not Connected',
-  configHint: 'Add ECOWITT_API_KEY, ECOWITT_APPLICATION_KEY, and ECOWITT_GATEWAY_MAC to your .env configuration'
+  configHint: 'Add gateway_ip to widget config to read the gateway on your network, or ECOWITT_API_KEY, ECOWITT_APPLICATION_KEY and ECOWITT_GATEWAY_MAC to .env for the Ecowitt cloud'
 };
 
 // Auto-register widget
@@ -1,11 +1,13 @@
 import React, { useState, useEffect } from 'react';
 import './Weather.css';
-import { fetchWeather, type WeatherData } from './weatherApi';
+import { fetchWeather, fetchAirQuality, type WeatherData, type AirQuality, type Pollen } from './weatherApi';
+import { unitSymbol } from '../../services/units';
 import ConfigurableWidget from '../../components/ConfigurableWidget';
 import { getWidgetMetadata, widgetMetadataToLegacyConfig } from '../../config/widgetRegistryHelper';
 
 const Weather: React.FC = () => {
   const [weather, setWeather] = useState<WeatherData | null>(null);
+  const [air, setAir] = useState<AirQuality | null>(null);
   const [loading, setLoading] = useState(true);
 
   // Get widget configuration from registry
@@ -29,6 +31,8 @@ const Weather: React.FC = () => {
     try {
       const data = await fetchWeather();
       setWeather(data);
+      // Air quality is extra; the tile still shows the weather without it
+      fetchAirQuality().then(setAir).catch((error) => console.error('Error loading air quality:', error));
     } catch (error) {
       console.error('Error loading weather data:', error);
     } finally {
@@ -62,6 +66,13 @@ const Weather: React.FC = () => {
     return conditions[condition] || '🌤️';
   };
 
+  const aqi = air ? air.aqi[air.scale] : undefined;
+  const pollenOrder = ['none', 'low', 'moderate', 'high', 'very high'];
+  const worstPollen = air?.pollen.reduce<Pollen | null>(
+    (worst, pollen) => (!worst || pollenOrder.indexOf(pollen.level) > pollenOrder.indexOf(worst.level) ? pollen : worst),
+    null,
+  );
+
   if (!config) {
     return <div>Widget configuration not found</div>;
   }
@@ -91,11 +102,11 @@ const Weather: React.FC = () => {
               </div>
               <div className="weather-temp">
                 <span className="temp-value">{Math.round(weather.temp)}</span>
-                <span className="temp-unit">°F</span>
+                <span className="temp-unit">{unitSymbol(weather.units.temperature)}</span>
               </div>
               <div className="weather-details">
                 <div className="weather-condition">{weather.condition}</div>
-                <div className="weather-feels">Feels like {Math.round(weather.feelsLike)}°F</div>
+                <div className="weather-feels">Feels like {Math.round(weather.feelsLike)}{unitSymbol(weather.units.temperature)}</div>
               </div>
             </div>
 
@@ -106,12 +117,26 @@ const Weather: React.FC = () => {
               </div>
               <div className="weather-stat">
                 <span className="stat-label">Wind</span>
-                <span className="stat-value">{weather.windSpeed} mph</span>
+                <span className="stat-value">{weather.windSpeed} {weather.units.speed}</span>
               </div>
               <div className="weather-stat">
                 <span className="stat-label">High/Low</span>
                 <span className="stat-value">{weather.high}°/{weather.low}°</span>
               </div>
+              {aqi && (
+                <div className="weather-stat">
+                  <span className="stat-label">Air Quality</span>
+                  <span className={`stat-value aqi-level-${aqi.level}`}>
+                    {air?.scale === 'us' ? `AQI ${aqi.value} · ` : ''}{aqi.category}
+                  </span>
+                </div>
+              )}
+              {worstPollen && worstPollen.level !== 'none' && (
+                <div className="weather-stat">
+                  <span className="stat-label">Pollen</span>
+                  <span className="stat-value">{worstPollen.name} {worstPollen.level}</span>
+                </div>
+              )}
             </div>
           </>
         ) : (
@@ -0,0 +1,69 @@
+import React from 'react';
+import './WeatherAlertBanner.css';
+import { useWeatherAlerts } from './useWeatherAlerts';
+import { getLocale } from '../../services/units';
+
+interface WeatherAlertBannerProps {
+  enabled?: boolean;
+}
+
+const formatTime = (iso?: string) => {
+  if (!iso) return null;
+  return new Date(iso).toLocaleString(getLocale(), {
+    weekday: 'short',
+    hour: 'numeric',
+    minute: '2-digit'
+  });
+};
+
+/**
+ * Full-screen banner for severe weather warnings. Shows the most severe
+ * warning that hasn't been dismissed; watches and advisories don't raise it.
+ */
+const WeatherAlertBanner: React.FC<WeatherAlertBannerProps> = ({ enabled = true }) => {
+  const { warnings, dismiss } = useWeatherAlerts(enabled);
+
+  if (!enabled || warnings.length === 0) {
+    return null;
+  }
+
+  const alert = warnings[0];
+  const onset = formatTime(alert.onset);
+  const expires = formatTime(alert.expires);
+
+  return (
+    <div className={`weather-alert-overlay severity-${alert.severity}`} role="alertdialog" aria-labelledby="weather-alert-event">
+      <div className="weather-alert">
+        <div className="weather-alert-header">
+          <span className="weather-alert-icon">⚠️</span>
+          <h2 id="weather-alert-event" className="weather-alert-event">{alert.event}</h2>
+        </div>
+        <div className="weather-alert-headline">{alert.headline}</div>
+        {(onset || expires) && (
+          <div className="weather-alert-times">
+            {onset && <span>From {onset}</span>}
+            {expires && <span>Until {expires}</span>}
+          </div>
+        )}
+        <div className="weather-alert-body">
+          <p className="weather-alert-description">{alert.description}</p>
+          {alert.instruction && (
+            <p className="weather-alert-instruction">{alert.instruction}</p>
+          )}
+        </div>
+        <div className="weather-alert-footer">
+          {warnings.length > 1 && (
+            <span className="weather-alert-more">
+              {warnings.length - 1} more warning{warnings.length > 2 ? 's' : ''}
+            </span>
+          )}
+          <button className="weather-alert-dismiss" onClick={() => dismiss(alert.id)}>
+            Dismiss
+          </button>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default WeatherAlertBanner;
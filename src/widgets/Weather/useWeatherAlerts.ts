@@ -0,0 +1,61 @@
+import { useCallback, useEffect, useState } from 'react';
+import { fetchAlerts, type WeatherAlert } from './weatherApi';
+
+// Alerts are fetched at most every 5 minutes by the backend
+const POLL_INTERVAL_MS = 2 * 60 * 1000;
+
+// Dismissed alerts are remembered across the dashboard's periodic reloads
+const DISMISSED_KEY = 'weather-alerts-dismissed';
+
+const loadDismissed = (): string[] => {
+  try {
+    return JSON.parse(localStorage.getItem(DISMISSED_KEY) || '[]');
+  } catch {
+    return [];
+  }
+};
+
+/**
+ * Polls /api/weather/alerts. Returns the active alerts, the warnings that
+ * haven't been dismissed yet, and a function to dismiss one.
+ */
+export const useWeatherAlerts = (enabled = true) => {
+  const [alerts, setAlerts] = useState<WeatherAlert[]>([]);
+  const [dismissed, setDismissed] = useState<string[]>(loadDismissed);
+
+  useEffect(() => {
+    if (!enabled) return;
+
+    const load = async () => {
+      try {
+        const data = await fetchAlerts();
+        setAlerts(data.alerts);
+        // Forget dismissals of alerts that have ended
+        setDismissed(current => {
+          const active = current.filter(id => data.alerts.some(alert => alert.id === id));
+          localStorage.setItem(DISMISSED_KEY, JSON.stringify(active));
+          return active;
+        });
+      } catch (error) {
+        // Weather isn't configured, or the provider is down: keep the last alerts
+        console.error('Error loading weather alerts:', error);
+      }
+    };
+
+    load();
+    const interval = setInterval(load, POLL_INTERVAL_MS);
+    return () => clearInterval(interval);
+  }, [enabled]);
+
+  const dismiss = useCallback((id: string) => {
+    setDismissed(current => {
+      const next = [...current, id];
+      localStorage.setItem(DISMISSED_KEY, JSON.stringify(next));
+      return next;
+    });
+  }, []);
+
+  const warnings = alerts.filter(alert => alert.warning && !dismissed.includes(alert.id));
+
+  return { alerts, warnings, dismiss };
+};
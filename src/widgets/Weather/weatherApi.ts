@@ -1,4 +1,6 @@
 // Weather API service - calls backend
+import { DEFAULT_UNITS, type Units } from '../../services/units';
+
 export interface WeatherData {
   temp: number;
   feelsLike: number;
@@ -8,6 +10,8 @@ export interface WeatherData {
   windSpeed: number;
   high: number;
   low: number;
+  provider?: string;
+  units: Units;
 }
 
 export const fetchWeather = async (): Promise<WeatherData> => {
@@ -27,6 +31,119 @@ export const fetchWeather = async (): Promise<WeatherData> => {
     windSpeed: data.windSpeed,
     high: data.high,
     low: data.low,
+    icon: data.icon,
+    provider: data.provider,
+    units: data.units || DEFAULT_UNITS,
   };
 };
 
+export interface HourlyForecast {
+  time: string;
+  temp: number;
+  feelsLike: number;
+  humidity: number;
+  windSpeed: number;
+  windGust?: number;
+  precipChance: number;
+  precipAmount: number;
+  uvIndex?: number;
+  condition: string;
+  description: string;
+  icon: string;
+}
+
+export interface DailyForecast {
+  date: string;
+  high: number;
+  low: number;
+  humidity: number;
+  windSpeed: number;
+  windGust?: number;
+  precipChance: number;
+  precipAmount: number;
+  uvIndex?: number;
+  sunrise?: string;
+  sunset?: string;
+  condition: string;
+  description: string;
+  icon: string;
+}
+
+export interface Forecast {
+  provider: string;
+  units: Units;
+  hourly: HourlyForecast[];
+  daily: DailyForecast[];
+}
+
+export const fetchForecast = async (hours = 48, days = 10): Promise<Forecast> => {
+  const response = await fetch(`/api/weather/forecast?hours=${hours}&days=${days}`);
+
+  if (!response.ok) {
+    throw new Error(`Backend API error: ${response.statusText}`);
+  }
+
+  return response.json();
+};
+
+
+export interface WeatherAlert {
+  id: string;
+  event: string;
+  severity: 'extreme' | 'severe' | 'moderate' | 'minor' | 'unknown';
+  warning: boolean;
+  headline: string;
+  description: string;
+  instruction?: string;
+  sender?: string;
+  onset?: string;
+  expires?: string;
+}
+
+export interface WeatherAlerts {
+  provider: string;
+  alerts: WeatherAlert[];
+}
+
+export const fetchAlerts = async (): Promise<WeatherAlerts> => {
+  const response = await fetch('/api/weather/alerts');
+
+  if (!response.ok) {
+    throw new Error(`Backend API error: ${response.statusText}`);
+  }
+
+  return response.json();
+};
+
+export interface AirQualityIndex {
+  value: number;
+  level: number; // 1 (good) to 6
+  category: string;
+  pollutant: 'pm2_5' | 'pm10' | 'o3';
+}
+
+export interface Pollen {
+  type: string;
+  name: string;
+  count: number;
+  level: 'none' | 'low' | 'moderate' | 'high' | 'very high';
+}
+
+export interface AirQuality {
+  provider: string;
+  time?: string;
+  scale: 'us' | 'eu';
+  pollutants: { pm2_5?: number; pm10?: number; o3?: number };
+  aqi: { us?: AirQualityIndex; eu?: AirQualityIndex };
+  pollen: Pollen[];
+}
+
+export const fetchAirQuality = async (): Promise<AirQuality> => {
+  const response = await fetch('/api/weather/air');
+
+  if (!response.ok) {
+    throw new Error(`Backend API error: ${response.statusText}`);
+  }
+
+  return response.json();
+};
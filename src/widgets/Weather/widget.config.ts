@@ -28,15 +28,8 @@ export const widgetConfig: WidgetMetadata = {
       description: 'Display name for your location (e.g., "Denver, CO")'
     }
   ],
-  requiredEnv: [
-    {
-      key: 'OPENWEATHER_API_KEY',
-      label: 'OpenWeather API Key',
-      description: 'Get your free API key from https://openweathermap.org/api'
-    }
-  ],
   configMessage: 'Weather Not Configured',
-  configHint: 'Add latitude and longitude to widget config, and OPENWEATHER_API_KEY to .env'
+  configHint: 'Add latitude and longitude to widget config (weather comes from Open-Meteo, or OpenWeatherMap when OPENWEATHER_API_KEY is in .env)'
 };
 
 // Auto-register widget